@@ -109,6 +109,7 @@ func (state ClientStateStart) Next(hr handshakeMessageReader) (HandshakeState, [
 		logf(logTypeHandshake, "[ClientStateStart] Error creating ClientHello random [%v]", err)
 		return nil, nil, AlertInternalError
 	}
+	state.Params.ClientRandom = ch.Random
 	for _, ext := range []ExtensionBody{&sv, &sni, &ks, &sg, &sa} {
 		err := ch.Extensions.Add(ext)
 		if err != nil {
@@ -149,6 +150,7 @@ func (state ClientStateStart) Next(hr handshakeMessageReader) (HandshakeState, [
 	var offeredPSK PreSharedKey
 	var earlyHash crypto.Hash
 	var earlySecret []byte
+	var earlyTrafficSecret []byte
 	var clientEarlyTrafficKeys keySet
 	var clientHello *HandshakeMessage
 	if key, ok := state.Caps.PSKs.Get(state.Opts.ServerName); ok {
@@ -249,7 +251,7 @@ func (state ClientStateStart) Next(hr handshakeMessageReader) (HandshakeState, [
 		h.Write(clientHello.Marshal())
 		chHash := h.Sum(nil)
 
-		earlyTrafficSecret := deriveSecret(params, earlySecret, labelEarlyTrafficSecret, chHash)
+		earlyTrafficSecret = deriveSecret(params, earlySecret, labelEarlyTrafficSecret, chHash)
 		logf(logTypeCrypto, "early traffic secret: [%d] %x", len(earlyTrafficSecret), earlyTrafficSecret)
 		clientEarlyTrafficKeys = makeTrafficKeys(params, earlyTrafficSecret)
 	} else if len(state.Opts.EarlyData) > 0 {
@@ -284,6 +286,7 @@ func (state ClientStateStart) Next(hr handshakeMessageReader) (HandshakeState, [
 	}
 	if state.Params.ClientSendingEarlyData {
 		toSend = append(toSend, []HandshakeAction{
+			LogSecret{Label: keyLogLabelClientEarlyTrafficSecret, ClientRandom: state.Params.ClientRandom, Secret: earlyTrafficSecret},
 			RekeyOut{Label: "early", KeySet: clientEarlyTrafficKeys},
 			SendEarlyData{},
 		}...)
@@ -503,6 +506,7 @@ func (state ClientStateWaitSH) Next(hr handshakeMessageReader) (HandshakeState,
 		logf(logTypeHandshake, "[ClientStateWaitSH] -> [ClientStateWaitEE]")
 		nextState := ClientStateWaitEE{
 			Caps:                         state.Caps,
+			AuthCertificate:              state.Caps.AuthCertificate,
 			Params:                       state.Params,
 			cryptoParams:                 params,
 			handshakeHash:                handshakeHash,
@@ -512,6 +516,8 @@ func (state ClientStateWaitSH) Next(hr handshakeMessageReader) (HandshakeState,
 			serverHandshakeTrafficSecret: serverHandshakeTrafficSecret,
 		}
 		toSend := []HandshakeAction{
+			LogSecret{Label: keyLogLabelClientHandshakeTrafficSecret, ClientRandom: state.Params.ClientRandom, Secret: clientHandshakeTrafficSecret},
+			LogSecret{Label: keyLogLabelServerHandshakeTrafficSecret, ClientRandom: state.Params.ClientRandom, Secret: serverHandshakeTrafficSecret},
 			RekeyIn{Label: "handshake", KeySet: serverHandshakeKeys},
 		}
 		return nextState, toSend, AlertNoAlert
@@ -876,7 +882,11 @@ func (state ClientStateWaitFinished) Next(hr handshakeMessageReader) (HandshakeS
 	logf(logTypeCrypto, "client exporter secret: [%d] %x", len(exporterSecret), exporterSecret)
 
 	// Assemble client's second flight
-	toSend := []HandshakeAction{}
+	toSend := []HandshakeAction{
+		LogSecret{Label: keyLogLabelClientTrafficSecret, ClientRandom: state.Params.ClientRandom, Secret: clientTrafficSecret},
+		LogSecret{Label: keyLogLabelServerTrafficSecret, ClientRandom: state.Params.ClientRandom, Secret: serverTrafficSecret},
+		LogSecret{Label: keyLogLabelExporterSecret, ClientRandom: state.Params.ClientRandom, Secret: exporterSecret},
+	}
 
 	if state.Params.UsingEarlyData {
 		// Note: We only send EOED if the server is actually going to use the early
@@ -90,6 +90,9 @@ type Config struct {
 	// In blocking mode, a default cookie protector is used, if this is unused.
 	CookieProtector   CookieProtector
 	RequireClientAuth bool
+	// GetCertificate returns the certificate for the server name requested by the client.
+	// If set, it is used instead of Certificates.
+	GetCertificate func(serverName string) (*Certificate, error)
 
 	// Shared fields
 	Certificates     []*Certificate
@@ -101,6 +104,11 @@ type Config struct {
 	PSKs             PreSharedKeyCache
 	PSKModes         []PSKKeyExchangeMode
 	NonBlocking      bool
+	// KeyLogWriter optionally specifies a destination for the TLS secrets
+	// in the NSS key log format, that can be used to decrypt the connection
+	// with external programs like Wireshark.
+	// Use of KeyLogWriter compromises security and should only be used for debugging.
+	KeyLogWriter io.Writer
 
 	// The same config object can be shared among different connections, so it
 	// needs its own mutex
@@ -125,6 +133,7 @@ func (c *Config) Clone() *Config {
 		CookieHandler:      c.CookieHandler,
 		CookieProtector:    c.CookieProtector,
 		RequireClientAuth:  c.RequireClientAuth,
+		GetCertificate:     c.GetCertificate,
 
 		Certificates:     c.Certificates,
 		AuthCertificate:  c.AuthCertificate,
@@ -135,6 +144,7 @@ func (c *Config) Clone() *Config {
 		PSKs:             c.PSKs,
 		PSKModes:         c.PSKModes,
 		NonBlocking:      c.NonBlocking,
+		KeyLogWriter:     c.KeyLogWriter,
 	}
 }
 
@@ -163,7 +173,7 @@ func (c *Config) Init(isClient bool) error {
 	}
 
 	// If there is no certificate, generate one
-	if !isClient && len(c.Certificates) == 0 {
+	if !isClient && len(c.Certificates) == 0 && c.GetCertificate == nil {
 		logf(logTypeHandshake, "Generating key name=%v", c.ServerName)
 		priv, err := newSigningKey(RSA_PSS_SHA256)
 		if err != nil {
@@ -188,6 +198,7 @@ func (c *Config) Init(isClient bool) error {
 
 func (c *Config) ValidForServer() bool {
 	return (reflect.ValueOf(c.PSKs).IsValid() && c.PSKs.Size() > 0) ||
+		c.GetCertificate != nil ||
 		(len(c.Certificates) > 0 &&
 			len(c.Certificates[0].Chain) > 0 &&
 			c.Certificates[0].PrivateKey != nil)
@@ -599,6 +610,16 @@ func (c *Conn) takeAction(actionGeneric HandshakeAction) Alert {
 			c.config.PSKs.Put(hex.EncodeToString(action.PSK.Identity), action.PSK)
 		}
 
+	case LogSecret:
+		if c.config.KeyLogWriter == nil {
+			break
+		}
+		_, err := fmt.Fprintf(c.config.KeyLogWriter, "%s %x %x\n", action.Label, action.ClientRandom, action.Secret)
+		if err != nil {
+			logf(logTypeHandshake, "%s Error writing the key log: %v", label, err)
+			return AlertInternalError
+		}
+
 	default:
 		logf(logTypeHandshake, "%s Unknown actionuction type", label)
 		return AlertInternalError
@@ -631,6 +652,8 @@ func (c *Conn) HandshakeSetup() Alert {
 		RequireClientAuth: c.config.RequireClientAuth,
 		NextProtos:        c.config.NextProtos,
 		Certificates:      c.config.Certificates,
+		GetCertificate:    c.config.GetCertificate,
+		AuthCertificate:   c.config.AuthCertificate,
 		ExtensionHandler:  c.extHandler,
 	}
 	opts := ConnectionOptions{
@@ -575,6 +575,16 @@ const (
 	labelResumption                     = "resumption"
 )
 
+// labels of the NSS key log format
+const (
+	keyLogLabelClientEarlyTrafficSecret     = "CLIENT_EARLY_TRAFFIC_SECRET"
+	keyLogLabelClientHandshakeTrafficSecret = "CLIENT_HANDSHAKE_TRAFFIC_SECRET"
+	keyLogLabelServerHandshakeTrafficSecret = "SERVER_HANDSHAKE_TRAFFIC_SECRET"
+	keyLogLabelClientTrafficSecret          = "CLIENT_TRAFFIC_SECRET_0"
+	keyLogLabelServerTrafficSecret          = "SERVER_TRAFFIC_SECRET_0"
+	keyLogLabelExporterSecret               = "EXPORTER_SECRET"
+)
+
 // struct HkdfLabel {
 //    uint16 length;
 //    opaque label<9..255>;
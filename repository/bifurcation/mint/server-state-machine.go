@@ -98,7 +98,7 @@ func (state ServerStateStart) Next(hr handshakeMessageReader) (HandshakeState, [
 	}
 
 	clientHello := hm
-	connParams := ConnectionParameters{}
+	connParams := ConnectionParameters{ClientRandom: ch.Random}
 
 	supportedVersions := new(SupportedVersionsExtension)
 	serverName := new(ServerNameExtension)
@@ -321,7 +321,16 @@ func (state ServerStateStart) Next(hr handshakeMessageReader) (HandshakeState, [
 		// Select a certificate
 		name := string(*serverName)
 		var err error
-		cert, certScheme, err = CertificateSelection(&name, signatureAlgorithms.Algorithms, state.Caps.Certificates)
+		if state.Caps.GetCertificate != nil {
+			var c *Certificate
+			c, err = state.Caps.GetCertificate(name)
+			if err == nil {
+				// the callback already selected the certificate for the server name
+				cert, certScheme, err = CertificateSelection(nil, signatureAlgorithms.Algorithms, []*Certificate{c})
+			}
+		} else {
+			cert, certScheme, err = CertificateSelection(&name, signatureAlgorithms.Algorithms, state.Caps.Certificates)
+		}
 		if err != nil {
 			logf(logTypeHandshake, "[ServerStateStart] No appropriate certificate found [%v]", err)
 			return nil, nil, AlertAccessDenied
@@ -555,6 +564,8 @@ func (state ServerStateNegotiated) Next(_ handshakeMessageReader) (HandshakeStat
 	handshakeHash.Write(eem.Marshal())
 
 	toSend := []HandshakeAction{
+		LogSecret{Label: keyLogLabelClientHandshakeTrafficSecret, ClientRandom: state.Params.ClientRandom, Secret: clientHandshakeTrafficSecret},
+		LogSecret{Label: keyLogLabelServerHandshakeTrafficSecret, ClientRandom: state.Params.ClientRandom, Secret: serverHandshakeTrafficSecret},
 		SendHandshakeMessage{serverHello},
 		RekeyOut{Label: "handshake", KeySet: serverHandshakeKeys},
 		SendHandshakeMessage{eem},
@@ -658,6 +669,12 @@ func (state ServerStateNegotiated) Next(_ handshakeMessageReader) (HandshakeStat
 	exporterSecret := deriveSecret(params, masterSecret, labelExporterSecret, h4)
 	logf(logTypeCrypto, "server exporter secret: [%d] %x", len(exporterSecret), exporterSecret)
 
+	toSend = append(toSend, []HandshakeAction{
+		LogSecret{Label: keyLogLabelClientTrafficSecret, ClientRandom: state.Params.ClientRandom, Secret: clientTrafficSecret},
+		LogSecret{Label: keyLogLabelServerTrafficSecret, ClientRandom: state.Params.ClientRandom, Secret: serverTrafficSecret},
+		LogSecret{Label: keyLogLabelExporterSecret, ClientRandom: state.Params.ClientRandom, Secret: exporterSecret},
+	}...)
+
 	if state.Params.UsingEarlyData {
 		clientEarlyTrafficKeys := makeTrafficKeys(params, state.clientEarlyTrafficSecret)
 
@@ -674,6 +691,7 @@ func (state ServerStateNegotiated) Next(_ handshakeMessageReader) (HandshakeStat
 			exporterSecret:               exporterSecret,
 		}
 		toSend = append(toSend, []HandshakeAction{
+			LogSecret{Label: keyLogLabelClientEarlyTrafficSecret, ClientRandom: state.Params.ClientRandom, Secret: state.clientEarlyTrafficSecret},
 			RekeyIn{Label: "early", KeySet: clientEarlyTrafficKeys},
 			ReadEarlyData{},
 		}...)
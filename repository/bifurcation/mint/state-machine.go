@@ -32,6 +32,14 @@ type StorePSK struct {
 	PSK PreSharedKey
 }
 
+// LogSecret writes a secret to the KeyLogWriter of the Config.
+// The Label is one of the labels of the NSS key log format.
+type LogSecret struct {
+	Label        string
+	ClientRandom [32]byte
+	Secret       []byte
+}
+
 type HandshakeState interface {
 	Next(handshakeMessageReader) (HandshakeState, []HandshakeAction, Alert)
 	State() State
@@ -64,6 +72,7 @@ type Capabilities struct {
 	CookieProtector   CookieProtector
 	CookieHandler     CookieHandler
 	RequireClientAuth bool
+	GetCertificate    func(serverName string) (*Certificate, error)
 }
 
 // ConnectionOptions objects represent per-connection settings for a client
@@ -83,9 +92,10 @@ type ConnectionParameters struct {
 	UsingEarlyData         bool
 	UsingClientAuth        bool
 
-	CipherSuite CipherSuite
-	ServerName  string
-	NextProto   string
+	CipherSuite  CipherSuite
+	ServerName   string
+	NextProto    string
+	ClientRandom [32]byte
 }
 
 // StateConnected is symmetric between client and server
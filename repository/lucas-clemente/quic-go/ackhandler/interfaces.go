@@ -3,6 +3,7 @@ package ackhandler
 import (
 	"time"
 
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/congestion"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/wire"
 )
@@ -11,13 +12,17 @@ import (
 type SentPacketHandler interface {
 	// SentPacket may modify the packet
 	SentPacket(packet *Packet) error
-	ReceivedAck(ackFrame *wire.AckFrame, withPacketNumber protocol.PacketNumber, recvTime time.Time) error
+	// ReceivedAck is called for an ACK frame received in the packet withPacketNumber of the path rcvPathID.
+	// ACK frames may be received on any path, so packet numbers are only compared for the same receiving path.
+	ReceivedAck(ackFrame *wire.AckFrame, rcvPathID protocol.PathID, withPacketNumber protocol.PacketNumber, recvTime time.Time) error
 
 	// Specific to multipath operation
-	ReceivedClosePath(f *wire.ClosePathFrame, withPacketNumber protocol.PacketNumber, recvTime time.Time) error
+	ReceivedClosePath(f *wire.ClosePathFrame, rcvPathID protocol.PathID, withPacketNumber protocol.PacketNumber, recvTime time.Time) error
 	SetInflightAsLost()
 
 	SendingAllowed() bool
+	SetApplicationLimited()
+	IsApplicationLimited() bool
 	GetStopWaitingFrame(force bool) *wire.StopWaitingFrame
 	ShouldSendRetransmittablePacket() bool
 	DequeuePacketForRetransmission() (packet *Packet)
@@ -27,14 +32,35 @@ type SentPacketHandler interface {
 	OnAlarm()
 
 	DuplicatePacket(packet *Packet)
+	GetPacketsForReinjection(sentBefore time.Time) []*Packet
+	DequeuePacketsWithEncryptionLevel(protocol.EncryptionLevel) []*Packet
 
-	GetStatistics() (uint64, uint64, uint64)
+	GetStatistics() SentPacketStatistics
+	GetReorderingStatistics() (uint64, protocol.PacketNumber)
+	GetSpuriousRetransmissionStatistics() (uint64, uint64)
+}
+
+// SentPacketStatistics are the statistics of the packets sent on a path
+type SentPacketStatistics struct {
+	Packets         uint64
+	Retransmissions uint64
+	Losses          uint64
+
+	Bytes              protocol.ByteCount
+	RetransmittedBytes protocol.ByteCount
+	LostBytes          protocol.ByteCount
+
+	CongestionWindow protocol.ByteCount
+	BytesInFlight    protocol.ByteCount
+	// The congestion window per smoothed RTT, 0 until the RTT was measured
+	BandwidthEstimate congestion.Bandwidth
 }
 
 // ReceivedPacketHandler handles ACKs needed to send for incoming packets
 type ReceivedPacketHandler interface {
 	ReceivedPacket(packetNumber protocol.PacketNumber, shouldInstigateAck bool) error
 	SetLowerLimit(protocol.PacketNumber)
+	SetAckFrequency(ackSendDelay time.Duration, retransmittablePacketsBeforeAck int)
 
 	GetAlarmTimeout() time.Time
 	GetAckFrame() *wire.AckFrame
@@ -16,6 +16,9 @@ type Packet struct {
 	EncryptionLevel protocol.EncryptionLevel
 
 	SendTime time.Time
+
+	// Reinjected is set once the frames of the packet were queued for retransmission on another path
+	Reinjected bool
 }
 
 // GetFramesForRetransmission gets all the frames for retransmission
@@ -18,6 +18,8 @@ type receivedPacketHandler struct {
 	packetHistory *receivedPacketHistory
 
 	ackSendDelay time.Duration //从接收报文到ack发送之间所经历的延迟
+	// the number of retransmittable packets after which an ACK is sent
+	retransmittablePacketsBeforeAck int
 
 	packetsReceivedSinceLastAck                int
 	retransmittablePacketsReceivedSinceLastAck int
@@ -36,9 +38,17 @@ func NewReceivedPacketHandler(version protocol.VersionNumber) ReceivedPacketHand
 		packetHistory: newReceivedPacketHistory(),
 		ackSendDelay:  protocol.AckSendDelay,
 		version:       version,
+
+		retransmittablePacketsBeforeAck: protocol.RetransmittablePacketsBeforeAck,
 	}
 }
 
+// SetAckFrequency sets how often ACKs are sent, as requested by the peer
+func (h *receivedPacketHandler) SetAckFrequency(ackSendDelay time.Duration, retransmittablePacketsBeforeAck int) {
+	h.ackSendDelay = ackSendDelay
+	h.retransmittablePacketsBeforeAck = retransmittablePacketsBeforeAck
+}
+
 func (h *receivedPacketHandler) GetStatistics() uint64 {// 已经发送的报文的总数
 	return h.packets
 }
@@ -108,7 +118,7 @@ func (h *receivedPacketHandler) maybeQueueAck(packetNumber protocol.PacketNumber
 	}
 
 	if !h.ackQueued && shouldInstigateAck {
-		if h.retransmittablePacketsReceivedSinceLastAck >= protocol.RetransmittablePacketsBeforeAck {
+		if h.retransmittablePacketsReceivedSinceLastAck >= h.retransmittablePacketsBeforeAck {
 			h.ackQueued = true
 		} else {
 			if h.ackAlarm.IsZero() {
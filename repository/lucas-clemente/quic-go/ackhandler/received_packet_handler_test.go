@@ -126,6 +126,20 @@ var _ = Describe("receivedPacketHandler", func() {
 				Expect(handler.GetAlarmTimeout()).To(BeZero())
 			})
 
+			It("uses the ACK frequency requested by the peer", func() {
+				handler.SetAckFrequency(50*time.Millisecond, 4)
+				receiveAndAck10Packets()
+				for i := 11; i < 14; i++ {
+					err := handler.ReceivedPacket(protocol.PacketNumber(i), true)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(handler.ackQueued).To(BeFalse())
+				}
+				Expect(handler.GetAlarmTimeout()).To(BeTemporally("~", time.Now().Add(50*time.Millisecond), 10*time.Millisecond))
+				err := handler.ReceivedPacket(14, true)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(handler.ackQueued).To(BeTrue())
+			})
+
 			It("only sets the timer when receiving a retransmittable packets", func() {
 				receiveAndAck10Packets()
 				err := handler.ReceivedPacket(11, false)
@@ -3,12 +3,14 @@ package ackhandler
 import (
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/congestion"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/utils"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/wire"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/logging"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/qerr"
 )
 
@@ -16,6 +18,10 @@ const (
 	// Maximum reordering in time space before time based loss detection considers a packet lost.
 	// In fraction of an RTT.
 	timeReorderingFraction = 1.0 / 8
+	// The reordering tolerance of a path never exceeds one RTT
+	maxTimeReorderingFraction = 1.0
+	// After this number of round trips without losses, the reordering tolerance is halved again
+	reorderingDecayRoundTrips = 16
 	// defaultRTOTimeout is the RTO time on new connections
 	defaultRTOTimeout = 500 * time.Millisecond
 	// Minimum time in the future an RTO alarm may be set for.
@@ -31,6 +37,8 @@ const (
 	minRetransmissionTime = 200 * time.Millisecond
 	// Minimum tail loss probe time in ms
 	minTailLossProbeTimeout = 10 * time.Millisecond
+	// Maximum number of packets declared lost that are kept to detect spurious retransmissions
+	maxTrackedLostPackets = 1000
 )
 
 var (
@@ -45,6 +53,14 @@ var (
 
 var errPacketNumberNotIncreasing = errors.New("Already sent a packet with a higher packet number")
 
+// A lostPacket is a packet that was declared lost and queued for retransmission.
+// If it is acked later on, the retransmission was spurious.
+type lostPacket struct {
+	packetNumber protocol.PacketNumber
+	// declared lost by an RTO, not by loss detection
+	rto bool
+}
+
 type sentPacketHandler struct {
 	lastSentPacketNumber protocol.PacketNumber //上一次发送的报文序号
 	skippedPackets       []protocol.PacketNumber //跳过的报文序号
@@ -53,7 +69,8 @@ type sentPacketHandler struct {
 
 	LargestAcked protocol.PacketNumber //最大被确认的报文序号
 
-	largestReceivedPacketWithAck protocol.PacketNumber // 收到的ack的最大报文序号
+	// The largest packet number that carried an ACK frame for this path, for each path the ACKs were received on
+	largestReceivedPacketWithAck map[protocol.PathID]protocol.PacketNumber // 收到的ack的最大报文序号
 
 	packetHistory      *PacketList //发送的报文历史数据
 	stopWaitingManager stopWaitingManager
@@ -79,16 +96,56 @@ type sentPacketHandler struct {
 	// The time the last packet was sent, used to set the retransmission timeout
 	lastSentTime time.Time
 
+	// RACK: send time and RTT of the most recently sent packet that was acked
+	rackSentTime time.Time
+	rackRTT      time.Duration
+
+	// Fraction of the RTT a packet may be reordered before it is declared lost.
+	// It grows when reordering beyond it is observed on the path, and decays back to the initial fraction
+	// after reorderingDecayRoundTrips round trips without losses.
+	reorderingFraction        float64
+	initialReorderingFraction float64
+	// The largest packet number sent when the current round trip started, and the number of round trips without losses
+	roundTripEnd       protocol.PacketNumber
+	lossFreeRoundTrips int
+	// Number of packets acked after a packet sent later, and the largest packet number distance seen
+	reorderings      uint64
+	reorderingDegree protocol.PacketNumber
+
+	// Packets declared lost in the current loss epoch, sorted by packet number.
+	// The epoch ends with the largest packet number sent when its first loss was detected.
+	lostPackets    []lostPacket
+	lossEpochEnd   protocol.PacketNumber
+	spuriousLosses uint64
+	spuriousRTOs   uint64
+
 	// The alarm timeout
 	alarm time.Time // 通知超时的字段
 
+	// Set when the path ran out of data while the congestion window was not full
+	appLimited bool
+
 	packets         uint64 // 发送的报文数
 	retransmissions uint64 // 重传的次数
 	losses          uint64 // 丢失的报文数目
+
+	bytesSent          protocol.ByteCount
+	bytesRetransmitted protocol.ByteCount
+	bytesLost          protocol.ByteCount
+
+	// The events of the path are recorded with its ID
+	pathID protocol.PathID
+	tracer logging.ConnectionTracer
+	// The metrics that were recorded last, to only record them again when they change
+	tracedSmoothedRTT time.Duration
+	tracedLatestRTT   time.Duration
+	tracedCwnd        protocol.ByteCount
 }
 
 // NewSentPacketHandler creates a new sentPacketHandler
-func NewSentPacketHandler(rttStats *congestion.RTTStats, cong congestion.SendAlgorithm, onRTOCallback func(time.Time) bool) SentPacketHandler {
+// reorderingTolerance is the fraction of the RTT a packet may be reordered before being declared lost, 0 selects the default
+// the losses and the metrics of the path are recorded by the tracer, if it is not nil
+func NewSentPacketHandler(rttStats *congestion.RTTStats, cong congestion.SendAlgorithm, onRTOCallback func(time.Time) bool, reorderingTolerance float64, pathID protocol.PathID, tracer logging.ConnectionTracer) SentPacketHandler {
 	var congestionControl congestion.SendAlgorithm // 拥塞控制算法
 
 	if cong != nil {
@@ -103,17 +160,52 @@ func NewSentPacketHandler(rttStats *congestion.RTTStats, cong congestion.SendAlg
 		)
 	}
 
+	if reorderingTolerance <= 0 {
+		reorderingTolerance = timeReorderingFraction
+	}
+	reorderingTolerance = math.Min(reorderingTolerance, maxTimeReorderingFraction)
+
 	return &sentPacketHandler{
-		packetHistory:      NewPacketList(),
-		stopWaitingManager: stopWaitingManager{},
-		rttStats:           rttStats,
-		congestion:         congestionControl,
-		onRTOCallback:      onRTOCallback, //超时的回调函数
+		reorderingFraction:           reorderingTolerance,
+		initialReorderingFraction:    reorderingTolerance,
+		largestReceivedPacketWithAck: make(map[protocol.PathID]protocol.PacketNumber),
+		packetHistory:                NewPacketList(),
+		stopWaitingManager:           stopWaitingManager{},
+		rttStats:                     rttStats,
+		congestion:                   congestionControl,
+		onRTOCallback:                onRTOCallback, //超时的回调函数
+		pathID:                       pathID,
+		tracer:                       tracer,
+	}
+}
+
+// GetStatistics returns the statistics of the packets sent on the path
+func (h *sentPacketHandler) GetStatistics() SentPacketStatistics {// 取出该路径的数据，发送报文数、
+	stats := SentPacketStatistics{
+		Packets:            h.packets,
+		Retransmissions:    h.retransmissions,
+		Losses:             h.losses,
+		Bytes:              h.bytesSent,
+		RetransmittedBytes: h.bytesRetransmitted,
+		LostBytes:          h.bytesLost,
+		CongestionWindow:   h.congestion.GetCongestionWindow(),
+		BytesInFlight:      h.bytesInFlight,
+	}
+	if srtt := h.rttStats.SmoothedRTT(); srtt > 0 {
+		stats.BandwidthEstimate = congestion.BandwidthFromDelta(stats.CongestionWindow, srtt)
 	}
+	return stats
 }
 
-func (h *sentPacketHandler) GetStatistics() (uint64, uint64, uint64) {// 取出该路径的数据，发送报文数、
-	return h.packets, h.retransmissions, h.losses
+// GetSpuriousRetransmissionStatistics returns the number of packets that were declared lost by loss detection
+// and by RTOs, but were acked later on
+func (h *sentPacketHandler) GetSpuriousRetransmissionStatistics() (uint64, uint64) {
+	return h.spuriousLosses, h.spuriousRTOs
+}
+
+// GetReorderingStatistics returns the number of reordered packets and the largest reordering distance, in packets
+func (h *sentPacketHandler) GetReorderingStatistics() (uint64, protocol.PacketNumber) {
+	return h.reorderings, h.reorderingDegree
 }
 
 func (h *sentPacketHandler) largestInOrderAcked() protocol.PacketNumber {// 这个返回的是那些被确认的报文的最大序号
@@ -151,6 +243,7 @@ func (h *sentPacketHandler) SentPacket(packet *Packet) error {
 
 	// Update some statistics
 	h.packets++
+	h.bytesSent += packet.Length
 
 	// XXX RTO and TLP are recomputed based on the possible last sent retransmission. Is it ok like this?
 	h.lastSentTime = now
@@ -175,36 +268,47 @@ func (h *sentPacketHandler) SentPacket(packet *Packet) error {
 		packet.Length,
 		isRetransmittable,
 	)
+	if h.appLimited && h.bytesInFlight >= h.congestion.GetCongestionWindow() {
+		h.appLimited = false
+	}
 
 	h.updateLossDetectionAlarm() // 报文丢失的alarm
 	return nil
 }
 
-func (h *sentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, withPacketNumber protocol.PacketNumber, rcvTime time.Time) error {
+func (h *sentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, rcvPathID protocol.PathID, withPacketNumber protocol.PacketNumber, rcvTime time.Time) error {
 	if ackFrame.LargestAcked > h.lastSentPacketNumber {//接收到的ackFrame的确认的报文序号大于在该路径上发送的报文序号，那肯定报错啊，属于薛定谔的报文序号了
 		return errAckForUnsentPacket
 	}
 
 	// duplicate or out-of-order ACK
-	if withPacketNumber <= h.largestReceivedPacketWithAck {//这一个是为了啥？？？？？
+	if withPacketNumber <= h.largestReceivedPacketWithAck[rcvPathID] {//这一个是为了啥？？？？？
 		return ErrDuplicateOrOutOfOrderAck
 	}
-	h.largestReceivedPacketWithAck = withPacketNumber
+	h.largestReceivedPacketWithAck[rcvPathID] = withPacketNumber
+
+	if h.skippedPacketsAcked(ackFrame) {// 确认了包含从未发送过的报文序号，那么就会报错
+		return ErrAckForSkippedPacket
+	}
+
+	// An ACK that doesn't increase the LargestAcked may still ack packets that were declared lost
+	h.detectSpuriousLosses(ackFrame)
 
 	// ignore repeated ACK (ACKs that don't have a higher LargestAcked than the last ACK)
 	if ackFrame.LargestAcked <= h.largestInOrderAcked() {//重复的ack
 		return nil
 	}
-	h.LargestAcked = ackFrame.LargestAcked//更新确认的最大的报文序号
-
-	if h.skippedPacketsAcked(ackFrame) {// 确认了包含从未发送过的报文序号，那么就会报错
-		return ErrAckForSkippedPacket
-	}
+	previousLargestAcked := h.LargestAcked
+	// An ACK received on another path may be older than the last one, but still ack new packets
+	h.LargestAcked = utils.MaxPacketNumber(h.LargestAcked, ackFrame.LargestAcked)//更新确认的最大的报文序号
 
 	rttUpdated := h.maybeUpdateRTT(ackFrame.LargestAcked, ackFrame.DelayTime, rcvTime)
 
 	if rttUpdated {
 		h.congestion.MaybeExitSlowStart()
+		if h.tracer != nil {
+			h.tracer.UpdatedRTT(h.pathID, h.rttStats)
+		}
 	}
 
 	ackedPackets, err := h.determineNewlyAckedPackets(ackFrame)//拿到所有的报文序号
@@ -214,30 +318,33 @@ func (h *sentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, withPacketNumbe
 
 	if len(ackedPackets) > 0 {
 		for _, p := range ackedPackets {
+			h.updateRACK(&p.Value, previousLargestAcked, rcvTime)
 			h.onPacketAcked(p)// 所做的工作就是将该报文从 报文的存档中删除掉， bytesInflight也要减小
 			h.congestion.OnPacketAcked(p.Value.PacketNumber, p.Value.Length, h.bytesInFlight)//调整拥塞
 		}
 	}
 	// todo begin----------------------------------
 	h.detectLostPackets() //检测丢失的报文
+	h.maybeDecayReorderingWindow()
 	h.updateLossDetectionAlarm() // 更新Alarm
 
 	h.garbageCollectSkippedPackets()
 	h.stopWaitingManager.ReceivedAck(ackFrame) // 这个是在干啥？？
 	// todo ------------------------------------end
+	h.traceMetrics()
 	return nil
 }
 // 接收到 closePathFrame 的话
-func (h *sentPacketHandler) ReceivedClosePath(f *wire.ClosePathFrame, withPacketNumber protocol.PacketNumber, rcvTime time.Time) error {
+func (h *sentPacketHandler) ReceivedClosePath(f *wire.ClosePathFrame, rcvPathID protocol.PathID, withPacketNumber protocol.PacketNumber, rcvTime time.Time) error {
 	if f.LargestAcked > h.lastSentPacketNumber {
 		return errAckForUnsentPacket
 	}
 
 	// this should never happen, since a closePath frame should be the last packet on a path
-	if withPacketNumber <= h.largestReceivedPacketWithAck {
+	if withPacketNumber <= h.largestReceivedPacketWithAck[rcvPathID] {
 		return ErrDuplicateOrOutOfOrderAck
 	}
-	h.largestReceivedPacketWithAck = withPacketNumber
+	h.largestReceivedPacketWithAck[rcvPathID] = withPacketNumber
 
 	// Compared to ACK frames, we should not ignore duplicate LargestAcked
 
@@ -387,18 +494,65 @@ func (h *sentPacketHandler) updateLossDetectionAlarm() {
 	}
 }
 
+// updateRACK records the most recently sent packet that was acked, and detects reordering
+func (h *sentPacketHandler) updateRACK(packet *Packet, previousLargestAcked protocol.PacketNumber, rcvTime time.Time) {
+	if packet.SendTime.Before(h.rackSentTime) {
+		// A packet sent later was already acked
+		h.reorderings++
+		if previousLargestAcked > packet.PacketNumber {
+			h.reorderingDegree = utils.MaxPacketNumber(h.reorderingDegree, previousLargestAcked-packet.PacketNumber)
+		}
+		// Tolerate more reordering if this packet was acked after it could have been declared lost
+		if rcvTime.Sub(packet.SendTime)-h.rackRTT > h.reorderingWindow() {
+			h.increaseReorderingWindow()
+		}
+		return
+	}
+	h.rackSentTime = packet.SendTime
+	h.rackRTT = rcvTime.Sub(packet.SendTime)
+}
+
+// increaseReorderingWindow doubles the reordering tolerance, after reordering beyond it was observed
+func (h *sentPacketHandler) increaseReorderingWindow() {
+	h.reorderingFraction = math.Min(2*h.reorderingFraction, maxTimeReorderingFraction)
+	h.lossFreeRoundTrips = 0
+}
+
+// maybeDecayReorderingWindow halves the reordering tolerance after reorderingDecayRoundTrips round trips without losses,
+// down to its initial value. Like in RACK, the tolerance adapts again when the reordering on the path decreases.
+// A round trip ends when a packet sent after its start is acked.
+func (h *sentPacketHandler) maybeDecayReorderingWindow() {
+	if h.LargestAcked <= h.roundTripEnd {
+		return
+	}
+	h.roundTripEnd = h.lastSentPacketNumber
+	h.lossFreeRoundTrips++
+	if h.lossFreeRoundTrips < reorderingDecayRoundTrips {
+		return
+	}
+	h.lossFreeRoundTrips = 0
+	h.reorderingFraction = math.Max(h.reorderingFraction/2, h.initialReorderingFraction)
+}
+
+// reorderingWindow is the time a packet may be reordered before it is declared lost
+func (h *sentPacketHandler) reorderingWindow() time.Duration {
+	maxRTT := float64(utils.MaxDuration(h.rttStats.LatestRTT(), h.rttStats.SmoothedRTT()))
+	return time.Duration(h.reorderingFraction * maxRTT)
+}
+
+// detectLostPackets implements RACK: a packet sent before the most recently delivered packet is lost
+// if it was not acked within the RTT of that packet plus the reordering window
 func (h *sentPacketHandler) detectLostPackets() {
 	h.lossTime = time.Time{}
 	now := time.Now()
 
-	maxRTT := float64(utils.MaxDuration(h.rttStats.LatestRTT(), h.rttStats.SmoothedRTT()))
-	delayUntilLost := time.Duration((1.0 + timeReorderingFraction) * maxRTT)
+	delayUntilLost := h.rackRTT + h.reorderingWindow()
 
 	var lostPackets []*PacketElement
 	for el := h.packetHistory.Front(); el != nil; el = el.Next() {
 		packet := el.Value
 
-		if packet.PacketNumber > h.LargestAcked {
+		if packet.SendTime.After(h.rackSentTime) {
 			break
 		}
 
@@ -406,6 +560,7 @@ func (h *sentPacketHandler) detectLostPackets() {
 		if timeSinceSent > delayUntilLost {
 			// Update statistics
 			h.losses++
+			h.bytesLost += packet.Length
 			lostPackets = append(lostPackets, el)
 		} else if h.lossTime.IsZero() {
 			// Note: This conditional is only entered once per call
@@ -414,7 +569,10 @@ func (h *sentPacketHandler) detectLostPackets() {
 	}
 
 	if len(lostPackets) > 0 {
+		h.lossFreeRoundTrips = 0
 		for _, p := range lostPackets {
+			h.recordLostPacket(p.Value.PacketNumber, false)
+			h.traceLostPacket(p.Value.PacketNumber, logging.PacketLossTimeThreshold)
 			h.queuePacketForRetransmission(p) // 将所有需要重传的报文加入到队列当中去
 			h.congestion.OnPacketLost(p.Value.PacketNumber, p.Value.Length, h.bytesInFlight)
 		}
@@ -431,16 +589,19 @@ func (h *sentPacketHandler) SetInflightAsLost() {
 		}
 
 		h.losses++
+		h.bytesLost += packet.Length
 		lostPackets = append(lostPackets, el)
 	}
 
 	if len(lostPackets) > 0 {
 		for _, p := range lostPackets {
+			h.traceLostPacket(p.Value.PacketNumber, logging.PacketLossPathClosed)
 			h.queuePacketForRetransmission(p) // 将所有被视作为丢失的报文给缓存起来
 			// XXX (QDC): should we?
 			h.congestion.OnPacketLost(p.Value.PacketNumber, p.Value.Length, h.bytesInFlight)
 		}
 	}
+	h.traceMetrics()
 }
 
 func (h *sentPacketHandler) OnAlarm() {
@@ -475,6 +636,7 @@ func (h *sentPacketHandler) OnAlarm() {
 	}
 
 	h.updateLossDetectionAlarm()
+	h.traceMetrics()
 }
 
 func (h *sentPacketHandler) GetAlarmTimeout() time.Time {
@@ -499,6 +661,7 @@ func (h *sentPacketHandler) DequeuePacketForRetransmission() *Packet {
 	h.retransmissionQueue = h.retransmissionQueue[:len(h.retransmissionQueue)-1]
 	// Update statistics
 	h.retransmissions++
+	h.bytesRetransmitted += packet.Length
 	return packet
 }
 // 拿到最小的未被确认的报文
@@ -525,6 +688,26 @@ func (h *sentPacketHandler) SendingAllowed() bool {
 	return !maxTrackedLimited && (!congestionLimited || haveRetransmissions)
 }
 
+// SetApplicationLimited is called when there is no more data to send on this path
+// although the congestion window would allow it
+func (h *sentPacketHandler) SetApplicationLimited() {
+	if h.bytesInFlight >= h.congestion.GetCongestionWindow() {
+		return
+	}
+	if !h.appLimited {
+		utils.Debugf("Application limited: bytes in flight %d, window %d",
+			h.bytesInFlight,
+			h.congestion.GetCongestionWindow())
+	}
+	h.appLimited = true
+	h.congestion.OnApplicationLimited(h.bytesInFlight)
+}
+
+// IsApplicationLimited returns true if the path did not use its congestion window since it ran out of data
+func (h *sentPacketHandler) IsApplicationLimited() bool {
+	return h.appLimited
+}
+
 func (h *sentPacketHandler) retransmitTLP() {
 	if p := h.packetHistory.Back(); p != nil {
 		h.queuePacketForRetransmission(p)
@@ -557,11 +740,76 @@ func (h *sentPacketHandler) queueRTO(el *PacketElement) {
 		packet.PacketNumber,
 		h.packetHistory.Len(),
 	)
+	h.recordLostPacket(packet.PacketNumber, true)
+	h.lossFreeRoundTrips = 0
+	h.traceLostPacket(packet.PacketNumber, logging.PacketLossRetransmissionTimeout)
 	h.queuePacketForRetransmission(el)
 	h.losses++
+	h.bytesLost += packet.Length
 	h.congestion.OnPacketLost(packet.PacketNumber, packet.Length, h.bytesInFlight)
 }
 
+func (h *sentPacketHandler) recordLostPacket(packetNumber protocol.PacketNumber, rto bool) {
+	if packetNumber > h.lossEpochEnd {
+		// A new loss epoch starts. The congestion controller can only undo the reduction of this one,
+		// so an ACK for a packet lost earlier must not be taken for a spurious loss of this epoch.
+		h.lostPackets = h.lostPackets[:0]
+		h.lossEpochEnd = h.lastSentPacketNumber
+	}
+	h.lostPackets = append(h.lostPackets, lostPacket{packetNumber: packetNumber, rto: rto})
+	if len(h.lostPackets) > maxTrackedLostPackets {
+		h.lostPackets = h.lostPackets[1:]
+	}
+}
+
+// detectSpuriousLosses checks if the ACK frame acks packets that were declared lost.
+// If all the losses covered by the ACK frame were spurious, the congestion window reduction is undone (like Eifel and F-RTO).
+func (h *sentPacketHandler) detectSpuriousLosses(ackFrame *wire.AckFrame) {
+	if len(h.lostPackets) == 0 {
+		return
+	}
+	var spurious, stillLost bool
+	remaining := h.lostPackets[:0]
+	for _, p := range h.lostPackets {
+		if !ackFrame.AcksPacket(p.packetNumber) {
+			if p.packetNumber >= ackFrame.LowestAcked && p.packetNumber <= ackFrame.LargestAcked {
+				stillLost = true
+			}
+			remaining = append(remaining, p)
+			continue
+		}
+		// The original packet arrived, there is no need to retransmit it
+		spurious = true
+		h.dequeueRetransmission(p.packetNumber)
+		if p.rto {
+			h.spuriousRTOs++
+			continue
+		}
+		h.spuriousLosses++
+		// The packet was reordered, not lost
+		h.increaseReorderingWindow()
+	}
+	h.lostPackets = remaining
+	if spurious && !stillLost {
+		utils.Debugf("Spurious retransmission detected, undoing the congestion window reduction")
+		h.congestion.OnSpuriousCongestionEvent()
+	}
+}
+
+// dequeueRetransmission removes a packet from the retransmission queue, if it was not retransmitted yet
+func (h *sentPacketHandler) dequeueRetransmission(packetNumber protocol.PacketNumber) {
+	queue := h.retransmissionQueue[:0]
+	for _, p := range h.retransmissionQueue {
+		if p.PacketNumber != packetNumber {
+			queue = append(queue, p)
+		}
+	}
+	for i := len(queue); i < len(h.retransmissionQueue); i++ {
+		h.retransmissionQueue[i] = nil
+	}
+	h.retransmissionQueue = queue
+}
+
 func (h *sentPacketHandler) queuePacketForRetransmission(packetElement *PacketElement) {
 	packet := &packetElement.Value
 	h.bytesInFlight -= packet.Length
@@ -574,6 +822,58 @@ func (h *sentPacketHandler) DuplicatePacket(packet *Packet) {
 	h.retransmissionQueue = append(h.retransmissionQueue, packet)
 }
 
+// GetPacketsForReinjection returns the packets in flight sent before sentBefore that were not reinjected yet.
+// They are marked as reinjected, but stay in flight on this path.
+func (h *sentPacketHandler) GetPacketsForReinjection(sentBefore time.Time) []*Packet {
+	var packets []*Packet
+	for el := h.packetHistory.Front(); el != nil; el = el.Next() {
+		packet := &el.Value
+		if !packet.SendTime.Before(sentBefore) {
+			break
+		}
+		// Handshake packets are bound to the initial path and to the handshake keys
+		if packet.Reinjected || packet.EncryptionLevel != protocol.EncryptionForwardSecure {
+			continue
+		}
+		packet.Reinjected = true
+		packets = append(packets, packet)
+	}
+	return packets
+}
+
+// DequeuePacketsWithEncryptionLevel removes the packets sent with an encryption level from flight and from the retransmission queue.
+// It is used when the peer rejected these keys, such that the frames of the packets can be sent again with other keys.
+func (h *sentPacketHandler) DequeuePacketsWithEncryptionLevel(encLevel protocol.EncryptionLevel) []*Packet {
+	var packets []*Packet
+	queue := h.retransmissionQueue[:0]
+	for _, packet := range h.retransmissionQueue {
+		if packet.EncryptionLevel == encLevel {
+			packets = append(packets, packet)
+			continue
+		}
+		queue = append(queue, packet)
+	}
+	for i := len(queue); i < len(h.retransmissionQueue); i++ {
+		h.retransmissionQueue[i] = nil
+	}
+	h.retransmissionQueue = queue
+
+	var next *PacketElement
+	for el := h.packetHistory.Front(); el != nil; el = next {
+		next = el.Next()
+		if el.Value.EncryptionLevel != encLevel {
+			continue
+		}
+		packet := &el.Value
+		h.bytesInFlight -= packet.Length
+		h.packetHistory.Remove(el)
+		h.stopWaitingManager.QueuedRetransmissionForPacketNumber(packet.PacketNumber)
+		packets = append(packets, packet)
+	}
+	h.updateLossDetectionAlarm()
+	return packets
+}
+
 func (h *sentPacketHandler) computeRTOTimeout() time.Duration {
 	rto := h.congestion.RetransmissionDelay()
 	if rto == 0 {
@@ -625,3 +925,24 @@ func (h *sentPacketHandler) garbageCollectSkippedPackets() {
 	}
 	h.skippedPackets = h.skippedPackets[deleteIndex:]
 }
+
+func (h *sentPacketHandler) traceLostPacket(packetNumber protocol.PacketNumber, reason logging.PacketLossReason) {
+	if h.tracer != nil {
+		h.tracer.LostPacket(h.pathID, packetNumber, reason)
+	}
+}
+
+// traceMetrics records the RTT estimates and the congestion window, if they changed since they were last recorded
+func (h *sentPacketHandler) traceMetrics() {
+	if h.tracer == nil {
+		return
+	}
+	cwnd := h.congestion.GetCongestionWindow()
+	if h.rttStats.SmoothedRTT() == h.tracedSmoothedRTT && h.rttStats.LatestRTT() == h.tracedLatestRTT && cwnd == h.tracedCwnd {
+		return
+	}
+	h.tracedSmoothedRTT = h.rttStats.SmoothedRTT()
+	h.tracedLatestRTT = h.rttStats.LatestRTT()
+	h.tracedCwnd = cwnd
+	h.tracer.UpdatedMetrics(h.pathID, h.rttStats, cwnd, h.bytesInFlight)
+}
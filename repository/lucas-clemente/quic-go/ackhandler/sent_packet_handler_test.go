@@ -3,9 +3,12 @@ package ackhandler
 import (
 	"time"
 
+	"github.com/yyleeshine/mpquic/repository/golang/mock/gomock"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/congestion"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/mocks"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/wire"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/logging"
 	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
 	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
 )
@@ -17,6 +20,9 @@ type mockCongestion struct {
 	getCongestionWindow     bool
 	packetsAcked            [][]interface{}
 	packetsLost             [][]interface{}
+	appLimited              []protocol.ByteCount
+
+	spuriousCongestionEvents int
 }
 
 func (m *mockCongestion) TimeUntilSend(now time.Time, bytesInFlight protocol.ByteCount) time.Duration {
@@ -58,6 +64,14 @@ func (m *mockCongestion) OnPacketLost(n protocol.PacketNumber, l protocol.ByteCo
 	m.packetsLost = append(m.packetsLost, []interface{}{n, l, bif})
 }
 
+func (m *mockCongestion) OnSpuriousCongestionEvent() {
+	m.spuriousCongestionEvents++
+}
+
+func (m *mockCongestion) OnApplicationLimited(bif protocol.ByteCount) {
+	m.appLimited = append(m.appLimited, bif)
+}
+
 func retransmittablePacket(num protocol.PacketNumber) *Packet {
 	return &Packet{PacketNumber: num, Length: 1, Frames: []wire.Frame{&wire.PingFrame{}}}
 }
@@ -74,7 +88,7 @@ var _ = Describe("SentPacketHandler", func() {
 
 	BeforeEach(func() {
 		rttStats := &congestion.RTTStats{}
-		handler = NewSentPacketHandler(rttStats, nil, nil).(*sentPacketHandler)
+		handler = NewSentPacketHandler(rttStats, nil, nil, 0, 0, nil).(*sentPacketHandler)
 		streamFrame = wire.StreamFrame{
 			StreamID: 5,
 			Data:     []byte{0x13, 0x37},
@@ -298,10 +312,10 @@ var _ = Describe("SentPacketHandler", func() {
 					LargestAcked: protocol.PacketNumber(largestAcked),
 					LowestAcked:  1,
 				}
-				err := handler.ReceivedAck(&ack, 1337, time.Now())
+				err := handler.ReceivedAck(&ack, protocol.InitialPathID, 1337, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(len(packets) - 3)))
-				err = handler.ReceivedAck(&ack, 1337, time.Now())
+				err = handler.ReceivedAck(&ack, protocol.InitialPathID, 1337, time.Now())
 				Expect(err).To(MatchError(ErrDuplicateOrOutOfOrderAck))
 				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(len(packets) - 3)))
 			})
@@ -310,20 +324,35 @@ var _ = Describe("SentPacketHandler", func() {
 				ack := wire.AckFrame{
 					LargestAcked: 3,
 				}
-				err := handler.ReceivedAck(&ack, 1337, time.Now())
+				err := handler.ReceivedAck(&ack, protocol.InitialPathID, 1337, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(len(packets) - 3)))
-				err = handler.ReceivedAck(&ack, 1337-1, time.Now())
+				err = handler.ReceivedAck(&ack, protocol.InitialPathID, 1337-1, time.Now())
 				Expect(err).To(MatchError(ErrDuplicateOrOutOfOrderAck))
 				Expect(handler.LargestAcked).To(Equal(protocol.PacketNumber(3)))
 				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(len(packets) - 3)))
 			})
 
+			It("compares the packet numbers of ACKs received on different paths separately", func() {
+				ack := wire.AckFrame{LargestAcked: 3, LowestAcked: 3}
+				err := handler.ReceivedAck(&ack, 1, 1337, time.Now())
+				Expect(err).ToNot(HaveOccurred())
+				// an older ACK frame, received on another path
+				ack = wire.AckFrame{LargestAcked: 2, LowestAcked: 1}
+				err = handler.ReceivedAck(&ack, 2, 10, time.Now())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(len(packets) - 3)))
+				// the LargestAcked doesn't go backwards
+				Expect(handler.LargestAcked).To(Equal(protocol.PacketNumber(3)))
+				err = handler.ReceivedAck(&ack, 2, 9, time.Now())
+				Expect(err).To(MatchError(ErrDuplicateOrOutOfOrderAck))
+			})
+
 			It("rejects ACKs with a too high LargestAcked packet number", func() {
 				ack := wire.AckFrame{
 					LargestAcked: packets[len(packets)-1].PacketNumber + 1337,
 				}
-				err := handler.ReceivedAck(&ack, 1, time.Now())
+				err := handler.ReceivedAck(&ack, protocol.InitialPathID, 1, time.Now())
 				Expect(err).To(MatchError(errAckForUnsentPacket))
 				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(len(packets))))
 			})
@@ -333,10 +362,10 @@ var _ = Describe("SentPacketHandler", func() {
 					LargestAcked: 3,
 					LowestAcked:  1,
 				}
-				err := handler.ReceivedAck(&ack, 1337, time.Now())
+				err := handler.ReceivedAck(&ack, protocol.InitialPathID, 1337, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(len(packets) - 3)))
-				err = handler.ReceivedAck(&ack, 1337+1, time.Now())
+				err = handler.ReceivedAck(&ack, protocol.InitialPathID, 1337+1, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				Expect(handler.LargestAcked).To(Equal(protocol.PacketNumber(3)))
 				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(len(packets) - 3)))
@@ -347,7 +376,7 @@ var _ = Describe("SentPacketHandler", func() {
 					LargestAcked: 12,
 					LowestAcked:  5,
 				}
-				err := handler.ReceivedAck(&ack, 1337, time.Now())
+				err := handler.ReceivedAck(&ack, protocol.InitialPathID, 1337, time.Now())
 				Expect(err).To(MatchError(ErrAckForSkippedPacket))
 			})
 
@@ -360,7 +389,7 @@ var _ = Describe("SentPacketHandler", func() {
 						{First: 5, Last: 10},
 					},
 				}
-				err := handler.ReceivedAck(&ack, 1337, time.Now())
+				err := handler.ReceivedAck(&ack, protocol.InitialPathID, 1337, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				Expect(handler.LargestAcked).ToNot(BeZero())
 			})
@@ -372,7 +401,7 @@ var _ = Describe("SentPacketHandler", func() {
 					LargestAcked: 5,
 					LowestAcked:  1,
 				}
-				err := handler.ReceivedAck(&ack, 1, time.Now())
+				err := handler.ReceivedAck(&ack, protocol.InitialPathID, 1, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				Expect(handler.LargestAcked).To(Equal(protocol.PacketNumber(5)))
 				el := handler.packetHistory.Front()
@@ -388,7 +417,7 @@ var _ = Describe("SentPacketHandler", func() {
 					LargestAcked: 8,
 					LowestAcked:  2,
 				}
-				err := handler.ReceivedAck(&ack, 1, time.Now())
+				err := handler.ReceivedAck(&ack, protocol.InitialPathID, 1, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				el := handler.packetHistory.Front()
 				Expect(el.Value.PacketNumber).To(Equal(protocol.PacketNumber(1)))
@@ -408,7 +437,7 @@ var _ = Describe("SentPacketHandler", func() {
 						{First: 2, Last: 3},
 					},
 				}
-				err := handler.ReceivedAck(&ack, 1, time.Now())
+				err := handler.ReceivedAck(&ack, protocol.InitialPathID, 1, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				el := handler.packetHistory.Front()
 				Expect(el.Value.PacketNumber).To(Equal(protocol.PacketNumber(1)))
@@ -426,7 +455,7 @@ var _ = Describe("SentPacketHandler", func() {
 					LargestAcked: 8,
 					LowestAcked:  3,
 				}
-				err := handler.ReceivedAck(&ack, 1, time.Now())
+				err := handler.ReceivedAck(&ack, protocol.InitialPathID, 1, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				el := handler.packetHistory.Front()
 				Expect(el.Value.PacketNumber).To(Equal(protocol.PacketNumber(1)))
@@ -446,7 +475,7 @@ var _ = Describe("SentPacketHandler", func() {
 						{First: 1, Last: 1},
 					},
 				}
-				err := handler.ReceivedAck(&ack, 1, time.Now())
+				err := handler.ReceivedAck(&ack, protocol.InitialPathID, 1, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				el := handler.packetHistory.Front()
 				Expect(el.Value.PacketNumber).To(Equal(protocol.PacketNumber(2)))
@@ -470,7 +499,7 @@ var _ = Describe("SentPacketHandler", func() {
 						{First: 1, Last: 2},
 					},
 				}
-				err := handler.ReceivedAck(&ack1, 1, time.Now())
+				err := handler.ReceivedAck(&ack1, protocol.InitialPathID, 1, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(len(packets) - 5)))
 				el := handler.packetHistory.Front()
@@ -479,7 +508,7 @@ var _ = Describe("SentPacketHandler", func() {
 					LargestAcked: protocol.PacketNumber(largestObserved),
 					LowestAcked:  1,
 				}
-				err = handler.ReceivedAck(&ack2, 2, time.Now())
+				err = handler.ReceivedAck(&ack2, protocol.InitialPathID, 2, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(len(packets) - 6)))
 				Expect(handler.packetHistory.Front().Value.PacketNumber).To(Equal(protocol.PacketNumber(7)))
@@ -494,7 +523,7 @@ var _ = Describe("SentPacketHandler", func() {
 						{First: 1, Last: 2},
 					},
 				}
-				err := handler.ReceivedAck(&ack1, 1, time.Now())
+				err := handler.ReceivedAck(&ack1, protocol.InitialPathID, 1, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(len(packets) - 5)))
 				el := handler.packetHistory.Front()
@@ -503,7 +532,7 @@ var _ = Describe("SentPacketHandler", func() {
 					LargestAcked: 7,
 					LowestAcked:  1,
 				}
-				err = handler.ReceivedAck(&ack2, 2, time.Now())
+				err = handler.ReceivedAck(&ack2, protocol.InitialPathID, 2, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(len(packets) - 7)))
 				Expect(handler.packetHistory.Front().Value.PacketNumber).To(Equal(protocol.PacketNumber(8)))
@@ -514,7 +543,7 @@ var _ = Describe("SentPacketHandler", func() {
 					LargestAcked: 6,
 					LowestAcked:  1,
 				}
-				err := handler.ReceivedAck(&ack1, 1, time.Now())
+				err := handler.ReceivedAck(&ack1, protocol.InitialPathID, 1, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				Expect(handler.packetHistory.Front().Value.PacketNumber).To(Equal(protocol.PacketNumber(7)))
 				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(len(packets) - 6)))
@@ -527,7 +556,7 @@ var _ = Describe("SentPacketHandler", func() {
 						{First: 1, Last: 1},
 					},
 				}
-				err = handler.ReceivedAck(&ack2, 2, time.Now())
+				err = handler.ReceivedAck(&ack2, protocol.InitialPathID, 2, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(len(packets) - 6 - 3)))
 				Expect(handler.packetHistory.Front().Value.PacketNumber).To(Equal(protocol.PacketNumber(7)))
@@ -543,13 +572,13 @@ var _ = Describe("SentPacketHandler", func() {
 				getPacketElement(2).Value.SendTime = now.Add(-5 * time.Minute)
 				getPacketElement(6).Value.SendTime = now.Add(-1 * time.Minute)
 				// Now, check that the proper times are used when calculating the deltas
-				err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1}, 1, time.Now())
+				err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1}, protocol.InitialPathID, 1, time.Now())
 				Expect(err).NotTo(HaveOccurred())
 				Expect(handler.rttStats.LatestRTT()).To(BeNumerically("~", 10*time.Minute, 1*time.Second))
-				err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2}, 2, time.Now())
+				err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2}, protocol.InitialPathID, 2, time.Now())
 				Expect(err).NotTo(HaveOccurred())
 				Expect(handler.rttStats.LatestRTT()).To(BeNumerically("~", 5*time.Minute, 1*time.Second))
-				err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 6}, 3, time.Now())
+				err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 6}, protocol.InitialPathID, 3, time.Now())
 				Expect(err).NotTo(HaveOccurred())
 				Expect(handler.rttStats.LatestRTT()).To(BeNumerically("~", 1*time.Minute, 1*time.Second))
 			})
@@ -557,7 +586,7 @@ var _ = Describe("SentPacketHandler", func() {
 			It("uses the DelayTime in the ack frame", func() {
 				now := time.Now()
 				getPacketElement(1).Value.SendTime = now.Add(-10 * time.Minute)
-				err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, DelayTime: 5 * time.Minute}, 1, time.Now())
+				err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, DelayTime: 5 * time.Minute}, protocol.InitialPathID, 1, time.Now())
 				Expect(err).NotTo(HaveOccurred())
 				Expect(handler.rttStats.LatestRTT()).To(BeNumerically("~", 5*time.Minute, 1*time.Second))
 			})
@@ -583,7 +612,7 @@ var _ = Describe("SentPacketHandler", func() {
 			// Increase RTT, because the tests would be flaky otherwise
 			handler.rttStats.UpdateRTT(time.Minute, 0, time.Now())
 			// Ack a single packet so that we have non-RTO timings
-			handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2, LowestAcked: 2}, 1, time.Now())
+			handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2, LowestAcked: 2}, protocol.InitialPathID, 1, time.Now())
 			Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(6)))
 		})
 
@@ -606,7 +635,7 @@ var _ = Describe("SentPacketHandler", func() {
 		Context("StopWaitings", func() {
 			It("gets a StopWaitingFrame", func() {
 				ack := wire.AckFrame{LargestAcked: 5, LowestAcked: 5}
-				err := handler.ReceivedAck(&ack, 2, time.Now())
+				err := handler.ReceivedAck(&ack, protocol.InitialPathID, 2, time.Now())
 				Expect(err).ToNot(HaveOccurred())
 				Expect(handler.GetStopWaitingFrame(false)).To(Equal(&wire.StopWaitingFrame{LeastUnacked: 6}))
 			})
@@ -644,7 +673,7 @@ var _ = Describe("SentPacketHandler", func() {
 				{First: 1, Last: 1},
 			},
 		}
-		err = handler.ReceivedAck(&ack, 1, time.Now())
+		err = handler.ReceivedAck(&ack, protocol.InitialPathID, 1, time.Now())
 		Expect(err).NotTo(HaveOccurred())
 		Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(2)))
 
@@ -681,7 +710,7 @@ var _ = Describe("SentPacketHandler", func() {
 		It("should call MaybeExitSlowStart and OnPacketAcked", func() {
 			handler.SentPacket(retransmittablePacket(1))
 			handler.SentPacket(retransmittablePacket(2))
-			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1}, 1, time.Now())
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1}, protocol.InitialPathID, 1, time.Now())
 			Expect(err).NotTo(HaveOccurred())
 			Expect(cong.maybeExitSlowStart).To(BeTrue())
 			Expect(cong.packetsAcked).To(BeEquivalentTo([][]interface{}{
@@ -716,6 +745,33 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(handler.SendingAllowed()).To(BeFalse())
 		})
 
+		It("notifies the congestion controller when the application is limited", func() {
+			handler.SentPacket(retransmittablePacket(1))
+			handler.SetApplicationLimited()
+			Expect(handler.IsApplicationLimited()).To(BeTrue())
+			Expect(cong.appLimited).To(Equal([]protocol.ByteCount{1}))
+			// using the whole window again ends the application limited period
+			err := handler.SentPacket(&Packet{
+				PacketNumber: 2,
+				Frames:       []wire.Frame{&wire.PingFrame{}},
+				Length:       protocol.DefaultTCPMSS,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(handler.IsApplicationLimited()).To(BeFalse())
+		})
+
+		It("is not application limited when the congestion window is full", func() {
+			err := handler.SentPacket(&Packet{
+				PacketNumber: 1,
+				Frames:       []wire.Frame{&wire.PingFrame{}},
+				Length:       protocol.DefaultTCPMSS,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			handler.SetApplicationLimited()
+			Expect(handler.IsApplicationLimited()).To(BeFalse())
+			Expect(cong.appLimited).To(BeEmpty())
+		})
+
 		It("allows or denies sending based on the number of tracked packets", func() {
 			Expect(handler.SendingAllowed()).To(BeTrue())
 			handler.retransmissionQueue = make([]*Packet, protocol.MaxTrackedSentPackets)
@@ -777,7 +833,7 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(handler.lossTime.IsZero()).To(BeTrue())
 
-			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2, LowestAcked: 2}, 1, time.Now().Add(time.Hour))
+			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2, LowestAcked: 2}, protocol.InitialPathID, 1, time.Now().Add(time.Hour))
 			Expect(err).NotTo(HaveOccurred())
 			Expect(handler.lossTime.IsZero()).To(BeFalse())
 
@@ -800,7 +856,7 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(err).NotTo(HaveOccurred())
 			Expect(handler.lossTime.IsZero()).To(BeTrue())
 
-			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1}, 1, time.Now().Add(time.Hour))
+			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1}, protocol.InitialPathID, 1, time.Now().Add(time.Hour))
 			Expect(err).NotTo(HaveOccurred())
 			Expect(handler.lossTime.IsZero()).To(BeTrue())
 			Expect(handler.GetAlarmTimeout().Sub(time.Now())).To(BeNumerically("~", handler.computeRTOTimeout(), time.Minute))
@@ -813,6 +869,294 @@ var _ = Describe("SentPacketHandler", func() {
 		})
 	})
 
+	Context("RACK loss detection", func() {
+		BeforeEach(func() {
+			for i := protocol.PacketNumber(1); i <= 3; i++ {
+				err := handler.SentPacket(retransmittablePacket(i))
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		It("measures reordering", func() {
+			ack := &wire.AckFrame{
+				LargestAcked: 3,
+				LowestAcked:  2,
+			}
+			err := handler.ReceivedAck(ack, protocol.InitialPathID, 1, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			reorderings, degree := handler.GetReorderingStatistics()
+			Expect(reorderings).To(BeZero())
+			Expect(degree).To(BeZero())
+			// packet 1 was sent before packet 3
+			handler.packetHistory.Front().Value.SendTime = time.Now().Add(-time.Millisecond)
+			ack = &wire.AckFrame{
+				LargestAcked: 3,
+				LowestAcked:  1,
+			}
+			err = handler.ReceivedAck(ack, protocol.InitialPathID, 2, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			reorderings, degree = handler.GetReorderingStatistics()
+			Expect(reorderings).To(BeEquivalentTo(1))
+			Expect(degree).To(BeEquivalentTo(2))
+		})
+
+		It("uses the configured reordering tolerance", func() {
+			handler = NewSentPacketHandler(handler.rttStats, nil, nil, 0.5, 0, nil).(*sentPacketHandler)
+			err := handler.SentPacket(retransmittablePacket(1))
+			Expect(err).NotTo(HaveOccurred())
+			err = handler.SentPacket(retransmittablePacket(2))
+			Expect(err).NotTo(HaveOccurred())
+			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2, LowestAcked: 2}, protocol.InitialPathID, 1, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(handler.lossTime.Sub(time.Now())).To(BeNumerically("~", time.Hour*3/2, time.Minute))
+		})
+
+		It("increases the reordering tolerance if a packet arrives after its reordering window", func() {
+			handler.packetHistory.Front().Value.SendTime = time.Now().Add(-time.Millisecond)
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 3, LowestAcked: 2}, protocol.InitialPathID, 1, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(handler.reorderingFraction).To(Equal(timeReorderingFraction))
+			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 3, LowestAcked: 1}, protocol.InitialPathID, 2, time.Now().Add(3*time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(handler.reorderingFraction).To(Equal(2 * timeReorderingFraction))
+		})
+
+		Context("decaying the reordering tolerance", func() {
+			var pn protocol.PacketNumber
+
+			// ackRoundTrip sends a packet and acks it, which completes a round trip
+			ackRoundTrip := func() {
+				pn++
+				err := handler.SentPacket(retransmittablePacket(pn))
+				Expect(err).NotTo(HaveOccurred())
+				err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: pn, LowestAcked: 1}, protocol.InitialPathID, pn, time.Now())
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			BeforeEach(func() {
+				pn = 3
+				handler.reorderingFraction = 4 * timeReorderingFraction
+			})
+
+			It("halves the reordering tolerance after round trips without losses, down to the initial tolerance", func() {
+				for i := 0; i < reorderingDecayRoundTrips-1; i++ {
+					ackRoundTrip()
+				}
+				Expect(handler.reorderingFraction).To(Equal(4 * timeReorderingFraction))
+				ackRoundTrip()
+				Expect(handler.reorderingFraction).To(Equal(2 * timeReorderingFraction))
+				for i := 0; i < 2*reorderingDecayRoundTrips; i++ {
+					ackRoundTrip()
+				}
+				Expect(handler.reorderingFraction).To(Equal(timeReorderingFraction))
+			})
+
+			It("restarts counting the round trips after a loss", func() {
+				for i := 0; i < reorderingDecayRoundTrips-1; i++ {
+					ackRoundTrip()
+				}
+				pn++
+				err := handler.SentPacket(retransmittablePacket(pn))
+				Expect(err).NotTo(HaveOccurred())
+				handler.tlpCount = maxTailLossProbes
+				handler.OnAlarm() // RTO
+				Expect(handler.retransmissionQueue).To(HaveLen(1))
+				ackRoundTrip()
+				Expect(handler.reorderingFraction).To(Equal(4 * timeReorderingFraction))
+			})
+		})
+
+		It("does not declare packets sent after the most recently acked packet lost", func() {
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2, LowestAcked: 2}, protocol.InitialPathID, 1, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			handler.packetHistory.Front().Value.SendTime = time.Now().Add(-2 * time.Hour)
+			handler.OnAlarm()
+			Expect(handler.DequeuePacketForRetransmission().PacketNumber).To(Equal(protocol.PacketNumber(1)))
+			Expect(handler.DequeuePacketForRetransmission()).To(BeNil())
+		})
+	})
+
+	Context("spurious retransmissions", func() {
+		var cong *mockCongestion
+
+		BeforeEach(func() {
+			cong = &mockCongestion{}
+			handler.congestion = cong
+			for i := protocol.PacketNumber(1); i <= 3; i++ {
+				err := handler.SentPacket(retransmittablePacket(i))
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		It("detects spurious losses", func() {
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 3, LowestAcked: 2}, protocol.InitialPathID, 1, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			handler.packetHistory.Front().Value.SendTime = time.Now().Add(-2 * time.Hour)
+			handler.OnAlarm()
+			Expect(cong.packetsLost).To(HaveLen(1))
+			Expect(handler.retransmissionQueue).To(HaveLen(1))
+			// the original packet arrives
+			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 3, LowestAcked: 1}, protocol.InitialPathID, 2, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			spuriousLosses, spuriousRTOs := handler.GetSpuriousRetransmissionStatistics()
+			Expect(spuriousLosses).To(BeEquivalentTo(1))
+			Expect(spuriousRTOs).To(BeZero())
+			Expect(handler.retransmissionQueue).To(BeEmpty())
+			Expect(handler.lostPackets).To(BeEmpty())
+			Expect(cong.spuriousCongestionEvents).To(Equal(1))
+			Expect(handler.reorderingFraction).To(Equal(2 * timeReorderingFraction))
+		})
+
+		It("detects spurious RTOs", func() {
+			handler.tlpCount = maxTailLossProbes
+			handler.OnAlarm()
+			Expect(cong.onRetransmissionTimeout).To(BeTrue())
+			Expect(handler.retransmissionQueue).To(HaveLen(2))
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1}, protocol.InitialPathID, 1, time.Now())
+			Expect(err).NotTo(HaveOccurred())
+			spuriousLosses, spuriousRTOs := handler.GetSpuriousRetransmissionStatistics()
+			Expect(spuriousLosses).To(BeZero())
+			Expect(spuriousRTOs).To(BeEquivalentTo(1))
+			Expect(handler.retransmissionQueue).To(HaveLen(1))
+			Expect(handler.retransmissionQueue[0].PacketNumber).To(Equal(protocol.PacketNumber(2)))
+			Expect(cong.spuriousCongestionEvents).To(Equal(1))
+		})
+
+		It("doesn't undo the congestion window reduction if other packets are still missing", func() {
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 3, LowestAcked: 3}, protocol.InitialPathID, 1, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			handler.packetHistory.Front().Value.SendTime = time.Now().Add(-2 * time.Hour)
+			handler.packetHistory.Front().Next().Value.SendTime = time.Now().Add(-2 * time.Hour)
+			handler.OnAlarm()
+			Expect(cong.packetsLost).To(HaveLen(2))
+			ack := &wire.AckFrame{
+				LargestAcked: 3,
+				LowestAcked:  1,
+				AckRanges: []wire.AckRange{
+					{First: 3, Last: 3},
+					{First: 1, Last: 1},
+				},
+			}
+			err = handler.ReceivedAck(ack, protocol.InitialPathID, 2, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			spuriousLosses, _ := handler.GetSpuriousRetransmissionStatistics()
+			Expect(spuriousLosses).To(BeEquivalentTo(1))
+			Expect(cong.spuriousCongestionEvents).To(BeZero())
+		})
+
+		It("only undoes losses of the current loss epoch", func() {
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 3, LowestAcked: 2}, protocol.InitialPathID, 1, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			handler.packetHistory.Front().Value.SendTime = time.Now().Add(-2 * time.Hour)
+			handler.OnAlarm()
+			Expect(cong.packetsLost).To(HaveLen(1))
+			// a packet sent after the first loss was detected is lost, too
+			for i := protocol.PacketNumber(4); i <= 6; i++ {
+				err = handler.SentPacket(retransmittablePacket(i))
+				Expect(err).NotTo(HaveOccurred())
+			}
+			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 6, LowestAcked: 5}, protocol.InitialPathID, 2, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			handler.packetHistory.Front().Value.SendTime = time.Now().Add(-2 * time.Hour)
+			handler.OnAlarm()
+			Expect(cong.packetsLost).To(HaveLen(2))
+			Expect(handler.lostPackets).To(HaveLen(1))
+			// a late ACK for the packet lost in the first epoch
+			ack := &wire.AckFrame{
+				LargestAcked: 6,
+				LowestAcked:  1,
+				AckRanges: []wire.AckRange{
+					{First: 5, Last: 6},
+					{First: 1, Last: 3},
+				},
+			}
+			err = handler.ReceivedAck(ack, protocol.InitialPathID, 3, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			spuriousLosses, _ := handler.GetSpuriousRetransmissionStatistics()
+			Expect(spuriousLosses).To(BeZero())
+			Expect(cong.spuriousCongestionEvents).To(BeZero())
+		})
+
+		It("doesn't undo the congestion window reduction for an invalid ACK", func() {
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 3, LowestAcked: 2}, protocol.InitialPathID, 1, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			handler.packetHistory.Front().Value.SendTime = time.Now().Add(-2 * time.Hour)
+			handler.OnAlarm()
+			Expect(cong.packetsLost).To(HaveLen(1))
+			handler.skippedPackets = []protocol.PacketNumber{2}
+			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 3, LowestAcked: 1}, protocol.InitialPathID, 2, time.Now().Add(time.Hour))
+			Expect(err).To(MatchError(ErrAckForSkippedPacket))
+			Expect(handler.lostPackets).To(HaveLen(1))
+			Expect(cong.spuriousCongestionEvents).To(BeZero())
+		})
+	})
+
+	Context("reinjection", func() {
+		It("returns the packets in flight sent before a given time", func() {
+			for i := protocol.PacketNumber(1); i <= 3; i++ {
+				p := retransmittablePacket(i)
+				p.EncryptionLevel = protocol.EncryptionForwardSecure
+				err := handler.SentPacket(p)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			handler.packetHistory.Front().Value.SendTime = time.Now().Add(-time.Second)
+			packets := handler.GetPacketsForReinjection(time.Now().Add(-time.Millisecond))
+			Expect(packets).To(HaveLen(1))
+			Expect(packets[0].PacketNumber).To(Equal(protocol.PacketNumber(1)))
+			Expect(packets[0].Reinjected).To(BeTrue())
+			// the packets stay in flight
+			Expect(handler.packetHistory.Len()).To(Equal(3))
+			Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(3)))
+			// packets are only reinjected once
+			packets = handler.GetPacketsForReinjection(time.Now().Add(time.Hour))
+			Expect(packets).To(HaveLen(2))
+			Expect(packets[0].PacketNumber).To(Equal(protocol.PacketNumber(2)))
+			Expect(packets[1].PacketNumber).To(Equal(protocol.PacketNumber(3)))
+		})
+
+		It("doesn't reinject handshake packets", func() {
+			p := retransmittablePacket(1)
+			p.EncryptionLevel = protocol.EncryptionSecure
+			err := handler.SentPacket(p)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(handler.GetPacketsForReinjection(time.Now().Add(time.Hour))).To(BeEmpty())
+		})
+
+		It("keeps the reinjected flag when the packet is queued for retransmission", func() {
+			p := retransmittablePacket(1)
+			p.EncryptionLevel = protocol.EncryptionForwardSecure
+			err := handler.SentPacket(p)
+			Expect(err).NotTo(HaveOccurred())
+			handler.GetPacketsForReinjection(time.Now().Add(time.Hour))
+			handler.tlpCount = maxTailLossProbes
+			handler.OnAlarm()
+			Expect(handler.DequeuePacketForRetransmission().Reinjected).To(BeTrue())
+		})
+	})
+
+	Context("rejected keys", func() {
+		It("dequeues the packets sent with an encryption level", func() {
+			for i := protocol.PacketNumber(1); i <= 4; i++ {
+				p := retransmittablePacket(i)
+				p.EncryptionLevel = protocol.EncryptionSecure
+				if i == 2 {
+					p.EncryptionLevel = protocol.EncryptionUnencrypted
+				}
+				err := handler.SentPacket(p)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			handler.queuePacketForRetransmission(handler.packetHistory.Back())
+			packets := handler.DequeuePacketsWithEncryptionLevel(protocol.EncryptionSecure)
+			Expect(packets).To(HaveLen(3))
+			Expect(packets[0].PacketNumber).To(Equal(protocol.PacketNumber(4)))
+			Expect(packets[1].PacketNumber).To(Equal(protocol.PacketNumber(1)))
+			Expect(packets[2].PacketNumber).To(Equal(protocol.PacketNumber(3)))
+			Expect(handler.packetHistory.Len()).To(Equal(1))
+			Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(1)))
+			Expect(handler.DequeuePacketForRetransmission()).To(BeNil())
+		})
+	})
+
 	Context("RTO retransmission", func() {
 		It("queues two packets if RTO expires", func() {
 			err := handler.SentPacket(retransmittablePacket(1))
@@ -837,4 +1181,134 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(handler.rtoCount).To(BeEquivalentTo(1))
 		})
 	})
+
+	Context("statistics", func() {
+		BeforeEach(func() {
+			for i := protocol.PacketNumber(1); i <= 3; i++ {
+				p := retransmittablePacket(i)
+				p.Length = protocol.ByteCount(i) * 100
+				err := handler.SentPacket(p)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		It("counts the sent packets and bytes", func() {
+			stats := handler.GetStatistics()
+			Expect(stats.Packets).To(BeEquivalentTo(3))
+			Expect(stats.Bytes).To(Equal(protocol.ByteCount(600)))
+			Expect(stats.BytesInFlight).To(Equal(protocol.ByteCount(600)))
+			Expect(stats.CongestionWindow).To(Equal(handler.congestion.GetCongestionWindow()))
+			Expect(stats.Retransmissions).To(BeZero())
+			Expect(stats.Losses).To(BeZero())
+			Expect(stats.BandwidthEstimate).To(BeZero())
+		})
+
+		It("counts the lost and the retransmitted packets and bytes", func() {
+			handler.packetHistory.Front().Value.SendTime = time.Now().Add(-2 * time.Hour)
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2, LowestAcked: 2}, protocol.InitialPathID, 1, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			stats := handler.GetStatistics()
+			Expect(stats.Losses).To(BeEquivalentTo(1))
+			Expect(stats.LostBytes).To(Equal(protocol.ByteCount(100)))
+			Expect(stats.Retransmissions).To(BeZero())
+			Expect(handler.DequeuePacketForRetransmission()).ToNot(BeNil())
+			stats = handler.GetStatistics()
+			Expect(stats.Retransmissions).To(BeEquivalentTo(1))
+			Expect(stats.RetransmittedBytes).To(Equal(protocol.ByteCount(100)))
+			Expect(stats.BytesInFlight).To(Equal(protocol.ByteCount(300)))
+		})
+
+		It("estimates the bandwidth once the RTT was measured", func() {
+			handler.rttStats.UpdateRTT(100*time.Millisecond, 0, time.Now())
+			stats := handler.GetStatistics()
+			Expect(stats.BandwidthEstimate).To(Equal(congestion.BandwidthFromDelta(stats.CongestionWindow, 100*time.Millisecond)))
+		})
+	})
+
+	Context("tracing", func() {
+		var (
+			mockCtrl *gomock.Controller
+			tracer   *mocks.MockConnectionTracer
+		)
+
+		BeforeEach(func() {
+			mockCtrl = gomock.NewController(GinkgoT())
+			tracer = mocks.NewMockConnectionTracer(mockCtrl)
+			handler = NewSentPacketHandler(&congestion.RTTStats{}, nil, nil, 0, 3, tracer).(*sentPacketHandler)
+			for i := protocol.PacketNumber(1); i <= 3; i++ {
+				err := handler.SentPacket(retransmittablePacket(i))
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		AfterEach(func() {
+			mockCtrl.Finish()
+		})
+
+		It("records the metrics when an ACK updates the RTT", func() {
+			tracer.EXPECT().UpdatedRTT(protocol.PathID(3), handler.rttStats)
+			tracer.EXPECT().UpdatedMetrics(protocol.PathID(3), handler.rttStats, gomock.Any(), protocol.ByteCount(2)).Do(
+				func(_ protocol.PathID, rttStats *congestion.RTTStats, _, _ protocol.ByteCount) {
+					Expect(rttStats.LatestRTT()).To(BeNumerically("~", time.Hour, time.Minute))
+				},
+			)
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1}, protocol.InitialPathID, 1, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("doesn't record the metrics again if they didn't change", func() {
+			tracer.EXPECT().UpdatedRTT(protocol.PathID(3), gomock.Any())
+			tracer.EXPECT().UpdatedMetrics(protocol.PathID(3), gomock.Any(), gomock.Any(), gomock.Any())
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1}, protocol.InitialPathID, 1, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			handler.traceMetrics()
+		})
+
+		It("records an RTT sample for every ACK that updates the RTT", func() {
+			tracer.EXPECT().UpdatedRTT(protocol.PathID(3), handler.rttStats).Times(2)
+			tracer.EXPECT().UpdatedMetrics(protocol.PathID(3), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1}, protocol.InitialPathID, 1, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2, LowestAcked: 1}, protocol.InitialPathID, 2, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			// a repeated ACK doesn't update the RTT
+			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2, LowestAcked: 1}, protocol.InitialPathID, 3, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("records packets declared lost by loss detection", func() {
+			gomock.InOrder(
+				tracer.EXPECT().UpdatedRTT(protocol.PathID(3), gomock.Any()),
+				tracer.EXPECT().LostPacket(protocol.PathID(3), protocol.PacketNumber(1), logging.PacketLossTimeThreshold),
+				tracer.EXPECT().UpdatedMetrics(protocol.PathID(3), gomock.Any(), gomock.Any(), gomock.Any()),
+			)
+			handler.packetHistory.Front().Value.SendTime = time.Now().Add(-2 * time.Hour)
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2, LowestAcked: 2}, protocol.InitialPathID, 1, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("records packets declared lost by an RTO", func() {
+			tracer.EXPECT().LostPacket(protocol.PathID(3), protocol.PacketNumber(1), logging.PacketLossRetransmissionTimeout)
+			tracer.EXPECT().LostPacket(protocol.PathID(3), protocol.PacketNumber(2), logging.PacketLossRetransmissionTimeout)
+			tracer.EXPECT().UpdatedMetrics(protocol.PathID(3), gomock.Any(), gomock.Any(), gomock.Any())
+			handler.tlpCount = maxTailLossProbes
+			handler.OnAlarm()
+		})
+
+		It("records the packets in flight on a closed path as lost", func() {
+			// packet 3 was sent before the other packets, so that the ACK doesn't make loss detection declare them lost
+			handler.packetHistory.Back().Value.SendTime = handler.packetHistory.Front().Value.SendTime.Add(-time.Millisecond)
+			tracer.EXPECT().UpdatedRTT(protocol.PathID(3), gomock.Any())
+			tracer.EXPECT().UpdatedMetrics(protocol.PathID(3), gomock.Any(), gomock.Any(), gomock.Any())
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 3, LowestAcked: 3}, protocol.InitialPathID, 1, time.Now())
+			Expect(err).NotTo(HaveOccurred())
+			gomock.InOrder(
+				tracer.EXPECT().LostPacket(protocol.PathID(3), protocol.PacketNumber(1), logging.PacketLossPathClosed),
+				tracer.EXPECT().LostPacket(protocol.PathID(3), protocol.PacketNumber(2), logging.PacketLossPathClosed),
+				// the losses reduced the congestion window
+				tracer.EXPECT().UpdatedMetrics(protocol.PathID(3), gomock.Any(), gomock.Any(), gomock.Any()),
+			)
+			handler.SetInflightAsLost()
+		})
+	})
 })
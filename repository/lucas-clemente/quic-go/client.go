@@ -33,6 +33,9 @@ type client struct {
 	connectionID protocol.ConnectionID
 	version      protocol.VersionNumber
 
+	// earlyData is set if the session may send data with 0-RTT keys
+	earlyData bool
+
 	closeListen chan error
 
 	session packetHandler
@@ -52,7 +55,7 @@ func DialAddr(addr string, tlsConf *tls.Config, config *Config) (Session, error)
 		return nil, err
 	}
 	// Create the pconnManager here. It will be used to manage UDP connections
-	pconnMgr := &pconnManager{perspective: protocol.PerspectiveClient}
+	pconnMgr := newPconnManager(protocol.PerspectiveClient, config)
 	err = pconnMgr.setup(nil, nil)
 	if err != nil {
 		return nil, err
@@ -72,7 +75,7 @@ func DialAddrNonFWSecure(
 		return nil, err
 	}
 	// Create the pconnManager here. It will be used to manage UDP connections
-	pconnMgr := &pconnManager{perspective: protocol.PerspectiveClient}
+	pconnMgr := newPconnManager(protocol.PerspectiveClient, config)
 	err = pconnMgr.setup(nil, nil)
 	if err != nil {
 		return nil, err
@@ -80,6 +83,46 @@ func DialAddrNonFWSecure(
 	return DialNonFWSecure(pconnMgr.pconnAny, udpAddr, addr, tlsConf, config, pconnMgr)
 }
 
+// DialAddrEarly establishes a new QUIC connection to a server, that can be used before the handshake completes.
+// The hostname for SNI is taken from the given address.
+func DialAddrEarly(
+	addr string,
+	tlsConf *tls.Config,
+	config *Config,
+) (EarlySession, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// Create the pconnManager here. It will be used to manage UDP connections
+	pconnMgr := newPconnManager(protocol.PerspectiveClient, config)
+	err = pconnMgr.setup(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return DialEarly(pconnMgr.pconnAny, udpAddr, addr, tlsConf, config, pconnMgr)
+}
+
+// DialEarly establishes a new QUIC connection to a server using a net.PacketConn.
+// If the handshake data of the server was cached (see Config.CacheHandshake), it returns before the server responded,
+// and data written to the session is sent with 0-RTT keys. Otherwise, it returns as soon as the connection is secure.
+// 0-RTT is not supported by the TLS versions, if one of them is negotiated, it returns as soon as the handshake completes.
+// The host parameter is used for SNI.
+func DialEarly(
+	pconn net.PacketConn,
+	remoteAddr net.Addr,
+	host string,
+	tlsConf *tls.Config,
+	config *Config,
+	pconnMgrArg *pconnManager,
+) (EarlySession, error) {
+	sess, err := dial(pconn, remoteAddr, host, tlsConf, config, pconnMgrArg, true)
+	if err != nil {
+		return nil, err
+	}
+	return sess.(EarlySession), nil
+}
+
 // DialNonFWSecure establishes a new non-forward-secure QUIC connection to a server using a net.PacketConn.
 // The host parameter is used for SNI.
 func DialNonFWSecure(
@@ -89,6 +132,18 @@ func DialNonFWSecure(
 	tlsConf *tls.Config,
 	config *Config,
 	pconnMgrArg *pconnManager,
+) (NonFWSession, error) {
+	return dial(pconn, remoteAddr, host, tlsConf, config, pconnMgrArg, false)
+}
+
+func dial(
+	pconn net.PacketConn,
+	remoteAddr net.Addr,
+	host string,
+	tlsConf *tls.Config,
+	config *Config,
+	pconnMgrArg *pconnManager,
+	earlyData bool,
 ) (NonFWSession, error) {
 	connID, err := generateConnectionID()
 	if err != nil {
@@ -110,7 +165,7 @@ func DialNonFWSecure(
 	var pconnMgr *pconnManager
 
 	if pconnMgrArg == nil {
-		pconnMgr = &pconnManager{perspective: protocol.PerspectiveClient}
+		pconnMgr = newPconnManager(protocol.PerspectiveClient, config)
 		err := pconnMgr.setup(pconn, nil)
 		if err != nil {
 			return nil, err
@@ -128,6 +183,7 @@ func DialNonFWSecure(
 		config:                 clientConfig,
 		version:                clientConfig.Versions[0],
 		versionNegotiationChan: make(chan struct{}),
+		earlyData:              earlyData,
 	}
 	// It's the responsibility of the client to give a proper connection
 	conn := &conn{pconn: c.pconnMgr.pconnAny, currentAddr: remoteAddr}
@@ -180,13 +236,17 @@ func populateClientConfig(config *Config) *Config {
 		idleTimeout = config.IdleTimeout
 	}
 
-	maxReceiveStreamFlowControlWindow := config.MaxReceiveStreamFlowControlWindow
-	if maxReceiveStreamFlowControlWindow == 0 {
-		maxReceiveStreamFlowControlWindow = protocol.DefaultMaxReceiveStreamFlowControlWindowClient
+	var handshakeCache HandshakeCache
+	if config.CacheHandshake {
+		handshakeCache = config.HandshakeCache
+		if handshakeCache == nil {
+			handshakeCache = getDefaultHandshakeCache()
+		}
 	}
-	maxReceiveConnectionFlowControlWindow := config.MaxReceiveConnectionFlowControlWindow
-	if maxReceiveConnectionFlowControlWindow == 0 {
-		maxReceiveConnectionFlowControlWindow = protocol.DefaultMaxReceiveConnectionFlowControlWindowClient
+
+	unreliableSendQueueSize := config.UnreliableSendQueueSize
+	if unreliableSendQueueSize == 0 && config.UnreliableSendQueuePolicy != SendQueueBlock {
+		unreliableSendQueueSize = protocol.DefaultUnreliableSendQueueSize
 	}
 
 	return &Config{
@@ -194,11 +254,22 @@ func populateClientConfig(config *Config) *Config {
 		HandshakeTimeout:                      handshakeTimeout,
 		IdleTimeout:                           idleTimeout,
 		RequestConnectionIDTruncation:         config.RequestConnectionIDTruncation,
-		MaxReceiveStreamFlowControlWindow:     maxReceiveStreamFlowControlWindow,
-		MaxReceiveConnectionFlowControlWindow: maxReceiveConnectionFlowControlWindow,
+		MaxReceiveStreamFlowControlWindow:     config.MaxReceiveStreamFlowControlWindow,
+		MaxReceiveConnectionFlowControlWindow: config.MaxReceiveConnectionFlowControlWindow,
 		KeepAlive:      config.KeepAlive,
 		CacheHandshake: config.CacheHandshake,
+		HandshakeCache: handshakeCache,
 		CreatePaths:    config.CreatePaths,
+		ReorderingTolerance: config.ReorderingTolerance,
+		AckPathPolicy:       config.AckPathPolicy,
+		AckSendDelay:        config.AckSendDelay,
+		RetransmittablePacketsBeforeAck: config.RetransmittablePacketsBeforeAck,
+		StreamSendBufferSize:            config.StreamSendBufferSize,
+		UnreliableSendQueueSize:         unreliableSendQueueSize,
+		UnreliableSendQueuePolicy:       config.UnreliableSendQueuePolicy,
+		Tracer:                          config.Tracer,
+		KeyLogWriter:                    config.KeyLogWriter,
+		PacketCapture:                   config.PacketCapture,
 	}
 }
 
@@ -228,6 +299,23 @@ func (c *client) establishSecureConnection(conn connection) error {
 		}
 	}()
 
+	// When sending data with 0-RTT keys, the session can be used before the server responded.
+	// If the server doesn't support the QUIC version, the session is replaced, and we wait for the new one.
+	if c.earlyData {
+		c.mutex.Lock()
+		handshakeChan := c.handshakeChan
+		c.mutex.Unlock()
+		select {
+		case <-errorChan:
+			return runErr
+		case <-c.versionNegotiationChan:
+		case ev := <-handshakeChan:
+			if ev.err != errCloseSessionForNewVersion {
+				return c.checkHandshakeEvent(ev)
+			}
+		}
+	}
+
 	// wait until the server accepts the QUIC version (or an error occurs)
 	select {
 	case <-errorChan:
@@ -239,14 +327,18 @@ func (c *client) establishSecureConnection(conn connection) error {
 	case <-errorChan:
 		return runErr
 	case ev := <-c.handshakeChan:
-		if ev.err != nil {
-			return ev.err
-		}
-		if !c.version.UsesTLS() && ev.encLevel != protocol.EncryptionSecure {
-			return fmt.Errorf("Client BUG: Expected encryption level to be secure, was %s", ev.encLevel)
-		}
-		return nil
+		return c.checkHandshakeEvent(ev)
+	}
+}
+
+func (c *client) checkHandshakeEvent(ev handshakeEvent) error {
+	if ev.err != nil {
+		return ev.err
+	}
+	if !c.version.UsesTLS() && ev.encLevel != protocol.EncryptionSecure {
+		return fmt.Errorf("Client BUG: Expected encryption level to be secure, was %s", ev.encLevel)
 	}
+	return nil
 }
 
 // Listen listens
@@ -401,6 +493,7 @@ func (c *client) createNewSession(negotiatedVersions []protocol.VersionNumber, c
 		c.tlsConf,
 		c.config,
 		negotiatedVersions,
+		c.earlyData,
 	)
 	return err
 }
@@ -25,7 +25,7 @@ var _ = Describe("Client", func() {
 		addr       net.Addr
 		pconnMgr   *pconnManager
 
-		originalClientSessConstructor func(conn connection, pconnMgr *pconnManager, createPaths bool, hostname string, v protocol.VersionNumber, connectionID protocol.ConnectionID, tlsConf *tls.Config, config *Config, negotiatedVersions []protocol.VersionNumber) (packetHandler, <-chan handshakeEvent, error)
+		originalClientSessConstructor func(conn connection, pconnMgr *pconnManager, createPaths bool, hostname string, v protocol.VersionNumber, connectionID protocol.ConnectionID, tlsConf *tls.Config, config *Config, negotiatedVersions []protocol.VersionNumber, earlyData bool) (packetHandler, <-chan handshakeEvent, error)
 	)
 
 	// generate a packet sent by the server that accepts the QUIC version suggested by the client
@@ -92,6 +92,7 @@ var _ = Describe("Client", func() {
 				_ *tls.Config,
 				_ *Config,
 				_ []protocol.VersionNumber,
+				_ bool,
 			) (packetHandler, <-chan handshakeEvent, error) {
 				Expect(conn.Write([]byte("fake CHLO"))).To(Succeed())
 				return sess, sess.handshakeChan, nil
@@ -122,6 +123,38 @@ var _ = Describe("Client", func() {
 			close(done)
 		})
 
+		It("dials early, before the server responded", func(done Done) {
+			var earlyData bool
+			newClientSession = func(
+				_ connection,
+				_ *pconnManager,
+				_ bool,
+				_ string,
+				_ protocol.VersionNumber,
+				_ protocol.ConnectionID,
+				_ *tls.Config,
+				_ *Config,
+				_ []protocol.VersionNumber,
+				earlyDataP bool,
+			) (packetHandler, <-chan handshakeEvent, error) {
+				earlyData = earlyDataP
+				return sess, sess.handshakeChan, nil
+			}
+			dialed := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				s, err := DialEarly(packetConn, addr, "quic.clemente.io:1337", nil, config, pconnMgr)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(s).ToNot(BeNil())
+				close(dialed)
+			}()
+			Consistently(dialed).ShouldNot(BeClosed())
+			sess.handshakeChan <- handshakeEvent{encLevel: protocol.EncryptionSecure}
+			Eventually(dialed).Should(BeClosed())
+			Expect(earlyData).To(BeTrue())
+			close(done)
+		})
+
 		It("dials a non-forward-secure address", func(done Done) {
 			serverAddr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
 			Expect(err).ToNot(HaveOccurred())
@@ -183,6 +216,7 @@ var _ = Describe("Client", func() {
 				_ *tls.Config,
 				_ *Config,
 				_ []protocol.VersionNumber,
+				_ bool,
 			) (packetHandler, <-chan handshakeEvent, error) {
 				remoteAddrChan <- conn.RemoteAddr().String()
 				return sess, nil, nil
@@ -211,6 +245,7 @@ var _ = Describe("Client", func() {
 				_ *tls.Config,
 				_ *Config,
 				_ []protocol.VersionNumber,
+				_ bool,
 			) (packetHandler, <-chan handshakeEvent, error) {
 				hostnameChan <- h
 				return sess, nil, nil
@@ -280,6 +315,27 @@ var _ = Describe("Client", func() {
 			Expect(c.HandshakeTimeout).To(Equal(protocol.DefaultHandshakeTimeout))
 			Expect(c.IdleTimeout).To(Equal(protocol.DefaultIdleTimeout))
 			Expect(c.RequestConnectionIDTruncation).To(BeFalse())
+			Expect(c.UnreliableSendQueueSize).To(BeZero())
+			Expect(c.HandshakeCache).To(BeNil())
+		})
+
+		It("uses the shared handshake cache if caching is enabled, but no cache is set", func() {
+			c := populateClientConfig(&Config{CacheHandshake: true})
+			Expect(c.HandshakeCache).ToNot(BeNil())
+			Expect(c.HandshakeCache).To(BeIdenticalTo(populateClientConfig(&Config{CacheHandshake: true}).HandshakeCache))
+			cache, err := NewMemoryHandshakeCache(1, time.Minute)
+			Expect(err).ToNot(HaveOccurred())
+			c = populateClientConfig(&Config{CacheHandshake: true, HandshakeCache: cache})
+			Expect(c.HandshakeCache).To(BeIdenticalTo(cache))
+			c = populateClientConfig(&Config{HandshakeCache: cache})
+			Expect(c.HandshakeCache).To(BeNil())
+		})
+
+		It("uses the default send queue size for unreliable streams if a drop policy is set", func() {
+			c := populateClientConfig(&Config{UnreliableSendQueuePolicy: SendQueueDropOldest})
+			Expect(c.UnreliableSendQueueSize).To(Equal(uint64(protocol.DefaultUnreliableSendQueueSize)))
+			c = populateClientConfig(&Config{UnreliableSendQueuePolicy: SendQueueDropNewest, UnreliableSendQueueSize: 1000})
+			Expect(c.UnreliableSendQueueSize).To(Equal(uint64(1000)))
 		})
 
 		It("errors when receiving an error from the connection", func(done Done) {
@@ -302,6 +358,7 @@ var _ = Describe("Client", func() {
 				_ *tls.Config,
 				_ *Config,
 				_ []protocol.VersionNumber,
+				_ bool,
 			) (packetHandler, <-chan handshakeEvent, error) {
 				return nil, nil, testErr
 			}
@@ -345,6 +402,7 @@ var _ = Describe("Client", func() {
 					_ *tls.Config,
 					_ *Config,
 					negotiatedVersionsP []protocol.VersionNumber,
+					_ bool,
 				) (packetHandler, <-chan handshakeEvent, error) {
 					negotiatedVersions = negotiatedVersionsP
 					// make the server accept the new version
@@ -394,6 +452,7 @@ var _ = Describe("Client", func() {
 					_ *tls.Config,
 					_ *Config,
 					negotiatedVersionsP []protocol.VersionNumber,
+					_ bool,
 				) (packetHandler, <-chan handshakeEvent, error) {
 					atomic.AddUint32(&sessionCounter, 1)
 					return sess, nil, nil
@@ -497,6 +556,7 @@ var _ = Describe("Client", func() {
 			_ *tls.Config,
 			configP *Config,
 			_ []protocol.VersionNumber,
+			_ bool,
 		) (packetHandler, <-chan handshakeEvent, error) {
 			cconn = connP
 			pconnMgrIn = pconnMgrP
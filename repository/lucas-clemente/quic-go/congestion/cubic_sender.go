@@ -16,6 +16,7 @@ const (
 type cubicSender struct {
 	hybridSlowStart HybridSlowStart
 	prr             PrrSender
+	cwndValidator   cwndValidator
 	rttStats        *RTTStats
 	stats           connectionStats
 	cubic           *Cubic
@@ -41,6 +42,17 @@ type cubicSender struct {
 	// Used for stats collection of slowstartPacketsLost
 	lastCutbackExitedSlowstart bool
 
+	// Whether the sender was application-limited the last time it ran out of data
+	appLimited bool
+
+	// State before the last reduction, restored if the losses that caused it turn out to be spurious.
+	// nil if there is nothing to undo.
+	priorState *cubicSenderState
+
+	// Track the largest packet sent while application-limited.
+	// ACKs for these packets must not make the congestion window grow.
+	largestSentWhileAppLimited protocol.PacketNumber
+
 	// When true, exit slow start with large cutback of congestion window.
 	slowStartLargeReduction bool
 
@@ -60,6 +72,18 @@ type cubicSender struct {
 	initialMaxCongestionWindow protocol.PacketNumber
 }
 
+// cubicSenderState is a snapshot of the congestion state taken before a window reduction
+type cubicSenderState struct {
+	congestionWindow           protocol.PacketNumber
+	slowstartThreshold         protocol.PacketNumber
+	congestionWindowCount      protocol.ByteCount
+	lastCutbackExitedSlowstart bool
+	cubic                      Cubic
+	hybridSlowStart            HybridSlowStart
+	// The reduction can only be undone until a packet sent after it is acknowledged
+	largestSentAtReduction protocol.PacketNumber
+}
+
 // NewCubicSender makes a new cubic sender
 func NewCubicSender(clock Clock, rttStats *RTTStats, reno bool, initialCongestionWindow, initialMaxCongestionWindow protocol.PacketNumber) SendAlgorithmWithDebugInfo {
 	return &cubicSender{
@@ -96,8 +120,22 @@ func (c *cubicSender) OnPacketSent(sentTime time.Time, bytesInFlight protocol.By
 		// PRR is used when in recovery.
 		c.prr.OnPacketSent(bytes)
 	}
+	idle := c.cwndValidator.OnPacketSent(sentTime, packetNumber)
+	if bytesInFlight <= bytes && c.RetransmissionDelay() != 0 && idle > c.RetransmissionDelay() {
+		c.restartAfterIdle()
+	} else if !c.InRecovery() && c.cwndValidator.NonValidatedPeriodExpired(sentTime, c.GetCongestionWindow()) {
+		c.reduceNonValidatedCwnd()
+	}
 	c.largestSentPacketNumber = packetNumber
 	c.hybridSlowStart.OnPacketSent(packetNumber)
+	if c.appLimited {
+		if c.isCwndLimited(bytesInFlight) {
+			// The application caught up, the window is used again
+			c.appLimited = false
+		} else {
+			c.largestSentWhileAppLimited = packetNumber
+		}
+	}
 	return true
 }
 
@@ -133,6 +171,11 @@ func (c *cubicSender) MaybeExitSlowStart() {
 
 func (c *cubicSender) OnPacketAcked(ackedPacketNumber protocol.PacketNumber, ackedBytes protocol.ByteCount, bytesInFlight protocol.ByteCount) {
 	c.largestAckedPacketNumber = utils.MaxPacketNumber(ackedPacketNumber, c.largestAckedPacketNumber)
+	c.cwndValidator.OnPacketAcked(ackedPacketNumber, ackedBytes)
+	if c.priorState != nil && ackedPacketNumber > c.priorState.largestSentAtReduction {
+		// A packet sent after the reduction was received, the reduction can't be undone anymore
+		c.priorState = nil
+	}
 	if c.InRecovery() {
 		// PRR is used when in recovery.
 		c.prr.OnPacketAcked(ackedBytes)
@@ -161,6 +204,8 @@ func (c *cubicSender) OnPacketLost(packetNumber protocol.PacketNumber, lostBytes
 		}
 		return
 	}
+	// A new loss epoch replaces the state saved for an earlier one
+	c.saveState()
 	c.lastCutbackExitedSlowstart = c.InSlowStart()
 	if c.InSlowStart() {
 		c.stats.slowstartPacketsLost++
@@ -204,6 +249,10 @@ func (c *cubicSender) maybeIncreaseCwnd(ackedPacketNumber protocol.PacketNumber,
 		c.cubic.OnApplicationLimited()
 		return
 	}
+	// Packets sent while application-limited didn't probe the window
+	if ackedPacketNumber <= c.largestSentWhileAppLimited {
+		return
+	}
 	if c.congestionWindow >= c.maxTCPCongestionWindow {
 		return
 	}
@@ -226,6 +275,71 @@ func (c *cubicSender) maybeIncreaseCwnd(ackedPacketNumber protocol.PacketNumber,
 	}
 }
 
+// OnApplicationLimited is called when the sender has no more data to send
+// although the congestion window would allow it
+func (c *cubicSender) OnApplicationLimited(bytesInFlight protocol.ByteCount) {
+	if c.isCwndLimited(bytesInFlight) {
+		return
+	}
+	c.appLimited = true
+	c.largestSentWhileAppLimited = c.largestSentPacketNumber
+	c.cubic.OnApplicationLimited()
+}
+
+// OnSpuriousCongestionEvent undoes the last congestion window reduction, since the losses that caused it were spurious
+func (c *cubicSender) OnSpuriousCongestionEvent() {
+	prior := c.priorState
+	if prior == nil {
+		return
+	}
+	if prior.congestionWindow > c.congestionWindow {
+		c.congestionWindow = prior.congestionWindow
+		c.congestionWindowCount = prior.congestionWindowCount
+	}
+	c.slowstartThreshold = utils.MaxPacketNumber(c.slowstartThreshold, prior.slowstartThreshold)
+	c.lastCutbackExitedSlowstart = prior.lastCutbackExitedSlowstart
+	*c.cubic = prior.cubic
+	c.hybridSlowStart = prior.hybridSlowStart
+	// Leave recovery
+	c.largestSentAtLastCutback = 0
+	c.priorState = nil
+}
+
+func (c *cubicSender) saveState() {
+	c.priorState = &cubicSenderState{
+		congestionWindow:           c.congestionWindow,
+		slowstartThreshold:         c.slowstartThreshold,
+		congestionWindowCount:      c.congestionWindowCount,
+		lastCutbackExitedSlowstart: c.lastCutbackExitedSlowstart,
+		cubic:                      *c.cubic,
+		hybridSlowStart:            c.hybridSlowStart,
+		largestSentAtReduction:     c.largestSentPacketNumber,
+	}
+}
+
+// restartAfterIdle restarts slow start from the initial window after the sender was idle for more than an RTO,
+// since the network conditions may have changed in between (RFC 5681, section 4.1)
+func (c *cubicSender) restartAfterIdle() {
+	if c.congestionWindow <= c.initialCongestionWindow {
+		return
+	}
+	c.slowstartThreshold = utils.MaxPacketNumber(c.slowstartThreshold, c.congestionWindow*3/4)
+	c.congestionWindow = c.initialCongestionWindow
+	c.congestionWindowCount = 0
+	c.hybridSlowStart.Restart()
+	c.cubic.Reset()
+}
+
+// reduceNonValidatedCwnd halves a congestion window that was not used during the whole non-validated period (RFC 7661, section 4.4)
+func (c *cubicSender) reduceNonValidatedCwnd() {
+	if c.congestionWindow <= c.initialCongestionWindow {
+		return
+	}
+	c.slowstartThreshold = utils.MaxPacketNumber(c.slowstartThreshold, c.congestionWindow*3/4)
+	c.congestionWindow = utils.MaxPacketNumber(c.congestionWindow/2, c.initialCongestionWindow)
+	c.congestionWindowCount = 0
+}
+
 func (c *cubicSender) isCwndLimited(bytesInFlight protocol.ByteCount) bool {
 	congestionWindow := c.GetCongestionWindow()
 	if bytesInFlight >= congestionWindow {
@@ -263,6 +377,10 @@ func (c *cubicSender) OnRetransmissionTimeout(packetsRetransmitted bool) {
 	if !packetsRetransmitted {
 		return
 	}
+	// Consecutive RTOs keep the state from before the first one
+	if c.priorState == nil || c.congestionWindow > c.priorState.congestionWindow {
+		c.saveState()
+	}
 	c.hybridSlowStart.Restart()
 	c.cubic.Reset()
 	c.slowstartThreshold = c.congestionWindow / 2
@@ -273,10 +391,12 @@ func (c *cubicSender) OnRetransmissionTimeout(packetsRetransmitted bool) {
 func (c *cubicSender) OnConnectionMigration() {
 	c.hybridSlowStart.Restart()
 	c.prr = PrrSender{}
+	c.cwndValidator.Reset()
 	c.largestSentPacketNumber = 0
 	c.largestAckedPacketNumber = 0
 	c.largestSentAtLastCutback = 0
 	c.lastCutbackExitedSlowstart = false
+	c.priorState = nil
 	c.cubic.Reset()
 	c.congestionWindowCount = 0
 	c.congestionWindow = c.initialCongestionWindow
@@ -119,6 +119,21 @@ var _ = Describe("Cubic Sender", func() {
 		Expect(bytesToSend).To(Equal(defaultWindowTCP + protocol.DefaultTCPMSS*2*2))
 	})
 
+	It("doesn't grow the window with packets sent while application limited", func() {
+		sender.OnPacketSent(clock.Now(), bytesInFlight, packetNumber, protocol.DefaultTCPMSS, true)
+		packetNumber++
+		bytesInFlight += protocol.DefaultTCPMSS
+		sender.OnApplicationLimited(bytesInFlight)
+		// Fill the window once the application has more data
+		SendAvailableSendWindow()
+		// The first 6 packets were sent before more than half of the window was used again
+		AckNPackets(6)
+		Expect(sender.GetCongestionWindow()).To(Equal(defaultWindowTCP))
+		SendAvailableSendWindow()
+		AckNPackets(1)
+		Expect(sender.GetCongestionWindow()).To(Equal(defaultWindowTCP + protocol.DefaultTCPMSS))
+	})
+
 	It("exponential slow start", func() {
 		const kNumberOfAcks = 20
 		// At startup make sure we can send.
@@ -780,6 +795,135 @@ var _ = Describe("Cubic Sender", func() {
 	//       sender.TimeUntilSend(QuicTime::Zero(), 4 * protocol.DefaultTCPMSS).IsZero());
 	// }
 
+	It("restarts slow start after an idle period", func() {
+		for i := 0; i < 2; i++ {
+			SendAvailableSendWindow()
+			AckNPackets(int(bytesInFlight / protocol.DefaultTCPMSS))
+		}
+		Expect(bytesInFlight).To(BeZero())
+		cwnd := sender.GetCongestionWindow()
+		Expect(cwnd).To(Equal(4 * defaultWindowTCP))
+
+		clock.Advance(time.Second)
+		SendAvailableSendWindow()
+		Expect(sender.GetCongestionWindow()).To(Equal(defaultWindowTCP))
+		Expect(protocol.ByteCount(sender.SlowstartThreshold()) * protocol.DefaultTCPMSS).To(BeNumerically(">=", cwnd*3/4))
+	})
+
+	It("doesn't restart slow start if the sender was not idle", func() {
+		SendAvailableSendWindow()
+		AckNPackets(int(bytesInFlight / protocol.DefaultTCPMSS))
+		cwnd := sender.GetCongestionWindow()
+		SendAvailableSendWindow()
+		Expect(sender.GetCongestionWindow()).To(Equal(cwnd))
+	})
+
+	It("halves a congestion window that is not used during the non-validated period", func() {
+		for i := 0; i < 2; i++ {
+			SendAvailableSendWindow()
+			AckNPackets(int(bytesInFlight / protocol.DefaultTCPMSS))
+		}
+		cwnd := sender.GetCongestionWindow()
+		// Only use a small part of the window
+		for i := 0; i < 2; i++ {
+			sender.OnPacketSent(clock.Now(), bytesInFlight, packetNumber, protocol.DefaultTCPMSS, true)
+			packetNumber++
+			bytesInFlight += protocol.DefaultTCPMSS
+		}
+		for clock.Now().Sub(time.Time{}) <= nonValidatedPeriod+10*time.Second {
+			sender.OnPacketSent(clock.Now(), bytesInFlight, packetNumber, protocol.DefaultTCPMSS, true)
+			packetNumber++
+			bytesInFlight += protocol.DefaultTCPMSS
+			ackedPacketNumber++
+			sender.OnPacketAcked(ackedPacketNumber, protocol.DefaultTCPMSS, bytesInFlight)
+			bytesInFlight -= protocol.DefaultTCPMSS
+			clock.Advance(time.Second)
+		}
+		Expect(sender.GetCongestionWindow()).To(Equal(cwnd / 2))
+	})
+
+	It("undoes the window reduction after a spurious loss", func() {
+		SendAvailableSendWindow()
+		AckNPackets(1)
+		SendAvailableSendWindow()
+		cwnd := sender.GetCongestionWindow()
+		LoseNPackets(1)
+		Expect(sender.GetCongestionWindow()).To(BeNumerically("<", cwnd))
+		Expect(sender.InRecovery()).To(BeTrue())
+		sender.OnSpuriousCongestionEvent()
+		Expect(sender.GetCongestionWindow()).To(Equal(cwnd))
+		Expect(sender.SlowstartThreshold()).To(Equal(MaxCongestionWindow))
+		Expect(sender.InRecovery()).To(BeFalse())
+	})
+
+	It("restores the Cubic state after a spurious loss", func() {
+		sender = NewCubicSender(&clock, rttStats, false /*cubic*/, initialCongestionWindowPackets, MaxCongestionWindow)
+		c := sender.(*cubicSender)
+		for i := 0; i < 5; i++ {
+			SendAvailableSendWindow()
+			AckNPackets(2)
+		}
+		LoseNPackets(1)
+		// grow the window in congestion avoidance, to set the Cubic epoch
+		AckNPackets(int(bytesInFlight / protocol.DefaultTCPMSS))
+		for i := 0; i < 5; i++ {
+			SendAvailableSendWindow()
+			AckNPackets(2)
+		}
+		SendAvailableSendWindow()
+		cwnd := sender.GetCongestionWindow()
+		ssthresh := sender.SlowstartThreshold()
+		cubic := *c.cubic
+		Expect(cubic.epoch).ToNot(BeZero())
+		LoseNPackets(1)
+		Expect(c.cubic.epoch).To(BeZero())
+		Expect(c.cubic.lastMaxCongestionWindow).ToNot(Equal(cubic.lastMaxCongestionWindow))
+		sender.OnSpuriousCongestionEvent()
+		Expect(sender.GetCongestionWindow()).To(Equal(cwnd))
+		Expect(sender.SlowstartThreshold()).To(Equal(ssthresh))
+		Expect(*c.cubic).To(Equal(cubic))
+	})
+
+	It("doesn't undo a reduction after a packet sent after it was acknowledged", func() {
+		SendAvailableSendWindow()
+		AckNPackets(1)
+		SendAvailableSendWindow()
+		LoseNPackets(1)
+		cwnd := sender.GetCongestionWindow()
+		sender.OnPacketSent(clock.Now(), bytesInFlight, packetNumber, protocol.DefaultTCPMSS, true)
+		sender.OnPacketAcked(packetNumber, protocol.DefaultTCPMSS, bytesInFlight)
+		sender.OnSpuriousCongestionEvent()
+		Expect(sender.GetCongestionWindow()).To(Equal(cwnd))
+	})
+
+	It("only undoes the reduction of the last loss epoch", func() {
+		SendAvailableSendWindow()
+		AckNPackets(1)
+		SendAvailableSendWindow()
+		LoseNPackets(1)
+		cwnd := sender.GetCongestionWindow()
+		// a packet sent after the first reduction is lost
+		sender.OnPacketSent(clock.Now(), bytesInFlight, packetNumber, protocol.DefaultTCPMSS, true)
+		LosePacket(packetNumber)
+		Expect(sender.GetCongestionWindow()).To(BeNumerically("<", cwnd))
+		sender.OnSpuriousCongestionEvent()
+		Expect(sender.GetCongestionWindow()).To(Equal(cwnd))
+	})
+
+	It("undoes the window reduction after a spurious RTO", func() {
+		SendAvailableSendWindow()
+		AckNPackets(int(bytesInFlight / protocol.DefaultTCPMSS))
+		cwnd := sender.GetCongestionWindow()
+		sender.OnRetransmissionTimeout(true)
+		Expect(sender.GetCongestionWindow()).To(Equal(2 * protocol.DefaultTCPMSS))
+		sender.OnSpuriousCongestionEvent()
+		Expect(sender.GetCongestionWindow()).To(Equal(cwnd))
+		// nothing left to undo
+		sender.OnRetransmissionTimeout(true)
+		sender.OnSpuriousCongestionEvent()
+		Expect(sender.GetCongestionWindow()).To(Equal(cwnd))
+	})
+
 	It("reset after connection migration", func() {
 		Expect(sender.GetCongestionWindow()).To(Equal(defaultWindowTCP))
 		Expect(sender.SlowstartThreshold()).To(Equal(MaxCongestionWindow))
@@ -0,0 +1,87 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+)
+
+// nonValidatedPeriod is the time a congestion window that is not validated is kept (RFC 7661, section 4.3)
+const nonValidatedPeriod = 5 * time.Minute
+
+// cwndValidator implements the congestion window validation of RFC 7661.
+// It measures how much of the congestion window is used per round trip (the pipeACK)
+// and how long the sender stayed idle.
+type cwndValidator struct {
+	// Bytes acknowledged during the last complete round trip
+	pipeACK protocol.ByteCount
+	// Whether a complete round trip was measured, the window is validated until then
+	hasPipeACKSample bool
+
+	// Bytes acknowledged during the current round trip
+	roundAckedBytes protocol.ByteCount
+	// The current round trip ends when a packet after this one is acked
+	roundEnd protocol.PacketNumber
+
+	largestSentPacketNumber protocol.PacketNumber
+	lastSentTime            time.Time
+
+	// Start of the non-validated phase, zero if the congestion window is validated
+	nonValidatedSince time.Time
+}
+
+// OnPacketSent returns the time the sender was idle before sending this packet
+func (v *cwndValidator) OnPacketSent(sentTime time.Time, packetNumber protocol.PacketNumber) time.Duration {
+	var idle time.Duration
+	if !v.lastSentTime.IsZero() {
+		idle = sentTime.Sub(v.lastSentTime)
+	}
+	v.lastSentTime = sentTime
+	v.largestSentPacketNumber = packetNumber
+	return idle
+}
+
+// OnPacketAcked updates the pipeACK measurement
+func (v *cwndValidator) OnPacketAcked(packetNumber protocol.PacketNumber, ackedBytes protocol.ByteCount) {
+	if v.roundEnd == 0 {
+		// The first round trip starts with the first ACK
+		v.roundEnd = v.largestSentPacketNumber
+		return
+	}
+	v.roundAckedBytes += ackedBytes
+	if packetNumber <= v.roundEnd {
+		return
+	}
+	v.pipeACK = v.roundAckedBytes
+	v.hasPipeACKSample = true
+	v.roundAckedBytes = 0
+	v.roundEnd = v.largestSentPacketNumber
+}
+
+// Validated returns true if at least half of the congestion window was used during the last round trip
+func (v *cwndValidator) Validated(congestionWindow protocol.ByteCount) bool {
+	return !v.hasPipeACKSample || v.pipeACK >= congestionWindow/2
+}
+
+// NonValidatedPeriodExpired returns true if the congestion window was not validated for longer than the non-validated period.
+// A new period starts after it expired.
+func (v *cwndValidator) NonValidatedPeriodExpired(now time.Time, congestionWindow protocol.ByteCount) bool {
+	if v.Validated(congestionWindow) {
+		v.nonValidatedSince = time.Time{}
+		return false
+	}
+	if v.nonValidatedSince.IsZero() {
+		v.nonValidatedSince = now
+		return false
+	}
+	if now.Sub(v.nonValidatedSince) <= nonValidatedPeriod {
+		return false
+	}
+	v.nonValidatedSince = now
+	return true
+}
+
+// Reset resets the measurements, e.g. after a connection migration
+func (v *cwndValidator) Reset() {
+	*v = cwndValidator{}
+}
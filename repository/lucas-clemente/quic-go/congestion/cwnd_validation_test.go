@@ -0,0 +1,68 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+var _ = Describe("Congestion window validation", func() {
+	var v cwndValidator
+
+	BeforeEach(func() {
+		v = cwndValidator{}
+	})
+
+	sendAndAck := func(from, to protocol.PacketNumber) {
+		for pn := from; pn <= to; pn++ {
+			v.OnPacketSent(time.Time{}, pn)
+		}
+		for pn := from; pn <= to; pn++ {
+			v.OnPacketAcked(pn, protocol.DefaultTCPMSS)
+		}
+	}
+
+	It("measures the idle time", func() {
+		now := time.Now()
+		Expect(v.OnPacketSent(now, 1)).To(BeZero())
+		Expect(v.OnPacketSent(now.Add(time.Second), 2)).To(Equal(time.Second))
+	})
+
+	It("is validated before a round trip was measured", func() {
+		Expect(v.Validated(100 * protocol.DefaultTCPMSS)).To(BeTrue())
+	})
+
+	It("measures the bytes acked per round trip", func() {
+		sendAndAck(1, 1)
+		sendAndAck(2, 5)
+		sendAndAck(6, 6)
+		Expect(v.pipeACK).To(Equal(4 * protocol.DefaultTCPMSS))
+		Expect(v.Validated(8 * protocol.DefaultTCPMSS)).To(BeTrue())
+		Expect(v.Validated(9 * protocol.DefaultTCPMSS)).To(BeFalse())
+	})
+
+	It("expires the non-validated period", func() {
+		sendAndAck(1, 1)
+		sendAndAck(2, 2)
+		sendAndAck(3, 3)
+		now := time.Now()
+		cwnd := 10 * protocol.DefaultTCPMSS
+		Expect(v.NonValidatedPeriodExpired(now, cwnd)).To(BeFalse())
+		Expect(v.NonValidatedPeriodExpired(now.Add(nonValidatedPeriod), cwnd)).To(BeFalse())
+		Expect(v.NonValidatedPeriodExpired(now.Add(nonValidatedPeriod+time.Second), cwnd)).To(BeTrue())
+		// a new period starts
+		Expect(v.NonValidatedPeriodExpired(now.Add(nonValidatedPeriod+2*time.Second), cwnd)).To(BeFalse())
+	})
+
+	It("restarts the non-validated period when the window is validated", func() {
+		sendAndAck(1, 1)
+		sendAndAck(2, 2)
+		sendAndAck(3, 3)
+		now := time.Now()
+		Expect(v.NonValidatedPeriodExpired(now, 10*protocol.DefaultTCPMSS)).To(BeFalse())
+		Expect(v.NonValidatedPeriodExpired(now.Add(time.Minute), protocol.DefaultTCPMSS)).To(BeFalse())
+		Expect(v.nonValidatedSince).To(BeZero())
+	})
+})
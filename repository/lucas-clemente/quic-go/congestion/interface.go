@@ -14,8 +14,10 @@ type SendAlgorithm interface {
 	MaybeExitSlowStart()
 	OnPacketAcked(number protocol.PacketNumber, ackedBytes protocol.ByteCount, bytesInFlight protocol.ByteCount)
 	OnPacketLost(number protocol.PacketNumber, lostBytes protocol.ByteCount, bytesInFlight protocol.ByteCount)
+	OnApplicationLimited(bytesInFlight protocol.ByteCount)
 	SetNumEmulatedConnections(n int)
 	OnRetransmissionTimeout(packetsRetransmitted bool)
+	OnSpuriousCongestionEvent()
 	OnConnectionMigration()
 	RetransmissionDelay() time.Duration
 	SmoothedRTT() time.Duration
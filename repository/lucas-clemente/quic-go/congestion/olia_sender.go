@@ -7,9 +7,22 @@ import (
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/utils"
 )
 
+// oliaSenderState is a snapshot of the congestion state taken before a window reduction
+type oliaSenderState struct {
+	congestionWindow           protocol.PacketNumber
+	slowstartThreshold         protocol.PacketNumber
+	congestionWindowCount      protocol.ByteCount
+	lastCutbackExitedSlowstart bool
+	olia                       Olia
+	hybridSlowStart            HybridSlowStart
+	// The reduction can only be undone until a packet sent after it is acknowledged
+	largestSentAtReduction protocol.PacketNumber
+}
+
 type OliaSender struct {
 	hybridSlowStart HybridSlowStart
 	prr             PrrSender
+	cwndValidator   cwndValidator
 	rttStats        *RTTStats
 	stats           connectionStats
 	olia            *Olia
@@ -34,6 +47,17 @@ type OliaSender struct {
 	// Used for stats collection of slowstartPacketsLost
 	lastCutbackExitedSlowstart bool
 
+	// Whether the sender was application-limited the last time it ran out of data
+	appLimited bool
+
+	// State before the last reduction, restored if the losses that caused it turn out to be spurious.
+	// nil if there is nothing to undo.
+	priorState *oliaSenderState
+
+	// Track the largest packet sent while application-limited.
+	// ACKs for these packets must not make the congestion window grow.
+	largestSentWhileAppLimited protocol.PacketNumber
+
 	// When true, texist slow start with large cutback of congestion window.
 	slowStartLargeReduction bool
 
@@ -88,8 +112,22 @@ func (o *OliaSender) OnPacketSent(sentTime time.Time, bytesInFlight protocol.Byt
 		// PRR is used when in recovery.
 		o.prr.OnPacketSent(bytes)
 	}
+	idle := o.cwndValidator.OnPacketSent(sentTime, packetNumber)
+	if bytesInFlight <= bytes && o.RetransmissionDelay() != 0 && idle > o.RetransmissionDelay() {
+		o.restartAfterIdle()
+	} else if !o.InRecovery() && o.cwndValidator.NonValidatedPeriodExpired(sentTime, o.GetCongestionWindow()) {
+		o.reduceNonValidatedCwnd()
+	}
 	o.largestSentPacketNumber = packetNumber
 	o.hybridSlowStart.OnPacketSent(packetNumber)
+	if o.appLimited {
+		if o.isCwndLimited(bytesInFlight) {
+			// The application caught up, the window is used again
+			o.appLimited = false
+		} else {
+			o.largestSentWhileAppLimited = packetNumber
+		}
+	}
 	return true
 }
 
@@ -108,7 +146,94 @@ func (o *OliaSender) ExitSlowstart() {
 func (o *OliaSender) MaybeExitSlowStart() {
 	if o.InSlowStart() && o.hybridSlowStart.ShouldExitSlowStart(o.rttStats.LatestRTT(), o.rttStats.MinRTT(), o.GetCongestionWindow()/protocol.DefaultTCPMSS) {
 		o.ExitSlowstart()
+		return
+	}
+	// Don't wait for a loss to leave slow start if another path can take the load
+	if o.InSlowStart() && o.QueueBuilding() && o.hasPathWithoutQueue() {
+		o.ExitSlowstart()
+	}
+}
+
+// QueueBuilding returns true if the delay-based signal of the RTTStats of this path
+// indicates that a queue is building, i.e. the path is getting congested before any loss
+func (o *OliaSender) QueueBuilding() bool {
+	return o.rttStats.QueueBuilding()
+}
+
+// hasPathWithoutQueue checks if there is another coupled path on which no queue is building
+func (o *OliaSender) hasPathWithoutQueue() bool {
+	for _, os := range o.oliaSenders {
+		if os != o && !os.QueueBuilding() {
+			return true
+		}
+	}
+	return false
+}
+
+// OnApplicationLimited is called when the sender has no more data to send
+// although the congestion window would allow it
+func (o *OliaSender) OnApplicationLimited(bytesInFlight protocol.ByteCount) {
+	if o.isCwndLimited(bytesInFlight) {
+		return
+	}
+	o.appLimited = true
+	o.largestSentWhileAppLimited = o.largestSentPacketNumber
+}
+
+// OnSpuriousCongestionEvent undoes the last congestion window reduction, since the losses that caused it were spurious
+func (o *OliaSender) OnSpuriousCongestionEvent() {
+	prior := o.priorState
+	if prior == nil {
+		return
+	}
+	if prior.congestionWindow > o.congestionWindow {
+		o.congestionWindow = prior.congestionWindow
+		o.congestionWindowCount = prior.congestionWindowCount
+	}
+	o.slowstartThreshold = utils.MaxPacketNumber(o.slowstartThreshold, prior.slowstartThreshold)
+	o.lastCutbackExitedSlowstart = prior.lastCutbackExitedSlowstart
+	// The bytes acked since then are still acked
+	olia := prior.olia
+	olia.loss3 = o.olia.loss3
+	*o.olia = olia
+	o.hybridSlowStart = prior.hybridSlowStart
+	// Leave recovery
+	o.largestSentAtLastCutback = 0
+	o.priorState = nil
+}
+
+func (o *OliaSender) saveState() {
+	o.priorState = &oliaSenderState{
+		congestionWindow:           o.congestionWindow,
+		slowstartThreshold:         o.slowstartThreshold,
+		congestionWindowCount:      o.congestionWindowCount,
+		lastCutbackExitedSlowstart: o.lastCutbackExitedSlowstart,
+		olia:                       *o.olia,
+		hybridSlowStart:            o.hybridSlowStart,
+		largestSentAtReduction:     o.largestSentPacketNumber,
+	}
+}
+
+// restartAfterIdle restarts slow start from the initial window after the sender was idle for more than an RTO,
+// since the network conditions may have changed in between (RFC 5681, section 4.1)
+func (o *OliaSender) restartAfterIdle() {
+	if o.congestionWindow <= o.initialCongestionWindow {
+		return
+	}
+	o.slowstartThreshold = utils.MaxPacketNumber(o.slowstartThreshold, o.congestionWindow*3/4)
+	o.congestionWindow = o.initialCongestionWindow
+	o.congestionWindowCount = 0
+	o.hybridSlowStart.Restart()
+}
+
+// reduceNonValidatedCwnd halves a congestion window that was not used during the whole non-validated period (RFC 7661, section 4.4)
+func (o *OliaSender) reduceNonValidatedCwnd() {
+	if o.congestionWindow <= o.initialCongestionWindow {
+		return
 	}
+	o.slowstartThreshold = utils.MaxPacketNumber(o.slowstartThreshold, o.congestionWindow*3/4)
+	o.congestionWindow = utils.MaxPacketNumber(o.congestionWindow/2, o.initialCongestionWindow)
+	o.congestionWindowCount = 0
 }
 
 func (o *OliaSender) isCwndLimited(bytesInFlight protocol.ByteCount) bool {
@@ -163,6 +288,10 @@ func (o *OliaSender) getEpsilon() {
 	// TODO: integrate this in the following loop - we just want to iterate once
 	maxCwnd := getMaxCwnd(o.oliaSenders)
 	for _, os := range o.oliaSenders {
+		// A path with a building queue is not a best path, even if it did not experience losses yet
+		if os.QueueBuilding() {
+			continue
+		}
 		tmpRTT = os.rttStats.SmoothedRTT() * os.rttStats.SmoothedRTT()
 		tmpBytes = os.olia.SmoothedBytesBetweenLosses()
 		if int64(tmpBytes) * bestRTT.Nanoseconds() >= int64(bestBytes) * tmpRTT.Nanoseconds() {
@@ -177,7 +306,7 @@ func (o *OliaSender) getEpsilon() {
 		tmpCwnd = os.congestionWindow
 		if tmpCwnd == maxCwnd {
 			M++
-		} else {
+		} else if !os.QueueBuilding() {
 			tmpRTT = os.rttStats.SmoothedRTT() * os.rttStats.SmoothedRTT()
 			tmpBytes = os.olia.SmoothedBytesBetweenLosses()
 			if int64(tmpBytes) * bestRTT.Nanoseconds() >= int64(bestBytes) * tmpRTT.Nanoseconds() {
@@ -196,7 +325,7 @@ func (o *OliaSender) getEpsilon() {
 			tmpBytes = os.olia.SmoothedBytesBetweenLosses()
 			tmpCwnd = os.congestionWindow
 
-			if tmpCwnd < maxCwnd && int64(tmpBytes) * bestRTT.Nanoseconds() >= int64(bestBytes) * tmpRTT.Nanoseconds() {
+			if tmpCwnd < maxCwnd && !os.QueueBuilding() && int64(tmpBytes) * bestRTT.Nanoseconds() >= int64(bestBytes) * tmpRTT.Nanoseconds() {
 				os.olia.epsilonNum = 1
 				os.olia.epsilonDen = uint32(len(o.oliaSenders)) * uint32(BNotM)
 			} else if tmpCwnd == maxCwnd {
@@ -216,6 +345,10 @@ func (o *OliaSender) maybeIncreaseCwnd(ackedPacketNumber protocol.PacketNumber,
 	if !o.isCwndLimited(bytesInFlight) {
 		return
 	}
+	// Packets sent while application-limited didn't probe the window
+	if ackedPacketNumber <= o.largestSentWhileAppLimited {
+		return
+	}
 	if o.congestionWindow >= o.maxTCPCongestionWindow {
 		return
 	}
@@ -224,6 +357,10 @@ func (o *OliaSender) maybeIncreaseCwnd(ackedPacketNumber protocol.PacketNumber,
 		o.congestionWindow++
 		return
 	} else {
+		// Shift the load to the other paths as long as the queue of this one is building
+		if o.QueueBuilding() && o.hasPathWithoutQueue() {
+			return
+		}
 		o.getEpsilon()
 		rate := getRate(o.oliaSenders, o.rttStats.SmoothedRTT())
 		cwndScaled := oliaScale(uint64(o.congestionWindow), scale)
@@ -233,6 +370,11 @@ func (o *OliaSender) maybeIncreaseCwnd(ackedPacketNumber protocol.PacketNumber,
 
 func (o *OliaSender) OnPacketAcked(ackedPacketNumber protocol.PacketNumber, ackedBytes protocol.ByteCount, bytesInFlight protocol.ByteCount) {
 	o.largestAckedPacketNumber = utils.MaxPacketNumber(ackedPacketNumber, o.largestAckedPacketNumber)
+	o.cwndValidator.OnPacketAcked(ackedPacketNumber, ackedBytes)
+	if o.priorState != nil && ackedPacketNumber > o.priorState.largestSentAtReduction {
+		// A packet sent after the reduction was received, the reduction can't be undone anymore
+		o.priorState = nil
+	}
 	if o.InRecovery() {
 		// PRR is used when in recovery
 		o.prr.OnPacketAcked(ackedBytes)
@@ -262,6 +404,8 @@ func (o *OliaSender) OnPacketLost(packetNumber protocol.PacketNumber, lostBytes
 		}
 		return
 	}
+	// A new loss epoch replaces the state saved for an earlier one
+	o.saveState()
 	o.lastCutbackExitedSlowstart = o.InSlowStart()
 	if o.InSlowStart() {
 		o.stats.slowstartPacketsLost++
@@ -298,6 +442,10 @@ func (o *OliaSender) OnRetransmissionTimeout(packetsRetransmitted bool) {
 	if !packetsRetransmitted {
 		return
 	}
+	// Consecutive RTOs keep the state from before the first one
+	if o.priorState == nil || o.congestionWindow > o.priorState.congestionWindow {
+		o.saveState()
+	}
 	o.hybridSlowStart.Restart()
 	o.olia.Reset()
 	o.slowstartThreshold = o.congestionWindow / 2
@@ -307,10 +455,12 @@ func (o *OliaSender) OnRetransmissionTimeout(packetsRetransmitted bool) {
 func (o *OliaSender) OnConnectionMigration() {
 	o.hybridSlowStart.Restart()
 	o.prr = PrrSender{}
+	o.cwndValidator.Reset()
 	o.largestSentPacketNumber = 0
 	o.largestAckedPacketNumber = 0
 	o.largestSentAtLastCutback = 0
 	o.lastCutbackExitedSlowstart = false
+	o.priorState = nil
 	o.olia.Reset()
 	o.congestionWindowCount = 0
 	o.congestionWindow = o.initialCongestionWindow
@@ -0,0 +1,140 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+var _ = Describe("OLIA sender", func() {
+	var (
+		oliaSenders map[protocol.PathID]*OliaSender
+		sender1     *OliaSender
+		sender2     *OliaSender
+		rttStats1   *RTTStats
+		rttStats2   *RTTStats
+	)
+
+	BeforeEach(func() {
+		oliaSenders = make(map[protocol.PathID]*OliaSender)
+		rttStats1 = NewRTTStats()
+		rttStats2 = NewRTTStats()
+		sender1 = NewOliaSender(oliaSenders, rttStats1, 10, protocol.DefaultMaxCongestionWindow).(*OliaSender)
+		sender2 = NewOliaSender(oliaSenders, rttStats2, 10, protocol.DefaultMaxCongestionWindow).(*OliaSender)
+		oliaSenders[1] = sender1
+		oliaSenders[3] = sender2
+		rttStats1.UpdateRTT(50*time.Millisecond, 0, time.Time{})
+		rttStats2.UpdateRTT(50*time.Millisecond, 0, time.Time{})
+	})
+
+	buildQueue := func(rttStats *RTTStats) {
+		rtt := 50 * time.Millisecond
+		for i := 0; i < 10; i++ {
+			rtt += 10 * time.Millisecond
+			rttStats.UpdateRTT(rtt, 0, time.Time{})
+		}
+		Expect(rttStats.QueueBuilding()).To(BeTrue())
+	}
+
+	It("exits slow start when a queue is building on the path", func() {
+		buildQueue(rttStats1)
+		Expect(sender1.InSlowStart()).To(BeTrue())
+		sender1.MaybeExitSlowStart()
+		Expect(sender1.InSlowStart()).To(BeFalse())
+		sender2.MaybeExitSlowStart()
+		Expect(sender2.InSlowStart()).To(BeTrue())
+	})
+
+	It("stays in slow start if all paths are building a queue", func() {
+		buildQueue(rttStats1)
+		buildQueue(rttStats2)
+		sender1.MaybeExitSlowStart()
+		Expect(sender1.InSlowStart()).To(BeTrue())
+	})
+
+	It("does not increase the congestion window of a path building a queue", func() {
+		sender1.ExitSlowstart()
+		sender2.ExitSlowstart()
+		buildQueue(rttStats1)
+		cwnd := sender1.GetCongestionWindow()
+		for i := 1; i <= 100; i++ {
+			sender1.OnPacketAcked(protocol.PacketNumber(i), protocol.DefaultTCPMSS, cwnd)
+		}
+		Expect(sender1.GetCongestionWindow()).To(Equal(cwnd))
+	})
+
+	It("does not consider a path building a queue as a best path", func() {
+		sender1.ExitSlowstart()
+		sender2.ExitSlowstart()
+		sender1.congestionWindow = 5
+		buildQueue(rttStats1)
+		sender2.getEpsilon()
+		Expect(sender1.olia.epsilonNum).To(BeZero())
+		Expect(sender2.olia.epsilonNum).To(BeZero())
+	})
+
+	It("does not increase the congestion window with packets sent while application limited", func() {
+		cwnd := sender1.GetCongestionWindow()
+		sender1.OnPacketSent(time.Time{}, 0, 1, protocol.DefaultTCPMSS, true)
+		sender1.OnApplicationLimited(protocol.DefaultTCPMSS)
+		Expect(sender1.appLimited).To(BeTrue())
+		sender1.OnPacketSent(time.Time{}, protocol.DefaultTCPMSS, 2, protocol.DefaultTCPMSS, true)
+		// the window is used again
+		sender1.OnPacketSent(time.Time{}, cwnd, 3, protocol.DefaultTCPMSS, true)
+		Expect(sender1.appLimited).To(BeFalse())
+		sender1.OnPacketAcked(1, protocol.DefaultTCPMSS, cwnd)
+		sender1.OnPacketAcked(2, protocol.DefaultTCPMSS, cwnd)
+		Expect(sender1.GetCongestionWindow()).To(Equal(cwnd))
+		sender1.OnPacketAcked(3, protocol.DefaultTCPMSS, cwnd)
+		Expect(sender1.GetCongestionWindow()).To(Equal(cwnd + protocol.DefaultTCPMSS))
+	})
+
+	It("ignores application limited notifications when the window is used", func() {
+		sender1.OnApplicationLimited(sender1.GetCongestionWindow())
+		Expect(sender1.appLimited).To(BeFalse())
+	})
+
+	It("restarts slow start after an idle period", func() {
+		sender1.congestionWindow = 40
+		sender1.ExitSlowstart()
+		now := time.Now()
+		sender1.OnPacketSent(now, protocol.DefaultTCPMSS, 1, protocol.DefaultTCPMSS, true)
+		sender1.OnPacketAcked(1, protocol.DefaultTCPMSS, protocol.DefaultTCPMSS)
+		Expect(sender1.congestionWindow).To(BeEquivalentTo(40))
+		sender1.OnPacketSent(now.Add(time.Second), protocol.DefaultTCPMSS, 2, protocol.DefaultTCPMSS, true)
+		Expect(sender1.congestionWindow).To(BeEquivalentTo(10))
+		Expect(sender1.InSlowStart()).To(BeTrue())
+		Expect(sender1.slowstartThreshold).To(BeEquivalentTo(40))
+		// the other path keeps its window
+		Expect(sender2.congestionWindow).To(BeEquivalentTo(10))
+	})
+
+	It("undoes the window reduction after a spurious loss", func() {
+		sender1.congestionWindow = 40
+		sender1.OnPacketSent(time.Time{}, 0, 1, protocol.DefaultTCPMSS, true)
+		sender1.OnPacketSent(time.Time{}, protocol.DefaultTCPMSS, 2, protocol.DefaultTCPMSS, true)
+		sender1.OnPacketLost(1, protocol.DefaultTCPMSS, protocol.DefaultTCPMSS)
+		Expect(sender1.congestionWindow).To(BeNumerically("<", 40))
+		sender1.OnSpuriousCongestionEvent()
+		Expect(sender1.congestionWindow).To(BeEquivalentTo(40))
+		Expect(sender1.InSlowStart()).To(BeTrue())
+		Expect(sender1.InRecovery()).To(BeFalse())
+	})
+
+	It("restores the OLIA loss history after a spurious loss", func() {
+		sender1.congestionWindow = 40
+		sender1.olia.loss1 = 1000
+		sender1.olia.loss2 = 2000
+		sender1.olia.loss3 = 5000
+		sender1.OnPacketSent(time.Time{}, 0, 1, protocol.DefaultTCPMSS, true)
+		sender1.OnPacketSent(time.Time{}, protocol.DefaultTCPMSS, 2, protocol.DefaultTCPMSS, true)
+		sender1.OnPacketLost(1, protocol.DefaultTCPMSS, protocol.DefaultTCPMSS)
+		Expect(sender1.olia.loss2).To(Equal(protocol.ByteCount(5000)))
+		sender1.OnSpuriousCongestionEvent()
+		Expect(sender1.olia.loss1).To(Equal(protocol.ByteCount(1000)))
+		Expect(sender1.olia.loss2).To(Equal(protocol.ByteCount(2000)))
+		Expect(sender1.olia.loss3).To(Equal(protocol.ByteCount(5000)))
+	})
+})
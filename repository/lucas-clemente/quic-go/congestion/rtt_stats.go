@@ -14,6 +14,12 @@ const (
 	oneMinusBeta  float32 = (1 - rttBeta)
 	halfWindow    float32 = 0.5
 	quarterWindow float32 = 0.25
+
+	// The queueing delay has to exceed minRTT/2^queueingDelayFactorExp before
+	// it is considered as a building queue, clamped to the range below.
+	queueingDelayFactorExp    = 3 // 2^3 = 8
+	queueingDelayMinThreshold = 4 * time.Millisecond
+	queueingDelayMaxThreshold = 16 * time.Millisecond
 )
 
 type rttSample struct {
@@ -31,6 +37,13 @@ type RTTStats struct {
 	smoothedRTT        time.Duration
 	meanDeviation      time.Duration
 
+	// Delay-based congestion signal, in the spirit of LEDBAT / Vegas.
+	// queueingDelay is the EWMA of the RTT samples above minRTT, and
+	// delayGradient the EWMA of the variation between two queueing delay samples.
+	queueingDelay       time.Duration
+	latestQueueingDelay time.Duration
+	delayGradient       time.Duration
+
 	numMinRTTsamplesRemaining uint32
 
 	newMinRTT        rttSample
@@ -75,6 +88,31 @@ func (r *RTTStats) GetHalfWindowRTT() time.Duration { return r.halfWindowRTT.rtt
 // MeanDeviation gets the mean deviation
 func (r *RTTStats) MeanDeviation() time.Duration { return r.meanDeviation }
 
+// QueueingDelay returns the smoothed estimation of the delay added by queues on the path,
+// i.e. the part of the RTT, corrected for the ACK delay, above the minRTT.
+// May return Zero if no valid updates have occurred.
+func (r *RTTStats) QueueingDelay() time.Duration { return r.queueingDelay }
+
+// DelayGradient returns the smoothed variation of the queueing delay between two RTT samples.
+// A positive value means that the queue on the path is growing.
+func (r *RTTStats) DelayGradient() time.Duration { return r.delayGradient }
+
+// QueueBuilding returns true if the RTT samples indicate that a queue is building on the path,
+// i.e. the queueing delay is significant compared to the minRTT and is still increasing.
+// This allows reacting to congestion before any loss occurs.
+func (r *RTTStats) QueueBuilding() bool {
+	if r.minRTT == 0 {
+		return false
+	}
+	return r.delayGradient > 0 && r.queueingDelay > r.queueingDelayThreshold()
+}
+
+func (r *RTTStats) queueingDelayThreshold() time.Duration {
+	// Same clamping as the delay increase detection of hybrid slow start.
+	threshold := r.minRTT >> queueingDelayFactorExp
+	return utils.MaxDuration(utils.MinDuration(threshold, queueingDelayMaxThreshold), queueingDelayMinThreshold)
+}
+
 // SetRecentMinRTTwindow sets how old a recent min rtt sample can be.
 func (r *RTTStats) SetRecentMinRTTwindow(recentMinRTTwindow time.Duration) {
 	r.recentMinRTTwindow = recentMinRTTwindow
@@ -112,6 +150,17 @@ func (r *RTTStats) UpdateRTT(sendDelta, ackDelay time.Duration, now time.Time) {
 		r.meanDeviation = time.Duration(oneMinusBeta*float32(r.meanDeviation/time.Microsecond)+rttBeta*float32(utils.AbsDuration(r.smoothedRTT-sample)/time.Microsecond)) * time.Microsecond
 		r.smoothedRTT = time.Duration((float32(r.smoothedRTT/time.Microsecond)*oneMinusAlpha)+(float32(sample/time.Microsecond)*rttAlpha)) * time.Microsecond
 	}
+	r.updateQueueingDelay(sample)
+}
+
+func (r *RTTStats) updateQueueingDelay(rttSample time.Duration) {
+	// Use the sample corrected for the peer's ackDelay, otherwise delayed ACKs look like a queue.
+	// It can be smaller than r.minRTT, which is taken from the raw sendDelta.
+	sample := utils.MaxDuration(rttSample-r.minRTT, 0)
+	gradient := sample - r.latestQueueingDelay
+	r.latestQueueingDelay = sample
+	r.delayGradient = time.Duration(float32(r.delayGradient/time.Microsecond)*oneMinusAlpha+float32(gradient/time.Microsecond)*rttAlpha) * time.Microsecond
+	r.queueingDelay = time.Duration(float32(r.queueingDelay/time.Microsecond)*oneMinusAlpha+float32(sample/time.Microsecond)*rttAlpha) * time.Microsecond
 }
 
 func (r *RTTStats) updateRecentMinRTT(sample time.Duration, now time.Time) { // Recent minRTT update.
@@ -165,6 +214,9 @@ func (r *RTTStats) OnConnectionMigration() {
 	r.minRTT = 0
 	r.smoothedRTT = 0
 	r.meanDeviation = 0
+	r.queueingDelay = 0
+	r.latestQueueingDelay = 0
+	r.delayGradient = 0
 	r.initialRTTus = initialRTTus
 	r.numMinRTTsamplesRemaining = 0
 	r.recentMinRTTwindow = utils.InfDuration
@@ -196,6 +196,51 @@ var _ = Describe("RTT stats", func() {
 		}
 	})
 
+	It("QueueingDelay", func() {
+		rttStats.UpdateRTT((50 * time.Millisecond), 0, time.Time{})
+		Expect(rttStats.QueueingDelay()).To(BeZero())
+		Expect(rttStats.QueueBuilding()).To(BeFalse())
+
+		// The RTT keeps increasing, a queue is building
+		rtt := (50 * time.Millisecond)
+		for i := 0; i < 10; i++ {
+			rtt += (10 * time.Millisecond)
+			rttStats.UpdateRTT(rtt, 0, time.Time{})
+		}
+		Expect(rttStats.MinRTT()).To(Equal((50 * time.Millisecond)))
+		Expect(rttStats.QueueingDelay()).To(BeNumerically(">", queueingDelayMinThreshold))
+		Expect(rttStats.DelayGradient()).To(BeNumerically(">", 0))
+		Expect(rttStats.QueueBuilding()).To(BeTrue())
+
+		// The queue drains
+		for i := 0; i < 3; i++ {
+			rtt -= (20 * time.Millisecond)
+			rttStats.UpdateRTT(rtt, 0, time.Time{})
+		}
+		Expect(rttStats.DelayGradient()).To(BeNumerically("<", 0))
+		Expect(rttStats.QueueBuilding()).To(BeFalse())
+	})
+
+	It("ignores small queueing delays", func() {
+		rttStats.UpdateRTT((100 * time.Millisecond), 0, time.Time{})
+		for i := 1; i <= 3; i++ {
+			rttStats.UpdateRTT((100*time.Millisecond)+time.Duration(i)*time.Millisecond, 0, time.Time{})
+		}
+		Expect(rttStats.DelayGradient()).To(BeNumerically(">", 0))
+		Expect(rttStats.QueueBuilding()).To(BeFalse())
+	})
+
+	It("doesn't mistake the ACK delay for a queue", func() {
+		// the first ACK is sent immediately, all later ACKs are delayed by the peer
+		rttStats.UpdateRTT((20 * time.Millisecond), 0, time.Time{})
+		for i := 0; i < 20; i++ {
+			rttStats.UpdateRTT((45 * time.Millisecond), (25 * time.Millisecond), time.Time{})
+			Expect(rttStats.QueueBuilding()).To(BeFalse())
+		}
+		Expect(rttStats.MinRTT()).To(Equal((20 * time.Millisecond)))
+		Expect(rttStats.QueueingDelay()).To(BeZero())
+	})
+
 	It("ResetAfterConnectionMigrations", func() {
 		rttStats.UpdateRTT((300 * time.Millisecond), (100 * time.Millisecond), time.Time{})
 		Expect(rttStats.LatestRTT()).To(Equal((200 * time.Millisecond)))
@@ -209,6 +254,8 @@ var _ = Describe("RTT stats", func() {
 		Expect(rttStats.SmoothedRTT()).To(Equal(time.Duration(0)))
 		Expect(rttStats.MinRTT()).To(Equal(time.Duration(0)))
 		Expect(rttStats.RecentMinRTT()).To(Equal(time.Duration(0)))
+		Expect(rttStats.QueueingDelay()).To(Equal(time.Duration(0)))
+		Expect(rttStats.DelayGradient()).To(Equal(time.Duration(0)))
 	})
 
 })
@@ -22,6 +22,7 @@ func main() {
 	multipath := flag.Bool("m", false, "multipath")
 	output := flag.String("o", "", "logging output")
 	cache := flag.Bool("c", false, "cache handshake information")
+	cacheDir := flag.String("cachedir", "", "directory to cache handshake information in across runs")
 	flag.Parse()
 	urls := flag.Args()
 
@@ -44,6 +45,13 @@ func main() {
 		CreatePaths: *multipath,
 		CacheHandshake: *cache,
 	}
+	if *cache && *cacheDir != "" {
+		handshakeCache, err := quic.NewDirectoryHandshakeCache(*cacheDir, 100, 24*time.Hour)
+		if err != nil {
+			panic(err)
+		}
+		quicConfig.HandshakeCache = handshakeCache
+	}
 
 	hclient := &http.Client{
 		Transport: &h2quic.RoundTripper{QuicConfig: quicConfig, TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
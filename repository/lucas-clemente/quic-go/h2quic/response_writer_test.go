@@ -11,6 +11,7 @@ import (
 	"github.com/yyleeshine/mpquic/repository/x/net/http2"
 	"github.com/yyleeshine/mpquic/repository/x/net/http2/hpack"
 
+	quic "github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
 	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
 	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
@@ -48,6 +49,12 @@ func (s *mockStream) SetReadDeadline(time.Time) error              { panic("not
 func (s *mockStream) SetWriteDeadline(time.Time) error             { panic("not implemented") }
 func (s *mockStream) GetBytesSent() (protocol.ByteCount, error)    { panic("not implemented") }
 func (s *mockStream) GetBytesRetrans() (protocol.ByteCount, error) { panic("not implemented") }
+func (s *mockStream) SetPathPreference([]protocol.PathID)          {}
+func (s *mockStream) SetPathPolicy(quic.PathPolicy)                {}
+func (s *mockStream) GetBytesDropped() protocol.ByteCount          { return 0 }
+func (s *mockStream) SetPriority(uint8, bool)                      {}
+func (s *mockStream) CancelRead(quic.StreamErrorCode)              {}
+func (s *mockStream) CancelWrite(quic.StreamErrorCode)             {}
 
 func (s *mockStream) Read(p []byte) (int, error) {
 	n, _ := s.dataToRead.Read(p)
@@ -58,6 +65,14 @@ func (s *mockStream) Read(p []byte) (int, error) {
 	return n, nil // never return an EOF
 }
 func (s *mockStream) Write(p []byte) (int, error) { return s.dataWritten.Write(p) }
+func (s *mockStream) WriteBuffers(bufs [][]byte) (int, error) {
+	var n int
+	for _, b := range bufs {
+		l, _ := s.dataWritten.Write(b)
+		n += l
+	}
+	return n, nil
+}
 
 var _ = Describe("Response Writer", func() {
 	var (
@@ -51,6 +51,11 @@ type Server struct {
 	listenerMutex sync.Mutex
 	listener      quic.Listener
 
+	// the sessions that are not closed yet, needed to close them gracefully
+	sessionsMutex sync.Mutex
+	sessions      map[quic.Session]struct{}
+	closing       bool
+
 	supportedVersionsAsString string
 }
 
@@ -112,10 +117,34 @@ func (s *Server) serveImpl(tlsConfig *tls.Config, conn net.PacketConn) error {
 		if err != nil {
 			return err
 		}
+		if !s.addSession(sess) {
+			sess.Close(nil)
+			continue
+		}
 		go s.handleHeaderStream(sess.(streamCreator))
 	}
 }
 
+// addSession tracks a session until it is closed. It returns false if the server is closing gracefully.
+func (s *Server) addSession(sess quic.Session) bool {
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+	if s.closing {
+		return false
+	}
+	if s.sessions == nil {
+		s.sessions = make(map[quic.Session]struct{})
+	}
+	s.sessions[sess] = struct{}{}
+	go func() {
+		<-sess.Context().Done()
+		s.sessionsMutex.Lock()
+		delete(s.sessions, sess)
+		s.sessionsMutex.Unlock()
+	}()
+	return true
+}
+
 func (s *Server) handleHeaderStream(session streamCreator) {
 	stream, err := session.AcceptStream()
 	if err != nil {
@@ -255,8 +284,24 @@ func (s *Server) Close() error {
 // CloseGracefully shuts down the server gracefully. The server sends a GOAWAY frame first, then waits for either timeout to trigger, or for all running requests to complete.
 // CloseGracefully in combination with ListenAndServe() (instead of Serve()) may race if it is called before a UDP socket is established.
 func (s *Server) CloseGracefully(timeout time.Duration) error {
-	// TODO: implement
-	return nil
+	s.sessionsMutex.Lock()
+	s.closing = true
+	sessions := make([]quic.Session, 0, len(s.sessions))
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.sessionsMutex.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(len(sessions))
+	for _, sess := range sessions {
+		go func(sess quic.Session) {
+			defer wg.Done()
+			sess.CloseGracefully(timeout)
+		}(sess)
+	}
+	wg.Wait()
+	return s.Close()
 }
 
 // SetQuicHeaders can be used to set the proper headers that announce that this server supports QUIC.
@@ -29,6 +29,7 @@ import (
 type mockSession struct {
 	closed              bool
 	closedWithError     error
+	closedGracefully    time.Duration
 	dataStream          quic.Stream
 	streamToAccept      quic.Stream
 	streamsToOpen       []quic.Stream
@@ -56,12 +57,22 @@ func (s *mockSession) OpenStreamSync() (quic.Stream, error) {
 	}
 	return s.OpenStream()
 }
+func (s *mockSession) OpenUniStream() (quic.SendStream, error) {
+	panic("not implemented")
+}
+func (s *mockSession) AcceptUniStream() (quic.ReceiveStream, error) {
+	panic("not implemented")
+}
 func (s *mockSession) Close(e error) error {
 	s.closed = true
 	s.closedWithError = e
 	s.ctxCancel()
 	return nil
 }
+func (s *mockSession) CloseGracefully(timeout time.Duration) error {
+	s.closedGracefully = timeout
+	return s.Close(nil)
+}
 func (s *mockSession) LocalAddr() net.Addr {
 	panic("not implemented")
 }
@@ -71,6 +82,12 @@ func (s *mockSession) RemoteAddr() net.Addr {
 func (s *mockSession) Context() context.Context {
 	return s.ctx
 }
+func (s *mockSession) ConnectionState() quic.ConnectionState {
+	panic("not implemented")
+}
+func (s *mockSession) Stats() quic.ConnectionStats {
+	panic("not implemented")
+}
 
 var _ = Describe("H2 server", func() {
 	var (
@@ -496,6 +513,28 @@ var _ = Describe("H2 server", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
+	It("closes all sessions gracefully", func() {
+		session2 := &mockSession{}
+		session2.ctx, session2.ctxCancel = context.WithCancel(context.Background())
+		Expect(s.addSession(session)).To(BeTrue())
+		Expect(s.addSession(session2)).To(BeTrue())
+		err := s.CloseGracefully(time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(session.closedGracefully).To(Equal(time.Minute))
+		Expect(session2.closedGracefully).To(Equal(time.Minute))
+		Eventually(func() int {
+			s.sessionsMutex.Lock()
+			defer s.sessionsMutex.Unlock()
+			return len(s.sessions)
+		}).Should(BeZero())
+	})
+
+	It("doesn't accept new sessions while closing gracefully", func() {
+		err := s.CloseGracefully(0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(s.addSession(session)).To(BeFalse())
+	})
+
 	It("errors when listening fails", func() {
 		testErr := errors.New("listen error")
 		quicListenAddr = func(addr string, tlsConf *tls.Config, config *quic.Config) (quic.Listener, error) {
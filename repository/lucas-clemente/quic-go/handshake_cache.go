@@ -0,0 +1,38 @@
+package quic
+
+import (
+	"sync"
+	"time"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/handshake"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+)
+
+var (
+	defaultHandshakeCache     HandshakeCache
+	defaultHandshakeCacheOnce sync.Once
+)
+
+// getDefaultHandshakeCache returns the cache shared by all clients that cache the handshake, but don't set a HandshakeCache
+func getDefaultHandshakeCache() HandshakeCache {
+	defaultHandshakeCacheOnce.Do(func() {
+		// this can't fail, the default size is positive
+		defaultHandshakeCache, _ = NewMemoryHandshakeCache(protocol.DefaultHandshakeCacheSize, protocol.DefaultHandshakeCacheTTL)
+	})
+	return defaultHandshakeCache
+}
+
+// NewMemoryHandshakeCache creates a HandshakeCache that keeps the entries of at most maxEntries servers in memory, each for at most ttl.
+// When it is full, the least recently used entry is evicted.
+// It returns an error if maxEntries is negative.
+func NewMemoryHandshakeCache(maxEntries int, ttl time.Duration) (HandshakeCache, error) {
+	return handshake.NewMemoryHandshakeCache(maxEntries, ttl)
+}
+
+// NewDirectoryHandshakeCache creates a HandshakeCache that stores the entries of at most maxEntries servers in dir, each for at most ttl.
+// The files are only accessible by the user, and are replaced atomically, so that multiple processes of the user can share the directory.
+// When it is full, the least recently written entry is evicted.
+// It returns an error if maxEntries is negative.
+func NewDirectoryHandshakeCache(dir string, maxEntries int, ttl time.Duration) (HandshakeCache, error) {
+	return handshake.NewDirectoryHandshakeCache(dir, maxEntries, ttl)
+}
@@ -0,0 +1,71 @@
+package self_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+
+	quic "github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/testdata"
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+var _ = Describe("Multipath nonces", func() {
+	var server quic.Listener
+
+	data := bytes.Repeat([]byte("foobar"), 500000) // 3 MB, enough to use all paths
+
+	AfterEach(func() {
+		Expect(server.Close()).To(Succeed())
+	})
+
+	// transfer echoes data over multiple paths, with a client and a server that support the given versions
+	transfer := func(clientVersions, serverVersions []protocol.VersionNumber) {
+		var err error
+		server, err = quic.ListenAddr("localhost:0", testdata.GetTLSConfig(), &quic.Config{Versions: serverVersions})
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			defer GinkgoRecover()
+			sess, err := server.Accept()
+			if err != nil {
+				return
+			}
+			str, err := sess.AcceptStream()
+			Expect(err).ToNot(HaveOccurred())
+			b, err := ioutil.ReadAll(str)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = str.Write(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.Close()).To(Succeed())
+		}()
+
+		// the certificate for quic.clemente.io has expired
+		clientTLS := &tls.Config{ServerName: "quic.clemente.io", InsecureSkipVerify: true}
+		sess, err := quic.DialAddr(server.Addr().String(), clientTLS, &quic.Config{Versions: clientVersions, CreatePaths: true})
+		Expect(err).ToNot(HaveOccurred())
+		str, err := sess.OpenStreamSync()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = str.Write(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(str.Close()).To(Succeed())
+		b, err := ioutil.ReadAll(str)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b).To(Equal(data))
+		Expect(len(sess.Stats().Paths)).To(BeNumerically(">", 1))
+		Expect(sess.Close(nil)).To(Succeed())
+	}
+
+	It("uses the path ID in the nonce, if both peers support it", func() {
+		transfer([]protocol.VersionNumber{protocol.VersionMP2}, []protocol.VersionNumber{protocol.VersionMP2})
+	})
+
+	It("uses the old nonces with a server that doesn't support the new version", func() {
+		transfer([]protocol.VersionNumber{protocol.VersionMP2, protocol.VersionMP}, []protocol.VersionNumber{protocol.VersionMP})
+	})
+
+	It("uses the old nonces with a client that doesn't support the new version", func() {
+		transfer([]protocol.VersionNumber{protocol.VersionMP}, []protocol.VersionNumber{protocol.VersionMP2, protocol.VersionMP})
+	})
+})
@@ -0,0 +1,292 @@
+package self_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	quic "github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/testdata"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/metrics"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/pcap"
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+// a lockedBuffer is a bytes.Buffer that can be written to concurrently
+type lockedBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Len() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Len()
+}
+
+var _ = Describe("TLS handshake", func() {
+	var (
+		server       quic.Listener
+		serverConfig *quic.Config
+		serverTLS    *tls.Config
+		clientConfig *quic.Config
+		clientTLS    *tls.Config
+		serverSess   chan quic.Session
+	)
+
+	data := bytes.Repeat([]byte("foobar"), 500000) // 3 MB, more than the initial flow control windows
+
+	BeforeEach(func() {
+		serverConfig = &quic.Config{Versions: []protocol.VersionNumber{protocol.VersionMPTLS}}
+		serverTLS = testdata.GetTLSConfig()
+		clientConfig = &quic.Config{Versions: []protocol.VersionNumber{protocol.VersionMPTLS}}
+		// the certificate for quic.clemente.io has expired
+		clientTLS = &tls.Config{ServerName: "quic.clemente.io", InsecureSkipVerify: true}
+		serverSess = make(chan quic.Session, 1)
+	})
+
+	AfterEach(func() {
+		Expect(server.Close()).To(Succeed())
+	})
+
+	runServer := func() {
+		var err error
+		server, err = quic.ListenAddr("localhost:0", serverTLS, serverConfig)
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			defer GinkgoRecover()
+			sess, err := server.Accept()
+			if err != nil {
+				return
+			}
+			serverSess <- sess
+		}()
+	}
+
+	// the server echoes the data on the first stream opened by the client
+	runEchoServer := func() {
+		runServer()
+		go func() {
+			defer GinkgoRecover()
+			var sess quic.Session
+			Eventually(serverSess, 5).Should(Receive(&sess))
+			serverSess <- sess
+			str, err := sess.AcceptStream()
+			Expect(err).ToNot(HaveOccurred())
+			b, err := ioutil.ReadAll(str)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = str.Write(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.Close()).To(Succeed())
+		}()
+	}
+
+	echo := func(sess quic.Session) {
+		str, err := sess.OpenStreamSync()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = str.Write(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(str.Close()).To(Succeed())
+		b, err := ioutil.ReadAll(str)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b).To(Equal(data))
+	}
+
+	It("transfers data after the handshake", func() {
+		runEchoServer()
+		sess, err := quic.DialAddr(server.Addr().String(), clientTLS, clientConfig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sess.ConnectionState().HandshakeComplete).To(BeTrue())
+		echo(sess)
+		Expect(sess.Close(nil)).To(Succeed())
+	})
+
+	It("transfers data when creating multiple paths", func() {
+		clientConfig.CreatePaths = true
+		runEchoServer()
+		sess, err := quic.DialAddr(server.Addr().String(), clientTLS, clientConfig)
+		Expect(err).ToNot(HaveOccurred())
+		echo(sess)
+		Expect(sess.Close(nil)).To(Succeed())
+	})
+
+	It("reports the statistics of every path", func() {
+		clientConfig.CreatePaths = true
+		runEchoServer()
+		sess, err := quic.DialAddr(server.Addr().String(), clientTLS, clientConfig)
+		Expect(err).ToNot(HaveOccurred())
+		echo(sess)
+		stats := sess.Stats()
+		Expect(len(stats.Paths)).To(BeNumerically(">", 1))
+		var bytesSent, bytesReceived quic.ByteCount
+		for _, pathStats := range stats.Paths {
+			bytesSent += pathStats.BytesSent
+			bytesReceived += pathStats.BytesReceived
+		}
+		Expect(bytesSent).To(BeNumerically(">", len(data)))
+		Expect(bytesReceived).To(BeNumerically(">", len(data)))
+		Expect(stats.Paths[0].SmoothedRTT).ToNot(BeZero())
+		Expect(sess.Close(nil)).To(Succeed())
+		Expect(sess.Stats().Paths).To(HaveLen(len(stats.Paths)))
+	})
+
+	It("collects the metrics of the server", func() {
+		collector := metrics.NewCollector()
+		serverConfig.Tracer = collector
+		clientConfig.CreatePaths = true
+		runEchoServer()
+		sess, err := quic.DialAddr(server.Addr().String(), clientTLS, clientConfig)
+		Expect(err).ToNot(HaveOccurred())
+		echo(sess)
+		scrape := func() string {
+			w := httptest.NewRecorder()
+			collector.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+			return w.Body.String()
+		}
+		Expect(scrape()).To(ContainSubstring(`quic_sessions_active{perspective="server"} 1`))
+		Expect(scrape()).To(MatchRegexp(`quic_path_rtt_seconds_count{path_id="0"} [1-9]`))
+		Expect(sess.Close(nil)).To(Succeed())
+		Eventually(scrape).Should(ContainSubstring(`quic_sessions_active{perspective="server"} 0`))
+		Expect(scrape()).To(ContainSubstring(`quic_paths_active{perspective="server"} 0`))
+		Expect(scrape()).ToNot(ContainSubstring("quic_handshake_failures_total{"))
+	})
+
+	It("writes the keys to the key log, and captures the packets of all paths", func() {
+		serverKeyLog := &bytes.Buffer{}
+		serverConfig.KeyLogWriter = serverKeyLog
+		clientKeyLog := &bytes.Buffer{}
+		clientConfig.KeyLogWriter = clientKeyLog
+		capture := &lockedBuffer{}
+		pcapWriter, err := pcap.NewWriter(capture)
+		Expect(err).ToNot(HaveOccurred())
+		clientConfig.PacketCapture = pcapWriter
+		clientConfig.CreatePaths = true
+		runEchoServer()
+		sess, err := quic.DialAddr(server.Addr().String(), clientTLS, clientConfig)
+		Expect(err).ToNot(HaveOccurred())
+		echo(sess)
+		Expect(sess.Close(nil)).To(Succeed())
+		// the client and the server log the same keys, with the same connection ID
+		Expect(clientKeyLog.String()).To(MatchRegexp(`(?m)^QUIC_CLIENT_FORWARD_SECURE_KEY [0-9a-f]{16} [0-9a-f]{32}$`))
+		// and the secrets of the TLS handshake in the NSS key log format, with the client random
+		for _, label := range []string{"CLIENT_HANDSHAKE_TRAFFIC_SECRET", "SERVER_HANDSHAKE_TRAFFIC_SECRET", "CLIENT_TRAFFIC_SECRET_0", "SERVER_TRAFFIC_SECRET_0", "EXPORTER_SECRET"} {
+			Expect(clientKeyLog.String()).To(MatchRegexp(`(?m)^` + label + ` [0-9a-f]{64} [0-9a-f]{64}$`))
+		}
+		Expect(strings.Split(clientKeyLog.String(), "\n")).To(ConsistOf(strings.Split(serverKeyLog.String(), "\n")))
+		Expect(pcapWriter.Err()).ToNot(HaveOccurred())
+		// the pcap header is 24 bytes, the data of the stream more than 3 MB
+		Expect(capture.Len()).To(BeNumerically(">", 24+len(data)))
+	})
+
+	It("negotiates the transport parameters", func() {
+		clientConfig.RequestConnectionIDTruncation = true
+		runEchoServer()
+		sess, err := quic.DialAddr(server.Addr().String(), clientTLS, clientConfig)
+		Expect(err).ToNot(HaveOccurred())
+		echo(sess)
+		Expect(sess.Close(nil)).To(Succeed())
+	})
+
+	It("opens unreliable streams, if the peer announced support for them", func() {
+		runServer()
+		sess, err := quic.DialAddr(server.Addr().String(), clientTLS, clientConfig)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = sess.OpenUnreliableStream()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sess.Close(nil)).To(Succeed())
+	})
+
+	It("doesn't open unreliable streams with the single-path TLS version", func() {
+		serverConfig.Versions = []protocol.VersionNumber{protocol.VersionTLS}
+		clientConfig.Versions = []protocol.VersionNumber{protocol.VersionTLS}
+		runServer()
+		sess, err := quic.DialAddr(server.Addr().String(), clientTLS, clientConfig)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = sess.OpenUnreliableStream()
+		Expect(err).To(MatchError("the peer doesn't support unreliable streams"))
+		Expect(sess.Close(nil)).To(Succeed())
+	})
+
+	It("falls back to QUIC crypto, if the server doesn't support TLS", func() {
+		serverConfig.Versions = []protocol.VersionNumber{protocol.VersionMP}
+		clientConfig.Versions = []protocol.VersionNumber{protocol.VersionMPTLS, protocol.VersionMP}
+		runEchoServer()
+		sess, err := quic.DialAddr(server.Addr().String(), clientTLS, clientConfig)
+		Expect(err).ToNot(HaveOccurred())
+		echo(sess)
+		Expect(sess.Close(nil)).To(Succeed())
+	})
+
+	Context("ALPN", func() {
+		It("negotiates the application protocol", func() {
+			serverTLS.NextProtos = []string{"foo", "bar"}
+			clientTLS.NextProtos = []string{"bar"}
+			runServer()
+			sess, err := quic.DialAddr(server.Addr().String(), clientTLS, clientConfig)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sess.ConnectionState().NegotiatedProtocol).To(Equal("bar"))
+			var sSess quic.Session
+			Eventually(serverSess).Should(Receive(&sSess))
+			Expect(sSess.ConnectionState().NegotiatedProtocol).To(Equal("bar"))
+			Expect(sess.Close(nil)).To(Succeed())
+		})
+
+		It("fails the handshake if there's no common application protocol", func() {
+			serverTLS.NextProtos = []string{"foo"}
+			clientTLS.NextProtos = []string{"bar"}
+			runServer()
+			_, err := quic.DialAddr(server.Addr().String(), clientTLS, clientConfig)
+			Expect(err).To(MatchError("CryptoMessageParameterNoOverlap: no application protocol negotiated"))
+		})
+	})
+
+	Context("selecting certificates", func() {
+		It("uses the certificate returned by GetCertificate, for every handshake", func() {
+			cert := testdata.GetCertificate()
+			serverNames := make(chan string, 2)
+			serverTLS = &tls.Config{
+				GetCertificate: func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
+					serverNames <- info.ServerName
+					return &cert, nil
+				},
+			}
+			runServer()
+			for i := 0; i < 2; i++ {
+				sess, err := quic.DialAddr(server.Addr().String(), clientTLS, clientConfig)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sess.Close(nil)).To(Succeed())
+			}
+			Expect(serverNames).To(HaveLen(2))
+			Expect(serverNames).To(Receive(Equal("quic.clemente.io")))
+			Expect(serverNames).To(Receive(Equal("quic.clemente.io")))
+		})
+
+		It("uses the first certificate, if none matches the server name", func() {
+			clientTLS.ServerName = ""
+			runServer()
+			sess, err := quic.DialAddr(server.Addr().String(), clientTLS, clientConfig)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sess.ConnectionState().HandshakeComplete).To(BeTrue())
+			Expect(sess.Close(nil)).To(Succeed())
+		})
+	})
+
+	It("verifies the certificate chain", func() {
+		clientTLS.InsecureSkipVerify = false
+		runServer()
+		_, err := quic.DialAddr(server.Addr().String(), clientTLS, clientConfig)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("bad certificate"))
+	})
+})
@@ -0,0 +1,174 @@
+package self_test
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"time"
+
+	quic "github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/integrationtests/tools/proxy"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/testdata"
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+var _ = Describe("0-RTT", func() {
+	var (
+		proxy        *quicproxy.QuicProxy
+		server       quic.Listener
+		serverConfig *quic.Config
+		clientConfig *quic.Config
+		serverSess   chan quic.Session
+	)
+
+	rtt := 400 * time.Millisecond
+	data := []byte("foobar")
+
+	newHandshakeCache := func() quic.HandshakeCache {
+		cache, err := quic.NewMemoryHandshakeCache(10, time.Hour)
+		Expect(err).ToNot(HaveOccurred())
+		return cache
+	}
+
+	BeforeEach(func() {
+		serverConfig = &quic.Config{}
+		clientConfig = &quic.Config{
+			CacheHandshake: true,
+			HandshakeCache: newHandshakeCache(),
+		}
+		serverSess = make(chan quic.Session, 2)
+	})
+
+	AfterEach(func() {
+		Expect(proxy.Close()).To(Succeed())
+		Expect(server.Close()).To(Succeed())
+	})
+
+	// runServerAndProxy starts the server and the proxy, and does a first handshake to fill the cache of the client
+	runServerAndProxy := func() {
+		var err error
+		server, err = quic.ListenAddr("localhost:0", testdata.GetTLSConfig(), serverConfig)
+		Expect(err).ToNot(HaveOccurred())
+		proxy, err = quicproxy.NewQuicProxy("localhost:0", protocol.VersionWhatever, &quicproxy.Opts{
+			RemoteAddr:  server.Addr().String(),
+			DelayPacket: func(_ quicproxy.Direction, _ uint64) time.Duration { return rtt / 2 },
+		})
+		Expect(err).ToNot(HaveOccurred())
+		go func() {
+			defer GinkgoRecover()
+			for {
+				sess, err := server.Accept()
+				if err != nil {
+					return
+				}
+				serverSess <- sess
+			}
+		}()
+
+		sess, err := quic.DialAddr(proxy.LocalAddr().String(), &tls.Config{InsecureSkipVerify: true}, clientConfig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sess.ConnectionState().Used0RTT).To(BeFalse())
+		Expect(sess.Close(nil)).To(Succeed())
+		Eventually(serverSess).Should(Receive())
+	}
+
+	// sendData sends data on a new stream and returns a channel that receives the data read by the server
+	sendData := func(sess quic.Session) <-chan []byte {
+		received := make(chan []byte, 1)
+		go func() {
+			defer GinkgoRecover()
+			var sess quic.Session
+			Eventually(serverSess, 5).Should(Receive(&sess))
+			str, err := sess.AcceptStream()
+			Expect(err).ToNot(HaveOccurred())
+			b, err := ioutil.ReadAll(str)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sess.ConnectionState().Used0RTT).To(Equal(serverConfig.Accept0RTT == nil))
+			received <- b
+		}()
+		str, err := sess.OpenStream()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = str.Write(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(str.Close()).To(Succeed())
+		return received
+	}
+
+	It("sends data before the server responded", func() {
+		runServerAndProxy()
+		testStartedAt := time.Now()
+		sess, err := quic.DialAddrEarly(proxy.LocalAddr().String(), &tls.Config{InsecureSkipVerify: true}, clientConfig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(time.Since(testStartedAt)).To(BeNumerically("<", rtt/2))
+		received := sendData(sess)
+		var b []byte
+		Eventually(received, 5).Should(Receive(&b))
+		Expect(b).To(Equal(data))
+		// the data only takes half an RTT to reach the server
+		Expect(time.Since(testStartedAt)).To(BeNumerically("<", rtt))
+		Expect(sess.WaitUntilHandshakeComplete()).To(Succeed())
+		Expect(sess.ConnectionState().Used0RTT).To(BeTrue())
+		Expect(sess.EarlyDataRejected()).ToNot(BeClosed())
+		Expect(sess.Close(nil)).To(Succeed())
+	})
+
+	It("sends the data again if the server rejects 0-RTT", func() {
+		serverConfig.Accept0RTT = func(net.Addr) bool { return false }
+		runServerAndProxy()
+		sess, err := quic.DialAddrEarly(proxy.LocalAddr().String(), &tls.Config{InsecureSkipVerify: true}, clientConfig)
+		Expect(err).ToNot(HaveOccurred())
+		received := sendData(sess)
+		Eventually(sess.EarlyDataRejected(), 5).Should(BeClosed())
+		var b []byte
+		Eventually(received, 5).Should(Receive(&b))
+		Expect(b).To(Equal(data))
+		Expect(sess.WaitUntilHandshakeComplete()).To(Succeed())
+		Expect(sess.ConnectionState().Used0RTT).To(BeFalse())
+		Expect(sess.Close(nil)).To(Succeed())
+	})
+
+	It("sends the data again if the server config was rotated", func() {
+		// the first handshake takes at least 2 RTTs, so the cached server config is outdated when it completes
+		serverConfig.ServerConfigRotationInterval = rtt
+		runServerAndProxy()
+		sess, err := quic.DialAddrEarly(proxy.LocalAddr().String(), &tls.Config{InsecureSkipVerify: true}, clientConfig)
+		Expect(err).ToNot(HaveOccurred())
+		received := make(chan []byte, 1)
+		go func() {
+			defer GinkgoRecover()
+			var sess quic.Session
+			Eventually(serverSess, 5).Should(Receive(&sess))
+			str, err := sess.AcceptStream()
+			Expect(err).ToNot(HaveOccurred())
+			b, err := ioutil.ReadAll(str)
+			Expect(err).ToNot(HaveOccurred())
+			received <- b
+		}()
+		str, err := sess.OpenStream()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = str.Write(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(str.Close()).To(Succeed())
+		Eventually(sess.EarlyDataRejected(), 5).Should(BeClosed())
+		var b []byte
+		Eventually(received, 5).Should(Receive(&b))
+		Expect(b).To(Equal(data))
+		Expect(sess.WaitUntilHandshakeComplete()).To(Succeed())
+		Expect(sess.Close(nil)).To(Succeed())
+	})
+
+	It("does a full handshake without cached data", func() {
+		runServerAndProxy()
+		clientConfig.HandshakeCache = newHandshakeCache()
+		testStartedAt := time.Now()
+		sess, err := quic.DialAddrEarly(proxy.LocalAddr().String(), &tls.Config{InsecureSkipVerify: true}, clientConfig)
+		Expect(err).ToNot(HaveOccurred())
+		// 1 RTT for verifying the source address, 1 RTT to become secure
+		Expect(time.Since(testStartedAt)).To(BeNumerically(">=", 2*rtt))
+		Expect(sess.WaitUntilHandshakeComplete()).To(Succeed())
+		Expect(sess.ConnectionState().Used0RTT).To(BeFalse())
+		Expect(sess.Close(nil)).To(Succeed())
+	})
+})
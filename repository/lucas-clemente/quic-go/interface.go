@@ -2,63 +2,174 @@ package quic
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"net"
 	"time"
 
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/handshake"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/logging"
 )
 
 // The StreamID is the ID of a QUIC stream.
 type StreamID = protocol.StreamID
 
+// The PathID is the ID of a path of a multipath QUIC connection.
+type PathID = protocol.PathID
+
+// A ByteCount is a number of bytes.
+type ByteCount = protocol.ByteCount
+
 // A VersionNumber is a QUIC version number.
 type VersionNumber = protocol.VersionNumber
 
 // A Cookie can be used to verify the ownership of the client address.
 type Cookie = handshake.Cookie
 
-// Stream is the interface implemented by QUIC streams
-// Stream 是一个接口，被QUIC的stream给实现
-type Stream interface {
+// ConnectionState records basic details about the QUIC connection.
+type ConnectionState = handshake.ConnectionState
+
+// A HandshakeCache stores the handshake data of the servers a client connected to.
+// It must be safe for concurrent use.
+type HandshakeCache = handshake.HandshakeCache
+
+// A HandshakeCacheEntry holds the data a client needs to resume the handshake with a server.
+type HandshakeCacheEntry = handshake.HandshakeCacheEntry
+
+// An AckPathPolicy determines on which path the ACKs for the packets received on a path are sent.
+type AckPathPolicy uint8
+
+const (
+	// AckOnSamePath sends the ACKs for a path on this path.
+	AckOnSamePath AckPathPolicy = iota
+	// AckOnLowestRTTPath sends the ACKs for all paths on the open path with the lowest RTT,
+	// so that the RTT estimates of the other paths are not inflated by a slow return path.
+	// The ACKs are only sent on another path if the peer announced that it supports it during the handshake,
+	// otherwise they are sent on the same path.
+	AckOnLowestRTTPath
+)
+
+// A PathPolicy determines on which paths the data of a stream is sent.
+type PathPolicy uint8
+
+const (
+	// SendOnAllPaths sends the data of the stream on all paths, or on the preferred paths of the stream, if any.
+	SendOnAllPaths PathPolicy = iota
+	// SendOnLowestRTTPath only sends the data of the stream on the open path with the lowest RTT.
+	SendOnLowestRTTPath
+)
+
+// A SendQueuePolicy determines what happens to a message written to an unreliable stream when its send queue is full.
+type SendQueuePolicy uint8
+
+const (
+	// SendQueueBlock blocks Write until there is enough space in the send queue.
+	SendQueueBlock SendQueuePolicy = iota
+	// SendQueueDropOldest drops the oldest messages that were not sent yet, to make space for the new message.
+	// If the new message doesn't fit nevertheless, it is dropped, and Write returns an ErrMessageDropped.
+	SendQueueDropOldest
+	// SendQueueDropNewest drops the new message, and Write returns an ErrMessageDropped.
+	SendQueueDropNewest
+)
+
+// A StreamErrorCode is an application-defined error code, sent to the peer when canceling a stream.
+type StreamErrorCode uint32
+
+// A StreamError is returned by Read and Write once the reading or the writing of a stream was canceled.
+type StreamError struct {
+	StreamID  StreamID
+	ErrorCode StreamErrorCode
+	// Remote is set if the peer canceled the stream
+	Remote bool
+}
+
+func (e *StreamError) Error() string {
+	if e.Remote {
+		return fmt.Sprintf("stream %d canceled by peer with error code %d", e.StreamID, e.ErrorCode)
+	}
+	return fmt.Sprintf("stream %d canceled with error code %d", e.StreamID, e.ErrorCode)
+}
+
+// ReceiveStream is the interface implemented by the receiving half of QUIC streams
+type ReceiveStream interface {
+	// StreamID returns the StreamID of the stream
+	StreamID() StreamID
 	// Read reads data from the stream.
 	// Read can be made to time out and return a net.Error with Timeout() == true
 	// after a fixed time limit; see SetDeadline and SetReadDeadline.
 	io.Reader
+	// SetReadDeadline sets the deadline for future Read calls and
+	// any currently-blocked Read call.
+	// A zero value for t means Read will not time out.
+	SetReadDeadline(t time.Time) error
+	// CancelRead aborts receiving on the stream and asks the peer to stop sending, with an application error code.
+	// Received data that was not read yet is discarded, and Read returns a StreamError.
+	// The peer's Write returns a StreamError with Remote set.
+	// If the peer doesn't support STOP_SENDING, the stream is reset with a RST_STREAM instead, which aborts the writing as well.
+	CancelRead(StreamErrorCode)
+}
+
+// SendStream is the interface implemented by the sending half of QUIC streams
+type SendStream interface {
+	// StreamID returns the StreamID of the stream
+	StreamID() StreamID
 	// Write writes data to the stream.
 	// Write can be made to time out and return a net.Error with Timeout() == true
 	// after a fixed time limit; see SetDeadline and SetWriteDeadline.
 	io.Writer
+	// WriteBuffers writes the data of all buffers, as if Write was called with their concatenation.
+	// It saves copying the buffers into one slice before writing them.
+	WriteBuffers([][]byte) (int, error)
 	io.Closer
-	// 返回该stream的StreamID
-	StreamID() StreamID
 	// Reset closes the stream with an error.
 	Reset(error)
+	// CancelWrite aborts sending on the stream and sends a RST_STREAM with an application error code.
+	// Data that was not sent yet is discarded, and Write returns a StreamError.
+	// The peer's Read returns a StreamError with Remote set.
+	// It doesn't affect the receiving side of the stream.
+	CancelWrite(StreamErrorCode)
 	// The context is canceled as soon as the write-side of the stream is closed.
 	// This happens when Close() is called, or when the stream is reset (either locally or remotely).
 	// Warning: This API should not be considered stable and might change soon.
 	Context() context.Context
-	// SetReadDeadline sets the deadline for future Read calls and
-	// any currently-blocked Read call.
-	// A zero value for t means Read will not time out.
-	SetReadDeadline(t time.Time) error
 	// SetWriteDeadline sets the deadline for future Write calls
 	// and any currently-blocked Write call.
 	// Even if write times out, it may return n > 0, indicating that
 	// some of the data was successfully written.
 	// A zero value for t means Write will not time out.
 	SetWriteDeadline(t time.Time) error
-	// SetDeadline sets the read and write deadlines associated
-	// with the connection. It is equivalent to calling both
-	// SetReadDeadline and SetWriteDeadline.
-	SetDeadline(t time.Time) error
 	// GetBytesSent returns the number of bytes of the stream that were sent to the peer
 	// 返回在该流上已经发送的字节数
 	GetBytesSent() (protocol.ByteCount, error)
 	// GetBytesRetrans returns the number of bytes of the stream that were retransmitted to the peer
 	// 返回重传的字节数
 	GetBytesRetrans() (protocol.ByteCount, error)
+	// GetBytesDropped returns the number of bytes written to the stream that were dropped from its send queue
+	GetBytesDropped() protocol.ByteCount
+	// SetPriority sets the priority of the stream, in the style of the HTTP extensible priorities.
+	// Data of streams with a lower urgency, from 0 to 7, is sent first. The default urgency is 3.
+	// Streams of the same urgency that are incremental share the bandwidth in round-robin,
+	// the others are sent one after the other, in the order they were opened.
+	// Streams are incremental by default.
+	SetPriority(urgency uint8, incremental bool)
+	// SetPathPreference restricts the paths on which the data of the stream is sent.
+	// The data is sent on any path if none of the preferred paths is usable, or if no path is given.
+	SetPathPreference(pathIDs []PathID)
+	// SetPathPolicy sets the policy used to select the paths on which the data of the stream is sent.
+	// The default is SendOnAllPaths.
+	SetPathPolicy(policy PathPolicy)
+}
+
+// Stream is the interface implemented by QUIC streams
+// Stream 是一个接口，被QUIC的stream给实现
+type Stream interface {
+	ReceiveStream
+	SendStream
+	// SetDeadline sets the read and write deadlines associated
+	// with the connection. It is equivalent to calling both
+	// SetReadDeadline and SetWriteDeadline.
+	SetDeadline(t time.Time) error
 }
 
 // A Session is a QUIC connection between two peers.
@@ -79,11 +190,67 @@ type Session interface {
 	RemoteAddr() net.Addr
 	// Close closes the connection. The error will be sent to the remote peer in a CONNECTION_CLOSE frame. An error value of nil is allowed and will cause a normal PeerGoingAway to be sent.
 	Close(error) error
+	// CloseGracefully sends a GOAWAY frame, so that the peer doesn't open new streams anymore, and lets the open streams finish.
+	// It closes the connection once all streams are finished, or when the timeout expires.
+	CloseGracefully(timeout time.Duration) error
 	// The context is cancelled when the session is closed.
 	// Warning: This API should not be considered stable and might change soon.
 	Context() context.Context
+	// ConnectionState returns basic details about the handshake, e.g. the application protocol negotiated with ALPN.
+	ConnectionState() ConnectionState
+	// Stats returns the statistics of the connection and of each of its paths.
+	// Once the connection is closed, it returns the statistics at the time it was closed.
+	Stats() ConnectionStats
 
 	OpenUnreliableStream()(Stream, error)
+	// OpenUniStream opens a new unidirectional QUIC stream, returning a special error when the peer's concurrent stream limit is reached.
+	// Unidirectional streams have their own StreamIDs and limits, they can only be opened once the peer announced its limit.
+	OpenUniStream() (SendStream, error)
+	// AcceptUniStream returns the next unidirectional stream opened by the peer, blocking until one is available.
+	AcceptUniStream() (ReceiveStream, error)
+}
+
+// ConnectionStats are the statistics of a QUIC connection.
+type ConnectionStats struct {
+	// Paths holds the statistics of every path of the connection, including the closed ones.
+	Paths map[PathID]PathStats
+	// ZeroFilledBytes is the number of bytes that were filled with zeros when reading unreliable streams,
+	// because they didn't arrive in time.
+	ZeroFilledBytes ByteCount
+
+	// BandwidthDelayProduct is the number of bytes the peer can have in flight on all paths,
+	// the receive flow control windows are auto-tuned to twice this value.
+	// It is the sum of the receive bandwidths of the paths times the largest smoothed RTT.
+	BandwidthDelayProduct ByteCount
+	// ReceiveWindow is the offset up to which the peer may send data on the connection.
+	// It is advanced by ReceiveWindowIncrement whenever a window update is sent.
+	ReceiveWindow          ByteCount
+	ReceiveWindowIncrement ByteCount
+}
+
+// PathStats are the statistics of a path of a QUIC connection.
+type PathStats struct {
+	// Open is false once the path was closed
+	Open bool
+
+	PacketsSent          uint64
+	BytesSent            ByteCount
+	PacketsReceived      uint64
+	BytesReceived        ByteCount
+	PacketsRetransmitted uint64
+	BytesRetransmitted   ByteCount
+	PacketsLost          uint64
+	BytesLost            ByteCount
+
+	SmoothedRTT time.Duration
+	MinRTT      time.Duration
+	RTTVariance time.Duration
+
+	CongestionWindow ByteCount
+	BytesInFlight    ByteCount
+	// PacingRate is the rate, in bytes per second, at which the congestion controller lets the path send:
+	// one congestion window per smoothed RTT. It is 0 until the RTT of the path was measured.
+	PacingRate uint64
 }
 
 // A NonFWSession is a QUIC connection between two peers half-way through the handshake.
@@ -93,10 +260,20 @@ type NonFWSession interface {
 	WaitUntilHandshakeComplete() error
 }
 
+// An EarlySession is a QUIC connection that can be used before the handshake completes.
+// If the handshake data of the server was cached, data is sent with 0-RTT keys, before the server responded.
+type EarlySession interface {
+	NonFWSession
+	// EarlyDataRejected is closed if the server rejected the data sent with 0-RTT keys.
+	// The rejected data is sent again once the server accepted the handshake, so it arrives one round trip later.
+	EarlyDataRejected() <-chan struct{}
+}
+
 // Config contains all configuration data needed for a QUIC server or client.
 type Config struct {
 	// The QUIC versions that can be negotiated.
-	// If not set, it uses all versions available.
+	// If not set, it uses all versions available, except for the TLS versions.
+	// The TLS 1.3 handshake is used if protocol.VersionMPTLS is listed here.
 	// Warning: This API should not be considered stable and will change soon.
 	Versions []VersionNumber
 	// Ask the server to truncate the connection ID sent in the Public Header.
@@ -117,18 +294,89 @@ type Config struct {
 	// If not set, it verifies that the address matches, and that the Cookie was issued within the last 24 hours.
 	// This option is only valid for the server.
 	AcceptCookie func(clientAddr net.Addr, cookie *Cookie) bool
+	// Accept0RTT determines if a client may send data before the handshake completes (0-RTT).
+	// If it returns false, the server rejects the first CHLO of the client, and the client sends its data again once the handshake completes.
+	// If not set, 0-RTT is accepted. Replayed handshakes are rejected in any case.
+	// This option is only valid for the server.
+	Accept0RTT func(clientAddr net.Addr) bool
+	// ZeroRTTReplayWindow is the time the server remembers the client nonces of 0-RTT handshakes, to reject replayed handshakes.
+	// 0-RTT handshakes with a client nonce generated longer ago (or later) are rejected as well,
+	// so it has to cover the clock skew between the clients and the server.
+	// If this value is zero, it is set to 10 seconds.
+	// This option is only valid for the server.
+	ZeroRTTReplayWindow time.Duration
+	// ServerConfigRotationInterval is the interval in which the server replaces its server config (SCFG)
+	// and the secret used to encrypt the source address tokens (STKs), without restarting the listener.
+	// STKs issued before the last rotation are still accepted.
+	// If this value is zero, they are never rotated.
+	// This option is only valid for the server.
+	ServerConfigRotationInterval time.Duration
 	// MaxReceiveStreamFlowControlWindow is the maximum stream-level flow control window for receiving data.
-	// If this value is zero, it will default to 1 MB for the server and 6 MB for the client.
+	// If this value is zero, it will default to 1 MB for the server and 16 MB for the client,
+	// and the window grows beyond this default, up to 64 MB, if the bandwidth-delay product of all paths requires it.
+	// A non-zero value is a hard limit.
 	MaxReceiveStreamFlowControlWindow uint64
 	// MaxReceiveConnectionFlowControlWindow is the connection-level flow control window for receiving data.
-	// If this value is zero, it will default to 1.5 MB for the server and 15 MB for the client.
+	// If this value is zero, it will default to 1.5 MB for the server and 24 MB for the client,
+	// and the window grows beyond this default, up to 96 MB, if the bandwidth-delay product of all paths requires it.
+	// A non-zero value is a hard limit.
 	MaxReceiveConnectionFlowControlWindow uint64
 	// KeepAlive defines whether this peer will periodically send PING frames to keep the connection alive.
 	KeepAlive bool
-	// Should we cache handshake parameters? If no cache available, should we create one?
+	// CacheHandshake makes the client cache the handshake data of servers, to resume the handshakes with them without a REJ.
+	// If HandshakeCache is not set, all sessions share a cache in memory.
+	// This option is only valid for the client.
 	CacheHandshake bool
+	// HandshakeCache stores the handshake data, if CacheHandshake is set.
+	// See NewMemoryHandshakeCache and NewDirectoryHandshakeCache.
+	HandshakeCache HandshakeCache
 	// Should the host try to create new paths, if possible?
 	CreatePaths bool
+	// ReorderingTolerance is the time, as a fraction of the RTT of a path, a packet may be reordered before it is declared lost.
+	// It grows on paths where more reordering is observed, up to one RTT.
+	// If this value is zero, it is set to 1/8.
+	ReorderingTolerance float64
+	// AckPathPolicy determines on which path ACKs are sent.
+	// If not set, the ACKs for a path are sent on this path.
+	AckPathPolicy AckPathPolicy
+	// AckSendDelay is the maximum delay the peer should wait before acknowledging a retransmittable packet.
+	// It is limited to 100ms. If this value is zero, the peer uses 25ms.
+	AckSendDelay time.Duration
+	// RetransmittablePacketsBeforeAck is the number of retransmittable packets after which the peer should send an ACK.
+	// It is limited to 20. If this value is zero, the peer acknowledges every second retransmittable packet.
+	RetransmittablePacketsBeforeAck int
+	// StreamSendBufferSize is the number of bytes each stream queues before Write blocks.
+	// With a send buffer, Write returns as soon as the data was queued, and the data is sent afterwards.
+	// If this value is zero, Write blocks until all its data was handed to the packet packer.
+	StreamSendBufferSize uint64
+	// UnreliableSendQueueSize is the number of bytes each unreliable stream queues before the UnreliableSendQueuePolicy applies.
+	// If this value is zero, unreliable streams use the StreamSendBufferSize, or 256 kB if a drop policy is set.
+	UnreliableSendQueueSize uint64
+	// UnreliableSendQueuePolicy determines what happens when the send queue of an unreliable stream is full.
+	// With a drop policy, every Write is a message that is either queued as a whole, or dropped as a whole, and Write never blocks.
+	// Write returns an ErrMessageDropped if the message was dropped.
+	// If not set, Write blocks until there is enough space in the send queue.
+	UnreliableSendQueuePolicy SendQueuePolicy
+	// Tracer records the events of the connections, e.g. to write a qlog file for every connection,
+	// or to collect metrics. See the qlog and the metrics packages. If not set, the connections are not traced.
+	Tracer logging.Tracer
+	// KeyLogWriter receives the keys and IVs of the connections, to decrypt captured packets.
+	// Every line has the format "<label> <connection ID> <secret>", with the connection ID and the secret in hex,
+	// and a label like QUIC_CLIENT_FORWARD_SECURE_KEY. The keys of later key phases are labeled with the number
+	// of the key update, e.g. QUIC_CLIENT_FORWARD_SECURE_1_KEY. This format is specific to mpquic, Wireshark doesn't understand it.
+	// Connections that use TLS additionally write the secrets of the TLS handshake in the NSS key log format,
+	// e.g. "CLIENT_TRAFFIC_SECRET_0 <client random> <secret>".
+	// Using it compromises the security of the connections, it should only be used for debugging.
+	KeyLogWriter io.Writer
+	// PacketCapture records the UDP datagrams sent and received on the sockets of all paths, e.g. to a pcap file.
+	// See the pcap package. If not set, no packets are captured.
+	PacketCapture PacketCapture
+}
+
+// A PacketCapture records the raw UDP datagrams sent and received on the sockets of all paths.
+// It is called concurrently for the different sockets.
+type PacketCapture interface {
+	CapturePacket(t time.Time, src, dst net.Addr, data []byte)
 }
 
 // A Listener for incoming QUIC connections
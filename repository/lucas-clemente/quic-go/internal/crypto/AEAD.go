@@ -3,8 +3,16 @@ package crypto
 import "github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
 
 // An AEAD implements QUIC's authenticated encryption and associated data
+// Every path has its own packet number space, so the nonce is derived from the path ID and the packet number.
 type AEAD interface {
-	Open(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error)
-	Seal(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) []byte
+	Open(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error)
+	Seal(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) []byte
 	Overhead() int
 }
+
+// nonceCounter combines the path ID and the packet number into the value used to compute the nonce.
+// Packet numbers are at most 48 bits long (protocol.PacketNumberLen6), so the path ID is put into the most significant byte.
+// This makes sure that two paths never use the same nonce, even if they send packets with the same packet number.
+func nonceCounter(pathID protocol.PathID, packetNumber protocol.PacketNumber) uint64 {
+	return uint64(pathID)<<56 | uint64(packetNumber)&(1<<56-1)
+}
@@ -11,13 +11,15 @@ import (
 )
 
 type aeadAESGCM12 struct {
+	otherKey  []byte
+	myKey     []byte
 	otherIV   []byte
 	myIV      []byte
 	encrypter cipher.AEAD
 	decrypter cipher.AEAD
 }
 
-var _ AEAD = &aeadAESGCM12{}
+var _ updatableAEAD = &aeadAESGCM12{}
 
 // NewAEADAESGCM12 creates a AEAD using AES-GCM with 12 bytes tag size
 //
@@ -45,6 +47,8 @@ func NewAEADAESGCM12(otherKey []byte, myKey []byte, otherIV []byte, myIV []byte)
 		return nil, err
 	}
 	return &aeadAESGCM12{
+		otherKey:  otherKey,
+		myKey:     myKey,
 		otherIV:   otherIV,
 		myIV:      myIV,
 		encrypter: encrypter,
@@ -52,21 +56,41 @@ func NewAEADAESGCM12(otherKey []byte, myKey []byte, otherIV []byte, myIV []byte)
 	}, nil
 }
 
-func (aead *aeadAESGCM12) Open(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error) {
-	return aead.decrypter.Open(dst, aead.makeNonce(aead.otherIV, packetNumber), src, associatedData)
+func (aead *aeadAESGCM12) Open(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error) {
+	return aead.decrypter.Open(dst, aead.makeNonce(aead.otherIV, pathID, packetNumber), src, associatedData)
 }
 
-func (aead *aeadAESGCM12) Seal(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) []byte {
-	return aead.encrypter.Seal(dst, aead.makeNonce(aead.myIV, packetNumber), src, associatedData)
+func (aead *aeadAESGCM12) Seal(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) []byte {
+	return aead.encrypter.Seal(dst, aead.makeNonce(aead.myIV, pathID, packetNumber), src, associatedData)
 }
 
-func (aead *aeadAESGCM12) makeNonce(iv []byte, packetNumber protocol.PacketNumber) []byte {
+func (aead *aeadAESGCM12) makeNonce(iv []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber) []byte {
 	res := make([]byte, 12)
 	copy(res[0:4], iv)
-	binary.LittleEndian.PutUint64(res[4:12], uint64(packetNumber))
+	binary.LittleEndian.PutUint64(res[4:12], nonceCounter(pathID, packetNumber))
 	return res
 }
 
+func (aead *aeadAESGCM12) next() (updatableAEAD, error) {
+	otherKey, otherIV, err := nextKeyAndIV(aead.otherKey, aead.otherIV)
+	if err != nil {
+		return nil, err
+	}
+	myKey, myIV, err := nextKeyAndIV(aead.myKey, aead.myIV)
+	if err != nil {
+		return nil, err
+	}
+	next, err := NewAEADAESGCM12(otherKey, myKey, otherIV, myIV)
+	if err != nil {
+		return nil, err
+	}
+	return next.(updatableAEAD), nil
+}
+
+func (aead *aeadAESGCM12) keys() ([]byte, []byte, []byte, []byte) {
+	return aead.otherKey, aead.myKey, aead.otherIV, aead.myIV
+}
+
 func (aead *aeadAESGCM12) Overhead() int {
 	return aead.encrypter.Overhead()
 }
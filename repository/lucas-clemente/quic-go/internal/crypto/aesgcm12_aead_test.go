@@ -30,30 +30,57 @@ var _ = Describe("AES-GCM", func() {
 	})
 
 	It("seals and opens", func() {
-		b := alice.Seal(nil, []byte("foobar"), 42, []byte("aad"))
-		text, err := bob.Open(nil, b, 42, []byte("aad"))
+		b := alice.Seal(nil, []byte("foobar"), 0, 42, []byte("aad"))
+		text, err := bob.Open(nil, b, 0, 42, []byte("aad"))
 		Expect(err).ToNot(HaveOccurred())
 		Expect(text).To(Equal([]byte("foobar")))
 	})
 
 	It("seals and opens reverse", func() {
-		b := bob.Seal(nil, []byte("foobar"), 42, []byte("aad"))
-		text, err := alice.Open(nil, b, 42, []byte("aad"))
+		b := bob.Seal(nil, []byte("foobar"), 0, 42, []byte("aad"))
+		text, err := alice.Open(nil, b, 0, 42, []byte("aad"))
 		Expect(err).ToNot(HaveOccurred())
 		Expect(text).To(Equal([]byte("foobar")))
 	})
 
 	It("has the proper length", func() {
-		b := bob.Seal(nil, []byte("foobar"), 42, []byte("aad"))
+		b := bob.Seal(nil, []byte("foobar"), 0, 42, []byte("aad"))
 		Expect(b).To(HaveLen(6 + bob.Overhead()))
 	})
 
 	It("fails with wrong aad", func() {
-		b := alice.Seal(nil, []byte("foobar"), 42, []byte("aad"))
-		_, err := bob.Open(nil, b, 42, []byte("aad2"))
+		b := alice.Seal(nil, []byte("foobar"), 0, 42, []byte("aad"))
+		_, err := bob.Open(nil, b, 0, 42, []byte("aad2"))
 		Expect(err).To(HaveOccurred())
 	})
 
+	Context("multiple paths", func() {
+		It("uses a different nonce on every path", func() {
+			b1 := alice.Seal(nil, []byte("foobar"), 1, 42, []byte("aad"))
+			b2 := alice.Seal(nil, []byte("foobar"), 2, 42, []byte("aad"))
+			Expect(b1).ToNot(Equal(b2))
+			nonce1 := alice.(*aeadAESGCM12).makeNonce(ivAlice, 1, 42)
+			nonce2 := alice.(*aeadAESGCM12).makeNonce(ivAlice, 2, 42)
+			Expect(nonce1).ToNot(Equal(nonce2))
+		})
+
+		It("doesn't use the nonce of another path for large packet numbers", func() {
+			// the largest packet number that can be sent on the wire is 48 bits long
+			nonce1 := alice.(*aeadAESGCM12).makeNonce(ivAlice, 0, 1<<48-1)
+			nonce2 := alice.(*aeadAESGCM12).makeNonce(ivAlice, 1, 1<<48-1)
+			Expect(nonce1).ToNot(Equal(nonce2))
+		})
+
+		It("fails to open a packet with the wrong path ID", func() {
+			b := alice.Seal(nil, []byte("foobar"), 1, 42, []byte("aad"))
+			_, err := bob.Open(nil, b, 2, 42, []byte("aad"))
+			Expect(err).To(HaveOccurred())
+			text, err := bob.Open(nil, b, 1, 42, []byte("aad"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(text).To(Equal([]byte("foobar")))
+		})
+	})
+
 	It("rejects wrong key and iv sizes", func() {
 		var err error
 		e := "AES-GCM: expected 16-byte keys and 4-byte IVs"
@@ -10,13 +10,15 @@ import (
 )
 
 type aeadAESGCM struct {
+	otherKey  []byte
+	myKey     []byte
 	otherIV   []byte
 	myIV      []byte
 	encrypter cipher.AEAD
 	decrypter cipher.AEAD
 }
 
-var _ AEAD = &aeadAESGCM{}
+var _ updatableAEAD = &aeadAESGCM{}
 
 const ivLen = 12
 
@@ -45,6 +47,8 @@ func NewAEADAESGCM(otherKey []byte, myKey []byte, otherIV []byte, myIV []byte) (
 	}
 
 	return &aeadAESGCM{
+		otherKey:  otherKey,
+		myKey:     myKey,
 		otherIV:   otherIV,
 		myIV:      myIV,
 		encrypter: encrypter,
@@ -52,23 +56,43 @@ func NewAEADAESGCM(otherKey []byte, myKey []byte, otherIV []byte, myIV []byte) (
 	}, nil
 }
 
-func (aead *aeadAESGCM) Open(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error) {
-	return aead.decrypter.Open(dst, aead.makeNonce(aead.otherIV, packetNumber), src, associatedData)
+func (aead *aeadAESGCM) Open(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error) {
+	return aead.decrypter.Open(dst, aead.makeNonce(aead.otherIV, pathID, packetNumber), src, associatedData)
 }
 
-func (aead *aeadAESGCM) Seal(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) []byte {
-	return aead.encrypter.Seal(dst, aead.makeNonce(aead.myIV, packetNumber), src, associatedData)
+func (aead *aeadAESGCM) Seal(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) []byte {
+	return aead.encrypter.Seal(dst, aead.makeNonce(aead.myIV, pathID, packetNumber), src, associatedData)
 }
 
-func (aead *aeadAESGCM) makeNonce(iv []byte, packetNumber protocol.PacketNumber) []byte {
+func (aead *aeadAESGCM) makeNonce(iv []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber) []byte {
 	nonce := make([]byte, ivLen)
-	binary.BigEndian.PutUint64(nonce[ivLen-8:], uint64(packetNumber))
+	binary.BigEndian.PutUint64(nonce[ivLen-8:], nonceCounter(pathID, packetNumber))
 	for i := 0; i < ivLen; i++ {
 		nonce[i] ^= iv[i]
 	}
 	return nonce
 }
 
+func (aead *aeadAESGCM) next() (updatableAEAD, error) {
+	otherKey, otherIV, err := nextKeyAndIV(aead.otherKey, aead.otherIV)
+	if err != nil {
+		return nil, err
+	}
+	myKey, myIV, err := nextKeyAndIV(aead.myKey, aead.myIV)
+	if err != nil {
+		return nil, err
+	}
+	next, err := NewAEADAESGCM(otherKey, myKey, otherIV, myIV)
+	if err != nil {
+		return nil, err
+	}
+	return next.(updatableAEAD), nil
+}
+
+func (aead *aeadAESGCM) keys() ([]byte, []byte, []byte, []byte) {
+	return aead.otherKey, aead.myKey, aead.otherIV, aead.myIV
+}
+
 func (aead *aeadAESGCM) Overhead() int {
 	return aead.encrypter.Overhead()
 }
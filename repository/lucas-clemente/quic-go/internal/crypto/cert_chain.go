@@ -56,7 +56,14 @@ func (c *certChain) GetLeafCert(sni string) ([]byte, error) {
 }
 
 func (cc *certChain) getCertForSNI(sni string) (*tls.Certificate, error) {
-	c := cc.config
+	return GetCertificateForSNI(cc.config, sni)
+}
+
+// GetCertificateForSNI selects the certificate for a server name.
+// It calls the GetConfigForClient and GetCertificate callbacks of the tls.Config, if set,
+// such that the certificates can be replaced without restarting the server.
+// If no certificate matches the server name, it returns the first certificate.
+func GetCertificateForSNI(c *tls.Config, sni string) (*tls.Certificate, error) {
 	c, err := maybeGetConfigForClient(c, sni)
 	if err != nil {
 		return nil, err
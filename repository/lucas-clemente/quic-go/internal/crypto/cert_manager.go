@@ -97,30 +97,39 @@ func (c *certManager) Verify(hostname string) error {
 	if len(c.chain) == 0 {
 		return errNoCertificateChain
 	}
+	return VerifyCertificateChain(c.chain, hostname, c.config)
+}
+
+// VerifyCertificateChain verifies a certificate chain sent by a server, the first certificate being the leaf certificate
+// The roots, the time and InsecureSkipVerify are taken from the tls.Config, which may be nil.
+func VerifyCertificateChain(chain []*x509.Certificate, hostname string, config *tls.Config) error {
+	if len(chain) == 0 {
+		return errNoCertificateChain
+	}
 
-	if c.config != nil && c.config.InsecureSkipVerify {
+	if config != nil && config.InsecureSkipVerify {
 		return nil
 	}
 
-	leafCert := c.chain[0]
+	leafCert := chain[0]
 
 	var opts x509.VerifyOptions
-	if c.config != nil {
-		opts.Roots = c.config.RootCAs
-		if c.config.Time == nil {
+	if config != nil {
+		opts.Roots = config.RootCAs
+		if config.Time == nil {
 			opts.CurrentTime = time.Now()
 		} else {
-			opts.CurrentTime = c.config.Time()
+			opts.CurrentTime = config.Time()
 		}
 	}
 	// we don't need to care about the tls.Config.ServerName here, since hostname has already been set to that value in the session setup
 	opts.DNSName = hostname
 
 	// the first certificate is the leaf certificate, all others are intermediates
-	if len(c.chain) > 1 {
+	if len(chain) > 1 {
 		intermediates := x509.NewCertPool()
-		for i := 1; i < len(c.chain); i++ {
-			intermediates.AddCert(c.chain[i])
+		for i := 1; i < len(chain); i++ {
+			intermediates.AddCert(chain[i])
 		}
 		opts.Intermediates = intermediates
 	}
@@ -45,17 +45,17 @@ func NewAEADChacha20Poly1305(otherKey []byte, myKey []byte, otherIV []byte, myIV
 	}, nil
 }
 
-func (aead *aeadChacha20Poly1305) Open(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error) {
-	return aead.decrypter.Open(dst, aead.makeNonce(aead.otherIV, packetNumber), src, associatedData)
+func (aead *aeadChacha20Poly1305) Open(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error) {
+	return aead.decrypter.Open(dst, aead.makeNonce(aead.otherIV, pathID, packetNumber), src, associatedData)
 }
 
-func (aead *aeadChacha20Poly1305) Seal(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) []byte {
-	return aead.encrypter.Seal(dst, aead.makeNonce(aead.myIV, packetNumber), src, associatedData)
+func (aead *aeadChacha20Poly1305) Seal(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) []byte {
+	return aead.encrypter.Seal(dst, aead.makeNonce(aead.myIV, pathID, packetNumber), src, associatedData)
 }
 
-func (aead *aeadChacha20Poly1305) makeNonce(iv []byte, packetNumber protocol.PacketNumber) []byte {
+func (aead *aeadChacha20Poly1305) makeNonce(iv []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber) []byte {
 	res := make([]byte, 12)
 	copy(res[0:4], iv)
-	binary.LittleEndian.PutUint64(res[4:12], uint64(packetNumber))
+	binary.LittleEndian.PutUint64(res[4:12], nonceCounter(pathID, packetNumber))
 	return res
 }
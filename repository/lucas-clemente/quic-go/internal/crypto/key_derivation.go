@@ -14,6 +14,7 @@ const (
 type MintController interface {
 	Handshake() mint.Alert
 	GetCipherSuite() mint.CipherSuiteParams
+	State() mint.ConnectionState
 	ComputeExporter(label string, context []byte, keyLength int) ([]byte, error)
 }
 
@@ -19,6 +19,8 @@ var _ MintController = &mockMintController{}
 
 func (c *mockMintController) Handshake() mint.Alert { panic("not implemented") }
 
+func (c *mockMintController) State() mint.ConnectionState { panic("not implemented") }
+
 func (c *mockMintController) GetCipherSuite() mint.CipherSuiteParams {
 	return mint.CipherSuiteParams{
 		Hash:   c.hash,
@@ -40,8 +42,8 @@ var _ = Describe("Key Derivation", func() {
 		Expect(err).ToNot(HaveOccurred())
 		serverAEAD, err := DeriveAESKeys(&mockMintController{hash: crypto.SHA256}, protocol.PerspectiveServer)
 		Expect(err).ToNot(HaveOccurred())
-		ciphertext := clientAEAD.Seal(nil, []byte("foobar"), 0, []byte("aad"))
-		data, err := serverAEAD.Open(nil, ciphertext, 0, []byte("aad"))
+		ciphertext := clientAEAD.Seal(nil, []byte("foobar"), 0, 0, []byte("aad"))
+		data, err := serverAEAD.Open(nil, ciphertext, 0, 0, []byte("aad"))
 		Expect(err).ToNot(HaveOccurred())
 		Expect(data).To(Equal([]byte("foobar")))
 	})
@@ -51,8 +53,8 @@ var _ = Describe("Key Derivation", func() {
 		Expect(err).ToNot(HaveOccurred())
 		serverAEAD, err := DeriveAESKeys(&mockMintController{hash: crypto.SHA512}, protocol.PerspectiveServer)
 		Expect(err).ToNot(HaveOccurred())
-		ciphertext := clientAEAD.Seal(nil, []byte("foobar"), 0, []byte("aad"))
-		_, err = serverAEAD.Open(nil, ciphertext, 0, []byte("aad"))
+		ciphertext := clientAEAD.Seal(nil, []byte("foobar"), 0, 0, []byte("aad"))
+		_, err = serverAEAD.Open(nil, ciphertext, 0, 0, []byte("aad"))
 		Expect(err).To(MatchError("cipher: message authentication failed"))
 	})
 
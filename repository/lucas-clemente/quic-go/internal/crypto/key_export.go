@@ -0,0 +1,19 @@
+package crypto
+
+// ExportKeys returns the keys and the IVs of an AEAD created by this package, e.g. to write them to a key log.
+// For an AEAD that regularly updates its keys, these are the keys of the current key phase.
+// The keys of the following key phases are derived from them (see nextKeyAndIV).
+// ok is false if the AEAD doesn't use any keys, e.g. for the null AEAD.
+func ExportKeys(aead AEAD) (otherKey, myKey, otherIV, myIV []byte, ok bool) {
+	switch a := aead.(type) {
+	case updatableAEAD:
+		otherKey, myKey, otherIV, myIV = a.keys()
+	case *keyUpdatingAEAD:
+		a.mutex.Lock()
+		otherKey, myKey, otherIV, myIV = a.current.keys()
+		a.mutex.Unlock()
+	default:
+		return nil, nil, nil, nil, false
+	}
+	return otherKey, myKey, otherIV, myIV, true
+}
@@ -0,0 +1,54 @@
+package crypto
+
+import (
+	"time"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+var _ = Describe("Key export", func() {
+	var (
+		otherKey = []byte("aaaaaaaaaaaaaaaa")
+		myKey    = []byte("bbbbbbbbbbbbbbbb")
+		otherIV  = []byte("cccc")
+		myIV     = []byte("dddd")
+	)
+
+	It("exports the keys of an AES-GCM AEAD", func() {
+		aead, err := NewAEADAESGCM12(otherKey, myKey, otherIV, myIV)
+		Expect(err).ToNot(HaveOccurred())
+		k1, k2, iv1, iv2, ok := ExportKeys(aead)
+		Expect(ok).To(BeTrue())
+		Expect(k1).To(Equal(otherKey))
+		Expect(k2).To(Equal(myKey))
+		Expect(iv1).To(Equal(otherIV))
+		Expect(iv2).To(Equal(myIV))
+	})
+
+	It("exports the keys of the current key phase", func() {
+		aead, err := NewAEADAESGCM12(otherKey, myKey, otherIV, myIV)
+		Expect(err).ToNot(HaveOccurred())
+		a, err := newKeyUpdatingAEAD(aead.(updatableAEAD), 1, time.Hour)
+		Expect(err).ToNot(HaveOccurred())
+		_, k, _, _, ok := ExportKeys(a)
+		Expect(ok).To(BeTrue())
+		Expect(k).To(Equal(myKey))
+		for pn := protocol.PacketNumber(1); pn <= 2; pn++ {
+			a.KeyPhase()
+			a.Seal(nil, []byte("foobar"), 0, pn, nil)
+		}
+		nextKey, _, err := nextKeyAndIV(myKey, myIV)
+		Expect(err).ToNot(HaveOccurred())
+		_, k, _, _, ok = ExportKeys(a)
+		Expect(ok).To(BeTrue())
+		Expect(k).To(Equal(nextKey))
+	})
+
+	It("doesn't export any keys for the null AEAD", func() {
+		_, _, _, _, ok := ExportKeys(NewNullAEAD(protocol.PerspectiveClient, protocol.VersionWhatever))
+		Expect(ok).To(BeFalse())
+	})
+})
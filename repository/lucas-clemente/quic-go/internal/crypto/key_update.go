@@ -0,0 +1,197 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/utils"
+
+	"github.com/yyleeshine/mpquic/repository/x/crypto/hkdf"
+)
+
+// an updatableAEAD can derive the AEAD for the next key phase
+type updatableAEAD interface {
+	AEAD
+	next() (updatableAEAD, error)
+	keys() (otherKey, myKey, otherIV, myIV []byte)
+}
+
+// A keyUpdatingAEAD regularly updates the forward-secure keys.
+// It is shared by all paths of a session, such that all paths seal with the keys of the same key phase,
+// and the packet count that triggers the key update includes the packets sent on all paths.
+// The key phase is sent in the public header. A packet with the other key phase was sealed with the keys
+// of the previous key phase (the packet was reordered) or of the next key phase (the peer updated the keys).
+type keyUpdatingAEAD struct {
+	mutex sync.Mutex
+
+	keyPhase protocol.KeyPhaseBit
+	// the number of key updates, the key phase bit only tells if it is even or odd
+	updates  uint64
+	previous updatableAEAD
+	current  updatableAEAD
+	next     updatableAEAD
+
+	// the keys are only updated again once the peer used the current keys
+	// this makes sure that the key phases of the peers never differ by more than one
+	confirmed bool
+	sealed    uint64
+	updatedAt time.Time
+
+	packetInterval uint64
+	timeInterval   time.Duration
+
+	onUpdate func(updates uint64, aead AEAD)
+}
+
+var _ AEAD = &keyUpdatingAEAD{}
+
+var errKeyUpdatesNotSupported = errors.New("the AEAD doesn't support key updates")
+
+// NewKeyUpdatingAEAD creates an AEAD that regularly updates the keys of the AEAD.
+// Only the versions that send the key phase in the public header can use it.
+// If set, onUpdate is called with the number of updates and the AEAD of the new key phase whenever the keys are updated,
+// e.g. to log the new keys. It is called while the keyUpdatingAEAD is locked.
+func NewKeyUpdatingAEAD(aead AEAD, onUpdate func(updates uint64, aead AEAD)) (AEAD, error) {
+	a, ok := aead.(updatableAEAD)
+	if !ok {
+		return nil, errKeyUpdatesNotSupported
+	}
+	updating, err := newKeyUpdatingAEAD(a, protocol.KeyUpdatePacketInterval, protocol.KeyUpdateInterval)
+	if err != nil {
+		return nil, err
+	}
+	updating.onUpdate = onUpdate
+	return updating, nil
+}
+
+func newKeyUpdatingAEAD(aead updatableAEAD, packetInterval uint64, timeInterval time.Duration) (*keyUpdatingAEAD, error) {
+	next, err := aead.next()
+	if err != nil {
+		return nil, err
+	}
+	return &keyUpdatingAEAD{
+		keyPhase:       protocol.KeyPhaseZero,
+		current:        aead,
+		next:           next,
+		confirmed:      true,
+		updatedAt:      time.Now(),
+		packetInterval: packetInterval,
+		timeInterval:   timeInterval,
+	}, nil
+}
+
+// OpenWithKeyPhase opens a packet.
+// If the AEAD updates its keys, the key phase sent in the public header selects the keys that are used.
+func OpenWithKeyPhase(aead AEAD, dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, keyPhase protocol.KeyPhaseBit, associatedData []byte) ([]byte, error) {
+	if a, ok := aead.(*keyUpdatingAEAD); ok {
+		a.mutex.Lock()
+		defer a.mutex.Unlock()
+		return a.open(dst, src, pathID, packetNumber, keyPhase, associatedData)
+	}
+	return aead.Open(dst, src, pathID, packetNumber, associatedData)
+}
+
+// Open opens a packet sealed with the keys of the current key phase
+func (a *keyUpdatingAEAD) Open(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.open(dst, src, pathID, packetNumber, a.keyPhase, associatedData)
+}
+
+func (a *keyUpdatingAEAD) open(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, keyPhase protocol.KeyPhaseBit, associatedData []byte) ([]byte, error) {
+	if keyPhase == a.keyPhase {
+		data, err := a.current.Open(dst, src, pathID, packetNumber, associatedData)
+		if err != nil {
+			return nil, err
+		}
+		a.confirmed = true
+		return data, nil
+	}
+	if a.previous != nil {
+		if data, err := a.previous.Open(dst, src, pathID, packetNumber, associatedData); err == nil {
+			return data, nil
+		}
+	}
+	data, err := a.next.Open(dst, src, pathID, packetNumber, associatedData)
+	if err != nil {
+		return nil, err
+	}
+	// the peer updated the keys
+	if err := a.update(); err != nil {
+		return nil, err
+	}
+	a.confirmed = true
+	return data, nil
+}
+
+// KeyPhase returns the key phase of the keys that the next packet is sealed with.
+// It has to be called before sealing a packet, since it updates the keys when an update is due.
+func (a *keyUpdatingAEAD) KeyPhase() protocol.KeyPhaseBit {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.confirmed && (a.sealed >= a.packetInterval || time.Since(a.updatedAt) >= a.timeInterval) {
+		if err := a.update(); err != nil {
+			utils.Errorf("error updating the keys: %s", err.Error())
+		} else {
+			a.confirmed = false
+		}
+	}
+	return a.keyPhase
+}
+
+func (a *keyUpdatingAEAD) Seal(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) []byte {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.sealed++
+	return a.current.Seal(dst, src, pathID, packetNumber, associatedData)
+}
+
+func (a *keyUpdatingAEAD) Overhead() int {
+	return a.current.Overhead()
+}
+
+// update switches to the keys of the next key phase
+// the keys of the previous key phase are kept to open reordered packets
+func (a *keyUpdatingAEAD) update() error {
+	next, err := a.next.next()
+	if err != nil {
+		return err
+	}
+	a.keyPhase = a.keyPhase.Next()
+	a.updates++
+	a.previous = a.current
+	a.current = a.next
+	a.next = next
+	a.sealed = 0
+	a.updatedAt = time.Now()
+	utils.Debugf("Updated the forward-secure keys")
+	if a.onUpdate != nil {
+		a.onUpdate(a.updates, a.current)
+	}
+	return nil
+}
+
+// nextKeyAndIV derives the key and IV of the next key phase
+func nextKeyAndIV(key, iv []byte) ([]byte, []byte, error) {
+	secret := make([]byte, len(key)+len(iv))
+	copy(secret, key)
+	copy(secret[len(key):], iv)
+
+	r := hkdf.New(sha256.New, secret, nil, []byte("QUIC key update"))
+
+	nextKey := make([]byte, len(key))
+	if _, err := io.ReadFull(r, nextKey); err != nil {
+		return nil, nil, err
+	}
+	nextIV := make([]byte, len(iv))
+	if _, err := io.ReadFull(r, nextIV); err != nil {
+		return nil, nil, err
+	}
+	return nextKey, nextIV, nil
+}
@@ -0,0 +1,213 @@
+package crypto
+
+import (
+	"crypto/rand"
+	mrand "math/rand"
+	"time"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+var _ = Describe("Key Update", func() {
+	var alice, bob *keyUpdatingAEAD
+
+	type packet struct {
+		data     []byte
+		pathID   protocol.PathID
+		pn       protocol.PacketNumber
+		keyPhase protocol.KeyPhaseBit
+	}
+
+	newAEADs := func(packetInterval uint64) (*keyUpdatingAEAD, *keyUpdatingAEAD) {
+		keyAlice := make([]byte, 16)
+		keyBob := make([]byte, 16)
+		ivAlice := make([]byte, 4)
+		ivBob := make([]byte, 4)
+		rand.Read(keyAlice)
+		rand.Read(keyBob)
+		rand.Read(ivAlice)
+		rand.Read(ivBob)
+		a, err := NewAEADAESGCM12(keyBob, keyAlice, ivBob, ivAlice)
+		Expect(err).ToNot(HaveOccurred())
+		b, err := NewAEADAESGCM12(keyAlice, keyBob, ivAlice, ivBob)
+		Expect(err).ToNot(HaveOccurred())
+		alice, err := newKeyUpdatingAEAD(a.(updatableAEAD), packetInterval, time.Hour)
+		Expect(err).ToNot(HaveOccurred())
+		bob, err := newKeyUpdatingAEAD(b.(updatableAEAD), packetInterval, time.Hour)
+		Expect(err).ToNot(HaveOccurred())
+		return alice, bob
+	}
+
+	// seal seals a packet the way the packet packer does: it gets the key phase for the public header first
+	seal := func(sender *keyUpdatingAEAD, pathID protocol.PathID, pn protocol.PacketNumber) packet {
+		keyPhase := sender.KeyPhase()
+		return packet{
+			data:     sender.Seal(nil, []byte("foobar"), pathID, pn, nil),
+			pathID:   pathID,
+			pn:       pn,
+			keyPhase: keyPhase,
+		}
+	}
+
+	open := func(receiver *keyUpdatingAEAD, p packet) error {
+		data, err := OpenWithKeyPhase(receiver, nil, p.data, p.pathID, p.pn, p.keyPhase, nil)
+		if err == nil {
+			Expect(data).To(Equal([]byte("foobar")))
+		}
+		return err
+	}
+
+	BeforeEach(func() {
+		alice, bob = newAEADs(3)
+	})
+
+	It("seals and opens", func() {
+		b := alice.Seal(nil, []byte("foobar"), 0, 42, []byte("aad"))
+		text, err := bob.Open(nil, b, 0, 42, []byte("aad"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(text).To(Equal([]byte("foobar")))
+	})
+
+	It("fails if the packet can't be opened with any keys", func() {
+		b := alice.Seal(nil, []byte("foobar"), 0, 42, []byte("aad"))
+		_, err := bob.Open(nil, b, 0, 42, []byte("aad2"))
+		Expect(err).To(MatchError("cipher: message authentication failed"))
+	})
+
+	It("is only created for AEADs that can update their keys", func() {
+		_, err := NewKeyUpdatingAEAD(&nullAEADFNV128a{}, nil)
+		Expect(err).To(MatchError(errKeyUpdatesNotSupported))
+		aead, err := NewAEADAESGCM12(make([]byte, 16), make([]byte, 16), make([]byte, 4), make([]byte, 4))
+		Expect(err).ToNot(HaveOccurred())
+		var updated bool
+		a, err := NewKeyUpdatingAEAD(aead, func(uint64, AEAD) { updated = true })
+		Expect(err).ToNot(HaveOccurred())
+		Expect(a.(*keyUpdatingAEAD).packetInterval).To(BeEquivalentTo(protocol.KeyUpdatePacketInterval))
+		Expect(a.(*keyUpdatingAEAD).timeInterval).To(Equal(protocol.KeyUpdateInterval))
+		Expect(a.(*keyUpdatingAEAD).update()).To(Succeed())
+		Expect(updated).To(BeTrue())
+	})
+
+	It("opens packets with AEADs that don't update their keys", func() {
+		aead, err := NewAEADAESGCM12(make([]byte, 16), make([]byte, 16), make([]byte, 4), make([]byte, 4))
+		Expect(err).ToNot(HaveOccurred())
+		b := aead.Seal(nil, []byte("foobar"), 0, 42, nil)
+		data, err := OpenWithKeyPhase(aead, nil, b, 0, 42, protocol.KeyPhaseZero, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal([]byte("foobar")))
+	})
+
+	It("updates the keys after sealing the packet interval", func() {
+		initialKeys := alice.current
+		for i := 0; i < 3; i++ {
+			Expect(seal(alice, 0, protocol.PacketNumber(i)).keyPhase).To(Equal(protocol.KeyPhaseZero))
+		}
+		Expect(alice.current).To(Equal(initialKeys))
+		p := seal(alice, 0, 3)
+		Expect(p.keyPhase).To(Equal(protocol.KeyPhaseOne))
+		Expect(alice.current).ToNot(Equal(initialKeys))
+		Expect(alice.previous).To(Equal(initialKeys))
+		// bob updates the keys when receiving the first packet with the new key phase
+		Expect(open(bob, p)).To(Succeed())
+		Expect(bob.keyPhase).To(Equal(protocol.KeyPhaseOne))
+		Expect(bob.previous).ToNot(BeNil())
+		p = seal(bob, 0, 0)
+		Expect(p.keyPhase).To(Equal(protocol.KeyPhaseOne))
+		Expect(open(alice, p)).To(Succeed())
+		Expect(alice.previous).To(Equal(initialKeys))
+	})
+
+	It("reports the keys of every new key phase, on both sides", func() {
+		var aliceUpdates, bobUpdates []uint64
+		alice.onUpdate = func(updates uint64, aead AEAD) {
+			Expect(aead).To(Equal(alice.current))
+			aliceUpdates = append(aliceUpdates, updates)
+		}
+		bob.onUpdate = func(updates uint64, aead AEAD) {
+			Expect(aead).To(Equal(bob.current))
+			bobUpdates = append(bobUpdates, updates)
+		}
+		for i := 0; i < 2; i++ {
+			for pn := 0; pn < 4; pn++ {
+				Expect(open(bob, seal(alice, 0, protocol.PacketNumber(4*i+pn)))).To(Succeed())
+			}
+			Expect(open(alice, seal(bob, 0, protocol.PacketNumber(i)))).To(Succeed())
+		}
+		Expect(aliceUpdates).To(Equal([]uint64{1, 2}))
+		Expect(bobUpdates).To(Equal([]uint64{1, 2}))
+	})
+
+	It("updates the keys after the time interval", func() {
+		initialKeys := alice.current
+		alice.updatedAt = time.Now().Add(-time.Hour)
+		p := seal(alice, 0, 0)
+		Expect(p.keyPhase).To(Equal(protocol.KeyPhaseOne))
+		Expect(alice.current).ToNot(Equal(initialKeys))
+		Expect(open(bob, p)).To(Succeed())
+	})
+
+	It("doesn't update the keys again before the peer used the new keys", func() {
+		var packets []packet
+		for i := 0; i < 10; i++ {
+			packets = append(packets, seal(alice, 0, protocol.PacketNumber(i)))
+		}
+		Expect(packets[9].keyPhase).To(Equal(protocol.KeyPhaseOne))
+		for i := len(packets) - 1; i >= 0; i-- {
+			Expect(open(bob, packets[i])).To(Succeed())
+		}
+		// bob used the new keys, so alice can update again
+		Expect(open(alice, seal(bob, 0, 0))).To(Succeed())
+		Expect(seal(alice, 0, 10).keyPhase).To(Equal(protocol.KeyPhaseZero))
+	})
+
+	It("opens reordered packets sealed with the previous keys", func() {
+		var packets []packet
+		for i := 0; i < 4; i++ {
+			packets = append(packets, seal(alice, 0, protocol.PacketNumber(i)))
+		}
+		Expect(open(bob, packets[3])).To(Succeed())
+		for i := 0; i < 3; i++ {
+			Expect(open(bob, packets[i])).To(Succeed())
+		}
+		Expect(bob.keyPhase).To(Equal(protocol.KeyPhaseOne))
+	})
+
+	It("rejects a packet sealed with keys that don't match the key phase", func() {
+		b := alice.next.Seal(nil, []byte("foobar"), 0, 0, nil)
+		_, err := OpenWithKeyPhase(bob, nil, b, 0, 0, protocol.KeyPhaseZero, nil)
+		Expect(err).To(MatchError("cipher: message authentication failed"))
+		Expect(bob.keyPhase).To(Equal(protocol.KeyPhaseZero))
+	})
+
+	It("forces rapid key updates on multiple paths", func() {
+		alice, bob = newAEADs(1)
+		const numPaths = 3
+		packetNumbers := make(map[*keyUpdatingAEAD][]protocol.PacketNumber)
+		packetNumbers[alice] = make([]protocol.PacketNumber, numPaths)
+		packetNumbers[bob] = make([]protocol.PacketNumber, numPaths)
+		keys := make(map[updatableAEAD]struct{})
+
+		// send packets on all paths, and lose and reorder some of them
+		send := func(sender, receiver *keyUpdatingAEAD) {
+			var packets []packet
+			for pathID := protocol.PathID(0); pathID < numPaths; pathID++ {
+				pn := packetNumbers[sender][pathID]
+				packetNumbers[sender][pathID]++
+				packets = append(packets, seal(sender, pathID, pn))
+			}
+			for _, i := range mrand.Perm(len(packets))[:2] {
+				Expect(open(receiver, packets[i])).To(Succeed())
+			}
+		}
+
+		for i := 0; i < 1000; i++ {
+			send(alice, bob)
+			send(bob, alice)
+			keys[alice.current] = struct{}{}
+		}
+		Expect(len(keys)).To(BeNumerically(">", 500))
+	})
+})
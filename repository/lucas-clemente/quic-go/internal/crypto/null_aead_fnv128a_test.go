@@ -20,28 +20,28 @@ var _ = Describe("NullAEAD using FNV128a", func() {
 	})
 
 	It("seals and opens, client => server", func() {
-		cipherText := aeadClient.Seal(nil, plainText, 0, aad)
-		res, err := aeadServer.Open(nil, cipherText, 0, aad)
+		cipherText := aeadClient.Seal(nil, plainText, 0, 0, aad)
+		res, err := aeadServer.Open(nil, cipherText, 0, 0, aad)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(res).To(Equal([]byte("They are endowed with reason and conscience and should act towards one another in a spirit of brotherhood.")))
 	})
 
 	It("seals and opens, server => client", func() {
-		cipherText := aeadServer.Seal(nil, plainText, 0, aad)
-		res, err := aeadClient.Open(nil, cipherText, 0, aad)
+		cipherText := aeadServer.Seal(nil, plainText, 0, 0, aad)
+		res, err := aeadClient.Open(nil, cipherText, 0, 0, aad)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(res).To(Equal([]byte("They are endowed with reason and conscience and should act towards one another in a spirit of brotherhood.")))
 	})
 
 	It("rejects short ciphertexts", func() {
-		_, err := aeadServer.Open(nil, nil, 0, nil)
+		_, err := aeadServer.Open(nil, nil, 0, 0, nil)
 		Expect(err).To(MatchError("NullAEAD: ciphertext cannot be less than 12 bytes long"))
 	})
 
 	It("seals in-place", func() {
 		buf := make([]byte, 6, 12+6)
 		copy(buf, []byte("foobar"))
-		res := aeadServer.Seal(buf[0:0], buf, 0, nil)
+		res := aeadServer.Seal(buf[0:0], buf, 0, 0, nil)
 		buf = buf[:12+6]
 		Expect(buf[12:]).To(Equal([]byte("foobar")))
 		Expect(res[12:]).To(Equal([]byte("foobar")))
@@ -49,7 +49,7 @@ var _ = Describe("NullAEAD using FNV128a", func() {
 
 	It("fails", func() {
 		cipherText := append(append(hash36, plainText...), byte(0x42))
-		_, err := aeadClient.Open(nil, cipherText, 0, aad)
+		_, err := aeadClient.Open(nil, cipherText, 0, 0, aad)
 		Expect(err).To(HaveOccurred())
 	})
 })
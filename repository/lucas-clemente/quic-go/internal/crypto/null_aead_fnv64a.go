@@ -13,7 +13,7 @@ type nullAEADFNV64a struct{}
 var _ AEAD = &nullAEADFNV64a{}
 
 // Open and verify the ciphertext
-func (n *nullAEADFNV64a) Open(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error) {
+func (n *nullAEADFNV64a) Open(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error) {
 	if len(src) < 8 {
 		return nil, errors.New("NullAEAD: ciphertext cannot be less than 8 bytes long")
 	}
@@ -30,7 +30,7 @@ func (n *nullAEADFNV64a) Open(dst, src []byte, packetNumber protocol.PacketNumbe
 }
 
 // Seal writes hash and ciphertext to the buffer
-func (n *nullAEADFNV64a) Seal(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) []byte {
+func (n *nullAEADFNV64a) Seal(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) []byte {
 	if cap(dst) < 8+len(src) {
 		dst = make([]byte, 8+len(src))
 	} else {
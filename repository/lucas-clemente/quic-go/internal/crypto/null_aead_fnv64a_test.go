@@ -24,18 +24,18 @@ var _ = Describe("NullAEAD using FNV128a", func() {
 	})
 
 	It("opens", func() {
-		data, err := aead.Open(nil, append(plainText, hash64...), 0, aad)
+		data, err := aead.Open(nil, append(plainText, hash64...), 0, 0, aad)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(data).To(Equal(plainText))
 	})
 
 	It("fails", func() {
-		_, err := aead.Open(nil, append(plainText, hash64...), 0, append(aad, []byte{0x42}...))
+		_, err := aead.Open(nil, append(plainText, hash64...), 0, 0, append(aad, []byte{0x42}...))
 		Expect(err).To(MatchError("NullAEAD: failed to authenticate received data"))
 	})
 
 	It("rejects short ciphertexts", func() {
-		_, err := aead.Open(nil, []byte{1, 2, 3, 4, 5, 6, 7}, 0, []byte{})
+		_, err := aead.Open(nil, []byte{1, 2, 3, 4, 5, 6, 7}, 0, 0, []byte{})
 		Expect(err).To(MatchError("NullAEAD: ciphertext cannot be less than 8 bytes long"))
 	})
 
@@ -43,13 +43,13 @@ var _ = Describe("NullAEAD using FNV128a", func() {
 		hash := fnv.New64a()
 		h := make([]byte, 8)
 		binary.BigEndian.PutUint64(h, hash.Sum64())
-		data, err := aead.Open(nil, h, 0, []byte{})
+		data, err := aead.Open(nil, h, 0, 0, []byte{})
 		Expect(err).ToNot(HaveOccurred())
 		Expect(data).To(BeEmpty())
 	})
 
 	It("seals", func() {
-		sealed := aead.Seal(nil, plainText, 0, aad)
+		sealed := aead.Seal(nil, plainText, 0, 0, aad)
 		Expect(sealed).To(Equal(append(plainText, hash64...)))
 		Expect(sealed).To(HaveLen(len(plainText) + aead.Overhead()))
 	})
@@ -57,7 +57,7 @@ var _ = Describe("NullAEAD using FNV128a", func() {
 	It("seals in-place", func() {
 		buf := make([]byte, 6, 6+8)
 		copy(buf, []byte("foobar"))
-		res := aead.Seal(buf[0:0], buf, 0, nil)
+		res := aead.Seal(buf[0:0], buf, 0, 0, nil)
 		// buf = buf[:8+6]
 		Expect(buf[:6]).To(Equal([]byte("foobar")))
 		// Expect(res[:6]).To(Equal([]byte("foobar")))
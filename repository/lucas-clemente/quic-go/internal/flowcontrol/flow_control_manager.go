@@ -18,6 +18,10 @@ type flowControlManager struct {
 	rttStats             *congestion.RTTStats
 	remoteRTTs           map[protocol.PathID]time.Duration
 
+	// limits of the auto-tuning of the stream-level windows beyond their maximum, and the last bandwidth-delay product
+	maxAutoTunedStreamWindow protocol.ByteCount
+	bdp                      protocol.ByteCount
+
 	streamFlowController map[protocol.StreamID]*flowController
 	connFlowController   *flowController
 	mutex                sync.RWMutex
@@ -27,14 +31,19 @@ var _ FlowControlManager = &flowControlManager{}
 
 var errMapAccess = errors.New("Error accessing the flowController map.")
 
-// NewFlowControlManager creates a new flow control manager
-func NewFlowControlManager(connectionParameters handshake.ConnectionParametersManager, rttStats *congestion.RTTStats, remoteRTTs map[protocol.PathID]time.Duration) FlowControlManager {
+// NewFlowControlManager creates a new flow control manager.
+// The receive windows grow beyond their maximum, up to maxAutoTunedStreamWindow and maxAutoTunedConnectionWindow,
+// if the bandwidth-delay product requires it. A limit of 0 disables this.
+func NewFlowControlManager(connectionParameters handshake.ConnectionParametersManager, rttStats *congestion.RTTStats, remoteRTTs map[protocol.PathID]time.Duration, maxAutoTunedStreamWindow, maxAutoTunedConnectionWindow protocol.ByteCount) FlowControlManager {
+	connFlowController := newFlowController(0, false, connectionParameters, rttStats, remoteRTTs)
+	connFlowController.SetAutoTuningLimit(maxAutoTunedConnectionWindow)
 	return &flowControlManager{
-		connectionParameters: connectionParameters,
-		rttStats:             rttStats,
-		remoteRTTs:           remoteRTTs,
-		streamFlowController: make(map[protocol.StreamID]*flowController),
-		connFlowController:   newFlowController(0, false, connectionParameters, rttStats, remoteRTTs),
+		connectionParameters:     connectionParameters,
+		rttStats:                 rttStats,
+		remoteRTTs:               remoteRTTs,
+		maxAutoTunedStreamWindow: maxAutoTunedStreamWindow,
+		streamFlowController:     make(map[protocol.StreamID]*flowController),
+		connFlowController:       connFlowController,
 	}
 }
 
@@ -44,11 +53,42 @@ func (f *flowControlManager) NewStream(streamID protocol.StreamID, contributesTo
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 
+	f.newStreamImpl(streamID, contributesToConnection)
+}
+
+// NewSendOnlyStream creates a flow controller for a unidirectional stream opened by us.
+// It only has a send window.
+func (f *flowControlManager) NewSendOnlyStream(streamID protocol.StreamID) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if fc := f.newStreamImpl(streamID, true); fc != nil {
+		fc.makeSendOnly()
+	}
+}
+
+// NewReceiveOnlyStream creates a flow controller for a unidirectional stream opened by the peer.
+// It only has a receive window.
+func (f *flowControlManager) NewReceiveOnlyStream(streamID protocol.StreamID) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if fc := f.newStreamImpl(streamID, true); fc != nil {
+		fc.makeReceiveOnly()
+	}
+}
+
+// newStreamImpl returns nil if the stream already exists
+func (f *flowControlManager) newStreamImpl(streamID protocol.StreamID, contributesToConnection bool) *flowController {
 	if _, ok := f.streamFlowController[streamID]; ok {
-		return
+		return nil
 	}
 
-	f.streamFlowController[streamID] = newFlowController(streamID, contributesToConnection, f.connectionParameters, f.rttStats, f.remoteRTTs)
+	fc := newFlowController(streamID, contributesToConnection, f.connectionParameters, f.rttStats, f.remoteRTTs)
+	fc.SetAutoTuningLimit(f.maxAutoTunedStreamWindow)
+	fc.UpdateBandwidthDelayProduct(f.bdp)
+	f.streamFlowController[streamID] = fc
+	return fc
 }
 
 // RemoveStream removes a closed stream from flow control
@@ -135,12 +175,27 @@ func (f *flowControlManager) AddBytesRead(streamID protocol.StreamID, n protocol
 	return nil
 }
 
+// UpdateBandwidthDelayProduct sets the sum of the bandwidth-delay products of the paths, used to auto-tune the receive windows
+func (f *flowControlManager) UpdateBandwidthDelayProduct(bdp protocol.ByteCount) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.bdp = bdp
+	f.connFlowController.UpdateBandwidthDelayProduct(bdp)
+	for _, fc := range f.streamFlowController {
+		fc.UpdateBandwidthDelayProduct(bdp)
+	}
+}
+
 func (f *flowControlManager) GetWindowUpdates(force bool) (res []WindowUpdate) {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 
 	// get WindowUpdates for streams
 	for id, fc := range f.streamFlowController {
+		if fc.sendOnly {
+			continue
+		}
 		if necessary, newIncrement, offset := fc.MaybeUpdateWindow(); force || necessary {
 			res = append(res, WindowUpdate{StreamID: id, Offset: offset})
 			if fc.ContributesToConnection() && newIncrement != 0 {
@@ -234,6 +289,27 @@ func (f *flowControlManager) GetBytesRetrans(streamID protocol.StreamID) (protoc
 	return fc.GetBytesRetrans(), nil
 }
 
+// streamID may be 0 here
+func (f *flowControlManager) GetWindowStatistics(streamID protocol.StreamID) (WindowStatistics, error) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	fc := f.connFlowController
+	if streamID != 0 {
+		var err error
+		fc, err = f.getFlowController(streamID)
+		if err != nil {
+			return WindowStatistics{}, err
+		}
+	}
+
+	return WindowStatistics{
+		ReceiveWindow:          fc.receiveWindow,
+		ReceiveWindowIncrement: fc.receiveWindowIncrement,
+		SendWindow:             fc.SendWindowOffset(),
+	}, nil
+}
+
 // must not be called with StreamID 0
 func (f *flowControlManager) SendWindowSize(streamID protocol.StreamID) (protocol.ByteCount, error) {
 	f.mutex.RLock()
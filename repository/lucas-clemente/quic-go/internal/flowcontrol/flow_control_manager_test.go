@@ -20,7 +20,7 @@ var _ = Describe("Flow Control Manager", func() {
 		mockCpm.EXPECT().GetReceiveConnectionFlowControlWindow().AnyTimes().Return(protocol.ByteCount(200))
 		mockCpm.EXPECT().GetMaxReceiveStreamFlowControlWindow().AnyTimes().Return(protocol.MaxByteCount)
 		mockCpm.EXPECT().GetMaxReceiveConnectionFlowControlWindow().AnyTimes().Return(protocol.MaxByteCount)
-		fcm = NewFlowControlManager(mockCpm, &congestion.RTTStats{}, make(map[protocol.PathID]time.Duration)).(*flowControlManager)
+		fcm = NewFlowControlManager(mockCpm, &congestion.RTTStats{}, make(map[protocol.PathID]time.Duration), protocol.MaxAutoTunedReceiveStreamFlowControlWindow, protocol.MaxAutoTunedReceiveConnectionFlowControlWindow).(*flowControlManager)
 	})
 
 	It("creates a connection level flow controller", func() {
@@ -46,6 +46,42 @@ var _ = Describe("Flow Control Manager", func() {
 			Expect(fc.bytesRead).To(BeEquivalentTo(0x1337))
 			Expect(fc.ContributesToConnection()).To(BeTrue())
 		})
+
+		It("creates a send-only stream without a receive window", func() {
+			fcm.NewSendOnlyStream(5)
+			Expect(fcm.streamFlowController).To(HaveKey(protocol.StreamID(5)))
+			rcvWindow, err := fcm.GetReceiveWindow(5)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rcvWindow).To(BeZero())
+			Expect(fcm.UpdateHighestReceived(5, 1)).To(MatchError(qerr.Error(qerr.FlowControlReceivedTooMuchData, "Received 1 bytes on stream 5, allowed 0 bytes")))
+		})
+
+		It("doesn't send WindowUpdates for send-only streams", func() {
+			fcm.NewSendOnlyStream(5)
+			Expect(fcm.GetWindowUpdates(true)).To(Equal([]WindowUpdate{{StreamID: 0, Offset: 200}}))
+		})
+
+		It("creates a receive-only stream without a send window", func() {
+			fcm.NewReceiveOnlyStream(5)
+			Expect(fcm.streamFlowController).To(HaveKey(protocol.StreamID(5)))
+			Expect(fcm.streamFlowController[5].ContributesToConnection()).To(BeTrue())
+			rcvWindow, err := fcm.GetReceiveWindow(5)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rcvWindow).To(Equal(protocol.ByteCount(100)))
+			updated, err := fcm.UpdateWindow(5, 1000)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updated).To(BeFalse())
+			Expect(fcm.streamFlowController[5].SendWindowSize()).To(BeZero())
+		})
+	})
+
+	It("updates the bandwidth-delay product of all flow controllers", func() {
+		fcm.NewStream(5, true)
+		fcm.UpdateBandwidthDelayProduct(1000)
+		Expect(fcm.connFlowController.bdp).To(Equal(protocol.ByteCount(1000)))
+		Expect(fcm.streamFlowController[5].bdp).To(Equal(protocol.ByteCount(1000)))
+		fcm.NewStream(7, true)
+		Expect(fcm.streamFlowController[7].bdp).To(Equal(protocol.ByteCount(1000)))
 	})
 
 	It("removes streams", func() {
@@ -107,6 +143,27 @@ var _ = Describe("Flow Control Manager", func() {
 			Expect(offset).To(Equal(protocol.ByteCount(200)))
 		})
 
+		It("gets the window statistics of a stream", func() {
+			_, err := fcm.UpdateWindow(4, 1000)
+			Expect(err).ToNot(HaveOccurred())
+			stats, err := fcm.GetWindowStatistics(4)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats).To(Equal(WindowStatistics{ReceiveWindow: 100, ReceiveWindowIncrement: 100, SendWindow: 1000}))
+		})
+
+		It("gets the window statistics of the connection", func() {
+			_, err := fcm.UpdateWindow(0, 2000)
+			Expect(err).ToNot(HaveOccurred())
+			stats, err := fcm.GetWindowStatistics(0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats).To(Equal(WindowStatistics{ReceiveWindow: 200, ReceiveWindowIncrement: 200, SendWindow: 2000}))
+		})
+
+		It("errors when asked for the window statistics of a stream that doesn't exist", func() {
+			_, err := fcm.GetWindowStatistics(17)
+			Expect(err).To(MatchError(errMapAccess))
+		})
+
 		Context("flow control violations", func() {
 			It("errors when encountering a stream level flow control violation", func() {
 				err := fcm.UpdateHighestReceived(4, 101)
@@ -13,6 +13,9 @@ import (
 type flowController struct {
 	streamID                protocol.StreamID
 	contributesToConnection bool // does the stream contribute to connection level flow control
+	// unidirectional streams are only flow controlled in one direction
+	sendOnly    bool
+	receiveOnly bool
 
 	connectionParameters handshake.ConnectionParametersManager
 	rttStats             *congestion.RTTStats
@@ -31,6 +34,12 @@ type flowController struct {
 	receiveWindow             protocol.ByteCount
 	receiveWindowIncrement    protocol.ByteCount
 	maxReceiveWindowIncrement protocol.ByteCount
+
+	// the sum of the bandwidth-delay products of the paths
+	bdp protocol.ByteCount
+	// the increment can grow beyond maxReceiveWindowIncrement up to this value, if the bandwidth-delay product requires it.
+	// It is equal to maxReceiveWindowIncrement if the maximum was configured.
+	maxAutoTunedReceiveWindowIncrement protocol.ByteCount
 }
 
 // ErrReceivedSmallerByteOffset occurs if the ByteOffset received is smaller than a ByteOffset that was set previously
@@ -55,10 +64,26 @@ func newFlowController(streamID protocol.StreamID, contributesToConnection bool,
 		fc.receiveWindowIncrement = fc.receiveWindow
 		fc.maxReceiveWindowIncrement = connectionParameters.GetMaxReceiveStreamFlowControlWindow()
 	}
+	fc.maxAutoTunedReceiveWindowIncrement = fc.maxReceiveWindowIncrement
 
 	return &fc
 }
 
+// makeSendOnly removes the receive window of the sending half of a unidirectional stream.
+// The peer must not send any data on it, and no WindowUpdates are sent for it.
+func (c *flowController) makeSendOnly() {
+	c.sendOnly = true
+	c.receiveWindow = 0
+	c.receiveWindowIncrement = 0
+	c.maxReceiveWindowIncrement = 0
+	c.maxAutoTunedReceiveWindowIncrement = 0
+}
+
+// makeReceiveOnly makes the flow controller ignore WindowUpdates for the receiving half of a unidirectional stream
+func (c *flowController) makeReceiveOnly() {
+	c.receiveOnly = true
+}
+
 func (c *flowController) ContributesToConnection() bool {
 	return c.contributesToConnection
 }
@@ -92,6 +117,9 @@ func (c *flowController) GetBytesRetrans() protocol.ByteCount {
 // UpdateSendWindow should be called after receiving a WindowUpdateFrame
 // it returns true if the window was actually updated
 func (c *flowController) UpdateSendWindow(newOffset protocol.ByteCount) bool {
+	if c.receiveOnly {
+		return false
+	}
 	if newOffset > c.sendWindow {
 		c.sendWindow = newOffset
 		return true
@@ -100,6 +128,9 @@ func (c *flowController) UpdateSendWindow(newOffset protocol.ByteCount) bool {
 }
 
 func (c *flowController) SendWindowSize() protocol.ByteCount {
+	if c.receiveOnly {
+		return 0
+	}
 	sendWindow := c.getSendWindow()
 
 	if c.bytesSent > sendWindow { // should never happen, but make sure we don't do an underflow here
@@ -192,7 +223,7 @@ func (c *flowController) maybeAdjustWindowIncrement() {
 	}
 
 	oldWindowSize := c.receiveWindowIncrement
-	c.receiveWindowIncrement = utils.MinByteCount(2*c.receiveWindowIncrement, c.maxReceiveWindowIncrement)
+	c.receiveWindowIncrement = utils.MinByteCount(2*c.receiveWindowIncrement, c.getMaxReceiveWindowIncrement())
 
 	// debug log, if the window size was actually increased
 	if oldWindowSize < c.receiveWindowIncrement {
@@ -205,12 +236,30 @@ func (c *flowController) maybeAdjustWindowIncrement() {
 	}
 }
 
+// SetAutoTuningLimit allows the increment to grow beyond its maximum, up to limit, if the bandwidth-delay product requires it
+func (c *flowController) SetAutoTuningLimit(limit protocol.ByteCount) {
+	c.maxAutoTunedReceiveWindowIncrement = utils.MaxByteCount(c.maxReceiveWindowIncrement, limit)
+}
+
+// UpdateBandwidthDelayProduct sets the sum of the bandwidth-delay products of the paths
+func (c *flowController) UpdateBandwidthDelayProduct(bdp protocol.ByteCount) {
+	c.bdp = bdp
+}
+
+// getMaxReceiveWindowIncrement gets the maximum increment.
+// It is larger than the default maximum if twice the bandwidth-delay product exceeds it,
+// so that the window doesn't limit the aggregated throughput of long-fat paths.
+func (c *flowController) getMaxReceiveWindowIncrement() protocol.ByteCount {
+	return utils.MaxByteCount(c.maxReceiveWindowIncrement, utils.MinByteCount(2*c.bdp, c.maxAutoTunedReceiveWindowIncrement))
+}
+
 // EnsureMinimumWindowIncrement sets a minimum window increment
 // it is intended be used for the connection-level flow controller
 // it should make sure that the connection-level window is increased when a stream-level window grows
 func (c *flowController) EnsureMinimumWindowIncrement(inc protocol.ByteCount) {
 	if inc > c.receiveWindowIncrement {
-		c.receiveWindowIncrement = utils.MinByteCount(inc, c.maxReceiveWindowIncrement)
+		// stream-level windows only grow beyond their maximum if the bandwidth-delay product requires it
+		c.receiveWindowIncrement = utils.MinByteCount(inc, utils.MaxByteCount(c.maxReceiveWindowIncrement, c.maxAutoTunedReceiveWindowIncrement))
 		c.lastWindowUpdateTime = time.Time{} // disables autotuning for the next window update
 	}
 }
@@ -38,6 +38,7 @@ var _ = Describe("Flow controller", func() {
 			Expect(fc.streamID).To(Equal(protocol.StreamID(5)))
 			Expect(fc.receiveWindow).To(Equal(protocol.ByteCount(2000)))
 			Expect(fc.maxReceiveWindowIncrement).To(Equal(mockCpm.GetMaxReceiveStreamFlowControlWindow()))
+			Expect(fc.maxAutoTunedReceiveWindowIncrement).To(Equal(mockCpm.GetMaxReceiveStreamFlowControlWindow()))
 		})
 
 		It("reads the stream send and receive windows when acting as connection-level flow controller", func() {
@@ -45,6 +46,15 @@ var _ = Describe("Flow controller", func() {
 			Expect(fc.streamID).To(Equal(protocol.StreamID(0)))
 			Expect(fc.receiveWindow).To(Equal(protocol.ByteCount(4000)))
 			Expect(fc.maxReceiveWindowIncrement).To(Equal(mockCpm.GetMaxReceiveConnectionFlowControlWindow()))
+			Expect(fc.maxAutoTunedReceiveWindowIncrement).To(Equal(mockCpm.GetMaxReceiveConnectionFlowControlWindow()))
+		})
+
+		It("allows the increment to grow beyond the maximum if an auto-tuning limit is set", func() {
+			fc := newFlowController(5, true, mockCpm, rttStats, make(map[protocol.PathID]time.Duration))
+			fc.SetAutoTuningLimit(20000)
+			Expect(fc.maxAutoTunedReceiveWindowIncrement).To(Equal(protocol.ByteCount(20000)))
+			fc.SetAutoTuningLimit(0)
+			Expect(fc.maxAutoTunedReceiveWindowIncrement).To(Equal(mockCpm.GetMaxReceiveStreamFlowControlWindow()))
 		})
 
 		It("does not set the stream flow control windows for sending", func() {
@@ -323,6 +333,55 @@ var _ = Describe("Flow controller", func() {
 				Expect(offset).To(Equal(protocol.ByteCount(9900 + oldIncrement)))
 			})
 
+			Context("bandwidth-delay product", func() {
+				BeforeEach(func() {
+					controller.receiveWindowIncrement = controller.maxReceiveWindowIncrement
+					oldIncrement = controller.receiveWindowIncrement
+					controller.maxAutoTunedReceiveWindowIncrement = 100000
+				})
+
+				It("increases the increment beyond the maximum if the bandwidth-delay product requires it", func() {
+					setRtt(20 * time.Millisecond)
+					controller.lastWindowUpdateTime = time.Now().Add(-35 * time.Millisecond)
+					controller.UpdateBandwidthDelayProduct(5000)
+					controller.maybeAdjustWindowIncrement()
+					Expect(controller.receiveWindowIncrement).To(Equal(2 * oldIncrement))
+				})
+
+				It("doesn't increase the increment beyond the maximum for a small bandwidth-delay product", func() {
+					setRtt(20 * time.Millisecond)
+					controller.lastWindowUpdateTime = time.Now().Add(-35 * time.Millisecond)
+					controller.UpdateBandwidthDelayProduct(100)
+					controller.maybeAdjustWindowIncrement()
+					Expect(controller.receiveWindowIncrement).To(Equal(oldIncrement))
+				})
+
+				It("doesn't increase the increment beyond the auto-tuning maximum", func() {
+					controller.SetAutoTuningLimit(4000)
+					setRtt(20 * time.Millisecond)
+					controller.lastWindowUpdateTime = time.Now().Add(-35 * time.Millisecond)
+					controller.UpdateBandwidthDelayProduct(5000)
+					controller.maybeAdjustWindowIncrement()
+					Expect(controller.receiveWindowIncrement).To(Equal(protocol.ByteCount(4000)))
+				})
+
+				It("treats a configured maximum as a hard limit", func() {
+					controller.SetAutoTuningLimit(0)
+					setRtt(20 * time.Millisecond)
+					controller.lastWindowUpdateTime = time.Now().Add(-35 * time.Millisecond)
+					controller.UpdateBandwidthDelayProduct(50000)
+					controller.maybeAdjustWindowIncrement()
+					Expect(controller.receiveWindowIncrement).To(Equal(oldIncrement))
+					controller.EnsureMinimumWindowIncrement(50000)
+					Expect(controller.receiveWindowIncrement).To(Equal(oldIncrement))
+				})
+
+				It("lets the connection-level window follow a stream-level window beyond the maximum", func() {
+					controller.EnsureMinimumWindowIncrement(50000)
+					Expect(controller.receiveWindowIncrement).To(Equal(protocol.ByteCount(50000)))
+				})
+			})
+
 			Context("setting the minimum increment", func() {
 				It("sets the minimum window increment", func() {
 					controller.EnsureMinimumWindowIncrement(1000)
@@ -8,9 +8,18 @@ type WindowUpdate struct {
 	Offset   protocol.ByteCount
 }
 
+// WindowStatistics contains the current flow control windows of a stream, or of the connection for StreamID 0
+type WindowStatistics struct {
+	ReceiveWindow          protocol.ByteCount
+	ReceiveWindowIncrement protocol.ByteCount
+	SendWindow             protocol.ByteCount
+}
+
 // A FlowControlManager manages the flow control
 type FlowControlManager interface {
 	NewStream(streamID protocol.StreamID, contributesToConnectionFlow bool)
+	NewSendOnlyStream(streamID protocol.StreamID)
+	NewReceiveOnlyStream(streamID protocol.StreamID)
 	RemoveStream(streamID protocol.StreamID)
 	// methods needed for receiving data
 	ResetStream(streamID protocol.StreamID, byteOffset protocol.ByteCount) error
@@ -27,4 +36,6 @@ type FlowControlManager interface {
 	GetBytesSent(streamID protocol.StreamID) (protocol.ByteCount, error)
 	AddBytesRetrans(streamID protocol.StreamID, n protocol.ByteCount) error
 	GetBytesRetrans(streamID protocol.StreamID) (protocol.ByteCount, error)
+	GetWindowStatistics(streamID protocol.StreamID) (WindowStatistics, error)
+	UpdateBandwidthDelayProduct(bdp protocol.ByteCount)
 }
@@ -30,8 +30,16 @@ type ConnectionParametersManager interface {
 	GetMaxReceiveConnectionFlowControlWindow() protocol.ByteCount
 	GetMaxOutgoingStreams() uint32
 	GetMaxIncomingStreams() uint32
+	GetMaxOutgoingUniStreams() uint32
+	GetMaxIncomingUniStreams() uint32
 	GetIdleConnectionStateLifetime() time.Duration
+	GetAckSendDelay() time.Duration
+	GetRetransmittablePacketsBeforeAck() int
 	TruncateConnectionID() bool
+	PeerSupportsMultipath() bool
+	PeerSupportsUnreliableStreams() bool
+	PeerAcceptsAcksOnOtherPaths() bool
+	PeerSupportsStopSending() bool
 }
 
 type connectionParametersManager struct {
@@ -45,6 +53,7 @@ type connectionParametersManager struct {
 	truncateConnectionID                   bool
 	maxStreamsPerConnection                uint32
 	maxIncomingDynamicStreamsPerConnection uint32
+	maxOutgoingUniStreamsPerConnection     uint32
 	idleConnectionStateLifetime            time.Duration
 	sendStreamFlowControlWindow            protocol.ByteCount
 	sendConnectionFlowControlWindow        protocol.ByteCount
@@ -52,6 +61,22 @@ type connectionParametersManager struct {
 	receiveConnectionFlowControlWindow     protocol.ByteCount
 	maxReceiveStreamFlowControlWindow      protocol.ByteCount
 	maxReceiveConnectionFlowControlWindow  protocol.ByteCount
+
+	// the ACK frequency requested by the peer, used for acknowledging its packets
+	ackSendDelay                    time.Duration
+	retransmittablePacketsBeforeAck int
+	// the ACK frequency we request from the peer, zero values keep its defaults
+	requestedAckSendDelay                    time.Duration
+	requestedRetransmittablePacketsBeforeAck uint32
+
+	// the capabilities of the peer
+	// With QUIC crypto, they are not negotiated. With TLS, they are announced in the transport parameters.
+	peerSupportsMultipath         bool
+	peerSupportsUnreliableStreams bool
+	// announced with both handshakes, older versions of mpquic only accept the ACKs for a path on this path
+	peerAcceptsAcksOnOtherPaths bool
+	// announced with both handshakes, older versions of mpquic don't know the STOP_SENDING frame
+	peerSupportsStopSending bool
 }
 
 var _ ConnectionParametersManager = &connectionParametersManager{}
@@ -69,6 +94,8 @@ func NewConnectionParamatersManager(
 	maxReceiveStreamFlowControlWindow protocol.ByteCount,
 	maxReceiveConnectionFlowControlWindow protocol.ByteCount,
 	idleTimeout time.Duration,
+	requestedAckSendDelay time.Duration,
+	requestedRetransmittablePacketsBeforeAck uint32,
 ) ConnectionParametersManager {
 	h := &connectionParametersManager{
 		perspective:                           pers,
@@ -82,6 +109,14 @@ func NewConnectionParamatersManager(
 	}
 
 	h.idleConnectionStateLifetime = idleTimeout
+	h.ackSendDelay = protocol.AckSendDelay
+	h.retransmittablePacketsBeforeAck = protocol.RetransmittablePacketsBeforeAck
+	h.requestedAckSendDelay = requestedAckSendDelay
+	h.requestedRetransmittablePacketsBeforeAck = requestedRetransmittablePacketsBeforeAck
+	if !v.UsesTLS() {
+		h.peerSupportsMultipath = true
+		h.peerSupportsUnreliableStreams = true
+	}
 	if h.perspective == protocol.PerspectiveServer {
 		h.maxStreamsPerConnection = protocol.MaxStreamsPerConnection                // this is the value negotiated based on what the client sent
 		h.maxIncomingDynamicStreamsPerConnection = protocol.MaxStreamsPerConnection // "incoming" seen from the client's perspective
@@ -119,6 +154,13 @@ func (h *connectionParametersManager) SetFromMap(params map[Tag][]byte) error {
 		}
 		h.maxIncomingDynamicStreamsPerConnection = h.negotiateMaxIncomingDynamicStreamsPerConnection(clientValue)
 	}
+	if value, ok := params[TagMIUS]; ok {
+		peerValue, err := utils.LittleEndian.ReadUint32(bytes.NewBuffer(value))
+		if err != nil {
+			return ErrMalformedTag
+		}
+		h.maxOutgoingUniStreamsPerConnection = h.negotiateMaxOutgoingUniStreamsPerConnection(peerValue)
+	}
 	if value, ok := params[TagICSL]; ok {
 		clientValue, err := utils.LittleEndian.ReadUint32(bytes.NewBuffer(value))
 		if err != nil {
@@ -147,6 +189,36 @@ func (h *connectionParametersManager) SetFromMap(params map[Tag][]byte) error {
 		h.sendConnectionFlowControlWindow = protocol.ByteCount(sendConnectionFlowControlWindow)
 	}
 
+	if value, ok := params[TagACKD]; ok {
+		ackDelay, err := utils.LittleEndian.ReadUint32(bytes.NewBuffer(value))
+		if err != nil {
+			return ErrMalformedTag
+		}
+		h.ackSendDelay = h.negotiateAckSendDelay(time.Duration(ackDelay) * time.Microsecond)
+	}
+	if value, ok := params[TagACKF]; ok {
+		packets, err := utils.LittleEndian.ReadUint32(bytes.NewBuffer(value))
+		if err != nil {
+			return ErrMalformedTag
+		}
+		h.retransmittablePacketsBeforeAck = h.negotiateRetransmittablePacketsBeforeAck(packets)
+	}
+
+	if _, ok := params[TagACKP]; ok {
+		h.peerAcceptsAcksOnOtherPaths = true
+	}
+	if _, ok := params[TagSTPS]; ok {
+		h.peerSupportsStopSending = true
+	}
+	if h.version.UsesTLS() {
+		if _, ok := params[TagMPTH]; ok {
+			h.peerSupportsMultipath = true
+		}
+		if _, ok := params[TagUNRS]; ok {
+			h.peerSupportsUnreliableStreams = true
+		}
+	}
+
 	_, containsSFCW := params[TagSFCW]
 	_, containsCFCW := params[TagCFCW]
 	if containsCFCW || containsSFCW {
@@ -164,10 +236,28 @@ func (h *connectionParametersManager) negotiateMaxIncomingDynamicStreamsPerConne
 	return utils.MinUint32(clientValue, protocol.MaxIncomingDynamicStreamsPerConnection)
 }
 
+func (h *connectionParametersManager) negotiateMaxOutgoingUniStreamsPerConnection(peerValue uint32) uint32 {
+	return utils.MinUint32(peerValue, protocol.MaxIncomingUniStreamsPerConnection)
+}
+
 func (h *connectionParametersManager) negotiateIdleConnectionStateLifetime(clientValue time.Duration) time.Duration {
 	return utils.MinDuration(clientValue, h.idleConnectionStateLifetime)
 }
 
+func (h *connectionParametersManager) negotiateAckSendDelay(peerValue time.Duration) time.Duration {
+	if peerValue == 0 {
+		return protocol.AckSendDelay
+	}
+	return utils.MinDuration(peerValue, protocol.MaxAckSendDelay)
+}
+
+func (h *connectionParametersManager) negotiateRetransmittablePacketsBeforeAck(peerValue uint32) int {
+	if peerValue == 0 {
+		return protocol.RetransmittablePacketsBeforeAck
+	}
+	return int(utils.MinUint32(peerValue, protocol.MaxRetransmittablePacketsBeforeAck))
+}
+
 // GetHelloMap gets all parameters needed for the Hello message
 func (h *connectionParametersManager) GetHelloMap() (map[Tag][]byte, error) {
 	sfcw := bytes.NewBuffer([]byte{})
@@ -178,16 +268,40 @@ func (h *connectionParametersManager) GetHelloMap() (map[Tag][]byte, error) {
 	utils.LittleEndian.WriteUint32(mspc, h.maxStreamsPerConnection)
 	mids := bytes.NewBuffer([]byte{})
 	utils.LittleEndian.WriteUint32(mids, protocol.MaxIncomingDynamicStreamsPerConnection)
+	mius := bytes.NewBuffer([]byte{})
+	utils.LittleEndian.WriteUint32(mius, protocol.MaxIncomingUniStreamsPerConnection)
 	icsl := bytes.NewBuffer([]byte{})
 	utils.LittleEndian.WriteUint32(icsl, uint32(h.GetIdleConnectionStateLifetime()/time.Second))
 
-	return map[Tag][]byte{
+	tags := map[Tag][]byte{
 		TagICSL: icsl.Bytes(),
 		TagMSPC: mspc.Bytes(),
 		TagMIDS: mids.Bytes(),
+		TagMIUS: mius.Bytes(),
 		TagCFCW: cfcw.Bytes(),
 		TagSFCW: sfcw.Bytes(),
-	}, nil
+	}
+	// only request an ACK frequency if it was configured, peers that don't know the tags ignore them
+	if h.requestedAckSendDelay != 0 {
+		ackd := bytes.NewBuffer([]byte{})
+		utils.LittleEndian.WriteUint32(ackd, uint32(h.requestedAckSendDelay/time.Microsecond))
+		tags[TagACKD] = ackd.Bytes()
+	}
+	if h.requestedRetransmittablePacketsBeforeAck != 0 {
+		ackf := bytes.NewBuffer([]byte{})
+		utils.LittleEndian.WriteUint32(ackf, h.requestedRetransmittablePacketsBeforeAck)
+		tags[TagACKF] = ackf.Bytes()
+	}
+	if h.version >= protocol.VersionMP {
+		tags[TagACKP] = []byte{}
+		tags[TagSTPS] = []byte{}
+	}
+	// with QUIC crypto, the capabilities are not negotiated
+	if h.version.UsesTLS() && h.version >= protocol.VersionMP {
+		tags[TagMPTH] = []byte{}
+		tags[TagUNRS] = []byte{}
+	}
+	return tags, nil
 }
 
 // GetSendStreamFlowControlWindow gets the size of the stream-level flow control window for sending data
@@ -245,6 +359,19 @@ func (h *connectionParametersManager) GetMaxIncomingStreams() uint32 {
 	return utils.MaxUint32(uint32(maxStreams)+protocol.MaxStreamsMinimumIncrement, uint32(float64(maxStreams)*protocol.MaxStreamsMultiplier))
 }
 
+// GetMaxOutgoingUniStreams gets the maximum number of outgoing unidirectional streams per connection
+// It is 0 until the peer announced how many unidirectional streams it accepts
+func (h *connectionParametersManager) GetMaxOutgoingUniStreams() uint32 {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.maxOutgoingUniStreamsPerConnection
+}
+
+// GetMaxIncomingUniStreams gets the maximum number of incoming unidirectional streams per connection
+func (h *connectionParametersManager) GetMaxIncomingUniStreams() uint32 {
+	return protocol.MaxIncomingUniStreamsPerConnection
+}
+
 // GetIdleConnectionStateLifetime gets the idle timeout
 func (h *connectionParametersManager) GetIdleConnectionStateLifetime() time.Duration {
 	h.mutex.RLock()
@@ -252,6 +379,20 @@ func (h *connectionParametersManager) GetIdleConnectionStateLifetime() time.Dura
 	return h.idleConnectionStateLifetime
 }
 
+// GetAckSendDelay gets the maximum delay before acknowledging a retransmittable packet of the peer
+func (h *connectionParametersManager) GetAckSendDelay() time.Duration {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.ackSendDelay
+}
+
+// GetRetransmittablePacketsBeforeAck gets the number of retransmittable packets of the peer after which an ACK is sent
+func (h *connectionParametersManager) GetRetransmittablePacketsBeforeAck() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.retransmittablePacketsBeforeAck
+}
+
 // TruncateConnectionID determines if the client requests truncated ConnectionIDs
 func (h *connectionParametersManager) TruncateConnectionID() bool {
 	if h.perspective == protocol.PerspectiveClient {
@@ -262,3 +403,31 @@ func (h *connectionParametersManager) TruncateConnectionID() bool {
 	defer h.mutex.RUnlock()
 	return h.truncateConnectionID
 }
+
+// PeerSupportsMultipath says if the peer can use multiple paths
+func (h *connectionParametersManager) PeerSupportsMultipath() bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.peerSupportsMultipath
+}
+
+// PeerSupportsUnreliableStreams says if the peer can receive unreliable streams
+func (h *connectionParametersManager) PeerSupportsUnreliableStreams() bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.peerSupportsUnreliableStreams
+}
+
+// PeerAcceptsAcksOnOtherPaths says if the ACKs for the packets received on a path can be sent on another path
+func (h *connectionParametersManager) PeerAcceptsAcksOnOtherPaths() bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.peerAcceptsAcksOnOtherPaths
+}
+
+// PeerSupportsStopSending says if the peer understands STOP_SENDING frames
+func (h *connectionParametersManager) PeerSupportsStopSending() bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.peerSupportsStopSending
+}
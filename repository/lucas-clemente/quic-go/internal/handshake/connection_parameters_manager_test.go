@@ -26,6 +26,7 @@ var _ = Describe("ConnectionsParameterManager", func() {
 			maxReceiveStreamFlowControlWindowServer,
 			maxReceiveConnectionFlowControlWindowServer,
 			idleTimeout,
+			0, 0,
 		).(*connectionParametersManager)
 		cpmClient = NewConnectionParamatersManager(
 			protocol.PerspectiveClient,
@@ -33,6 +34,7 @@ var _ = Describe("ConnectionsParameterManager", func() {
 			maxReceiveStreamFlowControlWindowClient,
 			maxReceiveConnectionFlowControlWindowClient,
 			idleTimeout,
+			0, 0,
 		).(*connectionParametersManager)
 	})
 
@@ -108,6 +110,8 @@ var _ = Describe("ConnectionsParameterManager", func() {
 			Expect(binary.LittleEndian.Uint32(entryMap[TagMSPC])).To(BeEquivalentTo(protocol.MaxStreamsPerConnection))
 			Expect(entryMap).To(HaveKey(TagMIDS))
 			Expect(binary.LittleEndian.Uint32(entryMap[TagMIDS])).To(BeEquivalentTo(protocol.MaxIncomingDynamicStreamsPerConnection))
+			Expect(entryMap).To(HaveKey(TagMIUS))
+			Expect(binary.LittleEndian.Uint32(entryMap[TagMIUS])).To(BeEquivalentTo(protocol.MaxIncomingUniStreamsPerConnection))
 			Expect(entryMap).To(HaveKey(TagSFCW))
 			Expect(binary.LittleEndian.Uint32(entryMap[TagSFCW])).To(BeEquivalentTo(protocol.ReceiveStreamFlowControlWindow))
 			Expect(entryMap).To(HaveKey(TagCFCW))
@@ -139,6 +143,85 @@ var _ = Describe("ConnectionsParameterManager", func() {
 		})
 	})
 
+	Context("multipath and unreliable streams", func() {
+		newCPM := func(v protocol.VersionNumber) *connectionParametersManager {
+			return NewConnectionParamatersManager(protocol.PerspectiveServer, v, 0, 0, idleTimeout, 0, 0).(*connectionParametersManager)
+		}
+
+		It("assumes the peer supports them with QUIC crypto", func() {
+			Expect(cpm.PeerSupportsMultipath()).To(BeTrue())
+			Expect(cpm.PeerSupportsUnreliableStreams()).To(BeTrue())
+			entryMap, err := cpm.GetHelloMap()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entryMap).ToNot(HaveKey(TagMPTH))
+			Expect(entryMap).ToNot(HaveKey(TagUNRS))
+		})
+
+		It("announces them with TLS", func() {
+			entryMap, err := newCPM(protocol.VersionMPTLS).GetHelloMap()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entryMap).To(HaveKey(TagMPTH))
+			Expect(entryMap).To(HaveKey(TagUNRS))
+		})
+
+		It("doesn't announce them with TLS without multipath", func() {
+			entryMap, err := newCPM(protocol.VersionTLS).GetHelloMap()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entryMap).ToNot(HaveKey(TagMPTH))
+			Expect(entryMap).ToNot(HaveKey(TagUNRS))
+		})
+
+		It("reads the announcement with TLS", func() {
+			c := newCPM(protocol.VersionMPTLS)
+			Expect(c.PeerSupportsMultipath()).To(BeFalse())
+			Expect(c.PeerSupportsUnreliableStreams()).To(BeFalse())
+			err := c.SetFromMap(map[Tag][]byte{TagMPTH: {}, TagUNRS: {}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.PeerSupportsMultipath()).To(BeTrue())
+			Expect(c.PeerSupportsUnreliableStreams()).To(BeTrue())
+		})
+	})
+
+	Context("ACKs on other paths", func() {
+		It("announces that it accepts them with the multipath versions", func() {
+			for _, v := range []protocol.VersionNumber{protocol.VersionMP, protocol.VersionMPTLS, protocol.VersionMP2} {
+				entryMap, err := NewConnectionParamatersManager(protocol.PerspectiveServer, v, 0, 0, idleTimeout, 0, 0).GetHelloMap()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(entryMap).To(HaveKey(TagACKP))
+			}
+			entryMap, err := cpm.GetHelloMap()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entryMap).ToNot(HaveKey(TagACKP))
+		})
+
+		It("only sends ACKs on other paths if the peer announced it", func() {
+			Expect(cpm.PeerAcceptsAcksOnOtherPaths()).To(BeFalse())
+			err := cpm.SetFromMap(map[Tag][]byte{TagACKP: {}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cpm.PeerAcceptsAcksOnOtherPaths()).To(BeTrue())
+		})
+	})
+
+	Context("STOP_SENDING", func() {
+		It("announces that it supports it with the multipath versions", func() {
+			for _, v := range []protocol.VersionNumber{protocol.VersionMP, protocol.VersionMPTLS, protocol.VersionMP2} {
+				entryMap, err := NewConnectionParamatersManager(protocol.PerspectiveServer, v, 0, 0, idleTimeout, 0, 0).GetHelloMap()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(entryMap).To(HaveKey(TagSTPS))
+			}
+			entryMap, err := cpm.GetHelloMap()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entryMap).ToNot(HaveKey(TagSTPS))
+		})
+
+		It("only sends STOP_SENDING frames if the peer announced it", func() {
+			Expect(cpm.PeerSupportsStopSending()).To(BeFalse())
+			err := cpm.SetFromMap(map[Tag][]byte{TagSTPS: {}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cpm.PeerSupportsStopSending()).To(BeTrue())
+		})
+	})
+
 	Context("flow control", func() {
 		It("has the correct default flow control windows for sending", func() {
 			Expect(cpm.GetSendStreamFlowControlWindow()).To(Equal(protocol.InitialStreamFlowControlWindow))
@@ -252,6 +335,61 @@ var _ = Describe("ConnectionsParameterManager", func() {
 		})
 	})
 
+	Context("ACK frequency", func() {
+		It("uses the default ACK frequency", func() {
+			Expect(cpm.GetAckSendDelay()).To(Equal(protocol.AckSendDelay))
+			Expect(cpm.GetRetransmittablePacketsBeforeAck()).To(Equal(protocol.RetransmittablePacketsBeforeAck))
+		})
+
+		It("doesn't request an ACK frequency if none was configured", func() {
+			entryMap, err := cpmClient.GetHelloMap()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entryMap).ToNot(HaveKey(TagACKD))
+			Expect(entryMap).ToNot(HaveKey(TagACKF))
+		})
+
+		It("requests the configured ACK frequency", func() {
+			cpmClient.requestedAckSendDelay = 40 * time.Millisecond
+			cpmClient.requestedRetransmittablePacketsBeforeAck = 10
+			entryMap, err := cpmClient.GetHelloMap()
+			Expect(err).ToNot(HaveOccurred())
+			err = cpm.SetFromMap(entryMap)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cpm.GetAckSendDelay()).To(Equal(40 * time.Millisecond))
+			Expect(cpm.GetRetransmittablePacketsBeforeAck()).To(Equal(10))
+			// the client keeps the default values
+			Expect(cpmClient.GetAckSendDelay()).To(Equal(protocol.AckSendDelay))
+		})
+
+		It("limits the requested ACK frequency", func() {
+			values := map[Tag][]byte{
+				TagACKD: {0xff, 0xff, 0xff, 0xff},
+				TagACKF: {0xff, 0, 0, 0},
+			}
+			err := cpm.SetFromMap(values)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cpm.GetAckSendDelay()).To(Equal(protocol.MaxAckSendDelay))
+			Expect(cpm.GetRetransmittablePacketsBeforeAck()).To(Equal(protocol.MaxRetransmittablePacketsBeforeAck))
+		})
+
+		It("uses the default values when zero is requested", func() {
+			values := map[Tag][]byte{
+				TagACKD: {0, 0, 0, 0},
+				TagACKF: {0, 0, 0, 0},
+			}
+			err := cpm.SetFromMap(values)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cpm.GetAckSendDelay()).To(Equal(protocol.AckSendDelay))
+			Expect(cpm.GetRetransmittablePacketsBeforeAck()).To(Equal(protocol.RetransmittablePacketsBeforeAck))
+		})
+
+		It("errors when given an invalid value", func() {
+			values := map[Tag][]byte{TagACKF: {2, 0}}
+			err := cpm.SetFromMap(values)
+			Expect(err).To(MatchError(ErrMalformedTag))
+		})
+	})
+
 	Context("max streams per connection", func() {
 		It("errors when given an invalid max streams per connection value", func() {
 			values := map[Tag][]byte{TagMSPC: {2, 0, 0}} // 1 byte too short
@@ -294,4 +432,28 @@ var _ = Describe("ConnectionsParameterManager", func() {
 			})
 		})
 	})
+
+	Context("max unidirectional streams per connection", func() {
+		It("doesn't allow outgoing streams until the peer sent its limit", func() {
+			Expect(cpm.GetMaxOutgoingUniStreams()).To(BeZero())
+			Expect(cpm.GetMaxIncomingUniStreams()).To(BeEquivalentTo(protocol.MaxIncomingUniStreamsPerConnection))
+		})
+
+		It("sets the limit of outgoing streams", func() {
+			err := cpm.SetFromMap(map[Tag][]byte{TagMIUS: {7, 0, 0, 0}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cpm.GetMaxOutgoingUniStreams()).To(Equal(uint32(7)))
+		})
+
+		It("limits the number of outgoing streams", func() {
+			err := cpm.SetFromMap(map[Tag][]byte{TagMIUS: {0xff, 0xff, 0, 0}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cpm.GetMaxOutgoingUniStreams()).To(BeEquivalentTo(protocol.MaxIncomingUniStreamsPerConnection))
+		})
+
+		It("errors when given an invalid value", func() {
+			err := cpm.SetFromMap(map[Tag][]byte{TagMIUS: {2, 0, 0}}) // 1 byte too short
+			Expect(err).To(MatchError(ErrMalformedTag))
+		})
+	})
 })
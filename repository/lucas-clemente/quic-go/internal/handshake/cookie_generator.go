@@ -4,6 +4,7 @@ import (
 	"encoding/asn1"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/crypto"
@@ -29,7 +30,10 @@ type token struct {
 
 // A CookieGenerator generates Cookies
 type CookieGenerator struct {
+	mutex        sync.RWMutex
 	cookieSource crypto.StkSource
+	// the source used before the last rotation, Cookies issued with it are still accepted
+	previousCookieSource crypto.StkSource
 }
 
 // NewCookieGenerator initializes a new CookieGenerator
@@ -52,9 +56,25 @@ func (g *CookieGenerator) NewToken(raddr net.Addr) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
 	return g.cookieSource.NewToken(data)
 }
 
+// Rotate replaces the secret used to encrypt new Cookies.
+// Cookies encrypted with the secret before are still accepted, until Rotate is called again.
+func (g *CookieGenerator) Rotate() error {
+	stkSource, err := crypto.NewStkSource()
+	if err != nil {
+		return err
+	}
+	g.mutex.Lock()
+	g.previousCookieSource = g.cookieSource
+	g.cookieSource = stkSource
+	g.mutex.Unlock()
+	return nil
+}
+
 // DecodeToken decodes a Cookie
 func (g *CookieGenerator) DecodeToken(encrypted []byte) (*Cookie, error) {
 	// if the client didn't send any Cookie, DecodeToken will be called with a nil-slice
@@ -62,7 +82,12 @@ func (g *CookieGenerator) DecodeToken(encrypted []byte) (*Cookie, error) {
 		return nil, nil
 	}
 
+	g.mutex.RLock()
 	data, err := g.cookieSource.DecodeToken(encrypted)
+	if err != nil && g.previousCookieSource != nil {
+		data, err = g.previousCookieSource.DecodeToken(encrypted)
+	}
+	g.mutex.RUnlock()
 	if err != nil {
 		return nil, err
 	}
@@ -43,6 +43,37 @@ var _ = Describe("Cookie Generator", func() {
 		Expect(cookie.SentTime).To(BeTemporally("~", time.Now(), 2*time.Second))
 	})
 
+	Context("rotating the secret", func() {
+		It("accepts cookies issued before the last rotation", func() {
+			token, err := cookieGen.NewToken(&net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cookieGen.Rotate()).To(Succeed())
+			cookie, err := cookieGen.DecodeToken(token)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cookie.RemoteAddr).To(Equal("192.168.0.1"))
+		})
+
+		It("issues cookies with the new secret", func() {
+			oldSource := cookieGen.cookieSource
+			Expect(cookieGen.Rotate()).To(Succeed())
+			token, err := cookieGen.NewToken(&net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = oldSource.DecodeToken(token)
+			Expect(err).To(HaveOccurred())
+			_, err = cookieGen.DecodeToken(token)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("rejects cookies issued before the second to last rotation", func() {
+			token, err := cookieGen.NewToken(&net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cookieGen.Rotate()).To(Succeed())
+			Expect(cookieGen.Rotate()).To(Succeed())
+			_, err = cookieGen.DecodeToken(token)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
 	It("rejects invalid tokens", func() {
 		_, err := cookieGen.DecodeToken([]byte("invalid token"))
 		Expect(err).To(HaveOccurred())
@@ -1,7 +1,6 @@
 package handshake
 
 import (
-	"bufio"
 	"bytes"
 	"crypto/rand"
 	"crypto/tls"
@@ -9,8 +8,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"os"
 	"sync"
 	"time"
 
@@ -51,9 +48,11 @@ type cryptoSetupClient struct {
 	serverVerified     bool // has the certificate chain and the proof already been verified
 	keyDerivation      QuicCryptoKeyDerivationFunction
 	keyExchange        KeyExchangeFunction
+	keyLog             KeyLogFunc
 
 	receivedSecurePacket bool
 	nullAEAD             crypto.AEAD
+	earlyAEAD            crypto.AEAD // seals the data sent before the server responded, with 0-RTT keys
 	secureAEAD           crypto.AEAD
 	forwardSecureAEAD    crypto.AEAD
 	aeadChanged          chan<- protocol.EncryptionLevel
@@ -81,6 +80,7 @@ func NewCryptoSetupClient(
 	aeadChanged chan<- protocol.EncryptionLevel,
 	params *TransportParameters,
 	negotiatedVersions []protocol.VersionNumber,
+	keyLog KeyLogFunc,
 ) (CryptoSetup, error) {
 	return &cryptoSetupClient{
 		hostname:             hostname,
@@ -96,117 +96,48 @@ func NewCryptoSetupClient(
 		negotiatedVersions:   negotiatedVersions,
 		divNonceChan:         make(chan []byte),
 		params:               params,
+		keyLog:               keyLog,
 	}, nil
 }
 
-func writeBinaryFile(filename string, data []byte) {
-	// If previous cache present, let it as it
-	if _, err := os.Stat(filename); err == nil {
-		return
-	}
-
-	f, err := os.Create(filename)
-	if err != nil {
-		utils.Infof("Cannot write cache to %s", filename)
-		return
-	}
-	defer f.Close()
-
-	w := bufio.NewWriter(f)
-
-	// First is SNI
-	binary.Write(w, binary.LittleEndian, data)
-	w.Flush()
-}
-
-func readBinaryFile(filename string) ([]byte, error) {
-	// If no cache, skip this
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		return nil, err
-	}
-
-	f, err := os.Open(filename)
-	if err != nil {
-	utils.Infof("error when opening handshake cache %s: %s", filename, err)
-		return nil, err
-	}
-	defer f.Close()
-
-	data, err := ioutil.ReadAll(f)
-	if err != nil {
-		return nil, err
-	}
-
-	return data, nil
-}
-
 func (h *cryptoSetupClient) cacheHandshake() {
-	// First is SNI
-	cacheSNIFilename := "cache_sni_" + h.hostname
-	writeBinaryFile(cacheSNIFilename, []byte(h.hostname))
-	// STK
-	cacheSTKFilename := "cache_stk_" + h.hostname
-	writeBinaryFile(cacheSTKFilename, []byte(h.stk))
-	// Collect certData
-	cacheCERTFilename := "cache_cert_" + h.hostname
-	writeBinaryFile(cacheCERTFilename, []byte(h.certData))
-	// Server Config
-	cacheSCFGFilename := "cache_scfg_" + h.hostname
-	writeBinaryFile(cacheSCFGFilename, []byte(h.scfgData))
+	h.params.HandshakeCache.Put(h.hostname, &HandshakeCacheEntry{
+		STK:          h.stk,
+		CertData:     h.certData,
+		ServerConfig: h.scfgData,
+	})
 }
 
+// useHandshakeCache restores the server config, the certificates and the STK of a previous handshake with the server.
+// Cached data is only used if it is still valid.
 func (h *cryptoSetupClient) useHandshakeCache() {
-	// First is SNI
-	cacheSNIFilename := "cache_sni_" + h.hostname
-	sni, err := readBinaryFile(cacheSNIFilename)
-	if err != nil {
-		utils.Infof("%s", err)
-		return
-	}
-	hname := string(sni)
-	if h.hostname != hname {
-		utils.Infof("error when comparing SNI: %s != %s", h.hostname, hname)
-		return
-	}
-
-	// STK
-	cacheSTKFilename := "cache_stk_" + h.hostname
-	stk, err := readBinaryFile(cacheSTKFilename)
-	if err != nil {
-		utils.Infof("%s", err)
+	entry, ok := h.params.HandshakeCache.Get(h.hostname)
+	if !ok {
 		return
 	}
-	h.stk = stk
 
-	// Collect certData
-	cacheCERTFilename := "cache_cert_" + h.hostname
-	certData, err := readBinaryFile(cacheCERTFilename)
+	serverConfig, err := parseServerConfig(entry.ServerConfig)
 	if err != nil {
-		utils.Infof("%s", err)
+		utils.Infof("error when parsing cached server config: %s", err)
 		return
 	}
-	h.certData = certData
-	err = h.certManager.SetData(h.certData)
-	if err != nil {
-		utils.Infof("error when parsing certData: %s", err)
+	if serverConfig.IsExpired() {
+		utils.Infof("cached server config for %s is expired", h.hostname)
 		return
 	}
-	// Server Config
-	cacheSCFGFilename := "cache_scfg_" + h.hostname
-	scfgData, err := readBinaryFile(cacheSCFGFilename)
-	if err != nil {
-		utils.Infof("%s", err)
+	if err = h.certManager.SetData(entry.CertData); err != nil {
+		utils.Infof("error when parsing cached certData: %s", err)
 		return
 	}
-
-	h.scfgData = scfgData
-	h.serverConfig, err = parseServerConfig(h.scfgData)
-	if err != nil {
-		utils.Infof("error when parsing server config: %s", err)
+	if err = h.certManager.Verify(h.hostname); err != nil {
+		utils.Infof("cached certificate chain for %s is not valid anymore: %s", h.hostname, err)
 		return
 	}
 
-	// TODO Check if server config is expired
+	h.stk = entry.STK
+	h.certData = entry.CertData
+	h.scfgData = entry.ServerConfig
+	h.serverConfig = serverConfig
 
 	// Generate client nonce
 	err = h.generateClientNonce()
@@ -223,7 +154,7 @@ func (h *cryptoSetupClient) HandleCryptoStream() error {
 	messageChan := make(chan HandshakeMessage)
 	errorChan := make(chan error)
 
-	if h.params.CacheHandshake {
+	if h.params.HandshakeCache != nil {
 		h.useHandshakeCache()
 	}
 
@@ -253,6 +184,9 @@ func (h *cryptoSetupClient) HandleCryptoStream() error {
 			if err != nil {
 				return err
 			}
+			if err = h.maybeDeriveEarlyKeys(); err != nil {
+				return err
+			}
 		}
 
 		var message HandshakeMessage
@@ -275,7 +209,7 @@ func (h *cryptoSetupClient) HandleCryptoStream() error {
 			err = h.handleREJMessage(message.Data)
 		case TagSHLO:
 			err = h.handleSHLOMessage(message.Data)
-			if h.params.CacheHandshake && err == nil {
+			if h.params.HandshakeCache != nil && err == nil {
 				// It worked, cache the data
 				h.cacheHandshake()
 			}
@@ -291,6 +225,16 @@ func (h *cryptoSetupClient) HandleCryptoStream() error {
 func (h *cryptoSetupClient) handleREJMessage(cryptoData map[Tag][]byte) error {
 	var err error
 
+	h.mutex.Lock()
+	rejectedEarlyData := h.earlyAEAD != nil
+	h.earlyAEAD = nil
+	h.mutex.Unlock()
+	if rejectedEarlyData {
+		// the data sent with the 0-RTT keys has to be sent again, once the server accepts a CHLO
+		utils.Infof("Server rejected the 0-RTT handshake")
+		h.aeadChanged <- protocol.EncryptionUnencrypted
+	}
+
 	if stk, ok := cryptoData[TagSTK]; ok {
 		h.stk = stk
 	}
@@ -401,6 +345,12 @@ func (h *cryptoSetupClient) handleSHLOMessage(cryptoData map[Tag][]byte) error {
 	if err != nil {
 		return err
 	}
+	if h.version.UsesKeyUpdates() {
+		if h.forwardSecureAEAD, err = crypto.NewKeyUpdatingAEAD(h.forwardSecureAEAD, logKeyUpdates(h.keyLog, protocol.PerspectiveClient)); err != nil {
+			return err
+		}
+	}
+	logKeys(h.keyLog, keyLogForwardSecure, protocol.PerspectiveClient, h.forwardSecureAEAD)
 
 	err = h.connectionParameters.SetFromMap(cryptoData)
 	if err != nil {
@@ -414,21 +364,26 @@ func (h *cryptoSetupClient) handleSHLOMessage(cryptoData map[Tag][]byte) error {
 }
 
 func (h *cryptoSetupClient) validateVersionList(verTags []byte) bool {
-	if len(h.negotiatedVersions) == 0 {
+	return validateVersionList(verTags, h.negotiatedVersions)
+}
+
+// validateVersionList checks that the versions the server supports are the ones it offered in the version negotiation packet
+func validateVersionList(verTags []byte, negotiatedVersions []protocol.VersionNumber) bool {
+	if len(negotiatedVersions) == 0 {
 		return true
 	}
-	if len(verTags)%4 != 0 || len(verTags)/4 != len(h.negotiatedVersions) {
+	if len(verTags)%4 != 0 || len(verTags)/4 != len(negotiatedVersions) {
 		return false
 	}
 
 	b := bytes.NewReader(verTags)
-	for _, negotiatedVersion := range h.negotiatedVersions {
+	for _, negotiatedVersion := range negotiatedVersions {
 		verTag, err := utils.LittleEndian.ReadUint32(b)
 		if err != nil { // should never occur, since the length was already checked
 			return false
 		}
 		ver := protocol.VersionTagToNumber(verTag)
-		if !protocol.IsSupportedVersion(protocol.SupportedVersions, ver) {
+		if !protocol.IsValidVersion(ver) {
 			ver = protocol.VersionUnsupported
 		}
 		if ver != negotiatedVersion {
@@ -438,12 +393,12 @@ func (h *cryptoSetupClient) validateVersionList(verTags []byte) bool {
 	return true
 }
 
-func (h *cryptoSetupClient) Open(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, protocol.EncryptionLevel, error) {
+func (h *cryptoSetupClient) Open(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, keyPhase protocol.KeyPhaseBit, associatedData []byte) ([]byte, protocol.EncryptionLevel, error) {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
 	if h.forwardSecureAEAD != nil {
-		data, err := h.forwardSecureAEAD.Open(dst, src, packetNumber, associatedData)
+		data, err := crypto.OpenWithKeyPhase(h.forwardSecureAEAD, dst, src, pathID, packetNumber, keyPhase, associatedData)
 		if err == nil {
 			return data, protocol.EncryptionForwardSecure, nil
 		}
@@ -451,7 +406,7 @@ func (h *cryptoSetupClient) Open(dst, src []byte, packetNumber protocol.PacketNu
 	}
 
 	if h.secureAEAD != nil {
-		data, err := h.secureAEAD.Open(dst, src, packetNumber, associatedData)
+		data, err := h.secureAEAD.Open(dst, src, pathID, packetNumber, associatedData)
 		if err == nil {
 			h.receivedSecurePacket = true
 			return data, protocol.EncryptionSecure, nil
@@ -460,7 +415,7 @@ func (h *cryptoSetupClient) Open(dst, src []byte, packetNumber protocol.PacketNu
 			return nil, protocol.EncryptionUnspecified, err
 		}
 	}
-	res, err := h.nullAEAD.Open(dst, src, packetNumber, associatedData)
+	res, err := h.nullAEAD.Open(dst, src, pathID, packetNumber, associatedData)
 	if err != nil {
 		return nil, protocol.EncryptionUnspecified, err
 	}
@@ -474,6 +429,8 @@ func (h *cryptoSetupClient) GetSealer() (protocol.EncryptionLevel, Sealer) {
 		return protocol.EncryptionForwardSecure, h.forwardSecureAEAD
 	} else if h.secureAEAD != nil {
 		return protocol.EncryptionSecure, h.secureAEAD
+	} else if h.earlyAEAD != nil {
+		return protocol.EncryptionSecure, h.earlyAEAD
 	} else {
 		return protocol.EncryptionUnencrypted, h.nullAEAD
 	}
@@ -483,6 +440,15 @@ func (h *cryptoSetupClient) GetSealerForCryptoStream() (protocol.EncryptionLevel
 	return protocol.EncryptionUnencrypted, h.nullAEAD
 }
 
+func (h *cryptoSetupClient) ConnectionState() ConnectionState {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return ConnectionState{
+		HandshakeComplete: h.forwardSecureAEAD != nil,
+		Used0RTT:          h.forwardSecureAEAD != nil && h.earlyAEAD != nil,
+	}
+}
+
 func (h *cryptoSetupClient) GetSealerWithEncryptionLevel(encLevel protocol.EncryptionLevel) (Sealer, error) {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
@@ -491,10 +457,13 @@ func (h *cryptoSetupClient) GetSealerWithEncryptionLevel(encLevel protocol.Encry
 	case protocol.EncryptionUnencrypted:
 		return h.nullAEAD, nil
 	case protocol.EncryptionSecure:
-		if h.secureAEAD == nil {
-			return nil, errors.New("CryptoSetupClient: no secureAEAD")
+		if h.secureAEAD != nil {
+			return h.secureAEAD, nil
+		}
+		if h.earlyAEAD != nil {
+			return h.earlyAEAD, nil
 		}
-		return h.secureAEAD, nil
+		return nil, errors.New("CryptoSetupClient: no secureAEAD")
 	case protocol.EncryptionForwardSecure:
 		if h.forwardSecureAEAD == nil {
 			return nil, errors.New("CryptoSetupClient: no forwardSecureAEAD")
@@ -634,6 +603,7 @@ func (h *cryptoSetupClient) maybeUpgradeCrypto() error {
 		if err != nil {
 			return err
 		}
+		logKeys(h.keyLog, keyLogSecure, protocol.PerspectiveClient, h.secureAEAD)
 
 		h.aeadChanged <- protocol.EncryptionSecure
 	}
@@ -641,6 +611,38 @@ func (h *cryptoSetupClient) maybeUpgradeCrypto() error {
 	return nil
 }
 
+// maybeDeriveEarlyKeys derives the keys for 0-RTT data, if the first CHLO was a full CHLO using the cached handshake data.
+// The keys of the client don't depend on the diversification nonce, so they can be used before the server responded.
+func (h *cryptoSetupClient) maybeDeriveEarlyKeys() error {
+	if !h.params.EarlyData || !h.serverVerified || h.clientHelloCounter != 1 {
+		return nil
+	}
+
+	h.mutex.Lock()
+	earlyAEAD, err := h.keyDerivation(
+		false,
+		h.serverConfig.sharedSecret,
+		h.nonc,
+		h.connID,
+		h.lastSentCHLO,
+		h.serverConfig.Get(),
+		h.certManager.GetLeafCert(),
+		nil,
+		protocol.PerspectiveClient,
+	)
+	if err != nil {
+		h.mutex.Unlock()
+		return err
+	}
+	h.earlyAEAD = earlyAEAD
+	h.mutex.Unlock()
+	logKeys(h.keyLog, keyLogEarly, protocol.PerspectiveClient, earlyAEAD)
+
+	// don't hold the lock while sending, the session might need it to seal a packet before receiving from the channel
+	h.aeadChanged <- protocol.EncryptionSecure
+	return nil
+}
+
 func (h *cryptoSetupClient) generateClientNonce() error {
 	if len(h.nonc) > 0 {
 		return errClientNonceAlreadyExists
@@ -120,10 +120,12 @@ var _ = Describe("Client Crypto Setup", func() {
 				version,
 				protocol.DefaultMaxReceiveStreamFlowControlWindowClient, protocol.DefaultMaxReceiveConnectionFlowControlWindowClient,
 				protocol.DefaultIdleTimeout,
+				0, 0,
 			),
 			aeadChanged,
 			&TransportParameters{},
 			nil,
+			nil,
 		)
 		Expect(err).ToNot(HaveOccurred())
 		cs = csInt.(*cryptoSetupClient)
@@ -225,6 +227,14 @@ var _ = Describe("Client Crypto Setup", func() {
 				Expect(cs.validateVersionList(b.Bytes())).To(BeTrue())
 			})
 
+			It("accepts the TLS versions, even though they're not supported by default", func() {
+				cs.negotiatedVersions = []protocol.VersionNumber{protocol.VersionMPTLS, protocol.VersionMP}
+				b := &bytes.Buffer{}
+				utils.LittleEndian.WriteUint32(b, protocol.VersionNumberToTag(protocol.VersionMPTLS))
+				utils.LittleEndian.WriteUint32(b, protocol.VersionNumberToTag(protocol.VersionMP))
+				Expect(cs.validateVersionList(b.Bytes())).To(BeTrue())
+			})
+
 			It("returns the right error when detecting a downgrade attack", func() {
 				cs.negotiatedVersions = []protocol.VersionNumber{protocol.VersionWhatever}
 				cs.receivedSecurePacket = true
@@ -436,6 +446,17 @@ var _ = Describe("Client Crypto Setup", func() {
 			Expect(cs.forwardSecureAEAD).ToNot(BeNil())
 			Expect(aeadChanged).To(Receive(Equal(protocol.EncryptionForwardSecure)))
 			Expect(aeadChanged).To(BeClosed())
+			_, ok := cs.forwardSecureAEAD.(KeyUpdatingSealer)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("updates the forward-secure keys, if the version uses key updates", func() {
+			cs.version = protocol.VersionMP2
+			cs.keyDerivation = crypto.DeriveQuicCryptoAESKeys
+			err := cs.handleSHLOMessage(shloMap)
+			Expect(err).ToNot(HaveOccurred())
+			_, ok := cs.forwardSecureAEAD.(KeyUpdatingSealer)
+			Expect(ok).To(BeTrue())
 		})
 
 		It("reads the connection paramaters", func() {
@@ -452,6 +473,81 @@ var _ = Describe("Client Crypto Setup", func() {
 		})
 	})
 
+	Context("handshake cache", func() {
+		var (
+			cache HandshakeCache
+			scfg  []byte
+		)
+
+		BeforeEach(func() {
+			var err error
+			cache, err = NewMemoryHandshakeCache(10, time.Hour)
+			Expect(err).ToNot(HaveOccurred())
+			cs.params.HandshakeCache = cache
+			b := &bytes.Buffer{}
+			HandshakeMessage{Tag: TagSCFG, Data: getDefaultServerConfigClient()}.Write(b)
+			scfg = b.Bytes()
+		})
+
+		It("caches the handshake data", func() {
+			cs.stk = []byte("stk")
+			cs.certData = []byte("cert")
+			cs.scfgData = scfg
+			cs.cacheHandshake()
+			entry, ok := cache.Get("hostname")
+			Expect(ok).To(BeTrue())
+			Expect(entry).To(Equal(&HandshakeCacheEntry{STK: []byte("stk"), CertData: []byte("cert"), ServerConfig: scfg}))
+		})
+
+		It("uses cached handshake data", func() {
+			cache.Put("hostname", &HandshakeCacheEntry{STK: []byte("stk"), CertData: []byte("cert"), ServerConfig: scfg})
+			cs.useHandshakeCache()
+			Expect(cs.stk).To(Equal([]byte("stk")))
+			Expect(cs.certData).To(Equal([]byte("cert")))
+			Expect(cs.serverConfig).ToNot(BeNil())
+			Expect(cs.nonc).To(HaveLen(32))
+			Expect(certManager.setDataCalledWith).To(Equal([]byte("cert")))
+			Expect(certManager.verifyCalled).To(BeTrue())
+			Expect(cs.serverVerified).To(BeTrue())
+		})
+
+		It("doesn't use cached data for a different server", func() {
+			cache.Put("other hostname", &HandshakeCacheEntry{STK: []byte("stk"), CertData: []byte("cert"), ServerConfig: scfg})
+			cs.useHandshakeCache()
+			Expect(cs.stk).To(BeEmpty())
+			Expect(cs.serverConfig).To(BeNil())
+			Expect(cs.serverVerified).To(BeFalse())
+		})
+
+		It("doesn't use a cached certificate chain that is not valid anymore", func() {
+			certManager.verifyError = errors.New("expired")
+			cache.Put("hostname", &HandshakeCacheEntry{STK: []byte("stk"), CertData: []byte("cert"), ServerConfig: scfg})
+			cs.useHandshakeCache()
+			Expect(cs.stk).To(BeEmpty())
+			Expect(cs.serverConfig).To(BeNil())
+			Expect(cs.serverVerified).To(BeFalse())
+		})
+
+		It("doesn't use an expired cached server config", func() {
+			serverConfig := getDefaultServerConfigClient()
+			serverConfig[TagEXPY] = []byte{0x80, 0x54, 0x72, 0x4F, 0, 0, 0, 0} // 2012-03-28
+			b := &bytes.Buffer{}
+			HandshakeMessage{Tag: TagSCFG, Data: serverConfig}.Write(b)
+			cache.Put("hostname", &HandshakeCacheEntry{STK: []byte("stk"), CertData: []byte("cert"), ServerConfig: b.Bytes()})
+			cs.useHandshakeCache()
+			Expect(cs.serverConfig).To(BeNil())
+			Expect(certManager.verifyCalled).To(BeFalse())
+			Expect(cs.serverVerified).To(BeFalse())
+		})
+
+		It("doesn't use an invalid cached server config", func() {
+			cache.Put("hostname", &HandshakeCacheEntry{STK: []byte("stk"), CertData: []byte("cert"), ServerConfig: []byte("foobar")})
+			cs.useHandshakeCache()
+			Expect(cs.serverConfig).To(BeNil())
+			Expect(cs.serverVerified).To(BeFalse())
+		})
+	})
+
 	Context("CHLO generation", func() {
 		It("is longer than the miminum client hello size", func() {
 			err := cs.sendCHLO()
@@ -687,23 +783,88 @@ var _ = Describe("Client Crypto Setup", func() {
 			close(done)
 		})
 
+		Context("0-RTT", func() {
+			BeforeEach(func() {
+				cs.params.EarlyData = true
+				cs.serverVerified = true
+				cs.clientHelloCounter = 1
+				cs.diversificationNonce = nil
+			})
+
+			It("derives 0-RTT keys after sending a full CHLO with the cached handshake data", func() {
+				Expect(cs.maybeDeriveEarlyKeys()).To(Succeed())
+				Expect(cs.earlyAEAD).ToNot(BeNil())
+				Expect(keyDerivationCalledWith.forwardSecure).To(BeFalse())
+				Expect(keyDerivationCalledWith.nonces).To(Equal(cs.nonc))
+				Expect(keyDerivationCalledWith.chlo).To(Equal(cs.lastSentCHLO))
+				Expect(keyDerivationCalledWith.divNonce).To(BeNil())
+				Expect(aeadChanged).To(Receive(Equal(protocol.EncryptionSecure)))
+				enc, sealer := cs.GetSealer()
+				Expect(enc).To(Equal(protocol.EncryptionSecure))
+				Expect(sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})).To(Equal([]byte("foobar  normal sec")))
+				sealer, err := cs.GetSealerWithEncryptionLevel(protocol.EncryptionSecure)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sealer).To(Equal(cs.earlyAEAD))
+			})
+
+			It("doesn't derive 0-RTT keys if early data is disabled", func() {
+				cs.params.EarlyData = false
+				Expect(cs.maybeDeriveEarlyKeys()).To(Succeed())
+				Expect(cs.earlyAEAD).To(BeNil())
+				Expect(aeadChanged).ToNot(Receive())
+			})
+
+			It("doesn't derive 0-RTT keys without a verified server", func() {
+				cs.serverVerified = false
+				Expect(cs.maybeDeriveEarlyKeys()).To(Succeed())
+				Expect(cs.earlyAEAD).To(BeNil())
+			})
+
+			It("doesn't derive 0-RTT keys after a REJ", func() {
+				cs.clientHelloCounter = 2
+				Expect(cs.maybeDeriveEarlyKeys()).To(Succeed())
+				Expect(cs.earlyAEAD).To(BeNil())
+			})
+
+			It("prefers the secureAEAD once the server responded", func() {
+				Expect(cs.maybeDeriveEarlyKeys()).To(Succeed())
+				Expect(aeadChanged).To(Receive())
+				cs.diversificationNonce = []byte("divnonce")
+				doCompleteREJ()
+				sealer, err := cs.GetSealerWithEncryptionLevel(protocol.EncryptionSecure)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sealer).To(Equal(cs.secureAEAD))
+			})
+
+			It("signals when the server rejected the 0-RTT keys", func() {
+				Expect(cs.maybeDeriveEarlyKeys()).To(Succeed())
+				Expect(aeadChanged).To(Receive())
+				// the REJ doesn't contain a valid server config, so handling it errors, but only after dropping the keys
+				cs.handleREJMessage(map[Tag][]byte{})
+				Expect(cs.earlyAEAD).To(BeNil())
+				Expect(aeadChanged).To(Receive(Equal(protocol.EncryptionUnencrypted)))
+				enc, _ := cs.GetSealer()
+				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
+			})
+		})
+
 		Context("null encryption", func() {
 			It("is used initially", func() {
 				enc, sealer := cs.GetSealer()
 				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar unencrypted")))
 			})
 
 			It("is used for the crypto stream", func() {
 				enc, sealer := cs.GetSealerForCryptoStream()
 				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar unencrypted")))
 			})
 
 			It("is accepted initially", func() {
-				d, enc, err := cs.Open(nil, []byte("unencrypted"), 0, []byte{})
+				d, enc, err := cs.Open(nil, []byte("unencrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).ToNot(HaveOccurred())
 				Expect(d).To(Equal([]byte("decrypted")))
 				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
@@ -713,7 +874,7 @@ var _ = Describe("Client Crypto Setup", func() {
 				doCompleteREJ()
 				cs.receivedSecurePacket = false
 				Expect(cs.secureAEAD).ToNot(BeNil())
-				d, enc, err := cs.Open(nil, []byte("unencrypted"), 0, []byte{})
+				d, enc, err := cs.Open(nil, []byte("unencrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).ToNot(HaveOccurred())
 				Expect(d).To(Equal([]byte("decrypted")))
 				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
@@ -722,13 +883,13 @@ var _ = Describe("Client Crypto Setup", func() {
 			It("is not accepted after the server sent an encrypted packet", func() {
 				doCompleteREJ()
 				cs.receivedSecurePacket = true
-				_, enc, err := cs.Open(nil, []byte("unecnrypted"), 0, []byte{})
+				_, enc, err := cs.Open(nil, []byte("unecnrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).To(MatchError("authentication failed"))
 				Expect(enc).To(Equal(protocol.EncryptionUnspecified))
 			})
 
 			It("errors if the has the wrong hash", func() {
-				_, enc, err := cs.Open(nil, []byte("not unecnrypted"), 0, []byte{})
+				_, enc, err := cs.Open(nil, []byte("not unecnrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).To(MatchError("authentication failed"))
 				Expect(enc).To(Equal(protocol.EncryptionUnspecified))
 			})
@@ -740,13 +901,13 @@ var _ = Describe("Client Crypto Setup", func() {
 				cs.receivedSecurePacket = false
 				enc, sealer := cs.GetSealer()
 				Expect(enc).To(Equal(protocol.EncryptionSecure))
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar  normal sec")))
 			})
 
 			It("is accepted", func() {
 				doCompleteREJ()
-				d, enc, err := cs.Open(nil, []byte("encrypted"), 0, []byte{})
+				d, enc, err := cs.Open(nil, []byte("encrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).ToNot(HaveOccurred())
 				Expect(d).To(Equal([]byte("decrypted")))
 				Expect(enc).To(Equal(protocol.EncryptionSecure))
@@ -755,7 +916,7 @@ var _ = Describe("Client Crypto Setup", func() {
 
 			It("is not used after receiving the SHLO", func() {
 				doSHLO()
-				_, enc, err := cs.Open(nil, []byte("encrypted"), 0, []byte{})
+				_, enc, err := cs.Open(nil, []byte("encrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).To(MatchError("authentication failed"))
 				Expect(enc).To(Equal(protocol.EncryptionUnspecified))
 			})
@@ -764,7 +925,7 @@ var _ = Describe("Client Crypto Setup", func() {
 				doCompleteREJ()
 				enc, sealer := cs.GetSealerForCryptoStream()
 				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar unencrypted")))
 			})
 		})
@@ -772,12 +933,12 @@ var _ = Describe("Client Crypto Setup", func() {
 		Context("forward-secure encryption", func() {
 			It("is used after receiving the SHLO", func() {
 				doSHLO()
-				_, enc, err := cs.Open(nil, []byte("forward secure encrypted"), 0, []byte{})
+				_, enc, err := cs.Open(nil, []byte("forward secure encrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).ToNot(HaveOccurred())
 				Expect(enc).To(Equal(protocol.EncryptionForwardSecure))
 				enc, sealer := cs.GetSealer()
 				Expect(enc).To(Equal(protocol.EncryptionForwardSecure))
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar forward sec")))
 			})
 
@@ -785,7 +946,7 @@ var _ = Describe("Client Crypto Setup", func() {
 				doSHLO()
 				enc, sealer := cs.GetSealerForCryptoStream()
 				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar unencrypted")))
 			})
 		})
@@ -794,7 +955,7 @@ var _ = Describe("Client Crypto Setup", func() {
 			It("forces null encryption", func() {
 				sealer, err := cs.GetSealerWithEncryptionLevel(protocol.EncryptionUnencrypted)
 				Expect(err).ToNot(HaveOccurred())
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar unencrypted")))
 			})
 
@@ -802,7 +963,7 @@ var _ = Describe("Client Crypto Setup", func() {
 				doCompleteREJ()
 				sealer, err := cs.GetSealerWithEncryptionLevel(protocol.EncryptionSecure)
 				Expect(err).ToNot(HaveOccurred())
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar  normal sec")))
 			})
 
@@ -816,7 +977,7 @@ var _ = Describe("Client Crypto Setup", func() {
 				doSHLO()
 				sealer, err := cs.GetSealerWithEncryptionLevel(protocol.EncryptionForwardSecure)
 				Expect(err).ToNot(HaveOccurred())
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar forward sec")))
 			})
 
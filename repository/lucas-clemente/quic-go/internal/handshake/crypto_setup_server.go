@@ -32,7 +32,13 @@ type cryptoSetupServer struct {
 	version           protocol.VersionNumber
 	supportedVersions []protocol.VersionNumber
 
-	acceptSTKCallback func(net.Addr, *Cookie) bool
+	acceptSTKCallback  func(net.Addr, *Cookie) bool
+	accept0RTTCallback func(net.Addr) bool
+
+	// the server nonce sent in the last REJ
+	// A CHLO with this nonce was sent after receiving the REJ, so it can't be a replayed 0-RTT handshake.
+	serverNonce  []byte
+	accepted0RTT bool
 
 	nullAEAD                    crypto.AEAD
 	secureAEAD                  crypto.AEAD
@@ -44,6 +50,7 @@ type cryptoSetupServer struct {
 
 	keyDerivation QuicCryptoKeyDerivationFunction
 	keyExchange   KeyExchangeFunction
+	keyLog        KeyLogFunc
 
 	cryptoStream io.ReadWriter
 
@@ -73,28 +80,27 @@ func NewCryptoSetup(
 	connectionParametersManager ConnectionParametersManager,
 	supportedVersions []protocol.VersionNumber,
 	acceptSTK func(net.Addr, *Cookie) bool,
+	accept0RTT func(net.Addr) bool,
 	aeadChanged chan<- protocol.EncryptionLevel,
+	keyLog KeyLogFunc,
 ) (CryptoSetup, error) {
-	stkGenerator, err := NewCookieGenerator()
-	if err != nil {
-		return nil, err
-	}
-
 	return &cryptoSetupServer{
 		connID:               connID,
 		remoteAddr:           remoteAddr,
 		version:              version,
 		supportedVersions:    supportedVersions,
 		scfg:                 scfg,
-		stkGenerator:         stkGenerator,
+		stkGenerator:         scfg.stkGenerator,
 		keyDerivation:        crypto.DeriveQuicCryptoAESKeys,
 		keyExchange:          getEphermalKEX,
 		nullAEAD:             crypto.NewNullAEAD(protocol.PerspectiveServer, version),
 		cryptoStream:         cryptoStream,
 		connectionParameters: connectionParametersManager,
 		acceptSTKCallback:    acceptSTK,
+		accept0RTTCallback:   accept0RTT,
 		sentSHLO:             make(chan struct{}),
 		aeadChanged:          aeadChanged,
+		keyLog:               keyLog,
 	}, nil
 }
 
@@ -162,7 +168,14 @@ func (h *cryptoSetupServer) handleMessage(chloData []byte, cryptoData map[Tag][]
 		return false, err
 	}
 
+	var accept bool
 	if !h.isInchoateCHLO(cryptoData, certUncompressed) {
+		accept, err = h.acceptCHLO(cryptoData)
+		if err != nil {
+			return false, err
+		}
+	}
+	if accept {
 		// We have a CHLO with a proper server config ID, do a 0-RTT handshake
 		reply, err = h.handleCHLO(sni, chloData, cryptoData)
 		if err != nil {
@@ -186,12 +199,12 @@ func (h *cryptoSetupServer) handleMessage(chloData []byte, cryptoData map[Tag][]
 }
 
 // Open a message
-func (h *cryptoSetupServer) Open(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, protocol.EncryptionLevel, error) {
+func (h *cryptoSetupServer) Open(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, keyPhase protocol.KeyPhaseBit, associatedData []byte) ([]byte, protocol.EncryptionLevel, error) {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
 	if h.forwardSecureAEAD != nil {
-		res, err := h.forwardSecureAEAD.Open(dst, src, packetNumber, associatedData)
+		res, err := crypto.OpenWithKeyPhase(h.forwardSecureAEAD, dst, src, pathID, packetNumber, keyPhase, associatedData)
 		if err == nil {
 			if !h.receivedForwardSecurePacket { // this is the first forward secure packet we receive from the client
 				h.receivedForwardSecurePacket = true
@@ -206,7 +219,7 @@ func (h *cryptoSetupServer) Open(dst, src []byte, packetNumber protocol.PacketNu
 		}
 	}
 	if h.secureAEAD != nil {
-		res, err := h.secureAEAD.Open(dst, src, packetNumber, associatedData)
+		res, err := h.secureAEAD.Open(dst, src, pathID, packetNumber, associatedData)
 		if err == nil {
 			h.receivedSecurePacket = true
 			return res, protocol.EncryptionSecure, nil
@@ -215,7 +228,7 @@ func (h *cryptoSetupServer) Open(dst, src []byte, packetNumber protocol.PacketNu
 			return nil, protocol.EncryptionUnspecified, err
 		}
 	}
-	res, err := h.nullAEAD.Open(dst, src, packetNumber, associatedData)
+	res, err := h.nullAEAD.Open(dst, src, pathID, packetNumber, associatedData)
 	if err != nil {
 		return res, protocol.EncryptionUnspecified, err
 	}
@@ -240,6 +253,15 @@ func (h *cryptoSetupServer) GetSealerForCryptoStream() (protocol.EncryptionLevel
 	return protocol.EncryptionUnencrypted, h.nullAEAD
 }
 
+func (h *cryptoSetupServer) ConnectionState() ConnectionState {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return ConnectionState{
+		HandshakeComplete: h.forwardSecureAEAD != nil,
+		Used0RTT:          h.forwardSecureAEAD != nil && h.accepted0RTT,
+	}
+}
+
 func (h *cryptoSetupServer) GetSealerWithEncryptionLevel(encLevel protocol.EncryptionLevel) (Sealer, error) {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
@@ -289,6 +311,28 @@ func (h *cryptoSetupServer) acceptSTK(token []byte) bool {
 	return h.acceptSTKCallback(h.remoteAddr, stk)
 }
 
+// acceptCHLO applies the anti-replay checks to a full CHLO
+// The client might have sent 0-RTT data with it, unless it includes the server nonce of our REJ.
+func (h *cryptoSetupServer) acceptCHLO(cryptoData map[Tag][]byte) (bool, error) {
+	clientNonce := cryptoData[TagNONC]
+	if err := h.validateClientNonce(clientNonce); err != nil {
+		return false, err
+	}
+	if sno, ok := cryptoData[TagSNO]; ok && len(h.serverNonce) > 0 && bytes.Equal(sno, h.serverNonce) {
+		return true, nil
+	}
+	if h.accept0RTTCallback != nil && !h.accept0RTTCallback(h.remoteAddr) {
+		utils.Debugf("Rejecting 0-RTT handshake")
+		return false, nil
+	}
+	if !h.scfg.strikeRegister.insert(clientNonce) {
+		utils.Infof("Rejecting 0-RTT handshake with a replayed or stale client nonce")
+		return false, nil
+	}
+	h.accepted0RTT = true
+	return true, nil
+}
+
 func (h *cryptoSetupServer) handleInchoateCHLO(sni string, chlo []byte, cryptoData map[Tag][]byte) ([]byte, error) {
 	if len(chlo) < protocol.ClientHelloMinimumSize {
 		return nil, qerr.Error(qerr.CryptoInvalidValueLength, "CHLO too small")
@@ -299,9 +343,15 @@ func (h *cryptoSetupServer) handleInchoateCHLO(sni string, chlo []byte, cryptoDa
 		return nil, err
 	}
 
+	h.serverNonce = make([]byte, 32)
+	if _, err = rand.Read(h.serverNonce); err != nil {
+		return nil, err
+	}
+
 	replyMap := map[Tag][]byte{
 		TagSCFG: h.scfg.Get(),
 		TagSTK:  token,
+		TagSNO:  h.serverNonce,
 		TagSVID: []byte("quic-go"),
 	}
 
@@ -375,10 +425,16 @@ func (h *cryptoSetupServer) handleCHLO(sni string, data []byte, cryptoData map[T
 		return nil, qerr.Error(qerr.CryptoNoSupport, "Unsupported AEAD or KEXS")
 	}
 
+	// the client uses the server nonce of our REJ, if it received one
+	nonce := clientNonce
+	if sno, ok := cryptoData[TagSNO]; ok {
+		nonce = append(append([]byte{}, clientNonce...), sno...)
+	}
+
 	h.secureAEAD, err = h.keyDerivation(
 		false,
 		sharedSecret,
-		clientNonce,
+		nonce,
 		h.connID,
 		data,
 		h.scfg.Get(),
@@ -389,6 +445,7 @@ func (h *cryptoSetupServer) handleCHLO(sni string, data []byte, cryptoData map[T
 	if err != nil {
 		return nil, err
 	}
+	logKeys(h.keyLog, keyLogSecure, protocol.PerspectiveServer, h.secureAEAD)
 
 	h.aeadChanged <- protocol.EncryptionSecure
 
@@ -416,6 +473,12 @@ func (h *cryptoSetupServer) handleCHLO(sni string, data []byte, cryptoData map[T
 	if err != nil {
 		return nil, err
 	}
+	if h.version.UsesKeyUpdates() {
+		if h.forwardSecureAEAD, err = crypto.NewKeyUpdatingAEAD(h.forwardSecureAEAD, logKeyUpdates(h.keyLog, protocol.PerspectiveServer)); err != nil {
+			return nil, err
+		}
+	}
+	logKeys(h.keyLog, keyLogForwardSecure, protocol.PerspectiveServer, h.forwardSecureAEAD)
 
 	err = h.connectionParameters.SetFromMap(cryptoData)
 	if err != nil {
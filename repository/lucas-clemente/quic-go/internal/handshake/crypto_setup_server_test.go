@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"net"
+	"time"
 
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/crypto"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
@@ -61,7 +62,7 @@ type mockAEAD struct {
 
 var _ crypto.AEAD = &mockAEAD{}
 
-func (m *mockAEAD) Seal(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) []byte {
+func (m *mockAEAD) Seal(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) []byte {
 	if cap(dst) < len(src)+12 {
 		dst = make([]byte, len(src)+12)
 	}
@@ -80,7 +81,7 @@ func (m *mockAEAD) Seal(dst, src []byte, packetNumber protocol.PacketNumber, ass
 	return dst
 }
 
-func (m *mockAEAD) Open(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error) {
+func (m *mockAEAD) Open(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, error) {
 	if m.encLevel == protocol.EncryptionUnencrypted && string(src) == "unencrypted" ||
 		m.encLevel == protocol.EncryptionForwardSecure && string(src) == "forward secure encrypted" ||
 		m.encLevel == protocol.EncryptionSecure && string(src) == "encrypted" {
@@ -192,6 +193,7 @@ var _ = Describe("Server Crypto Setup", func() {
 		nonce32 = make([]byte, 32)
 		aead = []byte("AESG")
 		kexs = []byte("C255")
+		binary.BigEndian.PutUint32(nonce32, uint32(time.Now().Unix()))
 		copy(nonce32[4:12], scfg.obit) // set the OBIT value at the right position
 		versionTag = make([]byte, 4)
 		binary.LittleEndian.PutUint32(versionTag, protocol.VersionNumberToTag(protocol.VersionWhatever))
@@ -203,6 +205,7 @@ var _ = Describe("Server Crypto Setup", func() {
 			protocol.VersionWhatever,
 			protocol.DefaultMaxReceiveStreamFlowControlWindowServer, protocol.DefaultMaxReceiveConnectionFlowControlWindowServer,
 			protocol.DefaultIdleTimeout,
+			0, 0,
 		)
 		csInt, err := NewCryptoSetup(
 			protocol.ConnectionID(42),
@@ -213,7 +216,9 @@ var _ = Describe("Server Crypto Setup", func() {
 			cpm,
 			supportedVersions,
 			nil,
+			nil,
 			aeadChanged,
+			nil,
 		)
 		Expect(err).NotTo(HaveOccurred())
 		cs = csInt.(*cryptoSetupServer)
@@ -403,6 +408,78 @@ var _ = Describe("Server Crypto Setup", func() {
 			Expect(aeadChanged).ToNot(BeClosed())
 		})
 
+		Context("anti-replay", func() {
+			// rejected CHLOs are answered with a REJ, which requires a padded CHLO
+			chloData := bytes.Repeat([]byte{'a'}, protocol.ClientHelloMinimumSize)
+
+			It("includes a server nonce in REJ messages", func() {
+				response, err := cs.handleInchoateCHLO("", chloData, nil)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cs.serverNonce).To(HaveLen(32))
+				Expect(response).To(ContainSubstring(string(cs.serverNonce)))
+			})
+
+			It("reports that it accepted a 0-RTT handshake", func() {
+				done, err := cs.handleMessage(chloData, fullCHLO)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(done).To(BeTrue())
+				Expect(cs.ConnectionState().Used0RTT).To(BeTrue())
+			})
+
+			It("rejects 0-RTT handshakes if the application doesn't accept them", func() {
+				var remoteAddr net.Addr
+				cs.accept0RTTCallback = func(addr net.Addr) bool {
+					remoteAddr = addr
+					return false
+				}
+				done, err := cs.handleMessage(chloData, fullCHLO)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(done).To(BeFalse())
+				Expect(remoteAddr).To(Equal(cs.remoteAddr))
+				Expect(stream.dataWritten.Bytes()).To(HavePrefix("REJ"))
+				Expect(aeadChanged).ToNot(Receive())
+			})
+
+			It("rejects replayed client nonces", func() {
+				Expect(scfg.strikeRegister.insert(nonce32)).To(BeTrue())
+				done, err := cs.handleMessage(chloData, fullCHLO)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(done).To(BeFalse())
+				Expect(stream.dataWritten.Bytes()).To(HavePrefix("REJ"))
+				Expect(aeadChanged).ToNot(Receive())
+			})
+
+			It("rejects stale client nonces", func() {
+				binary.BigEndian.PutUint32(nonce32, uint32(time.Now().Add(-2*protocol.DefaultZeroRTTReplayWindow).Unix()))
+				done, err := cs.handleMessage(chloData, fullCHLO)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(done).To(BeFalse())
+				Expect(stream.dataWritten.Bytes()).To(HavePrefix("REJ"))
+			})
+
+			It("accepts the CHLO following its REJ", func() {
+				cs.accept0RTTCallback = func(net.Addr) bool { return false }
+				done, err := cs.handleMessage(chloData, fullCHLO)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(done).To(BeFalse())
+				fullCHLO[TagSNO] = cs.serverNonce
+				expectedInitialNonceLen = 64 // the client nonce and the server nonce
+				done, err = cs.handleMessage(chloData, fullCHLO)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(done).To(BeTrue())
+				Expect(cs.ConnectionState().Used0RTT).To(BeFalse())
+			})
+
+			It("rejects CHLOs with a wrong server nonce", func() {
+				cs.accept0RTTCallback = func(net.Addr) bool { return false }
+				cs.serverNonce = []byte("server nonce")
+				fullCHLO[TagSNO] = []byte("another nonce")
+				done, err := cs.handleMessage(chloData, fullCHLO)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(done).To(BeFalse())
+			})
+		})
+
 		It("recognizes inchoate CHLOs missing SCID", func() {
 			delete(fullCHLO, TagSCID)
 			Expect(cs.isInchoateCHLO(fullCHLO, cert)).To(BeTrue())
@@ -476,7 +553,7 @@ var _ = Describe("Server Crypto Setup", func() {
 		})
 
 		It("accepts a non-matching version tag in the CHLO, if it is an unsupported version", func() {
-			supportedVersion := protocol.SupportedVersions[0]
+			supportedVersion := version
 			unsupportedVersion := supportedVersion + 1000
 			Expect(protocol.IsSupportedVersion(supportedVersions, unsupportedVersion)).To(BeFalse())
 			cs.version = supportedVersion
@@ -569,26 +646,26 @@ var _ = Describe("Server Crypto Setup", func() {
 			It("is used initially", func() {
 				enc, sealer := cs.GetSealer()
 				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar unencrypted")))
 			})
 
 			It("is used for crypto stream", func() {
 				enc, sealer := cs.GetSealerForCryptoStream()
 				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar unencrypted")))
 			})
 
 			It("is accepted initially", func() {
-				d, enc, err := cs.Open(nil, []byte("unencrypted"), 0, []byte{})
+				d, enc, err := cs.Open(nil, []byte("unencrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).ToNot(HaveOccurred())
 				Expect(d).To(Equal([]byte("decrypted")))
 				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
 			})
 
 			It("errors if the has the wrong hash", func() {
-				_, enc, err := cs.Open(nil, []byte("not unencrypted"), 0, []byte{})
+				_, enc, err := cs.Open(nil, []byte("not unencrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).To(MatchError("authentication failed"))
 				Expect(enc).To(Equal(protocol.EncryptionUnspecified))
 			})
@@ -596,7 +673,7 @@ var _ = Describe("Server Crypto Setup", func() {
 			It("is still accepted after CHLO", func() {
 				doCHLO()
 				Expect(cs.secureAEAD).ToNot(BeNil())
-				_, enc, err := cs.Open(nil, []byte("unencrypted"), 0, []byte{})
+				_, enc, err := cs.Open(nil, []byte("unencrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).ToNot(HaveOccurred())
 				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
 			})
@@ -604,11 +681,11 @@ var _ = Describe("Server Crypto Setup", func() {
 			It("is not accepted after receiving secure packet", func() {
 				doCHLO()
 				Expect(cs.secureAEAD).ToNot(BeNil())
-				d, enc, err := cs.Open(nil, []byte("encrypted"), 0, []byte{})
+				d, enc, err := cs.Open(nil, []byte("encrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(enc).To(Equal(protocol.EncryptionSecure))
 				Expect(err).ToNot(HaveOccurred())
 				Expect(d).To(Equal([]byte("decrypted")))
-				_, enc, err = cs.Open(nil, []byte("foobar unencrypted"), 0, []byte{})
+				_, enc, err = cs.Open(nil, []byte("foobar unencrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).To(MatchError("authentication failed"))
 				Expect(enc).To(Equal(protocol.EncryptionUnspecified))
 			})
@@ -617,7 +694,7 @@ var _ = Describe("Server Crypto Setup", func() {
 				doCHLO()
 				enc, sealer := cs.GetSealer()
 				Expect(enc).ToNot(Equal(protocol.EncryptionUnencrypted))
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).ToNot(Equal([]byte("foobar unencrypted")))
 			})
 		})
@@ -625,7 +702,7 @@ var _ = Describe("Server Crypto Setup", func() {
 		Context("initial encryption", func() {
 			It("is accepted after CHLO", func() {
 				doCHLO()
-				d, enc, err := cs.Open(nil, []byte("encrypted"), 0, []byte{})
+				d, enc, err := cs.Open(nil, []byte("encrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(enc).To(Equal(protocol.EncryptionSecure))
 				Expect(err).ToNot(HaveOccurred())
 				Expect(d).To(Equal([]byte("decrypted")))
@@ -633,9 +710,9 @@ var _ = Describe("Server Crypto Setup", func() {
 
 			It("is not accepted after receiving forward secure packet", func() {
 				doCHLO()
-				_, _, err := cs.Open(nil, []byte("forward secure encrypted"), 0, []byte{})
+				_, _, err := cs.Open(nil, []byte("forward secure encrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).ToNot(HaveOccurred())
-				_, enc, err := cs.Open(nil, []byte("encrypted"), 0, []byte{})
+				_, enc, err := cs.Open(nil, []byte("encrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).To(MatchError("authentication failed"))
 				Expect(enc).To(Equal(protocol.EncryptionUnspecified))
 			})
@@ -644,7 +721,7 @@ var _ = Describe("Server Crypto Setup", func() {
 				doCHLO()
 				enc, sealer := cs.GetSealerForCryptoStream()
 				Expect(enc).To(Equal(protocol.EncryptionSecure))
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar  normal sec")))
 			})
 		})
@@ -654,13 +731,13 @@ var _ = Describe("Server Crypto Setup", func() {
 				doCHLO()
 				enc, sealer := cs.GetSealer()
 				Expect(enc).To(Equal(protocol.EncryptionForwardSecure))
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar forward sec")))
 			})
 
 			It("regards the handshake as complete once it receives a forward encrypted packet", func() {
 				doCHLO()
-				_, _, err := cs.Open(nil, []byte("forward secure encrypted"), 0, []byte{})
+				_, _, err := cs.Open(nil, []byte("forward secure encrypted"), 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).ToNot(HaveOccurred())
 				Expect(aeadChanged).To(BeClosed())
 			})
@@ -670,7 +747,7 @@ var _ = Describe("Server Crypto Setup", func() {
 			It("forces null encryption", func() {
 				sealer, err := cs.GetSealerWithEncryptionLevel(protocol.EncryptionUnencrypted)
 				Expect(err).ToNot(HaveOccurred())
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar unencrypted")))
 			})
 
@@ -678,7 +755,7 @@ var _ = Describe("Server Crypto Setup", func() {
 				doCHLO()
 				sealer, err := cs.GetSealerWithEncryptionLevel(protocol.EncryptionSecure)
 				Expect(err).ToNot(HaveOccurred())
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar  normal sec")))
 			})
 
@@ -692,7 +769,7 @@ var _ = Describe("Server Crypto Setup", func() {
 				doCHLO()
 				sealer, err := cs.GetSealerWithEncryptionLevel(protocol.EncryptionForwardSecure)
 				Expect(err).ToNot(HaveOccurred())
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar forward sec")))
 			})
 
@@ -2,6 +2,7 @@ package handshake
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"sync"
@@ -9,6 +10,7 @@ import (
 	"github.com/yyleeshine/mpquic/repository/bifurcation/mint"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/crypto"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/qerr"
 )
 
 // KeyDerivationFunction is used for key derivation
@@ -18,60 +20,149 @@ type cryptoSetupTLS struct {
 	mutex sync.RWMutex
 
 	perspective protocol.Perspective
+	version     protocol.VersionNumber
 
 	keyDerivation KeyDerivationFunction
+	keyLog        KeyLogFunc
 
-	mintConf *mint.Config
-	conn     crypto.MintController
+	mintConf         *mint.Config
+	conn             crypto.MintController
+	extensionHandler *extensionHandler
 
-	nullAEAD crypto.AEAD
-	aead     crypto.AEAD
+	nullAEAD           crypto.AEAD
+	aead               crypto.AEAD
+	negotiatedProtocol string
 
 	aeadChanged chan<- protocol.EncryptionLevel
 }
 
-// NewCryptoSetupTLS creates a new CryptoSetup instance for a server
-func NewCryptoSetupTLS(
-	hostname string, // only needed for the client
-	perspective protocol.Perspective,
-	version protocol.VersionNumber,
+var errNoApplicationProtocol = qerr.Error(qerr.CryptoMessageParameterNoOverlap, "no application protocol negotiated")
+
+// NewCryptoSetupTLSServer creates a new TLS CryptoSetup instance for a server.
+// If set, the keyLogWriter receives the secrets of the TLS handshake in the NSS key log format.
+func NewCryptoSetupTLSServer(
+	cryptoStream io.ReadWriter,
 	tlsConfig *tls.Config,
+	connectionParameters ConnectionParametersManager,
+	supportedVersions []protocol.VersionNumber,
+	version protocol.VersionNumber,
+	aeadChanged chan<- protocol.EncryptionLevel,
+	keyLog KeyLogFunc,
+	keyLogWriter io.Writer,
+) (CryptoSetup, error) {
+	mintConf, err := tlsToMintConfig(tlsConfig, protocol.PerspectiveServer)
+	if err != nil {
+		return nil, err
+	}
+	mintConf.KeyLogWriter = keyLogWriter
+	return newCryptoSetupTLS(
+		mint.Server(&fakeConn{cryptoStream}, mintConf),
+		mintConf,
+		newExtensionHandlerServer(connectionParameters, supportedVersions),
+		protocol.PerspectiveServer,
+		version,
+		aeadChanged,
+		keyLog,
+	)
+}
+
+// NewCryptoSetupTLSClient creates a new TLS CryptoSetup instance for a client.
+// If set, the keyLogWriter receives the secrets of the TLS handshake in the NSS key log format.
+func NewCryptoSetupTLSClient(
 	cryptoStream io.ReadWriter,
+	hostname string,
+	tlsConfig *tls.Config,
+	connectionParameters ConnectionParametersManager,
+	params *TransportParameters,
+	negotiatedVersions []protocol.VersionNumber,
+	version protocol.VersionNumber,
 	aeadChanged chan<- protocol.EncryptionLevel,
+	keyLog KeyLogFunc,
+	keyLogWriter io.Writer,
 ) (CryptoSetup, error) {
-	mintConf, err := tlsToMintConfig(tlsConfig, perspective)
+	mintConf, err := tlsToMintConfig(tlsConfig, protocol.PerspectiveClient)
 	if err != nil {
 		return nil, err
 	}
 	mintConf.ServerName = hostname
-	var conn *mint.Conn
-	if perspective == protocol.PerspectiveServer {
-		conn = mint.Server(&fakeConn{cryptoStream}, mintConf)
-	} else {
-		conn = mint.Client(&fakeConn{cryptoStream}, mintConf)
+	mintConf.KeyLogWriter = keyLogWriter
+	mintConf.AuthCertificate = func(chain []mint.CertificateEntry) error {
+		certs := make([]*x509.Certificate, len(chain))
+		for i, entry := range chain {
+			certs[i] = entry.CertData
+		}
+		return crypto.VerifyCertificateChain(certs, hostname, tlsConfig)
+	}
+	return newCryptoSetupTLS(
+		mint.Client(&fakeConn{cryptoStream}, mintConf),
+		mintConf,
+		newExtensionHandlerClient(connectionParameters, params, negotiatedVersions),
+		protocol.PerspectiveClient,
+		version,
+		aeadChanged,
+		keyLog,
+	)
+}
+
+func newCryptoSetupTLS(
+	conn *mint.Conn,
+	mintConf *mint.Config,
+	extensionHandler *extensionHandler,
+	perspective protocol.Perspective,
+	version protocol.VersionNumber,
+	aeadChanged chan<- protocol.EncryptionLevel,
+	keyLog KeyLogFunc,
+) (CryptoSetup, error) {
+	if err := conn.SetExtensionHandler(extensionHandler); err != nil {
+		return nil, err
 	}
 	return &cryptoSetupTLS{
-		perspective:   perspective,
-		mintConf:      mintConf,
-		conn:          &mintController{conn},
-		nullAEAD:      crypto.NewNullAEAD(perspective, version),
-		keyDerivation: crypto.DeriveAESKeys,
-		aeadChanged:   aeadChanged,
+		perspective:      perspective,
+		version:          version,
+		mintConf:         mintConf,
+		conn:             &mintController{conn},
+		extensionHandler: extensionHandler,
+		nullAEAD:         crypto.NewNullAEAD(perspective, version),
+		keyDerivation:    crypto.DeriveAESKeys,
+		aeadChanged:      aeadChanged,
+		keyLog:           keyLog,
 	}, nil
 }
 
 func (h *cryptoSetupTLS) HandleCryptoStream() error {
-	alert := h.conn.Handshake()
-	if alert != mint.AlertNoAlert {
-		return fmt.Errorf("TLS handshake error: %s (Alert %d)", alert.String(), alert)
+	// in non-blocking mode, mint only advances the handshake by one state at a time
+	for {
+		alert := h.conn.Handshake()
+		if alert != mint.AlertNoAlert {
+			if h.extensionHandler.err != nil {
+				return h.extensionHandler.err
+			}
+			return fmt.Errorf("TLS handshake error: %s (Alert %d)", alert.String(), alert)
+		}
+		if h.handshakeComplete() {
+			break
+		}
+	}
+
+	// mint doesn't fail the handshake if ALPN was offered, but no protocol was selected
+	negotiatedProtocol := h.conn.State().NextProto
+	if len(h.mintConf.NextProtos) > 0 && negotiatedProtocol == "" {
+		return errNoApplicationProtocol
 	}
 
 	aead, err := h.keyDerivation(h.conn, h.perspective)
 	if err != nil {
 		return err
 	}
+	if h.version.UsesKeyUpdates() {
+		if aead, err = crypto.NewKeyUpdatingAEAD(aead, logKeyUpdates(h.keyLog, h.perspective)); err != nil {
+			return err
+		}
+	}
+	logKeys(h.keyLog, keyLogForwardSecure, h.perspective, aead)
 	h.mutex.Lock()
 	h.aead = aead
+	h.negotiatedProtocol = negotiatedProtocol
 	h.mutex.Unlock()
 
 	// signal to the outside world that the handshake completed
@@ -80,18 +171,23 @@ func (h *cryptoSetupTLS) HandleCryptoStream() error {
 	return nil
 }
 
-func (h *cryptoSetupTLS) Open(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, protocol.EncryptionLevel, error) {
+func (h *cryptoSetupTLS) handshakeComplete() bool {
+	state := h.conn.State().HandshakeState
+	return state == mint.StateClientConnected || state == mint.StateServerConnected
+}
+
+func (h *cryptoSetupTLS) Open(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, keyPhase protocol.KeyPhaseBit, associatedData []byte) ([]byte, protocol.EncryptionLevel, error) {
 	h.mutex.RLock()
 	defer h.mutex.RUnlock()
 
 	if h.aead != nil {
-		data, err := h.aead.Open(dst, src, packetNumber, associatedData)
+		data, err := crypto.OpenWithKeyPhase(h.aead, dst, src, pathID, packetNumber, keyPhase, associatedData)
 		if err != nil {
 			return nil, protocol.EncryptionUnspecified, err
 		}
 		return data, protocol.EncryptionForwardSecure, nil
 	}
-	data, err := h.nullAEAD.Open(dst, src, packetNumber, associatedData)
+	data, err := h.nullAEAD.Open(dst, src, pathID, packetNumber, associatedData)
 	if err != nil {
 		return nil, protocol.EncryptionUnspecified, err
 	}
@@ -137,3 +233,12 @@ func (h *cryptoSetupTLS) DiversificationNonce() []byte {
 func (h *cryptoSetupTLS) SetDiversificationNonce([]byte) {
 	panic("diversification nonce not needed for TLS")
 }
+
+func (h *cryptoSetupTLS) ConnectionState() ConnectionState {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return ConnectionState{
+		HandshakeComplete:  h.aead != nil,
+		NegotiatedProtocol: h.negotiatedProtocol,
+	}
+}
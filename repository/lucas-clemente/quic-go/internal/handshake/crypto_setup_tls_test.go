@@ -1,19 +1,22 @@
 package handshake
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/yyleeshine/mpquic/repository/bifurcation/mint"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/crypto"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/testdata"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/qerr"
 
 	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
 	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
 )
 
 type fakeMintController struct {
-	result mint.Alert
+	result    mint.Alert
+	nextProto string
 }
 
 var _ crypto.MintController = &fakeMintController{}
@@ -21,6 +24,12 @@ var _ crypto.MintController = &fakeMintController{}
 func (h *fakeMintController) Handshake() mint.Alert {
 	return h.result
 }
+func (h *fakeMintController) State() mint.ConnectionState {
+	if h.result != mint.AlertNoAlert {
+		return mint.ConnectionState{HandshakeState: mint.StateServerStart}
+	}
+	return mint.ConnectionState{HandshakeState: mint.StateServerConnected, NextProto: h.nextProto}
+}
 func (h *fakeMintController) GetCipherSuite() mint.CipherSuiteParams { panic("not implemented") }
 func (h *fakeMintController) ComputeExporter(label string, context []byte, keyLength int) ([]byte, error) {
 	panic("not implemented")
@@ -38,13 +47,15 @@ var _ = Describe("TLS Crypto Setup", func() {
 
 	BeforeEach(func() {
 		aeadChanged = make(chan protocol.EncryptionLevel, 2)
-		csInt, err := NewCryptoSetupTLS(
-			"",
-			protocol.PerspectiveServer,
-			protocol.VersionTLS,
-			testdata.GetTLSConfig(),
+		csInt, err := NewCryptoSetupTLSServer(
 			nil,
+			testdata.GetTLSConfig(),
+			NewConnectionParamatersManager(protocol.PerspectiveServer, protocol.VersionTLS, 0, 0, 0, 0, 0),
+			protocol.SupportedVersions,
+			protocol.VersionTLS,
 			aeadChanged,
+			nil,
+			nil,
 		)
 		Expect(err).ToNot(HaveOccurred())
 		cs = csInt.(*cryptoSetupTLS)
@@ -57,6 +68,54 @@ var _ = Describe("TLS Crypto Setup", func() {
 		Expect(err).To(MatchError(fmt.Errorf("TLS handshake error: %s (Alert %d)", alert.String(), alert)))
 	})
 
+	It("returns the error of the extension handler", func() {
+		cs.conn = &fakeMintController{result: mint.AlertHandshakeFailure}
+		cs.extensionHandler.err = qerr.Error(qerr.VersionNegotiationMismatch, "Downgrade attack detected")
+		err := cs.HandleCryptoStream()
+		Expect(err).To(MatchError(cs.extensionHandler.err))
+	})
+
+	It("errors when no application protocol was negotiated", func() {
+		cs.mintConf.NextProtos = []string{"foo"}
+		cs.conn = &fakeMintController{result: mint.AlertNoAlert}
+		cs.keyDerivation = mockKeyDerivation
+		err := cs.HandleCryptoStream()
+		Expect(err).To(MatchError(errNoApplicationProtocol))
+		Expect(aeadChanged).ToNot(Receive())
+	})
+
+	It("reports the negotiated application protocol", func() {
+		cs.mintConf.NextProtos = []string{"foo"}
+		cs.conn = &fakeMintController{result: mint.AlertNoAlert, nextProto: "foo"}
+		cs.keyDerivation = mockKeyDerivation
+		Expect(cs.ConnectionState().HandshakeComplete).To(BeFalse())
+		err := cs.HandleCryptoStream()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cs.ConnectionState()).To(Equal(ConnectionState{
+			HandshakeComplete:  true,
+			NegotiatedProtocol: "foo",
+		}))
+	})
+
+	It("passes the key log writer to mint", func() {
+		keyLogWriter := &bytes.Buffer{}
+		csInt, err := NewCryptoSetupTLSClient(
+			nil,
+			"quic.clemente.io",
+			nil,
+			NewConnectionParamatersManager(protocol.PerspectiveClient, protocol.VersionTLS, 0, 0, 0, 0, 0),
+			&TransportParameters{},
+			nil,
+			protocol.VersionTLS,
+			aeadChanged,
+			nil,
+			keyLogWriter,
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(csInt.(*cryptoSetupTLS).mintConf.KeyLogWriter).To(Equal(keyLogWriter))
+		Expect(cs.mintConf.KeyLogWriter).To(BeNil())
+	})
+
 	It("derives keys", func() {
 		cs.conn = &fakeMintController{result: mint.AlertNoAlert}
 		cs.keyDerivation = mockKeyDerivation
@@ -78,19 +137,19 @@ var _ = Describe("TLS Crypto Setup", func() {
 
 		BeforeEach(func() {
 			nullAEAD := crypto.NewNullAEAD(protocol.PerspectiveServer, protocol.VersionTLS)
-			foobarFNVSigned = nullAEAD.Seal(nil, []byte("foobar"), 0, nil)
+			foobarFNVSigned = nullAEAD.Seal(nil, []byte("foobar"), 0, 0, nil)
 		})
 
 		Context("null encryption", func() {
 			It("is used initially", func() {
 				enc, sealer := cs.GetSealer()
 				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal(foobarFNVSigned))
 			})
 
 			It("is accepted initially", func() {
-				d, enc, err := cs.Open(nil, foobarFNVSigned, 0, []byte{})
+				d, enc, err := cs.Open(nil, foobarFNVSigned, 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).ToNot(HaveOccurred())
 				Expect(d).To(Equal([]byte("foobar")))
 				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
@@ -99,20 +158,20 @@ var _ = Describe("TLS Crypto Setup", func() {
 			It("is used for crypto stream", func() {
 				enc, sealer := cs.GetSealerForCryptoStream()
 				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal(foobarFNVSigned))
 			})
 
 			It("errors if the has the wrong hash", func() {
 				foobarFNVSigned[0]++
-				_, enc, err := cs.Open(nil, foobarFNVSigned, 0, []byte{})
+				_, enc, err := cs.Open(nil, foobarFNVSigned, 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).To(MatchError("NullAEAD: failed to authenticate received data"))
 				Expect(enc).To(Equal(protocol.EncryptionUnspecified))
 			})
 
 			It("is not accepted after the handshake completes", func() {
 				doHandshake()
-				_, enc, err := cs.Open(nil, foobarFNVSigned, 0, []byte{})
+				_, enc, err := cs.Open(nil, foobarFNVSigned, 0, 0, protocol.KeyPhaseZero, []byte{})
 				Expect(err).To(MatchError("authentication failed"))
 				Expect(enc).To(Equal(protocol.EncryptionUnspecified))
 			})
@@ -123,13 +182,13 @@ var _ = Describe("TLS Crypto Setup", func() {
 				doHandshake()
 				enc, sealer := cs.GetSealer()
 				Expect(enc).To(Equal(protocol.EncryptionForwardSecure))
-				d := sealer.Seal(nil, []byte("foobar"), 0, nil)
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, nil)
 				Expect(d).To(Equal([]byte("foobar forward sec")))
 			})
 
 			It("is used for opening after the handshake completes", func() {
 				doHandshake()
-				d, enc, err := cs.Open(nil, []byte("forward secure encrypted"), 0, nil)
+				d, enc, err := cs.Open(nil, []byte("forward secure encrypted"), 0, 0, protocol.KeyPhaseZero, nil)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(enc).To(Equal(protocol.EncryptionForwardSecure))
 				Expect(d).To(Equal([]byte("decrypted")))
@@ -141,7 +200,7 @@ var _ = Describe("TLS Crypto Setup", func() {
 				doHandshake()
 				sealer, err := cs.GetSealerWithEncryptionLevel(protocol.EncryptionUnencrypted)
 				Expect(err).ToNot(HaveOccurred())
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal(foobarFNVSigned))
 			})
 
@@ -149,7 +208,7 @@ var _ = Describe("TLS Crypto Setup", func() {
 				doHandshake()
 				sealer, err := cs.GetSealerWithEncryptionLevel(protocol.EncryptionForwardSecure)
 				Expect(err).ToNot(HaveOccurred())
-				d := sealer.Seal(nil, []byte("foobar"), 0, []byte{})
+				d := sealer.Seal(nil, []byte("foobar"), 0, 0, []byte{})
 				Expect(d).To(Equal([]byte("foobar forward sec")))
 			})
 
@@ -0,0 +1,266 @@
+package handshake
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A HandshakeCacheEntry holds the data a client needs to resume the handshake with a server without a REJ
+type HandshakeCacheEntry struct {
+	STK          []byte
+	CertData     []byte
+	ServerConfig []byte
+}
+
+func (e *HandshakeCacheEntry) clone() *HandshakeCacheEntry {
+	return &HandshakeCacheEntry{
+		STK:          append([]byte(nil), e.STK...),
+		CertData:     append([]byte(nil), e.CertData...),
+		ServerConfig: append([]byte(nil), e.ServerConfig...),
+	}
+}
+
+// A HandshakeCache stores the handshake data of the servers a client connected to, keyed by the server name
+// It must be safe for concurrent use by multiple sessions.
+type HandshakeCache interface {
+	// Get returns the entry for the server, if there is one that didn't expire yet
+	Get(serverName string) (*HandshakeCacheEntry, bool)
+	// Put stores the entry for the server, replacing any previous entry
+	Put(serverName string, entry *HandshakeCacheEntry)
+}
+
+type memoryHandshakeCacheItem struct {
+	serverName string
+	entry      *HandshakeCacheEntry
+	expiry     time.Time
+}
+
+// memoryHandshakeCache keeps the least recently used entries in memory
+type memoryHandshakeCache struct {
+	mutex sync.Mutex
+
+	maxEntries int
+	ttl        time.Duration
+	now        func() time.Time
+
+	items map[string]*list.Element
+	lru   *list.List // the most recently used item is at the front
+}
+
+var _ HandshakeCache = &memoryHandshakeCache{}
+
+var errNegativeMaxEntries = errors.New("HandshakeCache: maxEntries must not be negative")
+
+// NewMemoryHandshakeCache creates a HandshakeCache that keeps at most maxEntries entries in memory, each for at most ttl
+func NewMemoryHandshakeCache(maxEntries int, ttl time.Duration) (HandshakeCache, error) {
+	if maxEntries < 0 {
+		return nil, errNegativeMaxEntries
+	}
+	return &memoryHandshakeCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		now:        time.Now,
+		items:      make(map[string]*list.Element),
+		lru:        list.New(),
+	}, nil
+}
+
+func (c *memoryHandshakeCache) Get(serverName string) (*HandshakeCacheEntry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.items[serverName]
+	if !ok {
+		return nil, false
+	}
+	item := el.Value.(*memoryHandshakeCacheItem)
+	if !c.now().Before(item.expiry) {
+		c.lru.Remove(el)
+		delete(c.items, serverName)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return item.entry.clone(), true
+}
+
+func (c *memoryHandshakeCache) Put(serverName string, entry *HandshakeCacheEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	item := &memoryHandshakeCacheItem{
+		serverName: serverName,
+		entry:      entry.clone(),
+		expiry:     c.now().Add(c.ttl),
+	}
+	if el, ok := c.items[serverName]; ok {
+		el.Value = item
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.items[serverName] = c.lru.PushFront(item)
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.items, oldest.Value.(*memoryHandshakeCacheItem).serverName)
+	}
+}
+
+const (
+	handshakeCacheFilePrefix  = "handshake_"
+	handshakeCacheFileVersion = 1
+)
+
+var errInvalidHandshakeCacheFile = errors.New("invalid handshake cache file")
+
+// directoryHandshakeCache stores every entry in its own file.
+// Files are only readable by the user, and are replaced atomically, so that multiple processes can share the directory.
+type directoryHandshakeCache struct {
+	mutex sync.Mutex
+
+	dir        string
+	maxEntries int
+	ttl        time.Duration
+	now        func() time.Time
+}
+
+var _ HandshakeCache = &directoryHandshakeCache{}
+
+// NewDirectoryHandshakeCache creates a HandshakeCache that stores at most maxEntries entries in dir, each for at most ttl
+// The directory is created if it doesn't exist yet.
+func NewDirectoryHandshakeCache(dir string, maxEntries int, ttl time.Duration) (HandshakeCache, error) {
+	if maxEntries < 0 {
+		return nil, errNegativeMaxEntries
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &directoryHandshakeCache{
+		dir:        dir,
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		now:        time.Now,
+	}, nil
+}
+
+func (c *directoryHandshakeCache) filename(serverName string) string {
+	// the server name may contain characters that are not allowed in file names
+	return filepath.Join(c.dir, handshakeCacheFilePrefix+hex.EncodeToString([]byte(serverName)))
+}
+
+func (c *directoryHandshakeCache) Get(serverName string) (*HandshakeCacheEntry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	filename := c.filename(serverName)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, false
+	}
+	name, expiry, entry, err := decodeHandshakeCacheEntry(data)
+	if err != nil || name != serverName {
+		os.Remove(filename)
+		return nil, false
+	}
+	if !c.now().Before(expiry) {
+		os.Remove(filename)
+		return nil, false
+	}
+	return entry, true
+}
+
+func (c *directoryHandshakeCache) Put(serverName string, entry *HandshakeCacheEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	data := encodeHandshakeCacheEntry(serverName, c.now().Add(c.ttl), entry)
+	// write to a temporary file first, so that readers never see a partially written entry
+	f, err := ioutil.TempFile(c.dir, ".tmp_"+handshakeCacheFilePrefix)
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.filename(serverName))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+	c.evict()
+}
+
+// evict deletes the least recently written files, if there are more than maxEntries
+// Attention: this function must only be called if the mutex has been acquired previously
+func (c *directoryHandshakeCache) evict() {
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	entries := make([]os.FileInfo, 0, len(files))
+	for _, f := range files {
+		if f.Mode().IsRegular() && strings.HasPrefix(f.Name(), handshakeCacheFilePrefix) {
+			entries = append(entries, f)
+		}
+	}
+	if len(entries) <= c.maxEntries {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ModTime().Before(entries[j].ModTime()) })
+	for _, f := range entries[:len(entries)-c.maxEntries] {
+		os.Remove(filepath.Join(c.dir, f.Name()))
+	}
+}
+
+func encodeHandshakeCacheEntry(serverName string, expiry time.Time, entry *HandshakeCacheEntry) []byte {
+	b := &bytes.Buffer{}
+	b.WriteByte(handshakeCacheFileVersion)
+	binary.Write(b, binary.BigEndian, expiry.UnixNano())
+	for _, field := range [][]byte{[]byte(serverName), entry.STK, entry.CertData, entry.ServerConfig} {
+		binary.Write(b, binary.BigEndian, uint32(len(field)))
+		b.Write(field)
+	}
+	return b.Bytes()
+}
+
+func decodeHandshakeCacheEntry(data []byte) (string, time.Time, *HandshakeCacheEntry, error) {
+	r := bytes.NewReader(data)
+	version, err := r.ReadByte()
+	if err != nil || version != handshakeCacheFileVersion {
+		return "", time.Time{}, nil, errInvalidHandshakeCacheFile
+	}
+	var expiry int64
+	if err := binary.Read(r, binary.BigEndian, &expiry); err != nil {
+		return "", time.Time{}, nil, errInvalidHandshakeCacheFile
+	}
+	fields := make([][]byte, 4)
+	for i := range fields {
+		var l uint32
+		if err := binary.Read(r, binary.BigEndian, &l); err != nil || int64(l) > int64(r.Len()) {
+			return "", time.Time{}, nil, errInvalidHandshakeCacheFile
+		}
+		fields[i] = make([]byte, l)
+		r.Read(fields[i])
+	}
+	if r.Len() != 0 {
+		return "", time.Time{}, nil, errInvalidHandshakeCacheFile
+	}
+	entry := &HandshakeCacheEntry{
+		STK:          fields[1],
+		CertData:     fields[2],
+		ServerConfig: fields[3],
+	}
+	return string(fields[0]), time.Unix(0, expiry), entry, nil
+}
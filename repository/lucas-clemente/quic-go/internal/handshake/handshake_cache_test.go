@@ -0,0 +1,210 @@
+package handshake
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+var _ = Describe("Handshake Cache", func() {
+	var entry *HandshakeCacheEntry
+
+	BeforeEach(func() {
+		entry = &HandshakeCacheEntry{
+			STK:          []byte("stk"),
+			CertData:     []byte("cert"),
+			ServerConfig: []byte("scfg"),
+		}
+	})
+
+	Context("in memory", func() {
+		var (
+			cache *memoryHandshakeCache
+			now   time.Time
+		)
+
+		BeforeEach(func() {
+			c, err := NewMemoryHandshakeCache(2, time.Hour)
+			Expect(err).ToNot(HaveOccurred())
+			cache = c.(*memoryHandshakeCache)
+			now = time.Now()
+			cache.now = func() time.Time { return now }
+		})
+
+		It("rejects a negative number of entries", func() {
+			_, err := NewMemoryHandshakeCache(-1, time.Hour)
+			Expect(err).To(MatchError(errNegativeMaxEntries))
+		})
+
+		It("returns a stored entry", func() {
+			cache.Put("foo", entry)
+			e, ok := cache.Get("foo")
+			Expect(ok).To(BeTrue())
+			Expect(e).To(Equal(entry))
+			_, ok = cache.Get("bar")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("replaces entries", func() {
+			cache.Put("foo", entry)
+			cache.Put("foo", &HandshakeCacheEntry{STK: []byte("new stk")})
+			e, ok := cache.Get("foo")
+			Expect(ok).To(BeTrue())
+			Expect(e.STK).To(Equal([]byte("new stk")))
+			Expect(cache.lru.Len()).To(Equal(1))
+		})
+
+		It("copies the entries", func() {
+			cache.Put("foo", entry)
+			entry.STK[0] = 'x'
+			e, _ := cache.Get("foo")
+			Expect(e.STK).To(Equal([]byte("stk")))
+			e.STK[0] = 'x'
+			e, _ = cache.Get("foo")
+			Expect(e.STK).To(Equal([]byte("stk")))
+		})
+
+		It("expires entries", func() {
+			cache.Put("foo", entry)
+			now = now.Add(time.Hour - time.Nanosecond)
+			_, ok := cache.Get("foo")
+			Expect(ok).To(BeTrue())
+			now = now.Add(time.Nanosecond)
+			_, ok = cache.Get("foo")
+			Expect(ok).To(BeFalse())
+			Expect(cache.items).To(BeEmpty())
+		})
+
+		It("evicts the least recently used entry", func() {
+			cache.Put("foo", entry)
+			cache.Put("bar", entry)
+			_, ok := cache.Get("foo")
+			Expect(ok).To(BeTrue())
+			cache.Put("baz", entry)
+			_, ok = cache.Get("bar")
+			Expect(ok).To(BeFalse())
+			_, ok = cache.Get("foo")
+			Expect(ok).To(BeTrue())
+			_, ok = cache.Get("baz")
+			Expect(ok).To(BeTrue())
+		})
+	})
+
+	Context("in a directory", func() {
+		var (
+			dir   string
+			cache *directoryHandshakeCache
+			now   time.Time
+		)
+
+		newCache := func() *directoryHandshakeCache {
+			c, err := NewDirectoryHandshakeCache(dir, 2, time.Hour)
+			Expect(err).ToNot(HaveOccurred())
+			c.(*directoryHandshakeCache).now = func() time.Time { return now }
+			return c.(*directoryHandshakeCache)
+		}
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "quic-handshake-cache")
+			Expect(err).ToNot(HaveOccurred())
+			dir = filepath.Join(dir, "cache")
+			now = time.Now()
+			cache = newCache()
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(filepath.Dir(dir))
+		})
+
+		It("rejects a negative number of entries", func() {
+			_, err := NewDirectoryHandshakeCache(filepath.Join(dir, "other"), -1, time.Hour)
+			Expect(err).To(MatchError(errNegativeMaxEntries))
+			_, err = os.Stat(filepath.Join(dir, "other"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("creates the directory", func() {
+			fi, err := os.Stat(dir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fi.IsDir()).To(BeTrue())
+			Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0700)))
+		})
+
+		It("returns a stored entry", func() {
+			cache.Put("foo", entry)
+			e, ok := cache.Get("foo")
+			Expect(ok).To(BeTrue())
+			Expect(e).To(Equal(entry))
+			_, ok = cache.Get("bar")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("persists entries across instances", func() {
+			cache.Put("foo", entry)
+			e, ok := newCache().Get("foo")
+			Expect(ok).To(BeTrue())
+			Expect(e).To(Equal(entry))
+		})
+
+		It("handles server names that are not valid file names", func() {
+			cache.Put("../foo/bar:443", entry)
+			_, ok := cache.Get("../foo/bar:443")
+			Expect(ok).To(BeTrue())
+			files, err := ioutil.ReadDir(dir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(files).To(HaveLen(1))
+		})
+
+		It("only makes the files accessible by the user", func() {
+			cache.Put("foo", entry)
+			fi, err := os.Stat(cache.filename("foo"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0600)))
+		})
+
+		It("expires entries", func() {
+			cache.Put("foo", entry)
+			now = now.Add(time.Hour)
+			_, ok := cache.Get("foo")
+			Expect(ok).To(BeFalse())
+			_, err := os.Stat(cache.filename("foo"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("evicts the least recently written entries", func() {
+			cache.Put("foo", entry)
+			Expect(os.Chtimes(cache.filename("foo"), now, now.Add(-time.Minute))).To(Succeed())
+			cache.Put("bar", entry)
+			cache.Put("baz", entry)
+			_, ok := cache.Get("foo")
+			Expect(ok).To(BeFalse())
+			_, ok = cache.Get("bar")
+			Expect(ok).To(BeTrue())
+			_, ok = cache.Get("baz")
+			Expect(ok).To(BeTrue())
+		})
+
+		It("removes corrupted files", func() {
+			cache.Put("foo", entry)
+			data, err := ioutil.ReadFile(cache.filename("foo"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ioutil.WriteFile(cache.filename("foo"), data[:len(data)-1], 0600)).To(Succeed())
+			_, ok := cache.Get("foo")
+			Expect(ok).To(BeFalse())
+			_, err = os.Stat(cache.filename("foo"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("doesn't return entries stored for a different server", func() {
+			cache.Put("foo", entry)
+			Expect(os.Rename(cache.filename("foo"), cache.filename("bar"))).To(Succeed())
+			_, ok := cache.Get("bar")
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -4,13 +4,20 @@ import "github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/
 
 // Sealer seals a packet
 type Sealer interface {
-	Seal(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) []byte
+	Seal(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) []byte
 	Overhead() int
 }
 
+// A KeyUpdatingSealer regularly updates its keys. The key phase is sent in the public header.
+type KeyUpdatingSealer interface {
+	Sealer
+	// KeyPhase returns the key phase of the next packet, it has to be called before sealing it
+	KeyPhase() protocol.KeyPhaseBit
+}
+
 // CryptoSetup is a crypto setup
 type CryptoSetup interface {
-	Open(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, protocol.EncryptionLevel, error)
+	Open(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, keyPhase protocol.KeyPhaseBit, associatedData []byte) ([]byte, protocol.EncryptionLevel, error)
 	HandleCryptoStream() error
 	// TODO: clean up this interface
 	DiversificationNonce() []byte   // only needed for cryptoSetupServer
@@ -19,10 +26,22 @@ type CryptoSetup interface {
 	GetSealer() (protocol.EncryptionLevel, Sealer)
 	GetSealerWithEncryptionLevel(protocol.EncryptionLevel) (Sealer, error)
 	GetSealerForCryptoStream() (protocol.EncryptionLevel, Sealer)
+
+	ConnectionState() ConnectionState
+}
+
+// ConnectionState records basic details about the handshake
+type ConnectionState struct {
+	HandshakeComplete  bool   // the handshake is complete, forward-secure keys are available
+	NegotiatedProtocol string // the application protocol negotiated with ALPN, only available with TLS
+	Used0RTT           bool   // the server accepted the data the client sent before the handshake completed
 }
 
 // TransportParameters are parameters sent to the peer during the handshake
 type TransportParameters struct {
 	RequestConnectionIDTruncation bool
-	CacheHandshake bool
+	// HandshakeCache stores the handshake data of servers, nil disables caching
+	HandshakeCache HandshakeCache
+	// EarlyData allows sending data with 0-RTT keys, if the handshake data of the server was cached
+	EarlyData bool
 }
@@ -0,0 +1,55 @@
+package handshake
+
+import (
+	"strconv"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/crypto"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+)
+
+// A KeyLogFunc is called with every key and IV derived by a CryptoSetup, e.g. to write them to a key log.
+// The label names the sender, the keys and whether the secret is a key or an IV, e.g. QUIC_CLIENT_FORWARD_SECURE_KEY.
+// The keys of the key phases after the first one are labeled with the number of the key update,
+// e.g. QUIC_CLIENT_FORWARD_SECURE_1_KEY. These labels are specific to mpquic, tools like Wireshark don't know them.
+type KeyLogFunc func(label string, secret []byte)
+
+// the names of the keys used in the labels of the key log
+const (
+	// the keys the client uses for 0-RTT data, before the server responded
+	keyLogEarly         = "EARLY"
+	keyLogSecure        = "SECURE"
+	keyLogForwardSecure = "FORWARD_SECURE"
+)
+
+// logKeys passes the keys and IVs of both directions of an AEAD to the KeyLogFunc.
+// For the 0-RTT keys, only the keys of the client are logged, since the server never uses its 0-RTT keys.
+func logKeys(keyLog KeyLogFunc, keys string, pers protocol.Perspective, aead crypto.AEAD) {
+	if keyLog == nil {
+		return
+	}
+	otherKey, myKey, otherIV, myIV, ok := crypto.ExportKeys(aead)
+	if !ok {
+		return
+	}
+	me, peer := "SERVER", "CLIENT"
+	if pers == protocol.PerspectiveClient {
+		me, peer = peer, me
+	}
+	keyLog("QUIC_"+me+"_"+keys+"_KEY", myKey)
+	keyLog("QUIC_"+me+"_"+keys+"_IV", myIV)
+	if keys == keyLogEarly {
+		return
+	}
+	keyLog("QUIC_"+peer+"_"+keys+"_KEY", otherKey)
+	keyLog("QUIC_"+peer+"_"+keys+"_IV", otherIV)
+}
+
+// logKeyUpdates returns the function that logs the keys of every new key phase of a key updating AEAD.
+func logKeyUpdates(keyLog KeyLogFunc, pers protocol.Perspective) func(uint64, crypto.AEAD) {
+	if keyLog == nil {
+		return nil
+	}
+	return func(updates uint64, aead crypto.AEAD) {
+		logKeys(keyLog, keyLogForwardSecure+"_"+strconv.FormatUint(updates, 10), pers, aead)
+	}
+}
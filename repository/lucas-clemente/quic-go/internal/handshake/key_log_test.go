@@ -0,0 +1,70 @@
+package handshake
+
+import (
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/crypto"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+var _ = Describe("Key log", func() {
+	var (
+		aead   crypto.AEAD
+		logged map[string][]byte
+		keyLog KeyLogFunc
+	)
+
+	BeforeEach(func() {
+		var err error
+		// the keys and IVs of the client
+		aead, err = crypto.NewAEADAESGCM12([]byte("serverserverserv"), []byte("clientclientclie"), []byte("sriv"), []byte("criv"))
+		Expect(err).ToNot(HaveOccurred())
+		logged = make(map[string][]byte)
+		keyLog = func(label string, secret []byte) { logged[label] = secret }
+	})
+
+	It("logs the keys and IVs of both directions", func() {
+		logKeys(keyLog, keyLogSecure, protocol.PerspectiveClient, aead)
+		Expect(logged).To(Equal(map[string][]byte{
+			"QUIC_CLIENT_SECURE_KEY": []byte("clientclientclie"),
+			"QUIC_CLIENT_SECURE_IV":  []byte("criv"),
+			"QUIC_SERVER_SECURE_KEY": []byte("serverserverserv"),
+			"QUIC_SERVER_SECURE_IV":  []byte("sriv"),
+		}))
+	})
+
+	It("names the keys from the perspective of the server", func() {
+		logKeys(keyLog, keyLogForwardSecure, protocol.PerspectiveServer, aead)
+		Expect(logged).To(HaveKeyWithValue("QUIC_SERVER_FORWARD_SECURE_KEY", []byte("clientclientclie")))
+		Expect(logged).To(HaveKeyWithValue("QUIC_CLIENT_FORWARD_SECURE_KEY", []byte("serverserverserv")))
+	})
+
+	It("only logs the 0-RTT keys of the client", func() {
+		logKeys(keyLog, keyLogEarly, protocol.PerspectiveClient, aead)
+		Expect(logged).To(Equal(map[string][]byte{
+			"QUIC_CLIENT_EARLY_KEY": []byte("clientclientclie"),
+			"QUIC_CLIENT_EARLY_IV":  []byte("criv"),
+		}))
+	})
+
+	It("doesn't log the keys of an AEAD without keys", func() {
+		logKeys(keyLog, keyLogSecure, protocol.PerspectiveClient, &mockAEAD{})
+		Expect(logged).To(BeEmpty())
+	})
+
+	It("doesn't log anything without a KeyLogFunc", func() {
+		Expect(func() { logKeys(nil, keyLogSecure, protocol.PerspectiveClient, aead) }).ToNot(Panic())
+		Expect(logKeyUpdates(nil, protocol.PerspectiveClient)).To(BeNil())
+	})
+
+	It("labels the keys of the later key phases with the number of the key update", func() {
+		logKeyUpdates(keyLog, protocol.PerspectiveClient)(3, aead)
+		Expect(logged).To(Equal(map[string][]byte{
+			"QUIC_CLIENT_FORWARD_SECURE_3_KEY": []byte("clientclientclie"),
+			"QUIC_CLIENT_FORWARD_SECURE_3_IV":  []byte("criv"),
+			"QUIC_SERVER_FORWARD_SECURE_3_KEY": []byte("serverserverserv"),
+			"QUIC_SERVER_FORWARD_SECURE_3_IV":  []byte("sriv"),
+		}))
+	})
+})
@@ -4,6 +4,7 @@ import (
 	gocrypto "crypto"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"io"
 	"net"
 	"time"
@@ -22,18 +23,24 @@ func tlsToMintConfig(tlsConf *tls.Config, pers protocol.Perspective) (*mint.Conf
 		},
 	}
 	if tlsConf != nil {
-		mconf.Certificates = make([]*mint.Certificate, len(tlsConf.Certificates))
-		for i, certChain := range tlsConf.Certificates {
-			mconf.Certificates[i] = &mint.Certificate{
-				Chain:      make([]*x509.Certificate, len(certChain.Certificate)),
-				PrivateKey: certChain.PrivateKey.(gocrypto.Signer),
+		mconf.NextProtos = tlsConf.NextProtos
+		if pers == protocol.PerspectiveServer {
+			// select the certificate in every handshake, such that it can be replaced without restarting the server
+			mconf.GetCertificate = func(sni string) (*mint.Certificate, error) {
+				cert, err := crypto.GetCertificateForSNI(tlsConf, sni)
+				if err != nil {
+					return nil, err
+				}
+				return tlsToMintCertificate(cert)
 			}
-			for j, cert := range certChain.Certificate {
-				c, err := x509.ParseCertificate(cert)
+		} else {
+			mconf.Certificates = make([]*mint.Certificate, len(tlsConf.Certificates))
+			for i := range tlsConf.Certificates {
+				cert, err := tlsToMintCertificate(&tlsConf.Certificates[i])
 				if err != nil {
 					return nil, err
 				}
-				mconf.Certificates[i].Chain[j] = c
+				mconf.Certificates[i] = cert
 			}
 		}
 	}
@@ -43,6 +50,25 @@ func tlsToMintConfig(tlsConf *tls.Config, pers protocol.Perspective) (*mint.Conf
 	return mconf, nil
 }
 
+func tlsToMintCertificate(certChain *tls.Certificate) (*mint.Certificate, error) {
+	signer, ok := certChain.PrivateKey.(gocrypto.Signer)
+	if !ok {
+		return nil, errors.New("private key is not a crypto.Signer")
+	}
+	cert := &mint.Certificate{
+		Chain:      make([]*x509.Certificate, len(certChain.Certificate)),
+		PrivateKey: signer,
+	}
+	for i, data := range certChain.Certificate {
+		c, err := x509.ParseCertificate(data)
+		if err != nil {
+			return nil, err
+		}
+		cert.Chain[i] = c
+	}
+	return cert, nil
+}
+
 type mintController struct {
 	conn *mint.Conn
 }
@@ -57,6 +83,10 @@ func (mc *mintController) GetCipherSuite() mint.CipherSuiteParams {
 	return mc.conn.State().CipherSuite
 }
 
+func (mc *mintController) State() mint.ConnectionState {
+	return mc.conn.State()
+}
+
 func (mc *mintController) ComputeExporter(label string, context []byte, keyLength int) ([]byte, error) {
 	return mc.conn.ComputeExporter(label, context, keyLength)
 }
@@ -0,0 +1,70 @@
+package handshake
+
+import (
+	"crypto/tls"
+	"errors"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/testdata"
+
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+var _ = Describe("Mint Utils", func() {
+	Context("converting a tls.Config", func() {
+		It("converts the certificates for the client", func() {
+			tlsConf := &tls.Config{Certificates: []tls.Certificate{testdata.GetCertificate()}}
+			mconf, err := tlsToMintConfig(tlsConf, protocol.PerspectiveClient)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mconf.Certificates).To(HaveLen(1))
+			Expect(mconf.Certificates[0].Chain).To(HaveLen(len(tlsConf.Certificates[0].Certificate)))
+			Expect(mconf.GetCertificate).To(BeNil())
+		})
+
+		It("selects the certificate for every handshake on the server", func() {
+			var sni string
+			cert := testdata.GetCertificate()
+			tlsConf := &tls.Config{
+				GetCertificate: func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
+					sni = info.ServerName
+					return &cert, nil
+				},
+			}
+			mconf, err := tlsToMintConfig(tlsConf, protocol.PerspectiveServer)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mconf.Certificates).To(BeEmpty())
+			c, err := mconf.GetCertificate("quic.clemente.io")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sni).To(Equal("quic.clemente.io"))
+			Expect(c.Chain[0].Raw).To(Equal(cert.Certificate[0]))
+		})
+
+		It("returns the error of GetCertificate", func() {
+			testErr := errors.New("no certificate")
+			tlsConf := &tls.Config{
+				GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return nil, testErr },
+			}
+			mconf, err := tlsToMintConfig(tlsConf, protocol.PerspectiveServer)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = mconf.GetCertificate("quic.clemente.io")
+			Expect(err).To(MatchError(testErr))
+		})
+
+		It("uses the first certificate if none matches the server name", func() {
+			tlsConf := testdata.GetTLSConfig()
+			mconf, err := tlsToMintConfig(tlsConf, protocol.PerspectiveServer)
+			Expect(err).ToNot(HaveOccurred())
+			c, err := mconf.GetCertificate("127.0.0.1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.Chain[0].Raw).To(Equal(tlsConf.Certificates[0].Certificate[0]))
+		})
+
+		It("errors if the private key can't be used for signing", func() {
+			cert := testdata.GetCertificate()
+			cert.PrivateKey = "foobar"
+			_, err := tlsToMintCertificate(&cert)
+			Expect(err).To(MatchError("private key is not a crypto.Signer"))
+		})
+	})
+})
@@ -3,8 +3,10 @@ package handshake
 import (
 	"bytes"
 	"crypto/rand"
+	"time"
 
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/crypto"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
 )
 
 // ServerConfig is a server config
@@ -13,6 +15,10 @@ type ServerConfig struct {
 	certChain crypto.CertChain
 	ID        []byte
 	obit      []byte
+
+	// the STKs are valid for all sessions of a server, such that clients can cache them
+	stkGenerator   *CookieGenerator
+	strikeRegister *strikeRegister
 }
 
 // NewServerConfig creates a new server config
@@ -28,14 +34,50 @@ func NewServerConfig(kex crypto.KeyExchange, certChain crypto.CertChain) (*Serve
 		return nil, err
 	}
 
+	stkGenerator, err := NewCookieGenerator()
+	if err != nil {
+		return nil, err
+	}
+
 	return &ServerConfig{
 		kex:       kex,
 		certChain: certChain,
 		ID:        id,
 		obit:      obit,
+
+		stkGenerator:   stkGenerator,
+		strikeRegister: newStrikeRegister(protocol.DefaultZeroRTTReplayWindow),
+	}, nil
+}
+
+// Rotate creates the server config that replaces this one, using a new key exchange.
+// It also rotates the secret used to encrypt the STKs, the STKs issued before are still accepted.
+// The OBIT is kept, such that clients that already generated a client nonce can complete the handshake.
+func (s *ServerConfig) Rotate(kex crypto.KeyExchange) (*ServerConfig, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	if err := s.stkGenerator.Rotate(); err != nil {
+		return nil, err
+	}
+	return &ServerConfig{
+		kex:       kex,
+		certChain: s.certChain,
+		ID:        id,
+		obit:      s.obit,
+
+		stkGenerator:   s.stkGenerator,
+		strikeRegister: s.strikeRegister,
 	}, nil
 }
 
+// SetZeroRTTReplayWindow sets the time the client nonces of 0-RTT handshakes are remembered, to detect replays
+// CHLOs with a client nonce generated longer ago are rejected.
+func (s *ServerConfig) SetZeroRTTReplayWindow(window time.Duration) {
+	s.strikeRegister.setWindow(window)
+}
+
 // Get the server config binary representation
 func (s *ServerConfig) Get() []byte {
 	var serverConfig bytes.Buffer
@@ -2,8 +2,10 @@ package handshake
 
 import (
 	"bytes"
+	"net"
 
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/crypto"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
 
 	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
 	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
@@ -29,6 +31,53 @@ var _ = Describe("ServerConfig", func() {
 		Expect(scfg1.obit).ToNot(Equal(scfg2.obit))
 	})
 
+	It("accepts the STKs it issued in other sessions", func() {
+		scfg, err := NewServerConfig(kex, nil)
+		Expect(err).ToNot(HaveOccurred())
+		remoteAddr := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 1234}
+		cs1, err := NewCryptoSetup(0, remoteAddr, protocol.VersionWhatever, scfg, nil, nil, nil, nil, nil, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		cs2, err := NewCryptoSetup(1, remoteAddr, protocol.VersionWhatever, scfg, nil, nil, nil, nil, nil, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		stk, err := cs1.(*cryptoSetupServer).stkGenerator.NewToken(remoteAddr)
+		Expect(err).ToNot(HaveOccurred())
+		cookie, err := cs2.(*cryptoSetupServer).stkGenerator.DecodeToken(stk)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cookie.RemoteAddr).To(Equal("1.2.3.4"))
+	})
+
+	Context("rotating", func() {
+		var scfg *ServerConfig
+
+		BeforeEach(func() {
+			var err error
+			scfg, err = NewServerConfig(kex, nil)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("uses a new ID and key exchange, but keeps the OBIT", func() {
+			kex2, err := crypto.NewCurve25519KEX()
+			Expect(err).ToNot(HaveOccurred())
+			rotated, err := scfg.Rotate(kex2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rotated.ID).ToNot(Equal(scfg.ID))
+			Expect(rotated.kex).To(Equal(kex2))
+			Expect(rotated.obit).To(Equal(scfg.obit))
+			Expect(rotated.strikeRegister).To(BeIdenticalTo(scfg.strikeRegister))
+		})
+
+		It("accepts the STKs issued before the rotation", func() {
+			remoteAddr := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 1234}
+			stk, err := scfg.stkGenerator.NewToken(remoteAddr)
+			Expect(err).ToNot(HaveOccurred())
+			rotated, err := scfg.Rotate(kex)
+			Expect(err).ToNot(HaveOccurred())
+			cookie, err := rotated.stkGenerator.DecodeToken(stk)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cookie.RemoteAddr).To(Equal("1.2.3.4"))
+		})
+	})
+
 	It("gets the proper binary representation", func() {
 		scfg, err := NewServerConfig(kex, nil)
 		Expect(err).NotTo(HaveOccurred())
@@ -0,0 +1,74 @@
+package handshake
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+)
+
+// The strikeRegister remembers the client nonces of the CHLOs a server accepted, to reject replayed 0-RTT handshakes.
+// The first 4 bytes of a client nonce are the time it was generated at. Nonces outside of the window are rejected,
+// so a nonce only needs to be remembered until it leaves the window.
+type strikeRegister struct {
+	mutex sync.Mutex
+
+	window     time.Duration
+	maxEntries int
+	nonces     map[string]time.Time // the time when the nonce leaves the window
+
+	lastGC time.Time
+	now    func() time.Time
+}
+
+func newStrikeRegister(window time.Duration) *strikeRegister {
+	return &strikeRegister{
+		window:     window,
+		maxEntries: protocol.MaxStrikeRegisterEntries,
+		nonces:     make(map[string]time.Time),
+		now:        time.Now,
+	}
+}
+
+func (r *strikeRegister) setWindow(window time.Duration) {
+	r.mutex.Lock()
+	r.window = window
+	r.mutex.Unlock()
+}
+
+// insert adds a nonce to the register
+// It returns false if the nonce was already seen, if it is outside the window, or if the register is full.
+func (r *strikeRegister) insert(nonce []byte) bool {
+	if len(nonce) < 4 {
+		return false
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.now()
+	generated := time.Unix(int64(binary.BigEndian.Uint32(nonce)), 0)
+	if generated.Before(now.Add(-r.window)) || generated.After(now.Add(r.window)) {
+		return false
+	}
+	if now.Sub(r.lastGC) >= r.window {
+		r.garbageCollect(now)
+	}
+	if _, ok := r.nonces[string(nonce)]; ok {
+		return false
+	}
+	if len(r.nonces) >= r.maxEntries {
+		return false
+	}
+	r.nonces[string(nonce)] = generated.Add(r.window)
+	return true
+}
+
+func (r *strikeRegister) garbageCollect(now time.Time) {
+	for nonce, expiry := range r.nonces {
+		if expiry.Before(now) {
+			delete(r.nonces, nonce)
+		}
+	}
+	r.lastGC = now
+}
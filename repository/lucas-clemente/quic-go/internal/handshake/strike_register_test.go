@@ -0,0 +1,68 @@
+package handshake
+
+import (
+	"encoding/binary"
+	"time"
+
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+var _ = Describe("Strike register", func() {
+	var (
+		r   *strikeRegister
+		now time.Time
+	)
+
+	nonceAt := func(t time.Time, rest byte) []byte {
+		nonce := make([]byte, 32)
+		binary.BigEndian.PutUint32(nonce, uint32(t.Unix()))
+		nonce[31] = rest
+		return nonce
+	}
+
+	BeforeEach(func() {
+		now = time.Unix(1500000000, 0)
+		r = newStrikeRegister(10 * time.Second)
+		r.now = func() time.Time { return now }
+	})
+
+	It("accepts a nonce once", func() {
+		nonce := nonceAt(now, 1)
+		Expect(r.insert(nonce)).To(BeTrue())
+		Expect(r.insert(nonce)).To(BeFalse())
+		Expect(r.insert(nonceAt(now, 2))).To(BeTrue())
+	})
+
+	It("rejects nonces that are too short", func() {
+		Expect(r.insert([]byte{1, 2, 3})).To(BeFalse())
+	})
+
+	It("rejects nonces outside the window", func() {
+		Expect(r.insert(nonceAt(now.Add(-11*time.Second), 1))).To(BeFalse())
+		Expect(r.insert(nonceAt(now.Add(11*time.Second), 1))).To(BeFalse())
+		Expect(r.insert(nonceAt(now.Add(-9*time.Second), 1))).To(BeTrue())
+	})
+
+	It("forgets nonces that left the window", func() {
+		nonce := nonceAt(now, 1)
+		Expect(r.insert(nonce)).To(BeTrue())
+		now = now.Add(15 * time.Second)
+		Expect(r.insert(nonceAt(now, 2))).To(BeTrue())
+		Expect(r.nonces).To(HaveLen(1))
+		// the old nonce is still rejected, since it's outside the window
+		Expect(r.insert(nonce)).To(BeFalse())
+	})
+
+	It("rejects nonces when it is full", func() {
+		r.maxEntries = 2
+		Expect(r.insert(nonceAt(now, 1))).To(BeTrue())
+		Expect(r.insert(nonceAt(now, 2))).To(BeTrue())
+		Expect(r.insert(nonceAt(now, 3))).To(BeFalse())
+	})
+
+	It("changes the window", func() {
+		r.setWindow(time.Minute)
+		Expect(r.insert(nonceAt(now.Add(-30*time.Second), 1))).To(BeTrue())
+	})
+})
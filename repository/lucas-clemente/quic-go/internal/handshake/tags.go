@@ -25,6 +25,8 @@ const (
 	TagMSPC Tag = 'M' + 'S'<<8 + 'P'<<16 + 'C'<<24
 	// TagMIDS is max incoming dyanamic streams
 	TagMIDS Tag = 'M' + 'I'<<8 + 'D'<<16 + 'S'<<24
+	// TagMIUS is max incoming unidirectional streams (unofficial tag by us)
+	TagMIUS Tag = 'M' + 'I'<<8 + 'U'<<16 + 'S'<<24
 	// TagUAID is the user agent ID
 	TagUAID Tag = 'U' + 'A'<<8 + 'I'<<16 + 'D'<<24
 	// TagSVID is the server ID (unofficial tag by us :)
@@ -49,6 +51,18 @@ const (
 	TagCFCW Tag = 'C' + 'F'<<8 + 'C'<<16 + 'W'<<24
 	// TagSFCW is the initial stream flow control receive window.
 	TagSFCW Tag = 'S' + 'F'<<8 + 'C'<<16 + 'W'<<24
+	// TagACKD is the ACK delay the peer should apply, in microseconds (unofficial tag by us)
+	TagACKD Tag = 'A' + 'C'<<8 + 'K'<<16 + 'D'<<24
+	// TagACKF is the number of retransmittable packets after which the peer should send an ACK (unofficial tag by us)
+	TagACKF Tag = 'A' + 'C'<<8 + 'K'<<16 + 'F'<<24
+	// TagACKP announces that the peer accepts the ACKs for a path on the other paths (unofficial tag by us)
+	TagACKP Tag = 'A' + 'C'<<8 + 'K'<<16 + 'P'<<24
+	// TagSTPS announces that the peer understands STOP_SENDING frames (unofficial tag by us)
+	TagSTPS Tag = 'S' + 'T'<<8 + 'P'<<16 + 'S'<<24
+	// TagMPTH announces that the peer supports multiple paths, only sent with the TLS handshake (unofficial tag by us)
+	TagMPTH Tag = 'M' + 'P'<<8 + 'T'<<16 + 'H'<<24
+	// TagUNRS announces that the peer supports unreliable streams, only sent with the TLS handshake (unofficial tag by us)
+	TagUNRS Tag = 'U' + 'N'<<8 + 'R'<<16 + 'S'<<24
 
 	// TagFHL2 forces head of line blocking.
 	// Chrome experiment (see https://codereview.chromium.org/2115033002)
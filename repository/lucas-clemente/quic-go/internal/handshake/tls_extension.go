@@ -0,0 +1,146 @@
+package handshake
+
+import (
+	"bytes"
+
+	"github.com/yyleeshine/mpquic/repository/bifurcation/mint"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/utils"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/qerr"
+)
+
+// quicTLSExtensionType is the type of the TLS extension carrying the transport parameters
+const quicTLSExtensionType mint.ExtensionType = 26
+
+// tlsExtensionBody carries the transport parameters, encoded as a handshake message
+// The client sends a CHLO in the ClientHello, the server replies with a SHLO in the EncryptedExtensions.
+type tlsExtensionBody struct {
+	data []byte
+}
+
+var _ mint.ExtensionBody = &tlsExtensionBody{}
+
+func (e *tlsExtensionBody) Type() mint.ExtensionType {
+	return quicTLSExtensionType
+}
+
+func (e *tlsExtensionBody) Marshal() ([]byte, error) {
+	return e.data, nil
+}
+
+func (e *tlsExtensionBody) Unmarshal(data []byte) (int, error) {
+	e.data = data
+	return len(data), nil
+}
+
+// extensionHandler exchanges the transport parameters during the TLS handshake
+type extensionHandler struct {
+	perspective          protocol.Perspective
+	connectionParameters ConnectionParametersManager
+
+	params             *TransportParameters     // only needed for the client
+	negotiatedVersions []protocol.VersionNumber // only needed for the client, the versions offered by the server in the version negotiation packet
+	supportedVersions  []protocol.VersionNumber // only needed for the server
+
+	// mint replaces errors returned by the handler with an alert, so we keep them
+	err error
+}
+
+var _ mint.AppExtensionHandler = &extensionHandler{}
+
+func newExtensionHandlerClient(
+	connectionParameters ConnectionParametersManager,
+	params *TransportParameters,
+	negotiatedVersions []protocol.VersionNumber,
+) *extensionHandler {
+	return &extensionHandler{
+		perspective:          protocol.PerspectiveClient,
+		connectionParameters: connectionParameters,
+		params:               params,
+		negotiatedVersions:   negotiatedVersions,
+	}
+}
+
+func newExtensionHandlerServer(
+	connectionParameters ConnectionParametersManager,
+	supportedVersions []protocol.VersionNumber,
+) *extensionHandler {
+	return &extensionHandler{
+		perspective:          protocol.PerspectiveServer,
+		connectionParameters: connectionParameters,
+		supportedVersions:    supportedVersions,
+	}
+}
+
+// the client sends its parameters in the ClientHello, the server in the EncryptedExtensions
+func (h *extensionHandler) carriesParameters(hType mint.HandshakeType, sent bool) bool {
+	if (h.perspective == protocol.PerspectiveClient) == sent {
+		return hType == mint.HandshakeTypeClientHello
+	}
+	return hType == mint.HandshakeTypeEncryptedExtensions
+}
+
+func (h *extensionHandler) Send(hType mint.HandshakeType, el *mint.ExtensionList) error {
+	if !h.carriesParameters(hType, true) {
+		return nil
+	}
+
+	tags, err := h.connectionParameters.GetHelloMap()
+	if err != nil {
+		h.err = err
+		return err
+	}
+	msgTag := TagCHLO
+	if h.perspective == protocol.PerspectiveServer {
+		msgTag = TagSHLO
+		// prevent version downgrade attacks, the client checks this list against the version negotiation packet
+		verTag := &bytes.Buffer{}
+		for _, v := range h.supportedVersions {
+			utils.LittleEndian.WriteUint32(verTag, protocol.VersionNumberToTag(v))
+		}
+		tags[TagVER] = verTag.Bytes()
+	} else if h.params.RequestConnectionIDTruncation {
+		tags[TagTCID] = []byte{0, 0, 0, 0}
+	}
+
+	b := &bytes.Buffer{}
+	HandshakeMessage{Tag: msgTag, Data: tags}.Write(b)
+	return el.Add(&tlsExtensionBody{data: b.Bytes()})
+}
+
+func (h *extensionHandler) Receive(hType mint.HandshakeType, el *mint.ExtensionList) error {
+	if !h.carriesParameters(hType, false) {
+		return nil
+	}
+	h.err = h.receive(el)
+	return h.err
+}
+
+func (h *extensionHandler) receive(el *mint.ExtensionList) error {
+	ext := &tlsExtensionBody{}
+	if !el.Find(ext) {
+		return qerr.Error(qerr.CryptoMessageParameterNotFound, "transport parameters")
+	}
+	message, err := ParseHandshakeMessage(bytes.NewReader(ext.data))
+	if err != nil {
+		return qerr.Error(qerr.InvalidCryptoMessageParameter, err.Error())
+	}
+
+	if h.perspective == protocol.PerspectiveServer {
+		if message.Tag != TagCHLO {
+			return qerr.InvalidCryptoMessageType
+		}
+	} else {
+		if message.Tag != TagSHLO {
+			return qerr.InvalidCryptoMessageType
+		}
+		verTag, ok := message.Data[TagVER]
+		if !ok {
+			return qerr.Error(qerr.InvalidCryptoMessageParameter, "server hello missing version list")
+		}
+		if !validateVersionList(verTag, h.negotiatedVersions) {
+			return qerr.Error(qerr.VersionNegotiationMismatch, "Downgrade attack detected")
+		}
+	}
+	return h.connectionParameters.SetFromMap(message.Data)
+}
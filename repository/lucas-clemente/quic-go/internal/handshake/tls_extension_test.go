@@ -0,0 +1,88 @@
+package handshake
+
+import (
+	"bytes"
+
+	"github.com/yyleeshine/mpquic/repository/bifurcation/mint"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/qerr"
+
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+var _ = Describe("TLS extension handler", func() {
+	var (
+		client, server       *extensionHandler
+		cpmClient, cpmServer *connectionParametersManager
+		el                   mint.ExtensionList
+	)
+
+	newCPM := func(pers protocol.Perspective) *connectionParametersManager {
+		return NewConnectionParamatersManager(pers, protocol.VersionMPTLS, 0, 0, 0, 0, 0).(*connectionParametersManager)
+	}
+
+	writeMessage := func(tag Tag, data map[Tag][]byte) {
+		b := &bytes.Buffer{}
+		HandshakeMessage{Tag: tag, Data: data}.Write(b)
+		Expect(el.Add(&tlsExtensionBody{data: b.Bytes()})).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		el = mint.ExtensionList{}
+		cpmClient = newCPM(protocol.PerspectiveClient)
+		cpmServer = newCPM(protocol.PerspectiveServer)
+		client = newExtensionHandlerClient(cpmClient, &TransportParameters{}, nil)
+		server = newExtensionHandlerServer(cpmServer, protocol.SupportedVersions)
+	})
+
+	It("only sends the parameters in the ClientHello and the EncryptedExtensions", func() {
+		Expect(client.Send(mint.HandshakeTypeEncryptedExtensions, &el)).To(Succeed())
+		Expect(server.Send(mint.HandshakeTypeClientHello, &el)).To(Succeed())
+		Expect(el).To(BeEmpty())
+	})
+
+	It("exchanges the parameters", func() {
+		Expect(client.Send(mint.HandshakeTypeClientHello, &el)).To(Succeed())
+		Expect(server.Receive(mint.HandshakeTypeClientHello, &el)).To(Succeed())
+		el = mint.ExtensionList{}
+		Expect(server.Send(mint.HandshakeTypeEncryptedExtensions, &el)).To(Succeed())
+		Expect(client.Receive(mint.HandshakeTypeEncryptedExtensions, &el)).To(Succeed())
+		Expect(cpmServer.PeerSupportsMultipath()).To(BeTrue())
+		Expect(cpmServer.PeerSupportsUnreliableStreams()).To(BeTrue())
+		Expect(cpmClient.PeerSupportsMultipath()).To(BeTrue())
+		Expect(cpmClient.PeerSupportsUnreliableStreams()).To(BeTrue())
+	})
+
+	It("requests truncated connection IDs", func() {
+		client.params.RequestConnectionIDTruncation = true
+		Expect(client.Send(mint.HandshakeTypeClientHello, &el)).To(Succeed())
+		Expect(server.Receive(mint.HandshakeTypeClientHello, &el)).To(Succeed())
+		Expect(cpmServer.TruncateConnectionID()).To(BeTrue())
+	})
+
+	It("errors when the extension is missing", func() {
+		err := server.Receive(mint.HandshakeTypeClientHello, &el)
+		Expect(err).To(MatchError(qerr.Error(qerr.CryptoMessageParameterNotFound, "transport parameters")))
+		Expect(server.err).To(MatchError(err))
+	})
+
+	It("errors when the message has the wrong tag", func() {
+		writeMessage(TagSHLO, map[Tag][]byte{})
+		err := server.Receive(mint.HandshakeTypeClientHello, &el)
+		Expect(err).To(MatchError(qerr.InvalidCryptoMessageType))
+	})
+
+	It("errors when the server doesn't send its version list", func() {
+		writeMessage(TagSHLO, map[Tag][]byte{})
+		err := client.Receive(mint.HandshakeTypeEncryptedExtensions, &el)
+		Expect(err).To(MatchError(qerr.Error(qerr.InvalidCryptoMessageParameter, "server hello missing version list")))
+	})
+
+	It("detects version downgrade attacks", func() {
+		client.negotiatedVersions = []protocol.VersionNumber{protocol.VersionMPTLS}
+		Expect(server.Send(mint.HandshakeTypeEncryptedExtensions, &el)).To(Succeed())
+		err := client.Receive(mint.HandshakeTypeEncryptedExtensions, &el)
+		Expect(err).To(MatchError(qerr.Error(qerr.VersionNegotiationMismatch, "Downgrade attack detected")))
+	})
+})
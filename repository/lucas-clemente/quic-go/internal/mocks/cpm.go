@@ -155,6 +155,30 @@ func (_mr *MockConnectionParametersManagerMockRecorder) GetMaxIncomingStreams()
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMaxIncomingStreams")
 }
 
+// GetMaxOutgoingUniStreams mocks base method
+func (_m *MockConnectionParametersManager) GetMaxOutgoingUniStreams() uint32 {
+	ret := _m.ctrl.Call(_m, "GetMaxOutgoingUniStreams")
+	ret0, _ := ret[0].(uint32)
+	return ret0
+}
+
+// GetMaxOutgoingUniStreams indicates an expected call of GetMaxOutgoingUniStreams
+func (_mr *MockConnectionParametersManagerMockRecorder) GetMaxOutgoingUniStreams() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMaxOutgoingUniStreams")
+}
+
+// GetMaxIncomingUniStreams mocks base method
+func (_m *MockConnectionParametersManager) GetMaxIncomingUniStreams() uint32 {
+	ret := _m.ctrl.Call(_m, "GetMaxIncomingUniStreams")
+	ret0, _ := ret[0].(uint32)
+	return ret0
+}
+
+// GetMaxIncomingUniStreams indicates an expected call of GetMaxIncomingUniStreams
+func (_mr *MockConnectionParametersManagerMockRecorder) GetMaxIncomingUniStreams() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetMaxIncomingUniStreams")
+}
+
 // GetIdleConnectionStateLifetime mocks base method
 func (_m *MockConnectionParametersManager) GetIdleConnectionStateLifetime() time.Duration {
 	ret := _m.ctrl.Call(_m, "GetIdleConnectionStateLifetime")
@@ -167,6 +191,30 @@ func (_mr *MockConnectionParametersManagerMockRecorder) GetIdleConnectionStateLi
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetIdleConnectionStateLifetime")
 }
 
+// GetAckSendDelay mocks base method
+func (_m *MockConnectionParametersManager) GetAckSendDelay() time.Duration {
+	ret := _m.ctrl.Call(_m, "GetAckSendDelay")
+	ret0, _ := ret[0].(time.Duration)
+	return ret0
+}
+
+// GetAckSendDelay indicates an expected call of GetAckSendDelay
+func (_mr *MockConnectionParametersManagerMockRecorder) GetAckSendDelay() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetAckSendDelay")
+}
+
+// GetRetransmittablePacketsBeforeAck mocks base method
+func (_m *MockConnectionParametersManager) GetRetransmittablePacketsBeforeAck() int {
+	ret := _m.ctrl.Call(_m, "GetRetransmittablePacketsBeforeAck")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// GetRetransmittablePacketsBeforeAck indicates an expected call of GetRetransmittablePacketsBeforeAck
+func (_mr *MockConnectionParametersManagerMockRecorder) GetRetransmittablePacketsBeforeAck() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetRetransmittablePacketsBeforeAck")
+}
+
 // TruncateConnectionID mocks base method
 func (_m *MockConnectionParametersManager) TruncateConnectionID() bool {
 	ret := _m.ctrl.Call(_m, "TruncateConnectionID")
@@ -178,3 +226,51 @@ func (_m *MockConnectionParametersManager) TruncateConnectionID() bool {
 func (_mr *MockConnectionParametersManagerMockRecorder) TruncateConnectionID() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "TruncateConnectionID")
 }
+
+// PeerSupportsMultipath mocks base method
+func (_m *MockConnectionParametersManager) PeerSupportsMultipath() bool {
+	ret := _m.ctrl.Call(_m, "PeerSupportsMultipath")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// PeerSupportsMultipath indicates an expected call of PeerSupportsMultipath
+func (_mr *MockConnectionParametersManagerMockRecorder) PeerSupportsMultipath() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "PeerSupportsMultipath")
+}
+
+// PeerSupportsUnreliableStreams mocks base method
+func (_m *MockConnectionParametersManager) PeerSupportsUnreliableStreams() bool {
+	ret := _m.ctrl.Call(_m, "PeerSupportsUnreliableStreams")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// PeerSupportsUnreliableStreams indicates an expected call of PeerSupportsUnreliableStreams
+func (_mr *MockConnectionParametersManagerMockRecorder) PeerSupportsUnreliableStreams() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "PeerSupportsUnreliableStreams")
+}
+
+// PeerAcceptsAcksOnOtherPaths mocks base method
+func (_m *MockConnectionParametersManager) PeerAcceptsAcksOnOtherPaths() bool {
+	ret := _m.ctrl.Call(_m, "PeerAcceptsAcksOnOtherPaths")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// PeerAcceptsAcksOnOtherPaths indicates an expected call of PeerAcceptsAcksOnOtherPaths
+func (_mr *MockConnectionParametersManagerMockRecorder) PeerAcceptsAcksOnOtherPaths() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "PeerAcceptsAcksOnOtherPaths")
+}
+
+// PeerSupportsStopSending mocks base method
+func (_m *MockConnectionParametersManager) PeerSupportsStopSending() bool {
+	ret := _m.ctrl.Call(_m, "PeerSupportsStopSending")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// PeerSupportsStopSending indicates an expected call of PeerSupportsStopSending
+func (_mr *MockConnectionParametersManagerMockRecorder) PeerSupportsStopSending() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "PeerSupportsStopSending")
+}
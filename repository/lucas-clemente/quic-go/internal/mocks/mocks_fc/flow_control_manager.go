@@ -42,6 +42,26 @@ func (_mr *MockFlowControlManagerMockRecorder) NewStream(arg0, arg1 interface{})
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "NewStream", arg0, arg1)
 }
 
+// NewSendOnlyStream mocks base method
+func (_m *MockFlowControlManager) NewSendOnlyStream(streamID protocol.StreamID) {
+	_m.ctrl.Call(_m, "NewSendOnlyStream", streamID)
+}
+
+// NewSendOnlyStream indicates an expected call of NewSendOnlyStream
+func (_mr *MockFlowControlManagerMockRecorder) NewSendOnlyStream(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "NewSendOnlyStream", arg0)
+}
+
+// NewReceiveOnlyStream mocks base method
+func (_m *MockFlowControlManager) NewReceiveOnlyStream(streamID protocol.StreamID) {
+	_m.ctrl.Call(_m, "NewReceiveOnlyStream", streamID)
+}
+
+// NewReceiveOnlyStream indicates an expected call of NewReceiveOnlyStream
+func (_mr *MockFlowControlManagerMockRecorder) NewReceiveOnlyStream(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "NewReceiveOnlyStream", arg0)
+}
+
 // RemoveStream mocks base method
 func (_m *MockFlowControlManager) RemoveStream(streamID protocol.StreamID) {
 	_m.ctrl.Call(_m, "RemoveStream", streamID)
@@ -200,3 +220,26 @@ func (_m *MockFlowControlManager) GetBytesRetrans(streamID protocol.StreamID) (p
 func (_mr *MockFlowControlManagerMockRecorder) GetBytesRetrans(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetBytesRetrans", arg0)
 }
+
+// GetWindowStatistics mocks base method
+func (_m *MockFlowControlManager) GetWindowStatistics(streamID protocol.StreamID) (flowcontrol.WindowStatistics, error) {
+	ret := _m.ctrl.Call(_m, "GetWindowStatistics", streamID)
+	ret0, _ := ret[0].(flowcontrol.WindowStatistics)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWindowStatistics indicates an expected call of GetWindowStatistics
+func (_mr *MockFlowControlManagerMockRecorder) GetWindowStatistics(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetWindowStatistics", arg0)
+}
+
+// UpdateBandwidthDelayProduct mocks base method
+func (_m *MockFlowControlManager) UpdateBandwidthDelayProduct(bdp protocol.ByteCount) {
+	_m.ctrl.Call(_m, "UpdateBandwidthDelayProduct", bdp)
+}
+
+// UpdateBandwidthDelayProduct indicates an expected call of UpdateBandwidthDelayProduct
+func (_mr *MockFlowControlManagerMockRecorder) UpdateBandwidthDelayProduct(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "UpdateBandwidthDelayProduct", arg0)
+}
@@ -27,12 +27,36 @@ type ConnectionID uint64
 // A StreamID in QUIC
 type StreamID uint32
 
+// UniStreamIDOffset is the first StreamID of the unidirectional streams, they have their own ID space above it.
+// As for bidirectional streams, the client opens the odd and the server the even StreamIDs.
+const UniStreamIDOffset StreamID = 1 << 30
+
+// IsUniStream returns true if the StreamID belongs to a unidirectional stream
+func (s StreamID) IsUniStream() bool {
+	return s > UniStreamIDOffset
+}
+
 // A PathID in QUIC
 type PathID uint8
 
 // Initial PathID
 const InitialPathID = 0
 
+// KeyPhaseBit is the key phase of the forward-secure keys, sent in the public header
+type KeyPhaseBit uint8
+
+const (
+	// KeyPhaseZero is key phase 0
+	KeyPhaseZero KeyPhaseBit = 0
+	// KeyPhaseOne is key phase 1
+	KeyPhaseOne KeyPhaseBit = 1
+)
+
+// Next returns the key phase that follows this key phase
+func (p KeyPhaseBit) Next() KeyPhaseBit {
+	return 1 - p
+}
+
 // A ByteCount in QUIC
 type ByteCount uint64
 
@@ -63,3 +87,9 @@ const ClientHelloMinimumSize = 1024
 // * one failure due to an incorrect or missing source-address token
 // * one failure due the server's certificate chain being unavailible and the server being unwilling to send it without a valid source-address token
 const MaxClientHellos = 3
+
+// DefaultStreamUrgency is the urgency of a stream whose priority was not set
+const DefaultStreamUrgency uint8 = 3
+
+// MaxStreamUrgency is the lowest urgency of a stream
+const MaxStreamUrgency uint8 = 7
@@ -30,6 +30,9 @@ const PublicResetTimeout = 500 * time.Millisecond
 // This is the value Chromium is using
 const AckSendDelay = 25 * time.Millisecond
 
+// MaxAckSendDelay is the maximum ACK delay that a peer can request
+const MaxAckSendDelay = 100 * time.Millisecond
+
 // ReceiveStreamFlowControlWindow is the stream-level flow control window for receiving data
 // This is the value that Google servers are using
 const ReceiveStreamFlowControlWindow = (1 << 10) * 10000 // 32 kB
@@ -38,6 +41,15 @@ const ReceiveStreamFlowControlWindow = (1 << 10) * 10000 // 32 kB
 // This is the value that Google servers are using
 const ReceiveConnectionFlowControlWindow = (1 << 10) * 100000 // 48 kB
 
+// DefaultHandshakeCacheSize is the number of servers the default handshake cache of the client keeps entries for
+const DefaultHandshakeCacheSize = 100
+
+// DefaultHandshakeCacheTTL is the time the default handshake cache of the client keeps an entry
+const DefaultHandshakeCacheTTL = 24 * time.Hour
+
+// DefaultUnreliableSendQueueSize is the default size of the send queue of unreliable streams, if a drop policy is used
+const DefaultUnreliableSendQueueSize = 256 * (1 << 10) // 256 kB
+
 // DefaultMaxReceiveStreamFlowControlWindowServer is the default maximum stream-level flow control window for receiving data, for the server
 // This is the value that Google servers are using
 const DefaultMaxReceiveStreamFlowControlWindowServer = 1 * (1 << 20) // 1 MB
@@ -54,6 +66,16 @@ const DefaultMaxReceiveStreamFlowControlWindowClient = 16 * (1 << 20) // 16 MB
 // This is the value that Google servers are using
 const DefaultMaxReceiveConnectionFlowControlWindowClient = 24 * (1 << 20) // 24 MB
 
+// MaxAutoTunedReceiveStreamFlowControlWindow is the maximum stream-level flow control window that the auto-tuning can reach
+// when the bandwidth-delay product of the paths requires a window larger than the default maximum.
+// It is not used if the maximum window is configured.
+const MaxAutoTunedReceiveStreamFlowControlWindow = 64 * (1 << 20) // 64 MB
+
+// MaxAutoTunedReceiveConnectionFlowControlWindow is the maximum connection-level flow control window that the auto-tuning can reach
+// when the bandwidth-delay product of the paths requires a window larger than the default maximum.
+// It is not used if the maximum window is configured.
+const MaxAutoTunedReceiveConnectionFlowControlWindow = 96 * (1 << 20) // 96 MB
+
 // ConnectionFlowControlMultiplier determines how much larger the connection flow control windows needs to be relative to any stream's flow control window
 // This is the value that Chromium is using
 const ConnectionFlowControlMultiplier = 1.5
@@ -64,6 +86,9 @@ const MaxStreamsPerConnection = 100
 // MaxIncomingDynamicStreamsPerConnection is the maximum value accepted for the incoming number of dynamic streams per connection
 const MaxIncomingDynamicStreamsPerConnection = 100
 
+// MaxIncomingUniStreamsPerConnection is the maximum number of unidirectional streams the peer can open
+const MaxIncomingUniStreamsPerConnection = 100
+
 // MaxStreamsMultiplier is the slack the client is allowed for the maximum number of streams per connection, needed e.g. when packets are out of order or dropped. The minimum of this procentual increase and the absolute increment specified by MaxStreamsMinimumIncrement is used.
 const MaxStreamsMultiplier = 1.1
 
@@ -86,6 +111,19 @@ const MaxTrackedSkippedPackets = 10
 // CookieExpiryTime is the valid time of a cookie
 const CookieExpiryTime = 24 * time.Hour
 
+// DefaultZeroRTTReplayWindow is the default time the server remembers the client nonces of 0-RTT handshakes
+const DefaultZeroRTTReplayWindow = 10 * time.Second
+
+// MaxStrikeRegisterEntries is the maximum number of client nonces the server remembers to detect replayed 0-RTT handshakes
+// When it is full, 0-RTT handshakes are rejected.
+const MaxStrikeRegisterEntries = 1 << 16
+
+// KeyUpdatePacketInterval is the number of packets sealed with the same forward-secure keys, on all paths, after which the keys are updated
+const KeyUpdatePacketInterval = 1 << 20
+
+// KeyUpdateInterval is the time after which the forward-secure keys are updated
+const KeyUpdateInterval = time.Hour
+
 // MaxTrackedSentPackets is maximum number of sent packets saved for either later retransmission or entropy calculation
 const MaxTrackedSentPackets = 2 * DefaultMaxCongestionWindow
 
@@ -101,6 +139,9 @@ const MaxNonRetransmittablePackets = 19
 // RetransmittablePacketsBeforeAck is the number of retransmittable that an ACK is sent for
 const RetransmittablePacketsBeforeAck = 2
 
+// MaxRetransmittablePacketsBeforeAck is the maximum number of retransmittable packets before an ACK that a peer can request
+const MaxRetransmittablePacketsBeforeAck = 20
+
 // MaxStreamFrameSorterGaps is the maximum number of gaps between received StreamFrames
 // prevents DoS attacks against the streamFrameSorter
 // XXX (QDC): needs to be compliant with the maximal congestion window
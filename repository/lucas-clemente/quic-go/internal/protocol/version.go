@@ -15,11 +15,16 @@ const (
 	VersionUnsupported VersionNumber = -1
 	VersionUnknown     VersionNumber = -2
 	VersionMP          VersionNumber = 512
+	VersionMPTLS       VersionNumber = 513 // multipath, using TLS 1.3 for the handshake
+	VersionMP2         VersionNumber = 514 // multipath, updating the forward-secure keys and using the path ID in the nonce
 )
 
 // SupportedVersions lists the versions that the server supports
 // must be in sorted descending order
+// The TLS versions are not included, they have to be enabled explicitly in the quic.Config
+// Peers that don't support VersionMP2 yet negotiate VersionMP.
 var SupportedVersions = []VersionNumber{
+	VersionMP2,
 	VersionMP,
 	Version39,
 	Version38,
@@ -28,7 +33,19 @@ var SupportedVersions = []VersionNumber{
 
 // UsesTLS says if this QUIC version uses TLS 1.3 for the handshake
 func (vn VersionNumber) UsesTLS() bool {
-	return vn == VersionTLS
+	return vn == VersionTLS || vn == VersionMPTLS
+}
+
+// UsesKeyUpdates says if the forward-secure keys are updated regularly.
+// The key phase is then sent in the public header.
+func (vn VersionNumber) UsesKeyUpdates() bool {
+	return vn == VersionMP2 || vn == VersionMPTLS
+}
+
+// UsesPathIDInNonce says if the path ID is used to compute the nonce of the AEAD.
+// Older versions only use the packet number, so packets with the same packet number on different paths use the same nonce.
+func (vn VersionNumber) UsesPathIDInNonce() bool {
+	return vn == VersionMP2 || vn == VersionMPTLS
 }
 
 func (vn VersionNumber) String() string {
@@ -57,6 +74,11 @@ func VersionTagToNumber(v uint32) VersionNumber {
 	return VersionNumber(((v>>8)&0xff-'0')*100 + ((v>>16)&0xff-'0')*10 + ((v>>24)&0xff - '0'))
 }
 
+// IsValidVersion says if the version is known to quic-go
+func IsValidVersion(v VersionNumber) bool {
+	return v == VersionTLS || v == VersionMPTLS || IsSupportedVersion(SupportedVersions, v)
+}
+
 // IsSupportedVersion returns true if the server supports this version
 func IsSupportedVersion(supported []VersionNumber, v VersionNumber) bool {
 	for _, t := range supported {
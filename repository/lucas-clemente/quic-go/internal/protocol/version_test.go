@@ -11,6 +11,24 @@ var _ = Describe("Version", func() {
 		Expect(Version38.UsesTLS()).To(BeFalse())
 		Expect(Version39.UsesTLS()).To(BeFalse())
 		Expect(VersionTLS.UsesTLS()).To(BeTrue())
+		Expect(VersionMP.UsesTLS()).To(BeFalse())
+		Expect(VersionMPTLS.UsesTLS()).To(BeTrue())
+	})
+
+	It("says if a version updates the keys", func() {
+		Expect(Version39.UsesKeyUpdates()).To(BeFalse())
+		Expect(VersionTLS.UsesKeyUpdates()).To(BeFalse())
+		Expect(VersionMP.UsesKeyUpdates()).To(BeFalse())
+		Expect(VersionMP2.UsesKeyUpdates()).To(BeTrue())
+		Expect(VersionMPTLS.UsesKeyUpdates()).To(BeTrue())
+	})
+
+	It("says if a version uses the path ID in the nonce", func() {
+		Expect(Version39.UsesPathIDInNonce()).To(BeFalse())
+		Expect(VersionTLS.UsesPathIDInNonce()).To(BeFalse())
+		Expect(VersionMP.UsesPathIDInNonce()).To(BeFalse())
+		Expect(VersionMP2.UsesPathIDInNonce()).To(BeTrue())
+		Expect(VersionMPTLS.UsesPathIDInNonce()).To(BeTrue())
 	})
 
 	It("has the right string representation", func() {
@@ -37,6 +55,25 @@ var _ = Describe("Version", func() {
 		Expect(IsSupportedVersion(SupportedVersions, SupportedVersions[len(SupportedVersions)-1])).To(BeTrue())
 	})
 
+	It("recognizes valid versions", func() {
+		Expect(IsValidVersion(VersionMP)).To(BeTrue())
+		Expect(IsValidVersion(Version37)).To(BeTrue())
+		Expect(IsValidVersion(VersionTLS)).To(BeTrue())
+		Expect(IsValidVersion(VersionMPTLS)).To(BeTrue())
+		Expect(IsValidVersion(VersionMP2)).To(BeTrue())
+		Expect(IsValidVersion(0x1337)).To(BeFalse())
+	})
+
+	It("prefers VersionMP2, and still supports VersionMP for older peers", func() {
+		Expect(SupportedVersions[0]).To(Equal(VersionMP2))
+		Expect(IsSupportedVersion(SupportedVersions, VersionMP)).To(BeTrue())
+	})
+
+	It("doesn't enable the TLS versions by default", func() {
+		Expect(IsSupportedVersion(SupportedVersions, VersionMPTLS)).To(BeFalse())
+		Expect(IsSupportedVersion(SupportedVersions, VersionTLS)).To(BeFalse())
+	})
+
 	It("has supported versions in sorted order", func() {
 		for i := 0; i < len(SupportedVersions)-1; i++ {
 			Expect(SupportedVersions[i]).To(BeNumerically(">", SupportedVersions[i+1]))
@@ -10,17 +10,21 @@ import (
 )
 
 // A GoawayFrame is a GOAWAY frame
+// If LastGoodUniStream is set, it is written with the type byte 0x14, and also carries the last unidirectional stream accepted.
+// This is not part of gQUIC, a gQUIC GOAWAY means that no unidirectional stream was accepted.
 type GoawayFrame struct {
-	ErrorCode      qerr.ErrorCode
-	LastGoodStream protocol.StreamID
-	ReasonPhrase   string
+	ErrorCode         qerr.ErrorCode
+	LastGoodStream    protocol.StreamID
+	LastGoodUniStream protocol.StreamID
+	ReasonPhrase      string
 }
 
 // ParseGoawayFrame parses a GOAWAY frame
 func ParseGoawayFrame(r *bytes.Reader, version protocol.VersionNumber) (*GoawayFrame, error) {
 	frame := &GoawayFrame{}
 
-	if _, err := r.ReadByte(); err != nil {
+	typeByte, err := r.ReadByte()
+	if err != nil {
 		return nil, err
 	}
 
@@ -36,6 +40,14 @@ func ParseGoawayFrame(r *bytes.Reader, version protocol.VersionNumber) (*GoawayF
 	}
 	frame.LastGoodStream = protocol.StreamID(lastGoodStream)
 
+	if typeByte == 0x14 {
+		lastGoodUniStream, err := utils.GetByteOrder(version).ReadUint32(r)
+		if err != nil {
+			return nil, err
+		}
+		frame.LastGoodUniStream = protocol.StreamID(lastGoodUniStream)
+	}
+
 	reasonPhraseLen, err := utils.GetByteOrder(version).ReadUint16(r)
 	if err != nil {
 		return nil, err
@@ -54,9 +66,16 @@ func ParseGoawayFrame(r *bytes.Reader, version protocol.VersionNumber) (*GoawayF
 }
 
 func (f *GoawayFrame) Write(b *bytes.Buffer, version protocol.VersionNumber) error {
-	b.WriteByte(0x03)
+	if f.LastGoodUniStream == 0 {
+		b.WriteByte(0x03)
+	} else {
+		b.WriteByte(0x14)
+	}
 	utils.GetByteOrder(version).WriteUint32(b, uint32(f.ErrorCode))
 	utils.GetByteOrder(version).WriteUint32(b, uint32(f.LastGoodStream))
+	if f.LastGoodUniStream != 0 {
+		utils.GetByteOrder(version).WriteUint32(b, uint32(f.LastGoodUniStream))
+	}
 	utils.GetByteOrder(version).WriteUint16(b, uint16(len(f.ReasonPhrase)))
 	b.WriteString(f.ReasonPhrase)
 	return nil
@@ -64,5 +83,9 @@ func (f *GoawayFrame) Write(b *bytes.Buffer, version protocol.VersionNumber) err
 
 // MinLength of a written frame
 func (f *GoawayFrame) MinLength(version protocol.VersionNumber) (protocol.ByteCount, error) {
-	return protocol.ByteCount(1 + 4 + 4 + 2 + len(f.ReasonPhrase)), nil
+	length := protocol.ByteCount(1 + 4 + 4 + 2 + len(f.ReasonPhrase))
+	if f.LastGoodUniStream != 0 {
+		length += 4
+	}
+	return length, nil
 }
@@ -80,6 +80,25 @@ var _ = Describe("GoawayFrame", func() {
 			})
 		})
 
+		It("accepts a frame with the last good unidirectional stream", func() {
+			b := bytes.NewReader([]byte{0x14,
+				0x37, 0x13, 0x0, 0x0, // error code
+				0x34, 0x12, 0x0, 0x0, // last good stream id
+				0x1, 0x0, 0x0, 0x40, // last good unidirectional stream id
+				0x3, 0x0, // reason phrase length
+				'f', 'o', 'o',
+			})
+			frame, err := ParseGoawayFrame(b, versionLittleEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&GoawayFrame{
+				ErrorCode:         0x1337,
+				LastGoodStream:    0x1234,
+				LastGoodUniStream: 0x40000001,
+				ReasonPhrase:      "foo",
+			}))
+			Expect(b.Len()).To(BeZero())
+		})
+
 		It("rejects long reason phrases", func() {
 			b := bytes.NewReader([]byte{0x3,
 				0x1, 0x0, 0x0, 0x0, // error code
@@ -130,6 +149,26 @@ var _ = Describe("GoawayFrame", func() {
 			})
 		})
 
+		It("writes a frame with the last good unidirectional stream", func() {
+			b := &bytes.Buffer{}
+			frame := GoawayFrame{
+				ErrorCode:         0x1337,
+				LastGoodStream:    2,
+				LastGoodUniStream: 0x40000001,
+				ReasonPhrase:      "foo",
+			}
+			err := frame.Write(b, versionLittleEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b.Bytes()).To(Equal([]byte{0x14,
+				0x37, 0x13, 0x0, 0x0, // error code
+				0x2, 0x0, 0x0, 0x0, // last good stream
+				0x1, 0x0, 0x0, 0x40, // last good unidirectional stream
+				0x3, 0x0, // reason phrase length
+				'f', 'o', 'o',
+			}))
+			Expect(frame.MinLength(versionLittleEndian)).To(Equal(protocol.ByteCount(b.Len())))
+		})
+
 		It("has the correct min length", func() {
 			frame := GoawayFrame{
 				ReasonPhrase: "foo",
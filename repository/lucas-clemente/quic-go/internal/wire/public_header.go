@@ -29,6 +29,7 @@ type PublicHeader struct {
 	ResetFlag            bool
 	TruncateConnectionID bool
 	MultipathFlag        bool
+	KeyPhase             protocol.KeyPhaseBit // only used by versions that update the keys
 	PacketNumberLen      protocol.PacketNumberLen
 	PacketNumber         protocol.PacketNumber
 	VersionNumber        protocol.VersionNumber   // VersionNumber sent by the client
@@ -78,6 +79,9 @@ func (h *PublicHeader) Write(b *bytes.Buffer, version protocol.VersionNumber, pe
 	if h.MultipathFlag {
 		publicFlagByte |= 0x40
 	}
+	if h.KeyPhase == protocol.KeyPhaseOne {
+		publicFlagByte |= 0x80
+	}
 
 	b.WriteByte(publicFlagByte)
 
@@ -190,6 +194,9 @@ func ParsePublicHeader(b *bytes.Reader, packetSentBy protocol.Perspective, versi
 	}
 
 	header.MultipathFlag = publicFlagByte&0x40 > 0
+	if publicFlagByte&0x80 > 0 {
+		header.KeyPhase = protocol.KeyPhaseOne
+	}
 
 	// Connection ID
 	if !header.TruncateConnectionID {
@@ -113,6 +113,15 @@ var _ = Describe("Public Header", func() {
 			Expect(b.Len()).To(BeZero())
 		})
 
+		It("reads the key phase", func() {
+			b := bytes.NewReader([]byte{0x80, 0x37})
+			hdr, err := ParsePublicHeader(b, protocol.PerspectiveServer, protocol.VersionMP2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hdr.KeyPhase).To(Equal(protocol.KeyPhaseOne))
+			Expect(hdr.PacketNumber).To(Equal(protocol.PacketNumber(0x37)))
+			Expect(b.Len()).To(BeZero())
+		})
+
 		It("returns an unknown version error when receiving a packet without a version for which the version is not given", func() {
 			b := bytes.NewReader([]byte{0x10, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0xef})
 			_, err := ParsePublicHeader(b, protocol.PerspectiveServer, protocol.VersionUnknown)
@@ -333,6 +342,20 @@ var _ = Describe("Public Header", func() {
 			}))
 		})
 
+		It("writes the key phase", func() {
+			b := &bytes.Buffer{}
+			hdr := PublicHeader{
+				ConnectionID:         0x4cfa9f9b668619f6,
+				TruncateConnectionID: true,
+				KeyPhase:             protocol.KeyPhaseOne,
+				PacketNumberLen:      protocol.PacketNumberLen1,
+				PacketNumber:         1,
+			}
+			err := hdr.Write(b, protocol.VersionMP2, protocol.PerspectiveServer)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b.Bytes()).To(Equal([]byte{0x80, 0x01}))
+		})
+
 		It("throws an error if both Reset Flag and Version Flag are set", func() {
 			b := &bytes.Buffer{}
 			hdr := PublicHeader{
@@ -0,0 +1,50 @@
+package wire
+
+import (
+	"bytes"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/utils"
+)
+
+// A StopSendingFrame asks the peer to stop sending data on a stream
+// It is not part of gQUIC, it uses the same semantics as the STOP_SENDING frame of IETF QUIC
+type StopSendingFrame struct {
+	StreamID  protocol.StreamID
+	ErrorCode uint32
+}
+
+// Write writes a STOP_SENDING frame
+func (f *StopSendingFrame) Write(b *bytes.Buffer, version protocol.VersionNumber) error {
+	b.WriteByte(0x13)
+	utils.GetByteOrder(version).WriteUint32(b, uint32(f.StreamID))
+	utils.GetByteOrder(version).WriteUint32(b, f.ErrorCode)
+	return nil
+}
+
+// MinLength of a written frame
+func (f *StopSendingFrame) MinLength(version protocol.VersionNumber) (protocol.ByteCount, error) {
+	return 1 + 4 + 4, nil
+}
+
+// ParseStopSendingFrame parses a STOP_SENDING frame
+func ParseStopSendingFrame(r *bytes.Reader, version protocol.VersionNumber) (*StopSendingFrame, error) {
+	frame := &StopSendingFrame{}
+
+	// read the TypeByte
+	if _, err := r.ReadByte(); err != nil {
+		return nil, err
+	}
+
+	sid, err := utils.GetByteOrder(version).ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	frame.StreamID = protocol.StreamID(sid)
+
+	frame.ErrorCode, err = utils.GetByteOrder(version).ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
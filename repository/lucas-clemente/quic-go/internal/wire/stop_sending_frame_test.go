@@ -0,0 +1,85 @@
+package wire
+
+import (
+	"bytes"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+var _ = Describe("StopSendingFrame", func() {
+	Context("when parsing", func() {
+		Context("in little endian", func() {
+			It("accepts sample frame", func() {
+				b := bytes.NewReader([]byte{0x13,
+					0xef, 0xbe, 0xad, 0xde, // stream id
+					0x34, 0x12, 0x37, 0x13, // error code
+				})
+				frame, err := ParseStopSendingFrame(b, versionLittleEndian)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(frame.StreamID).To(Equal(protocol.StreamID(0xdeadbeef)))
+				Expect(frame.ErrorCode).To(Equal(uint32(0x13371234)))
+				Expect(b.Len()).To(BeZero())
+			})
+		})
+
+		Context("in big endian", func() {
+			It("accepts sample frame", func() {
+				b := bytes.NewReader([]byte{0x13,
+					0xde, 0xad, 0xbe, 0xef, // stream id
+					0x34, 0x12, 0x37, 0x13, // error code
+				})
+				frame, err := ParseStopSendingFrame(b, versionBigEndian)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(frame.StreamID).To(Equal(protocol.StreamID(0xdeadbeef)))
+				Expect(frame.ErrorCode).To(Equal(uint32(0x34123713)))
+				Expect(b.Len()).To(BeZero())
+			})
+		})
+
+		It("errors on EOFs", func() {
+			data := []byte{0x13,
+				0xef, 0xbe, 0xad, 0xde, // stream id
+				0x34, 0x12, 0x37, 0x13, // error code
+			}
+			_, err := ParseStopSendingFrame(bytes.NewReader(data), protocol.VersionWhatever)
+			Expect(err).NotTo(HaveOccurred())
+			for i := range data {
+				_, err := ParseStopSendingFrame(bytes.NewReader(data[0:i]), protocol.VersionWhatever)
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
+
+	Context("when writing", func() {
+		It("writes a sample frame", func() {
+			frame := StopSendingFrame{
+				StreamID:  0x1337,
+				ErrorCode: 0xdeadbeef,
+			}
+			b := &bytes.Buffer{}
+			err := frame.Write(b, versionBigEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b.Bytes()).To(Equal([]byte{0x13,
+				0x0, 0x0, 0x13, 0x37, // stream id
+				0xde, 0xad, 0xbe, 0xef, // error code
+			}))
+		})
+
+		It("has the correct min length", func() {
+			frame := StopSendingFrame{StreamID: 0x1337, ErrorCode: 0xde}
+			Expect(frame.MinLength(0)).To(Equal(protocol.ByteCount(9)))
+		})
+
+		It("writes a frame that can be parsed", func() {
+			frame := &StopSendingFrame{StreamID: 0x1337, ErrorCode: 42}
+			b := &bytes.Buffer{}
+			err := frame.Write(b, versionLittleEndian)
+			Expect(err).ToNot(HaveOccurred())
+			parsed, err := ParseStopSendingFrame(bytes.NewReader(b.Bytes()), versionLittleEndian)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(parsed).To(Equal(frame))
+		})
+	})
+})
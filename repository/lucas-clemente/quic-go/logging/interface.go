@@ -0,0 +1,118 @@
+// Package logging defines a tracer interface for the events of a QUIC connection and its paths.
+// The qlog package provides an implementation that writes the events to a qlog file.
+package logging
+
+import (
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/congestion"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/wire"
+)
+
+type (
+	// The PathID is the ID of a path of a multipath QUIC connection.
+	PathID = protocol.PathID
+	// The ConnectionID is the ID of a QUIC connection.
+	ConnectionID = protocol.ConnectionID
+	// The PacketNumber is the packet number of a packet, counted per path.
+	PacketNumber = protocol.PacketNumber
+	// A ByteCount is a number of bytes.
+	ByteCount = protocol.ByteCount
+	// The StreamID is the ID of a QUIC stream.
+	StreamID = protocol.StreamID
+	// A VersionNumber is a QUIC version number.
+	VersionNumber = protocol.VersionNumber
+	// The EncryptionLevel is the encryption level of a packet.
+	EncryptionLevel = protocol.EncryptionLevel
+	// The Perspective determines if we're acting as a server or a client.
+	Perspective = protocol.Perspective
+	// RTTStats are the RTT estimates of a path.
+	RTTStats = congestion.RTTStats
+)
+
+type (
+	// A Frame is a QUIC frame.
+	Frame = wire.Frame
+	// An AckFrame is an ACK frame.
+	AckFrame = wire.AckFrame
+	// An AckRange is a range of packet numbers acked by an ACK or a CLOSE_PATH frame.
+	AckRange = wire.AckRange
+	// An AddAddressFrame is an ADD_ADDRESS frame.
+	AddAddressFrame = wire.AddAddressFrame
+	// A BlockedFrame is a BLOCKED frame.
+	BlockedFrame = wire.BlockedFrame
+	// A ClosePathFrame is a CLOSE_PATH frame.
+	ClosePathFrame = wire.ClosePathFrame
+	// A ConnectionCloseFrame is a CONNECTION_CLOSE frame.
+	ConnectionCloseFrame = wire.ConnectionCloseFrame
+	// A GoawayFrame is a GOAWAY frame.
+	GoawayFrame = wire.GoawayFrame
+	// A PathsFrame is a PATHS frame.
+	PathsFrame = wire.PathsFrame
+	// A PingFrame is a PING frame.
+	PingFrame = wire.PingFrame
+	// A RstStreamFrame is a RST_STREAM frame.
+	RstStreamFrame = wire.RstStreamFrame
+	// A StopWaitingFrame is a STOP_WAITING frame.
+	StopWaitingFrame = wire.StopWaitingFrame
+	// A StreamFrame is a STREAM frame.
+	StreamFrame = wire.StreamFrame
+	// A WindowUpdateFrame is a WINDOW_UPDATE frame.
+	WindowUpdateFrame = wire.WindowUpdateFrame
+)
+
+const (
+	// PerspectiveServer is used for a QUIC server
+	PerspectiveServer = protocol.PerspectiveServer
+	// PerspectiveClient is used for a QUIC client
+	PerspectiveClient = protocol.PerspectiveClient
+)
+
+// A Tracer creates the tracers for the connections.
+type Tracer interface {
+	// TracerForConnection is called for every new connection.
+	// It may return nil, if the connection should not be traced.
+	TracerForConnection(p Perspective, connID ConnectionID) ConnectionTracer
+}
+
+// A ConnectionTracer records the events of a connection.
+// Its methods are called from different goroutines, so it must be safe for concurrent use.
+// Every event on a path carries the ID of this path.
+type ConnectionTracer interface {
+	// StartedConnection is called when the session is created.
+	StartedConnection(version VersionNumber)
+	// CompletedHandshake is called when the handshake is complete, and the connection is forward-secure.
+	CompletedHandshake()
+	// ClosedConnection is called when the session is closed, with the error that closed it.
+	ClosedConnection(err error)
+
+	// SentPacket is called when a packet is sent on a path.
+	// The frames may be ACKs of other paths.
+	SentPacket(pathID PathID, packetNumber PacketNumber, size ByteCount, encLevel EncryptionLevel, frames []Frame)
+	// ReceivedPacket is called when a packet was received and decrypted on a path.
+	ReceivedPacket(pathID PathID, packetNumber PacketNumber, size ByteCount, encLevel EncryptionLevel, frames []Frame)
+	// DroppedPacket is called when a packet received on a path is dropped before it is processed.
+	DroppedPacket(pathID PathID, size ByteCount, reason PacketDropReason)
+	// LostPacket is called when a packet sent on a path is declared lost.
+	LostPacket(pathID PathID, packetNumber PacketNumber, reason PacketLossReason)
+	// UpdatedMetrics is called when the RTT estimates or the congestion window of a path change.
+	UpdatedMetrics(pathID PathID, rttStats *RTTStats, cwnd, bytesInFlight ByteCount)
+	// UpdatedRTT is called for every RTT sample of a path, even if the sample didn't change the RTT estimates.
+	UpdatedRTT(pathID PathID, rttStats *RTTStats)
+	// UpdatedPathState is called when a path is opened or closed, or its state changes.
+	UpdatedPathState(pathID PathID, state PathState)
+
+	// SelectedPath is called when the scheduler sent a packet on the path it chose.
+	// It is only called if a packet is sent, after the SentPacket call of that packet.
+	SelectedPath(pathID PathID, reason SchedulingReason)
+	// ReinjectedPackets is called when the data in flight on a stalled path is queued to be sent on the other paths.
+	ReinjectedPackets(pathID PathID, count int)
+	// DuplicatedPacket is called when a packet sent on a path with an unknown RTT is queued to be sent again on another path.
+	DuplicatedPacket(pathID PathID, packetNumber PacketNumber)
+
+	// FilledStreamGap is called when a gap in the data of an unreliable stream is filled with zeros,
+	// because the data didn't arrive in time. Streams are not bound to a path, it is recorded on the initial path.
+	FilledStreamGap(streamID StreamID, offset, length ByteCount)
+
+	// Close is called when no more events will be recorded.
+	Close()
+}
@@ -0,0 +1,97 @@
+package logging
+
+// A PacketLossReason is the reason why a packet was declared lost
+type PacketLossReason uint8
+
+const (
+	// PacketLossTimeThreshold is used when the packet was not acked within the reordering window after a packet sent later was acked
+	PacketLossTimeThreshold PacketLossReason = iota
+	// PacketLossRetransmissionTimeout is used when the packet was declared lost by an RTO
+	PacketLossRetransmissionTimeout
+	// PacketLossPathClosed is used when the packet was in flight on a path that was closed
+	PacketLossPathClosed
+)
+
+func (r PacketLossReason) String() string {
+	switch r {
+	case PacketLossTimeThreshold:
+		return "time_threshold"
+	case PacketLossRetransmissionTimeout:
+		return "retransmission_timeout"
+	case PacketLossPathClosed:
+		return "path_closed"
+	}
+	return "unknown"
+}
+
+// A PacketDropReason is the reason why a received packet was dropped
+type PacketDropReason uint8
+
+const (
+	// PacketDropQueueFull is used when the queue of packets waiting to be processed by the session is full
+	PacketDropQueueFull PacketDropReason = iota
+)
+
+func (r PacketDropReason) String() string {
+	switch r {
+	case PacketDropQueueFull:
+		return "queue_full"
+	}
+	return "unknown"
+}
+
+// A PathState is the state of a path
+type PathState uint8
+
+const (
+	// PathStateOpen is used when a path was opened, or when a potentially failed path received a packet again
+	PathStateOpen PathState = iota
+	// PathStatePotentiallyFailed is used when nothing was received on a path since an RTO
+	PathStatePotentiallyFailed
+	// PathStateClosed is used when a path was closed
+	PathStateClosed
+)
+
+func (s PathState) String() string {
+	switch s {
+	case PathStateOpen:
+		return "open"
+	case PathStatePotentiallyFailed:
+		return "potentially_failed"
+	case PathStateClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// A SchedulingReason is the reason why the scheduler selected a path
+type SchedulingReason uint8
+
+const (
+	// SchedulingSinglePath is used when the connection only has the initial path
+	SchedulingSinglePath SchedulingReason = iota
+	// SchedulingLowestRTT is used when the path has the lowest smoothed RTT of the paths that can send
+	SchedulingLowestRTT
+	// SchedulingUnprobedPath is used when the RTT of the path is not known yet, and the fewest packets were sent on it
+	SchedulingUnprobedPath
+	// SchedulingUnreliable is used when all paths are blocked by their congestion window, but unreliable streams have data to send
+	SchedulingUnreliable
+	// SchedulingStreamPreference is used when the streams that had data to send prefer another path than the one selected first
+	SchedulingStreamPreference
+)
+
+func (r SchedulingReason) String() string {
+	switch r {
+	case SchedulingSinglePath:
+		return "single_path"
+	case SchedulingLowestRTT:
+		return "lowest_rtt"
+	case SchedulingUnprobedPath:
+		return "unprobed_path"
+	case SchedulingUnreliable:
+		return "unreliable"
+	case SchedulingStreamPreference:
+		return "stream_preference"
+	}
+	return "unknown"
+}
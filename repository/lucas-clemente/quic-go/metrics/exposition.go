@@ -0,0 +1,152 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type metricType string
+
+const (
+	typeCounter   metricType = "counter"
+	typeGauge     metricType = "gauge"
+	typeHistogram metricType = "histogram"
+)
+
+// A family is a metric with at most one label, and one series per value of that label
+type family struct {
+	name string
+	help string
+	typ  metricType
+	// empty if the metric has no label
+	label string
+	// the upper bounds of the buckets, for histograms
+	buckets []float64
+
+	series map[string]*series
+}
+
+type series struct {
+	// the value of a counter or a gauge
+	value float64
+	// the number of observations per bucket of a histogram, not cumulative
+	bucketCounts []uint64
+	count        uint64
+	sum          float64
+}
+
+func newFamily(name, help string, typ metricType, label string, labelValues ...string) *family {
+	f := &family{
+		name:   name,
+		help:   help,
+		typ:    typ,
+		label:  label,
+		series: make(map[string]*series),
+	}
+	// the series that are known in advance are exported with a value of 0
+	for _, v := range labelValues {
+		f.get(v)
+	}
+	return f
+}
+
+func newHistogram(name, help, label string, buckets []float64) *family {
+	f := newFamily(name, help, typeHistogram, label)
+	f.buckets = buckets
+	return f
+}
+
+func (f *family) get(labelValue string) *series {
+	s, ok := f.series[labelValue]
+	if !ok {
+		s = &series{}
+		if f.typ == typeHistogram {
+			s.bucketCounts = make([]uint64, len(f.buckets))
+		}
+		f.series[labelValue] = s
+	}
+	return s
+}
+
+// add adds v to a counter or a gauge
+func (f *family) add(labelValue string, v float64) {
+	f.get(labelValue).value += v
+}
+
+// observe adds an observation to a histogram
+func (f *family) observe(labelValue string, v float64) {
+	s := f.get(labelValue)
+	s.count++
+	s.sum += v
+	if i := sort.SearchFloat64s(f.buckets, v); i < len(f.buckets) {
+		s.bucketCounts[i]++
+	}
+}
+
+// write writes the family in the OpenMetrics, or in the Prometheus text format.
+// The formats only differ in the name of counters in the TYPE and HELP lines.
+func (f *family) write(b *bytes.Buffer, openMetrics bool) {
+	name := f.name
+	if f.typ == typeCounter && !openMetrics {
+		name += "_total"
+	}
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, f.typ)
+	fmt.Fprintf(b, "# HELP %s %s\n", name, f.help)
+
+	labelValues := make([]string, 0, len(f.series))
+	for v := range f.series {
+		labelValues = append(labelValues, v)
+	}
+	sort.Strings(labelValues)
+	for _, v := range labelValues {
+		s := f.series[v]
+		switch f.typ {
+		case typeCounter:
+			f.writeSample(b, "_total", v, "", s.value)
+		case typeGauge:
+			f.writeSample(b, "", v, "", s.value)
+		case typeHistogram:
+			var cumulative uint64
+			for i, upperBound := range f.buckets {
+				cumulative += s.bucketCounts[i]
+				f.writeSample(b, "_bucket", v, formatFloat(upperBound), float64(cumulative))
+			}
+			f.writeSample(b, "_bucket", v, "+Inf", float64(s.count))
+			f.writeSample(b, "_sum", v, "", s.sum)
+			f.writeSample(b, "_count", v, "", float64(s.count))
+		}
+	}
+}
+
+func (f *family) writeSample(b *bytes.Buffer, suffix, labelValue, le string, value float64) {
+	b.WriteString(f.name)
+	b.WriteString(suffix)
+	var labels []string
+	if f.label != "" {
+		labels = append(labels, fmt.Sprintf(`%s="%s"`, f.label, escapeLabelValue(labelValue)))
+	}
+	if le != "" {
+		labels = append(labels, fmt.Sprintf(`le="%s"`, le))
+	}
+	if len(labels) > 0 {
+		b.WriteString("{" + strings.Join(labels, ",") + "}")
+	}
+	b.WriteString(" " + formatFloat(value) + "\n")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,124 @@
+// Package metrics collects metrics of QUIC connections, and exposes them to Prometheus.
+//
+// The Collector is used as the Tracer of the connections, and served over HTTP:
+//
+//	collector := metrics.NewCollector()
+//	ln, err := quic.ListenAddr(addr, tlsConf, &quic.Config{Tracer: collector})
+//	http.Handle("/metrics", collector)
+//
+// The metrics are served in the OpenMetrics format if the scraper accepts it,
+// and in the Prometheus text format otherwise.
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/logging"
+)
+
+const (
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	textContentType        = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	// in seconds
+	rttBuckets   = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+	pathsBuckets = []float64{1, 2, 3, 4, 6, 8}
+)
+
+// A Collector collects the metrics of the connections it traces.
+// It is a logging.Tracer, and an http.Handler serving the metrics.
+type Collector struct {
+	mutex sync.Mutex
+
+	activeSessions    *family
+	activePaths       *family
+	sessionPaths      *family
+	handshakeFailures *family
+	droppedPackets    *family
+	pathRTT           *family
+	streamGapBytes    *family
+}
+
+var _ logging.Tracer = &Collector{}
+var _ http.Handler = &Collector{}
+
+// NewCollector creates a new Collector
+func NewCollector() *Collector {
+	return &Collector{
+		activeSessions: newFamily(
+			"quic_sessions_active", "Number of open QUIC sessions.",
+			typeGauge, "perspective", "client", "server",
+		),
+		activePaths: newFamily(
+			"quic_paths_active", "Number of open paths, over all QUIC sessions.",
+			typeGauge, "perspective", "client", "server",
+		),
+		sessionPaths: newHistogram(
+			"quic_session_paths", "Number of paths opened by a QUIC session, observed when the session is closed.",
+			"perspective", pathsBuckets,
+		),
+		handshakeFailures: newFamily(
+			"quic_handshake_failures", "Number of QUIC sessions closed before the handshake completed, by error code.",
+			typeCounter, "error_code",
+		),
+		droppedPackets: newFamily(
+			"quic_received_packets_dropped", "Number of received packets dropped before they were processed by the session.",
+			typeCounter, "reason", logging.PacketDropQueueFull.String(),
+		),
+		pathRTT: newHistogram(
+			"quic_path_rtt_seconds", "RTT samples of the paths of the QUIC sessions.",
+			"path_id", rttBuckets,
+		),
+		streamGapBytes: newFamily(
+			"quic_unreliable_stream_gap_bytes", "Number of bytes of unreliable streams that were filled with zeros, because they didn't arrive in time.",
+			typeCounter, "",
+		),
+	}
+}
+
+// TracerForConnection returns the tracer that collects the metrics of a connection
+func (c *Collector) TracerForConnection(p logging.Perspective, _ logging.ConnectionID) logging.ConnectionTracer {
+	perspective := "server"
+	if p == protocol.PerspectiveClient {
+		perspective = "client"
+	}
+	return &connectionTracer{
+		c:           c,
+		perspective: perspective,
+		openPaths:   make(map[logging.PathID]struct{}),
+	}
+}
+
+// ServeHTTP serves the metrics
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+
+	b := &bytes.Buffer{}
+	c.mutex.Lock()
+	for _, f := range []*family{
+		c.activeSessions,
+		c.activePaths,
+		c.sessionPaths,
+		c.handshakeFailures,
+		c.droppedPackets,
+		c.pathRTT,
+		c.streamGapBytes,
+	} {
+		f.write(b, openMetrics)
+	}
+	c.mutex.Unlock()
+
+	if openMetrics {
+		b.WriteString("# EOF\n")
+		w.Header().Set("Content-Type", openMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", textContentType)
+	}
+	w.Write(b.Bytes())
+}
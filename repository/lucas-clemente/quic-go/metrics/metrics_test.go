@@ -0,0 +1,166 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/congestion"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/logging"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/qerr"
+
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	var collector *Collector
+
+	// scrape returns the lines served by the collector
+	scrape := func(accept string) ([]string, http.Header) {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		collector.ServeHTTP(w, req)
+		ExpectWithOffset(1, w.Code).To(Equal(http.StatusOK))
+		return strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n"), w.Header()
+	}
+
+	metrics := func() []string {
+		lines, _ := scrape("application/openmetrics-text; version=1.0.0")
+		return lines
+	}
+
+	BeforeEach(func() {
+		collector = NewCollector()
+	})
+
+	It("serves the metrics in the OpenMetrics format", func() {
+		lines, header := scrape("application/openmetrics-text; version=1.0.0,text/plain;version=0.0.4;q=0.5")
+		Expect(header.Get("Content-Type")).To(Equal(openMetricsContentType))
+		Expect(lines[len(lines)-1]).To(Equal("# EOF"))
+		Expect(lines).To(ContainElement("# TYPE quic_sessions_active gauge"))
+		Expect(lines).To(ContainElement(`quic_sessions_active{perspective="client"} 0`))
+		Expect(lines).To(ContainElement(`quic_sessions_active{perspective="server"} 0`))
+		Expect(lines).To(ContainElement("# TYPE quic_received_packets_dropped counter"))
+		Expect(lines).To(ContainElement(`quic_received_packets_dropped_total{reason="queue_full"} 0`))
+		Expect(lines).To(ContainElement("# TYPE quic_unreliable_stream_gap_bytes counter"))
+		// the metric has no label, its sample is exported once a gap was filled
+		Expect(lines).ToNot(ContainElement(HavePrefix("quic_unreliable_stream_gap_bytes_total")))
+	})
+
+	It("serves the metrics in the Prometheus text format", func() {
+		lines, header := scrape("")
+		Expect(header.Get("Content-Type")).To(Equal(textContentType))
+		Expect(lines).ToNot(ContainElement("# EOF"))
+		Expect(lines).To(ContainElement("# TYPE quic_received_packets_dropped_total counter"))
+		Expect(lines).To(ContainElement(`quic_received_packets_dropped_total{reason="queue_full"} 0`))
+	})
+
+	It("counts the sessions and their paths", func() {
+		client := collector.TracerForConnection(protocol.PerspectiveClient, 1)
+		server := collector.TracerForConnection(protocol.PerspectiveServer, 2)
+		client.StartedConnection(protocol.VersionMP)
+		client.UpdatedPathState(0, logging.PathStateOpen)
+		client.UpdatedPathState(1, logging.PathStateOpen)
+		client.UpdatedPathState(1, logging.PathStatePotentiallyFailed)
+		client.UpdatedPathState(1, logging.PathStateOpen)
+		client.UpdatedPathState(3, logging.PathStateOpen)
+		client.UpdatedPathState(3, logging.PathStateClosed)
+		server.StartedConnection(protocol.VersionMP)
+		server.UpdatedPathState(0, logging.PathStateOpen)
+		Expect(metrics()).To(ContainElement(`quic_sessions_active{perspective="client"} 1`))
+		Expect(metrics()).To(ContainElement(`quic_sessions_active{perspective="server"} 1`))
+		Expect(metrics()).To(ContainElement(`quic_paths_active{perspective="client"} 2`))
+		Expect(metrics()).To(ContainElement(`quic_paths_active{perspective="server"} 1`))
+		client.CompletedHandshake()
+		client.ClosedConnection(nil)
+		client.Close()
+		// paths closed after the session are ignored
+		client.UpdatedPathState(0, logging.PathStateClosed)
+		client.Close()
+		lines := metrics()
+		Expect(lines).To(ContainElement(`quic_sessions_active{perspective="client"} 0`))
+		Expect(lines).To(ContainElement(`quic_paths_active{perspective="client"} 0`))
+		Expect(lines).To(ContainElement(`quic_session_paths_bucket{perspective="client",le="2"} 0`))
+		Expect(lines).To(ContainElement(`quic_session_paths_bucket{perspective="client",le="3"} 1`))
+		Expect(lines).To(ContainElement(`quic_session_paths_bucket{perspective="client",le="+Inf"} 1`))
+		Expect(lines).To(ContainElement(`quic_session_paths_sum{perspective="client"} 3`))
+		Expect(lines).To(ContainElement(`quic_session_paths_count{perspective="client"} 1`))
+		Expect(lines).ToNot(ContainElement(ContainSubstring("quic_handshake_failures_total")))
+	})
+
+	It("counts the handshake failures by error code", func() {
+		for _, err := range []error{
+			qerr.Error(qerr.HandshakeTimeout, "Crypto handshake did not complete in time."),
+			qerr.HandshakeTimeout,
+			qerr.InvalidVersion,
+			errors.New("dial failed"),
+		} {
+			t := collector.TracerForConnection(protocol.PerspectiveClient, 1)
+			t.StartedConnection(protocol.VersionMP)
+			t.ClosedConnection(err)
+			t.Close()
+		}
+		lines := metrics()
+		Expect(lines).To(ContainElement(`quic_handshake_failures_total{error_code="HandshakeTimeout"} 2`))
+		Expect(lines).To(ContainElement(`quic_handshake_failures_total{error_code="InvalidVersion"} 1`))
+		Expect(lines).To(ContainElement(`quic_handshake_failures_total{error_code="InternalError"} 1`))
+	})
+
+	It("counts the dropped packets and the bytes filled with zeros", func() {
+		t := collector.TracerForConnection(protocol.PerspectiveServer, 1)
+		t.DroppedPacket(1, 1350, logging.PacketDropQueueFull)
+		t.DroppedPacket(0, 1350, logging.PacketDropQueueFull)
+		t.FilledStreamGap(5, 0, 100)
+		t.FilledStreamGap(7, 1000, 50)
+		lines := metrics()
+		Expect(lines).To(ContainElement(`quic_received_packets_dropped_total{reason="queue_full"} 2`))
+		Expect(lines).To(ContainElement("quic_unreliable_stream_gap_bytes_total 150"))
+	})
+
+	It("records the RTT samples of every path", func() {
+		t := collector.TracerForConnection(protocol.PerspectiveServer, 1)
+		rttStats := &congestion.RTTStats{}
+		rttStats.UpdateRTT(20*time.Millisecond, 0, time.Now())
+		t.UpdatedRTT(1, rttStats)
+		// only the congestion window changed
+		t.UpdatedMetrics(1, rttStats, 20000, 0)
+		rttStats.UpdateRTT(200*time.Millisecond, 0, time.Now())
+		t.UpdatedRTT(1, rttStats)
+		t.UpdatedRTT(2, rttStats)
+		lines := metrics()
+		Expect(lines).To(ContainElement("# TYPE quic_path_rtt_seconds histogram"))
+		Expect(lines).To(ContainElement(`quic_path_rtt_seconds_bucket{path_id="1",le="0.01"} 0`))
+		Expect(lines).To(ContainElement(`quic_path_rtt_seconds_bucket{path_id="1",le="0.025"} 1`))
+		Expect(lines).To(ContainElement(`quic_path_rtt_seconds_bucket{path_id="1",le="0.25"} 2`))
+		Expect(lines).To(ContainElement(`quic_path_rtt_seconds_bucket{path_id="1",le="+Inf"} 2`))
+		Expect(lines).To(ContainElement(`quic_path_rtt_seconds_sum{path_id="1"} 0.22`))
+		Expect(lines).To(ContainElement(`quic_path_rtt_seconds_count{path_id="1"} 2`))
+		Expect(lines).To(ContainElement(`quic_path_rtt_seconds_count{path_id="2"} 1`))
+	})
+
+	It("records every RTT sample, even if it is equal to the previous one", func() {
+		t := collector.TracerForConnection(protocol.PerspectiveServer, 1)
+		rttStats := &congestion.RTTStats{}
+		for i := 0; i < 3; i++ {
+			rttStats.UpdateRTT(20*time.Millisecond, 0, time.Now())
+			t.UpdatedRTT(1, rttStats)
+		}
+		Expect(metrics()).To(ContainElement(`quic_path_rtt_seconds_count{path_id="1"} 3`))
+	})
+
+	It("escapes the label values", func() {
+		f := newFamily("test", "A test.", typeGauge, "label")
+		f.add("a \"quoted\"\nback\\slash", 1)
+		b := &bytes.Buffer{}
+		f.write(b, true)
+		Expect(b.String()).To(ContainSubstring(`test{label="a \"quoted\"\nback\\slash"} 1`))
+	})
+})
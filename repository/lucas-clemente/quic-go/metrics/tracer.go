@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/logging"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/qerr"
+)
+
+// The connectionTracer updates the metrics of the Collector with the events of a connection.
+// Its state is protected by the mutex of the Collector.
+type connectionTracer struct {
+	c           *Collector
+	perspective string
+
+	handshakeComplete bool
+	openPaths         map[logging.PathID]struct{}
+	pathsOpened       int
+	// set once the session was closed, the events of the paths closed afterwards are ignored
+	closed bool
+}
+
+var _ logging.ConnectionTracer = &connectionTracer{}
+
+func (t *connectionTracer) StartedConnection(logging.VersionNumber) {
+	t.c.mutex.Lock()
+	t.c.activeSessions.add(t.perspective, 1)
+	t.c.mutex.Unlock()
+}
+
+func (t *connectionTracer) CompletedHandshake() {
+	t.c.mutex.Lock()
+	t.handshakeComplete = true
+	t.c.mutex.Unlock()
+}
+
+func (t *connectionTracer) ClosedConnection(err error) {
+	t.c.mutex.Lock()
+	defer t.c.mutex.Unlock()
+
+	if !t.handshakeComplete {
+		t.c.handshakeFailures.add(errorCode(err).String(), 1)
+	}
+}
+
+func (t *connectionTracer) SentPacket(logging.PathID, logging.PacketNumber, logging.ByteCount, logging.EncryptionLevel, []logging.Frame) {
+}
+
+func (t *connectionTracer) ReceivedPacket(logging.PathID, logging.PacketNumber, logging.ByteCount, logging.EncryptionLevel, []logging.Frame) {
+}
+
+func (t *connectionTracer) DroppedPacket(_ logging.PathID, _ logging.ByteCount, reason logging.PacketDropReason) {
+	t.c.mutex.Lock()
+	t.c.droppedPackets.add(reason.String(), 1)
+	t.c.mutex.Unlock()
+}
+
+func (t *connectionTracer) LostPacket(logging.PathID, logging.PacketNumber, logging.PacketLossReason) {
+}
+
+func (t *connectionTracer) UpdatedMetrics(logging.PathID, *logging.RTTStats, logging.ByteCount, logging.ByteCount) {
+}
+
+func (t *connectionTracer) UpdatedRTT(pathID logging.PathID, rttStats *logging.RTTStats) {
+	t.c.mutex.Lock()
+	t.c.pathRTT.observe(strconv.FormatUint(uint64(pathID), 10), rttStats.LatestRTT().Seconds())
+	t.c.mutex.Unlock()
+}
+
+func (t *connectionTracer) UpdatedPathState(pathID logging.PathID, state logging.PathState) {
+	t.c.mutex.Lock()
+	defer t.c.mutex.Unlock()
+
+	if t.closed {
+		return
+	}
+	_, open := t.openPaths[pathID]
+	switch state {
+	case logging.PathStateOpen:
+		if !open {
+			t.openPaths[pathID] = struct{}{}
+			t.pathsOpened++
+			t.c.activePaths.add(t.perspective, 1)
+		}
+	case logging.PathStateClosed:
+		if open {
+			delete(t.openPaths, pathID)
+			t.c.activePaths.add(t.perspective, -1)
+		}
+	}
+}
+
+func (t *connectionTracer) SelectedPath(logging.PathID, logging.SchedulingReason) {}
+
+func (t *connectionTracer) ReinjectedPackets(logging.PathID, int) {}
+
+func (t *connectionTracer) DuplicatedPacket(logging.PathID, logging.PacketNumber) {}
+
+func (t *connectionTracer) FilledStreamGap(_ logging.StreamID, _, length logging.ByteCount) {
+	t.c.mutex.Lock()
+	t.c.streamGapBytes.add("", float64(length))
+	t.c.mutex.Unlock()
+}
+
+func (t *connectionTracer) Close() {
+	t.c.mutex.Lock()
+	defer t.c.mutex.Unlock()
+
+	if t.closed {
+		return
+	}
+	t.closed = true
+	t.c.activeSessions.add(t.perspective, -1)
+	t.c.activePaths.add(t.perspective, -float64(len(t.openPaths)))
+	t.c.sessionPaths.observe(t.perspective, float64(t.pathsOpened))
+}
+
+// errorCode returns the error code of the error that closed a session
+func errorCode(err error) qerr.ErrorCode {
+	switch e := err.(type) {
+	case nil:
+		return qerr.PeerGoingAway
+	case *qerr.QuicError:
+		return e.ErrorCode
+	case qerr.ErrorCode:
+		return e
+	}
+	return qerr.InternalError
+}
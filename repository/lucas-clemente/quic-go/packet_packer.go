@@ -9,6 +9,7 @@ import (
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/handshake"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/wire"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/logging"
 )
 
 type packedPacket struct {
@@ -30,7 +31,10 @@ type packetPacker struct {
 
 	controlFrames []wire.Frame
 	stopWaiting   map[protocol.PathID]*wire.StopWaitingFrame
-	ackFrame      map[protocol.PathID]*wire.AckFrame
+	// ACK frames, indexed by the path they are sent on
+	ackFrames map[protocol.PathID][]*wire.AckFrame
+
+	tracer logging.ConnectionTracer
 }
 
 func newPacketPacker(connectionID protocol.ConnectionID,
@@ -39,6 +43,7 @@ func newPacketPacker(connectionID protocol.ConnectionID,
 	streamFramer *streamFramer,
 	perspective protocol.Perspective,
 	version protocol.VersionNumber,
+	tracer logging.ConnectionTracer,
 ) *packetPacker {
 	return &packetPacker{
 		cryptoSetup:          cryptoSetup,
@@ -48,7 +53,8 @@ func newPacketPacker(connectionID protocol.ConnectionID,
 		version:              version,
 		streamFramer:         streamFramer,
 		stopWaiting:          make(map[protocol.PathID]*wire.StopWaitingFrame),
-		ackFrame:             make(map[protocol.PathID]*wire.AckFrame),
+		ackFrames:            make(map[protocol.PathID][]*wire.AckFrame),
+		tracer:               tracer,
 	}
 }
 
@@ -57,7 +63,7 @@ func (p *packetPacker) PackConnectionClose(ccf *wire.ConnectionCloseFrame, pth *
 	frames := []wire.Frame{ccf}
 	encLevel, sealer := p.cryptoSetup.GetSealer()
 	ph := p.getPublicHeader(encLevel, pth)
-	raw, err := p.writeAndSealPacket(ph, frames, sealer, pth)
+	raw, err := p.writeAndSealPacket(ph, frames, encLevel, sealer, pth)
 	return &packedPacket{
 		number:          ph.PacketNumber,
 		raw:             raw,
@@ -75,20 +81,23 @@ func (p *packetPacker) PackPing(pf *wire.PingFrame, pth *path) (*packedPacket, e
 }
 
 func (p *packetPacker) PackAckPacket(pth *path) (*packedPacket, error) {
-	if p.ackFrame[pth.pathID] == nil {
+	if len(p.ackFrames[pth.pathID]) == 0 {
 		return nil, errors.New("packet packer BUG: no ack frame queued")
 	}
 	encLevel, sealer := p.cryptoSetup.GetSealer()
 	ph := p.getPublicHeader(encLevel, pth)
-	frames := []wire.Frame{p.ackFrame[pth.pathID]}
+	var frames []wire.Frame
+	for _, ack := range p.ackFrames[pth.pathID] {
+		frames = append(frames, ack)
+	}
 	if p.stopWaiting[pth.pathID] != nil {
 		p.stopWaiting[pth.pathID].PacketNumber = ph.PacketNumber
 		p.stopWaiting[pth.pathID].PacketNumberLen = ph.PacketNumberLen
 		frames = append(frames, p.stopWaiting[pth.pathID])
 		p.stopWaiting[pth.pathID] = nil
 	}
-	p.ackFrame[pth.pathID] = nil
-	raw, err := p.writeAndSealPacket(ph, frames, sealer, pth)
+	p.ackFrames[pth.pathID] = nil
+	raw, err := p.writeAndSealPacket(ph, frames, encLevel, sealer, pth)
 	return &packedPacket{
 		number:          ph.PacketNumber,
 		raw:             raw,
@@ -114,7 +123,7 @@ func (p *packetPacker) PackHandshakeRetransmission(packet *ackhandler.Packet, pt
 	p.stopWaiting[pth.pathID].PacketNumberLen = ph.PacketNumberLen
 	frames := append([]wire.Frame{p.stopWaiting[pth.pathID]}, packet.Frames...)
 	p.stopWaiting[pth.pathID] = nil
-	raw, err := p.writeAndSealPacket(ph, frames, sealer, pth)
+	raw, err := p.writeAndSealPacket(ph, frames, packet.EncryptionLevel, sealer, pth)
 	return &packedPacket{
 		number:          ph.PacketNumber,
 		raw:             raw,
@@ -171,9 +180,9 @@ func (p *packetPacker) PackPacket(pth *path) (*packedPacket, error) {
 		return nil, nil
 	}
 	p.stopWaiting[pth.pathID] = nil
-	p.ackFrame[pth.pathID] = nil
+	p.ackFrames[pth.pathID] = nil
 
-	raw, err := p.writeAndSealPacket(publicHeader, payloadFrames, sealer, pth)
+	raw, err := p.writeAndSealPacket(publicHeader, payloadFrames, encLevel, sealer, pth)
 	if err != nil {
 		return nil, err
 	}
@@ -194,7 +203,7 @@ func (p *packetPacker) packCryptoPacket(pth *path) (*packedPacket, error) {
 	}
 	maxLen := protocol.MaxPacketSize - protocol.ByteCount(sealer.Overhead()) - protocol.NonForwardSecurePacketSizeReduction - publicHeaderLength
 	frames := []wire.Frame{p.streamFramer.PopCryptoStreamFrame(maxLen)}
-	raw, err := p.writeAndSealPacket(publicHeader, frames, sealer, pth)
+	raw, err := p.writeAndSealPacket(publicHeader, frames, encLevel, sealer, pth)
 	if err != nil {
 		return nil, err
 	}
@@ -223,9 +232,9 @@ func (p *packetPacker) composeNextPacket( //为什么要有一个path的参数
 		}
 		payloadLength += l
 	}
-	if p.ackFrame[pth.pathID] != nil {
-		payloadFrames = append(payloadFrames, p.ackFrame[pth.pathID])
-		l, err := p.ackFrame[pth.pathID].MinLength(p.version)
+	for _, ack := range p.ackFrames[pth.pathID] {
+		payloadFrames = append(payloadFrames, ack)
+		l, err := ack.MinLength(p.version)
 		if err != nil {
 			return nil, err
 		}
@@ -281,12 +290,30 @@ func (p *packetPacker) QueueControlFrame(frame wire.Frame, pth *path) {
 	case *wire.StopWaitingFrame:
 		p.stopWaiting[pth.pathID] = f
 	case *wire.AckFrame:
-		p.ackFrame[pth.pathID] = f
+		p.queueAckFrame(f, pth)
 	default:
 		p.controlFrames = append(p.controlFrames, f)
 	}
 }
 
+// queueAckFrame queues an ACK frame to be sent on the given path.
+// The ACK frame may be for another path, it replaces any ACK frame for the same path that was not sent yet.
+func (p *packetPacker) queueAckFrame(f *wire.AckFrame, pth *path) {
+	if f == nil {
+		p.ackFrames[pth.pathID] = nil
+		return
+	}
+	for pathID, acks := range p.ackFrames {
+		for i, ack := range acks {
+			if ack.PathID == f.PathID {
+				p.ackFrames[pathID] = append(acks[:i], acks[i+1:]...)
+				break
+			}
+		}
+	}
+	p.ackFrames[pth.pathID] = append(p.ackFrames[pth.pathID], f)
+}
+
 func (p *packetPacker) getPublicHeader(encLevel protocol.EncryptionLevel, pth *path) *wire.PublicHeader {
 	pnum := pth.packetNumberGenerator.Peek()
 	packetNumberLen := protocol.GetPacketNumberLengthForPublicHeader(pnum, pth.leastUnacked)
@@ -319,34 +346,53 @@ func (p *packetPacker) getPublicHeader(encLevel protocol.EncryptionLevel, pth *p
 func (p *packetPacker) writeAndSealPacket(
 	publicHeader *wire.PublicHeader,
 	payloadFrames []wire.Frame,
+	encLevel protocol.EncryptionLevel,
 	sealer handshake.Sealer,
 	pth *path,
 ) ([]byte, error) {
 	raw := getPacketBuffer()
 	buffer := bytes.NewBuffer(raw)
 
+	if s, ok := sealer.(handshake.KeyUpdatingSealer); ok {
+		publicHeader.KeyPhase = s.KeyPhase()
+	}
 	if err := publicHeader.Write(buffer, p.version, p.perspective); err != nil {
 		return nil, err
 	}
+	// The server ignores small packets with a version it doesn't support, instead of sending a version negotiation packet.
+	// With QUIC crypto, the CHLO is padded. With TLS, the ClientHello isn't, so we pad the packet.
+	pad := p.perspective == protocol.PerspectiveClient && publicHeader.VersionFlag && p.version.UsesTLS()
 	payloadStartIndex := buffer.Len()
 	for _, frame := range payloadFrames {
+		// the padding must not be read as STREAM data
+		if sf, ok := frame.(*wire.StreamFrame); ok && pad {
+			sf.DataLenPresent = true
+		}
 		err := frame.Write(buffer, p.version)
 		if err != nil {
 			return nil, err
 		}
 	}
+	if pad {
+		if paddingLen := protocol.ClientHelloMinimumSize - (buffer.Len() - payloadStartIndex); paddingLen > 0 {
+			buffer.Write(bytes.Repeat([]byte{0}, paddingLen))
+		}
+	}
 	if protocol.ByteCount(buffer.Len()+sealer.Overhead()) > protocol.MaxPacketSize {
 		return nil, errors.New("PacketPacker BUG: packet too large")
 	}
 
 	raw = raw[0:buffer.Len()]
-	_ = sealer.Seal(raw[payloadStartIndex:payloadStartIndex], raw[payloadStartIndex:], publicHeader.PacketNumber, raw[:payloadStartIndex])
+	_ = sealer.Seal(raw[payloadStartIndex:payloadStartIndex], raw[payloadStartIndex:], noncePathID(p.version, publicHeader.PathID), publicHeader.PacketNumber, raw[:payloadStartIndex])
 	raw = raw[0 : buffer.Len()+sealer.Overhead()]
 
 	num := pth.packetNumberGenerator.Pop()
 	if num != publicHeader.PacketNumber {
 		return nil, errors.New("packetPacker BUG: Peeked and Popped packet numbers do not match")
 	}
+	if p.tracer != nil {
+		p.tracer.SentPacket(pth.pathID, num, protocol.ByteCount(len(raw)), encLevel, payloadFrames)
+	}
 
 	return raw, nil
 }
@@ -357,3 +403,12 @@ func (p *packetPacker) canSendData(encLevel protocol.EncryptionLevel) bool {
 	}
 	return encLevel == protocol.EncryptionForwardSecure
 }
+
+// noncePathID returns the path ID that is used to compute the nonce.
+// Versions that don't use the path ID in the nonce seal every packet as if it was sent on the initial path.
+func noncePathID(version protocol.VersionNumber, pathID protocol.PathID) protocol.PathID {
+	if !version.UsesPathIDInNonce() {
+		return protocol.InitialPathID
+	}
+	return pathID
+}
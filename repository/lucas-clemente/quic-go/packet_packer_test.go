@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"math"
 
+	"github.com/yyleeshine/mpquic/repository/golang/mock/gomock"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/ackhandler"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/congestion"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/crypto"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/handshake"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/mocks"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
@@ -16,7 +18,7 @@ import (
 
 type mockSealer struct{}
 
-func (s *mockSealer) Seal(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) []byte {
+func (s *mockSealer) Seal(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) []byte {
 	return append(src, bytes.Repeat([]byte{0}, 12)...)
 }
 
@@ -24,6 +26,16 @@ func (s *mockSealer) Overhead() int { return 12 }
 
 var _ handshake.Sealer = &mockSealer{}
 
+// keyPhaseSealer is a sealer that updates its keys
+type keyPhaseSealer struct {
+	mockSealer
+	keyPhase protocol.KeyPhaseBit
+}
+
+func (s *keyPhaseSealer) KeyPhase() protocol.KeyPhaseBit { return s.keyPhase }
+
+var _ handshake.KeyUpdatingSealer = &keyPhaseSealer{}
+
 type mockCryptoSetup struct {
 	handleErr          error
 	divNonce           []byte
@@ -36,7 +48,7 @@ var _ handshake.CryptoSetup = &mockCryptoSetup{}
 func (m *mockCryptoSetup) HandleCryptoStream() error {
 	return m.handleErr
 }
-func (m *mockCryptoSetup) Open(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, protocol.EncryptionLevel, error) {
+func (m *mockCryptoSetup) Open(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, keyPhase protocol.KeyPhaseBit, associatedData []byte) ([]byte, protocol.EncryptionLevel, error) {
 	return nil, protocol.EncryptionUnspecified, nil
 }
 func (m *mockCryptoSetup) GetSealer() (protocol.EncryptionLevel, handshake.Sealer) {
@@ -50,6 +62,19 @@ func (m *mockCryptoSetup) GetSealerWithEncryptionLevel(protocol.EncryptionLevel)
 }
 func (m *mockCryptoSetup) DiversificationNonce() []byte            { return m.divNonce }
 func (m *mockCryptoSetup) SetDiversificationNonce(divNonce []byte) { m.divNonce = divNonce }
+func (m *mockCryptoSetup) ConnectionState() handshake.ConnectionState {
+	panic("not implemented")
+}
+
+// sealerCryptoSetup seals forward-secure packets with the given sealer, e.g. a real AEAD
+type sealerCryptoSetup struct {
+	mockCryptoSetup
+	sealer handshake.Sealer
+}
+
+func (m *sealerCryptoSetup) GetSealer() (protocol.EncryptionLevel, handshake.Sealer) {
+	return protocol.EncryptionForwardSecure, m.sealer
+}
 
 var _ = Describe("Packet packer", func() {
 	var (
@@ -67,13 +92,13 @@ var _ = Describe("Packet packer", func() {
 
 		cryptoStream = &stream{}
 
-		streamsMap := newStreamsMap(nil, protocol.PerspectiveServer, nil)
+		streamsMap := newStreamsMap(nil, nil, protocol.PerspectiveServer, nil)
 		streamsMap.streams[1] = cryptoStream
 		streamsMap.openStreams = []protocol.StreamID{1}
 		streamFramer = newStreamFramer(streamsMap, nil)
 
 		pth = &path{
-			sentPacketHandler:     ackhandler.NewSentPacketHandler(&congestion.RTTStats{}, nil, nil),
+			sentPacketHandler:     ackhandler.NewSentPacketHandler(&congestion.RTTStats{}, nil, nil, 0, 0, nil),
 			packetNumberGenerator: newPacketNumberGenerator(protocol.SkipPacketAveragePeriodLength),
 		}
 
@@ -84,7 +109,7 @@ var _ = Describe("Packet packer", func() {
 			streamFramer:         streamFramer,
 			perspective:          protocol.PerspectiveServer,
 			stopWaiting:          make(map[protocol.PathID]*wire.StopWaitingFrame),
-			ackFrame:             make(map[protocol.PathID]*wire.AckFrame),
+			ackFrames:            make(map[protocol.PathID][]*wire.AckFrame),
 		}
 		publicHeaderLen = 1 + 8 + 2 // 1 flag byte, 8 connection ID, 2 packet number
 		maxFrameSize = protocol.MaxPacketSize - protocol.ByteCount((&mockSealer{}).Overhead()) - publicHeaderLen
@@ -124,6 +149,26 @@ var _ = Describe("Packet packer", func() {
 		Expect(p.encryptionLevel).To(Equal(protocol.EncryptionForwardSecure))
 	})
 
+	It("records the packed packets", func() {
+		tracer := mocks.NewMockConnectionTracer(mockCtrl)
+		packer.tracer = tracer
+		pth.pathID = 3
+		f := &wire.StreamFrame{
+			StreamID: 5,
+			Data:     []byte("foobar"),
+		}
+		streamFramer.AddFrameForRetransmission(f)
+		var size protocol.ByteCount
+		tracer.EXPECT().SentPacket(protocol.PathID(3), gomock.Any(), gomock.Any(), protocol.EncryptionForwardSecure, []wire.Frame{f}).Do(
+			func(_ protocol.PathID, _ protocol.PacketNumber, s protocol.ByteCount, _ protocol.EncryptionLevel, _ []wire.Frame) {
+				size = s
+			},
+		)
+		p, err := packer.PackPacket(pth)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(size).To(Equal(protocol.ByteCount(len(p.raw))))
+	})
+
 	Context("diversificaton nonces", func() {
 		var nonce []byte
 
@@ -256,6 +301,33 @@ var _ = Describe("Packet packer", func() {
 		Expect(hdr.VersionNumber).To(Equal(packer.version))
 	})
 
+	It("pads packets with the version flag when using TLS", func() {
+		packer.perspective = protocol.PerspectiveClient
+		packer.cryptoSetup.(*mockCryptoSetup).encLevelSeal = protocol.EncryptionUnencrypted
+		packer.controlFrames = []wire.Frame{&wire.BlockedFrame{StreamID: 0}}
+		packer.version = protocol.VersionTLS
+		p, err := packer.PackPacket(pth)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p).ToNot(BeNil())
+		hdr, err := wire.ParsePublicHeader(bytes.NewReader(p.raw), protocol.PerspectiveClient, packer.version)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hdr.VersionFlag).To(BeTrue())
+		Expect(len(p.raw)).To(BeNumerically(">=", protocol.ClientHelloMinimumSize))
+	})
+
+	It("sets the data length of STREAM frames in padded packets", func() {
+		packer.perspective = protocol.PerspectiveClient
+		packer.version = protocol.VersionTLS
+		packer.cryptoSetup.(*mockCryptoSetup).encLevelSeal = protocol.EncryptionSecure
+		f := &wire.StreamFrame{StreamID: 5, Data: []byte("foobar")}
+		streamFramer.AddFrameForRetransmission(f)
+		p, err := packer.PackPacket(pth)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p.frames).To(HaveLen(1))
+		Expect(p.frames[0].(*wire.StreamFrame).DataLenPresent).To(BeTrue())
+		Expect(len(p.raw)).To(BeNumerically(">=", protocol.ClientHelloMinimumSize))
+	})
+
 	It("doesn't add the version flag to the public header for forward-secure packets", func() {
 		packer.perspective = protocol.PerspectiveClient
 		packer.cryptoSetup.(*mockCryptoSetup).encLevelSeal = protocol.EncryptionForwardSecure
@@ -735,5 +807,117 @@ var _ = Describe("Packet packer", func() {
 				&wire.StopWaitingFrame{PacketNumber: 1, PacketNumberLen: 2},
 			}))
 		})
+
+		It("packs the ACKs for other paths", func() {
+			packer.QueueControlFrame(&wire.AckFrame{PathID: 0}, pth)
+			packer.QueueControlFrame(&wire.AckFrame{PathID: 3}, pth)
+			p, err := packer.PackAckPacket(pth)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(p.frames).To(Equal([]wire.Frame{
+				&wire.AckFrame{PathID: 0, DelayTime: math.MaxInt64},
+				&wire.AckFrame{PathID: 3, DelayTime: math.MaxInt64},
+			}))
+		})
+
+		It("only sends the latest ACK for a path", func() {
+			otherPth := &path{pathID: 3}
+			packer.QueueControlFrame(&wire.AckFrame{PathID: 3, LargestAcked: 1}, otherPth)
+			packer.QueueControlFrame(&wire.AckFrame{PathID: 3, LargestAcked: 2}, pth)
+			Expect(packer.ackFrames[otherPth.pathID]).To(BeEmpty())
+			Expect(packer.ackFrames[pth.pathID]).To(Equal([]*wire.AckFrame{{PathID: 3, LargestAcked: 2}}))
+		})
+	})
+
+	Context("key updates", func() {
+		It("writes the key phase of the sealer into the public header", func() {
+			packer.cryptoSetup = &sealerCryptoSetup{sealer: &keyPhaseSealer{keyPhase: protocol.KeyPhaseOne}}
+			packer.QueueControlFrame(&wire.PingFrame{}, pth)
+			p, err := packer.PackPacket(pth)
+			Expect(err).ToNot(HaveOccurred())
+			hdr, err := wire.ParsePublicHeader(bytes.NewReader(p.raw), protocol.PerspectiveServer, packer.version)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hdr.KeyPhase).To(Equal(protocol.KeyPhaseOne))
+		})
+
+		It("doesn't set the key phase if the sealer doesn't update its keys", func() {
+			packer.QueueControlFrame(&wire.PingFrame{}, pth)
+			p, err := packer.PackPacket(pth)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.raw[0] & 0x80).To(BeZero())
+		})
+	})
+
+	Context("sealing packets on multiple paths", func() {
+		var aead crypto.AEAD
+
+		newPath := func(pathID protocol.PathID) *path {
+			return &path{
+				pathID:                pathID,
+				sess:                  &session{handshakeComplete: true},
+				sentPacketHandler:     ackhandler.NewSentPacketHandler(&congestion.RTTStats{}, nil, nil, 0, 0, nil),
+				packetNumberGenerator: newPacketNumberGenerator(protocol.SkipPacketAveragePeriodLength),
+			}
+		}
+
+		BeforeEach(func() {
+			var err error
+			aead, err = crypto.NewAEADAESGCM12(make([]byte, 16), make([]byte, 16), make([]byte, 4), make([]byte, 4))
+			Expect(err).ToNot(HaveOccurred())
+			packer.cryptoSetup = &sealerCryptoSetup{sealer: aead}
+			packer.version = protocol.VersionMP2
+		})
+
+		It("never uses the same nonce on two paths, if they send the same packet number", func() {
+			pth1 := newPath(1)
+			pth2 := newPath(2)
+			packer.QueueControlFrame(&wire.PingFrame{}, pth1)
+			p1, err := packer.PackPacket(pth1)
+			Expect(err).ToNot(HaveOccurred())
+			packer.QueueControlFrame(&wire.PingFrame{}, pth2)
+			p2, err := packer.PackPacket(pth2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p1.number).To(Equal(p2.number))
+
+			hdr1, err := wire.ParsePublicHeader(bytes.NewReader(p1.raw), protocol.PerspectiveServer, packer.version)
+			Expect(err).ToNot(HaveOccurred())
+			hdr2, err := wire.ParsePublicHeader(bytes.NewReader(p2.raw), protocol.PerspectiveServer, packer.version)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hdr1.PathID).To(Equal(protocol.PathID(1)))
+			Expect(hdr2.PathID).To(Equal(protocol.PathID(2)))
+			hdrLen, err := hdr1.GetLength(protocol.PerspectiveServer)
+			Expect(err).ToNot(HaveOccurred())
+			// the same frames are sealed with the same key, only the nonce differs
+			Expect(p1.raw[hdrLen:]).ToNot(Equal(p2.raw[hdrLen:]))
+
+			// the receiver uses the path ID of the public header to open the packet
+			_, err = aead.Open(nil, p1.raw[hdrLen:], 1, hdr1.PacketNumber, p1.raw[:hdrLen])
+			Expect(err).ToNot(HaveOccurred())
+			_, err = aead.Open(nil, p1.raw[hdrLen:], 2, hdr1.PacketNumber, p1.raw[:hdrLen])
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("uses the nonce of the initial path on all paths, if the version doesn't use the path ID in the nonce", func() {
+			packer.version = protocol.VersionMP
+			pth1 := newPath(1)
+			pth2 := newPath(2)
+			packer.QueueControlFrame(&wire.PingFrame{}, pth1)
+			p1, err := packer.PackPacket(pth1)
+			Expect(err).ToNot(HaveOccurred())
+			packer.QueueControlFrame(&wire.PingFrame{}, pth2)
+			p2, err := packer.PackPacket(pth2)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p1.number).To(Equal(p2.number))
+
+			for _, p := range []*packedPacket{p1, p2} {
+				hdr, err := wire.ParsePublicHeader(bytes.NewReader(p.raw), protocol.PerspectiveServer, packer.version)
+				Expect(err).ToNot(HaveOccurred())
+				hdrLen, err := hdr.GetLength(protocol.PerspectiveServer)
+				Expect(err).ToNot(HaveOccurred())
+				_, err = aead.Open(nil, p.raw[hdrLen:], protocol.InitialPathID, hdr.PacketNumber, p.raw[:hdrLen])
+				Expect(err).ToNot(HaveOccurred())
+				_, err = aead.Open(nil, p.raw[hdrLen:], hdr.PathID, hdr.PacketNumber, p.raw[:hdrLen])
+				Expect(err).To(HaveOccurred())
+			}
+		})
 	})
 })
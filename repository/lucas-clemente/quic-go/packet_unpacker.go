@@ -16,7 +16,7 @@ type unpackedPacket struct {
 }
 
 type quicAEAD interface {
-	Open(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, protocol.EncryptionLevel, error)
+	Open(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, keyPhase protocol.KeyPhaseBit, associatedData []byte) ([]byte, protocol.EncryptionLevel, error)
 }
 
 type packetUnpacker struct {
@@ -30,7 +30,7 @@ func (u *packetUnpacker) Unpack(publicHeaderBinary []byte, hdr *wire.PublicHeade
 
 	buf := getPacketBuffer()
 	defer putPacketBuffer(buf)
-	decrypted, encryptionLevel, err := u.aead.Open(buf, data, hdr.PacketNumber, publicHeaderBinary)//返回解密之后的数据和加密级别
+	decrypted, encryptionLevel, err := u.aead.Open(buf, data, noncePathID(u.version, hdr.PathID), hdr.PacketNumber, hdr.KeyPhase, publicHeaderBinary)//返回解密之后的数据和加密级别
 	if err != nil {
 		// Wrap err in quicError so that public reset is sent by session
 		return nil, qerr.Error(qerr.DecryptionFailure, err.Error())
@@ -90,7 +90,7 @@ func (u *packetUnpacker) Unpack(publicHeaderBinary []byte, hdr *wire.PublicHeade
 				if err != nil {
 					err = qerr.Error(qerr.InvalidConnectionCloseData, err.Error())
 				}
-			case 0x03:
+			case 0x03, 0x14:
 				frame, err = wire.ParseGoawayFrame(r, u.version)
 				if err != nil {
 					err = qerr.Error(qerr.InvalidGoawayData, err.Error())
@@ -118,6 +118,11 @@ func (u *packetUnpacker) Unpack(publicHeaderBinary []byte, hdr *wire.PublicHeade
 				frame, err = wire.ParseClosePathFrame(r, u.version)
 			case 0x12:
 				frame, err = wire.ParsePathsFrame(r, u.version)
+			case 0x13:
+				frame, err = wire.ParseStopSendingFrame(r, u.version)
+				if err != nil {
+					err = qerr.Error(qerr.InvalidFrameData, err.Error())
+				}
 			default:
 				err = qerr.Error(qerr.InvalidFrameData, fmt.Sprintf("unknown type byte 0x%x", typeByte))
 			}
@@ -14,16 +14,20 @@ import (
 
 type mockAEAD struct {
 	encLevelOpen protocol.EncryptionLevel
+	keyPhase     protocol.KeyPhaseBit
+	pathID       protocol.PathID
 }
 
-func (m *mockAEAD) Open(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, protocol.EncryptionLevel, error) {
+func (m *mockAEAD) Open(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, keyPhase protocol.KeyPhaseBit, associatedData []byte) ([]byte, protocol.EncryptionLevel, error) {
+	m.keyPhase = keyPhase
+	m.pathID = pathID
 	nullAEAD := crypto.NewNullAEAD(protocol.PerspectiveClient, protocol.VersionWhatever)
-	res, err := nullAEAD.Open(dst, src, packetNumber, associatedData)
+	res, err := nullAEAD.Open(dst, src, pathID, packetNumber, associatedData)
 	return res, m.encLevelOpen, err
 }
-func (m *mockAEAD) Seal(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, protocol.EncryptionLevel) {
+func (m *mockAEAD) Seal(dst, src []byte, pathID protocol.PathID, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, protocol.EncryptionLevel) {
 	nullAEAD := crypto.NewNullAEAD(protocol.PerspectiveServer, protocol.VersionWhatever)
-	return nullAEAD.Seal(dst, src, packetNumber, associatedData), protocol.EncryptionUnspecified
+	return nullAEAD.Seal(dst, src, pathID, packetNumber, associatedData), protocol.EncryptionUnspecified
 }
 
 var _ quicAEAD = &mockAEAD{}
@@ -49,7 +53,7 @@ var _ = Describe("Packet unpacker", func() {
 	})
 
 	setData := func(p []byte) {
-		data, _ = unpacker.aead.(*mockAEAD).Seal(nil, p, 0, hdrBin)
+		data, _ = unpacker.aead.(*mockAEAD).Seal(nil, p, 0, 0, hdrBin)
 	}
 
 	It("does not read read a private flag for QUIC Version >= 34", func() {
@@ -73,6 +77,41 @@ var _ = Describe("Packet unpacker", func() {
 		Expect(packet.encryptionLevel).To(Equal(protocol.EncryptionSecure))
 	})
 
+	It("opens the packet with the key phase of the public header", func() {
+		f := &wire.ConnectionCloseFrame{ReasonPhrase: "foo"}
+		err := f.Write(buf, 0)
+		Expect(err).ToNot(HaveOccurred())
+		setData(buf.Bytes())
+		hdr.KeyPhase = protocol.KeyPhaseOne
+		_, err = unpacker.Unpack(hdrBin, hdr, data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(unpacker.aead.(*mockAEAD).keyPhase).To(Equal(protocol.KeyPhaseOne))
+	})
+
+	It("opens the packet with the path ID of the public header, if the version uses it in the nonce", func() {
+		unpacker.version = protocol.VersionMP2
+		f := &wire.ConnectionCloseFrame{ReasonPhrase: "foo"}
+		err := f.Write(buf, unpacker.version)
+		Expect(err).ToNot(HaveOccurred())
+		setData(buf.Bytes())
+		hdr.PathID = 3
+		_, err = unpacker.Unpack(hdrBin, hdr, data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(unpacker.aead.(*mockAEAD).pathID).To(Equal(protocol.PathID(3)))
+	})
+
+	It("opens the packet with the nonce of the initial path, if the version doesn't use the path ID in the nonce", func() {
+		unpacker.version = protocol.VersionMP
+		f := &wire.ConnectionCloseFrame{ReasonPhrase: "foo"}
+		err := f.Write(buf, unpacker.version)
+		Expect(err).ToNot(HaveOccurred())
+		setData(buf.Bytes())
+		hdr.PathID = 3
+		_, err = unpacker.Unpack(hdrBin, hdr, data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(unpacker.aead.(*mockAEAD).pathID).To(Equal(protocol.PathID(protocol.InitialPathID)))
+	})
+
 	It("unpacks ACK frames", func() {
 		unpacker.version = protocol.VersionWhatever
 		f := &wire.AckFrame{
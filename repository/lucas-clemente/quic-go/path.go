@@ -8,6 +8,7 @@ import (
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/utils"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/wire"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/logging"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/qerr"
 )
 
@@ -15,6 +16,9 @@ const (
 	minPathTimer = 10 * time.Millisecond
 	// XXX (QDC): To avoid idling...
 	maxPathTimer = 1 * time.Second
+	// Minimum time without receiving anything on a path with packets in flight before it is considered stalled,
+	// the same as the minimum RTO of the sent packet handler
+	minStallTimeout = 200 * time.Millisecond
 )
 
 type path struct {
@@ -45,6 +49,14 @@ type path struct {
 	largestRcvdPacketNumber protocol.PacketNumber
 	// 最小的未确认的报文序号
 	leastUnacked protocol.PacketNumber
+	// The largest packet number acked by the peer on this path, in ACK frames received on any path
+	largestAcked protocol.PacketNumber
+	// The size of the packets received on the path that could be decrypted
+	bytesReceived protocol.ByteCount
+	// The bandwidth at which packets are received on the path, measured over one RTT
+	receiveBandwidth       congestion.Bandwidth
+	receiveBandwidthStart  time.Time
+	receiveBandwidthOffset protocol.ByteCount
 
 	lastNetworkActivityTime time.Time
 
@@ -62,12 +74,13 @@ func (p *path) setup(oliaSenders map[protocol.PathID]*congestion.OliaSender) {
 		oliaSenders[p.pathID] = cong.(*congestion.OliaSender)
 	}
 
-	sentPacketHandler := ackhandler.NewSentPacketHandler(p.rttStats, cong, p.onRTO)// 创建发送的处理器
+	sentPacketHandler := ackhandler.NewSentPacketHandler(p.rttStats, cong, p.onRTO, p.sess.config.ReorderingTolerance, p.pathID, p.sess.tracer)// 创建发送的处理器
 
 	now := time.Now()
 
 	p.sentPacketHandler = sentPacketHandler
 	p.receivedPacketHandler = ackhandler.NewReceivedPacketHandler(p.sess.version) //创建接收的处理器
+	p.applyAckFrequency()
 
 	p.packetNumberGenerator = newPacketNumberGenerator(protocol.SkipPacketAveragePeriodLength) //创建报文序号的生成器
 
@@ -81,12 +94,19 @@ func (p *path) setup(oliaSenders map[protocol.PathID]*congestion.OliaSender) {
 	p.open.Set(true)  // 初始化的时候，该路径的状态肯定被设置为打开状态
 	p.potentiallyFailed.Set(false) // 初始化的时候，该路径的状态被设置为false
 
+	if p.sess.tracer != nil {
+		p.sess.tracer.UpdatedPathState(p.pathID, logging.PathStateOpen)
+	}
+
 	// Once the path is setup, run it
 	go p.run()
 }
 
 func (p *path) close() error {
 	p.open.Set(false)
+	if p.sess.tracer != nil {
+		p.sess.tracer.UpdatedPathState(p.pathID, logging.PathStateClosed)
+	}
 	return nil
 }
 // 其实这个run函数的目的就是用来接收各种来自session的事件通知
@@ -132,6 +152,30 @@ func (p *path) GetStopWaitingFrame(force bool) *wire.StopWaitingFrame { // stopW
 	return p.sentPacketHandler.GetStopWaitingFrame(force)
 }
 
+// receivedAck is called when an ACK frame for this path was received on another path.
+// If it acks a new packet, the path works although nothing was received on it.
+func (p *path) receivedAck(frame *wire.AckFrame, rcvTime time.Time) {
+	if frame.LargestAcked <= p.largestAcked {
+		return
+	}
+	p.largestAcked = frame.LargestAcked
+	if rcvTime.After(p.lastNetworkActivityTime) {
+		p.lastNetworkActivityTime = rcvTime
+	}
+	p.setPotentiallyFailed(false)
+}
+
+// applyAckFrequency sets the ACK frequency negotiated with the peer
+func (p *path) applyAckFrequency() {
+	if p.sess.connectionParameters == nil {
+		return
+	}
+	p.receivedPacketHandler.SetAckFrequency(
+		p.sess.connectionParameters.GetAckSendDelay(),
+		p.sess.connectionParameters.GetRetransmittablePacketsBeforeAck(),
+	)
+}
+
 func (p *path) GetAckFrame() *wire.AckFrame {// 从receivedPacketHandler当中拿到ACK frame
 	ack := p.receivedPacketHandler.GetAckFrame()
 	if ack != nil {
@@ -191,7 +235,7 @@ func (p *path) handlePacketImpl(pkt *receivedPacket) error {
 	data := pkt.data
 
 	// We just received a new packet on that path, so it works
-	p.potentiallyFailed.Set(false)
+	p.setPotentiallyFailed(false)
 
 	// Calculate packet number
 	hdr.PacketNumber = protocol.InferPacketNumber(
@@ -227,6 +271,12 @@ func (p *path) handlePacketImpl(pkt *receivedPacket) error {
 	// 这条路径上收到的最大报文序号
 	p.largestRcvdPacketNumber = utils.MaxPacketNumber(p.largestRcvdPacketNumber, hdr.PacketNumber)
 
+	p.bytesReceived += protocol.ByteCount(len(data) + len(hdr.Raw))
+	p.updateReceiveBandwidth(pkt.rcvTime)
+	if p.sess.tracer != nil {
+		p.sess.tracer.ReceivedPacket(p.pathID, hdr.PacketNumber, protocol.ByteCount(len(data)+len(hdr.Raw)), packet.encryptionLevel, packet.frames)
+	}
+
 	isRetransmittable := ackhandler.HasRetransmittableFrames(packet.frames)//如果存在streamFrame的话，那就是可重传的报文
 	if err = p.receivedPacketHandler.ReceivedPacket(hdr.PacketNumber, isRetransmittable); err != nil {// isRetransmittable代表了是否需要重传，是否需要ack
 		return err
@@ -242,13 +292,87 @@ func (p *path) handlePacketImpl(pkt *receivedPacket) error {
 func (p *path) onRTO(lastSentTime time.Time) bool {
 	// Was there any activity since last sent packet?
 	if p.lastNetworkActivityTime.Before(lastSentTime) {
-		p.potentiallyFailed.Set(true) // 如果出现超时的话，那么这条路径就可能down掉了，因此会被设置为true
+		p.setPotentiallyFailed(true) // 如果出现超时的话，那么这条路径就可能down掉了，因此会被设置为true
 		p.sess.schedulePathsFrame() // 一旦一个路径超时的话，那么就会发送一些path frame
 		return true
 	}
 	return false
 }
 
+// updateReceiveBandwidth measures the bandwidth at which packets are received on the path, once per smoothed RTT
+func (p *path) updateReceiveBandwidth(now time.Time) {
+	srtt := p.rttStats.SmoothedRTT()
+	if srtt == 0 || p.receiveBandwidthStart.IsZero() {
+		p.receiveBandwidthStart = now
+		p.receiveBandwidthOffset = p.bytesReceived
+		return
+	}
+	interval := now.Sub(p.receiveBandwidthStart)
+	if interval < srtt {
+		return
+	}
+	p.receiveBandwidth = congestion.BandwidthFromDelta(p.bytesReceived-p.receiveBandwidthOffset, interval)
+	p.receiveBandwidthStart = now
+	p.receiveBandwidthOffset = p.bytesReceived
+}
+
+// stats returns the statistics of the path
+func (p *path) stats() PathStats {
+	sent := p.sentPacketHandler.GetStatistics()
+	return PathStats{
+		Open:                 p.open.Get(),
+		PacketsSent:          sent.Packets,
+		BytesSent:            sent.Bytes,
+		PacketsReceived:      p.receivedPacketHandler.GetStatistics(),
+		BytesReceived:        p.bytesReceived,
+		PacketsRetransmitted: sent.Retransmissions,
+		BytesRetransmitted:   sent.RetransmittedBytes,
+		PacketsLost:          sent.Losses,
+		BytesLost:            sent.LostBytes,
+		SmoothedRTT:          p.rttStats.SmoothedRTT(),
+		MinRTT:               p.rttStats.MinRTT(),
+		RTTVariance:          p.rttStats.MeanDeviation(),
+		CongestionWindow:     sent.CongestionWindow,
+		BytesInFlight:        sent.BytesInFlight,
+		PacingRate:           uint64(sent.BandwidthEstimate / congestion.BytesPerSecond),
+	}
+}
+
+// setPotentiallyFailed marks the path as potentially failed, or as working again
+func (p *path) setPotentiallyFailed(failed bool) {
+	if p.potentiallyFailed.Get() == failed {
+		return
+	}
+	p.potentiallyFailed.Set(failed)
+	if p.sess.tracer == nil {
+		return
+	}
+	if failed {
+		p.sess.tracer.UpdatedPathState(p.pathID, logging.PathStatePotentiallyFailed)
+	} else {
+		p.sess.tracer.UpdatedPathState(p.pathID, logging.PathStateOpen)
+	}
+}
+
+// stalled returns true if the packets in flight on the path should be reinjected on other paths,
+// and the send time before which they should be reinjected.
+// A path stalls if it is potentially failed, or if nothing was received on it for more than one RTO,
+// such that delayed ACKs and RTT variations don't make a healthy path stall.
+func (p *path) stalled(now time.Time) (bool, time.Time) {
+	if p.potentiallyFailed.Get() {
+		return true, now
+	}
+	srtt := p.rttStats.SmoothedRTT()
+	if srtt == 0 {
+		return false, time.Time{}
+	}
+	stallTimeout := utils.MaxDuration(srtt+4*p.rttStats.MeanDeviation(), minStallTimeout)
+	if now.Sub(p.lastNetworkActivityTime) <= stallTimeout {
+		return false, time.Time{}
+	}
+	return true, now.Add(-stallTimeout)
+}
+
 func (p *path) SetLeastUnacked(leastUnacked protocol.PacketNumber) {
 	p.leastUnacked = leastUnacked
 }
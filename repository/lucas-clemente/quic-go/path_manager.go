@@ -165,6 +165,11 @@ func (pm *pathManager) createPaths() error {
 		utils.Debugf("Path manager tries to create paths")
 	}
 
+	// with TLS, the peer has to announce that it supports multiple paths
+	if !pm.sess.connectionParameters.PeerSupportsMultipath() {
+		return nil
+	}
+
 	// XXX (QDC): don't let the server create paths for now
 	if pm.sess.perspective == protocol.PerspectiveServer {
 		pm.advertiseAddresses()
@@ -0,0 +1,166 @@
+// Package pcap writes the UDP datagrams sent and received on the sockets of all paths to a pcap file,
+// which can be opened with Wireshark or tcpdump:
+//
+//	w, err := pcap.NewWriter(f)
+//	sess, err := quic.DialAddr(addr, tlsConf, &quic.Config{PacketCapture: w, KeyLogWriter: keyLog})
+//
+// The datagrams are written with synthetic IPv4 or IPv6 and UDP headers.
+// The local address is the address the socket is bound to, which is unspecified for a socket listening on any address.
+package pcap
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	quic "github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go"
+)
+
+const (
+	magicNumber = 0xa1b2c3d4 // the timestamps have a microsecond resolution
+	snapLength  = 65535
+	// LINKTYPE_RAW: every packet starts with an IPv4 or an IPv6 header
+	linkTypeRaw = 101
+
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
+	udpHeaderLen  = 8
+	protocolUDP   = 17
+	ttl           = 64
+)
+
+// A Writer writes the captured packets to a pcap file.
+// It is safe for concurrent use.
+type Writer struct {
+	mutex sync.Mutex
+	w     io.Writer
+	err   error
+}
+
+var _ quic.PacketCapture = &Writer{}
+
+// NewWriter creates a new Writer, and writes the header of the pcap file to w
+func NewWriter(w io.Writer) (*Writer, error) {
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:4], magicNumber)
+	binary.LittleEndian.PutUint16(hdr[4:6], 2) // major version
+	binary.LittleEndian.PutUint16(hdr[6:8], 4) // minor version
+	// the time zone offset and the accuracy of the timestamps are 0
+	binary.LittleEndian.PutUint32(hdr[16:20], snapLength)
+	binary.LittleEndian.PutUint32(hdr[20:24], linkTypeRaw)
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w}, nil
+}
+
+// CapturePacket writes a UDP datagram to the pcap file.
+// Once writing failed, no more packets are written, and Err returns the error.
+func (w *Writer) CapturePacket(t time.Time, src, dst net.Addr, data []byte) {
+	packet := encodePacket(udpAddr(src), udpAddr(dst), data)
+	record := make([]byte, 16, 16+len(packet))
+	micros := t.UnixNano() / int64(time.Microsecond)
+	binary.LittleEndian.PutUint32(record[0:4], uint32(micros/1e6))
+	binary.LittleEndian.PutUint32(record[4:8], uint32(micros%1e6))
+	binary.LittleEndian.PutUint32(record[8:12], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(record[12:16], uint32(len(packet)))
+	record = append(record, packet...)
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.err != nil {
+		return
+	}
+	_, w.err = w.w.Write(record)
+}
+
+// Err returns the error that occurred while writing a packet, if any
+func (w *Writer) Err() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.err
+}
+
+func udpAddr(addr net.Addr) *net.UDPAddr {
+	if a, ok := addr.(*net.UDPAddr); ok {
+		return a
+	}
+	if addr != nil {
+		if a, err := net.ResolveUDPAddr("udp", addr.String()); err == nil {
+			return a
+		}
+	}
+	return &net.UDPAddr{IP: net.IPv4zero}
+}
+
+// encodePacket prepends an IP and a UDP header to the data.
+// An IPv6 header is used if any of the addresses is an IPv6 address.
+func encodePacket(src, dst *net.UDPAddr, data []byte) []byte {
+	srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+	isIPv4 := srcIP != nil && dstIP != nil
+	if !isIPv4 {
+		srcIP, dstIP = src.IP.To16(), dst.IP.To16()
+		if srcIP == nil {
+			srcIP = net.IPv6unspecified
+		}
+		if dstIP == nil {
+			dstIP = net.IPv6unspecified
+		}
+	}
+
+	udpLen := udpHeaderLen + len(data)
+	var b []byte
+	if isIPv4 {
+		b = make([]byte, ipv4HeaderLen, ipv4HeaderLen+udpLen)
+		b[0] = 4<<4 | ipv4HeaderLen/4
+		binary.BigEndian.PutUint16(b[2:4], uint16(ipv4HeaderLen+udpLen))
+		binary.BigEndian.PutUint16(b[6:8], 0x4000) // don't fragment
+		b[8] = ttl
+		b[9] = protocolUDP
+		copy(b[12:16], srcIP)
+		copy(b[16:20], dstIP)
+		binary.BigEndian.PutUint16(b[10:12], ^uint16(checksum(0, b)))
+	} else {
+		b = make([]byte, ipv6HeaderLen, ipv6HeaderLen+udpLen)
+		b[0] = 6 << 4
+		binary.BigEndian.PutUint16(b[4:6], uint16(udpLen))
+		b[6] = protocolUDP
+		b[7] = ttl
+		copy(b[8:24], srcIP)
+		copy(b[24:40], dstIP)
+	}
+
+	udp := make([]byte, udpHeaderLen, udpLen)
+	binary.BigEndian.PutUint16(udp[0:2], uint16(src.Port))
+	binary.BigEndian.PutUint16(udp[2:4], uint16(dst.Port))
+	binary.BigEndian.PutUint16(udp[4:6], uint16(udpLen))
+	udp = append(udp, data...)
+
+	// the checksum covers a pseudo header with the addresses, the protocol and the length
+	sum := checksum(0, srcIP)
+	sum = checksum(sum, dstIP)
+	sum += protocolUDP + uint32(udpLen)
+	udpChecksum := ^uint16(checksum(sum, udp))
+	if udpChecksum == 0 {
+		udpChecksum = 0xffff
+	}
+	binary.BigEndian.PutUint16(udp[6:8], udpChecksum)
+
+	return append(b, udp...)
+}
+
+// checksum adds the data to the one's complement sum used by the IP and the UDP checksum
+func checksum(sum uint32, data []byte) uint32 {
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum&0xffff + sum>>16
+	}
+	return sum
+}
@@ -0,0 +1,13 @@
+package pcap
+
+import (
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+
+	"testing"
+)
+
+func TestPcap(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "pcap Suite")
+}
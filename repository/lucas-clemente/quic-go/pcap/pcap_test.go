@@ -0,0 +1,125 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+type errorWriter struct {
+	writes int
+}
+
+func (w *errorWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes > 1 {
+		return 0, errors.New("write failed")
+	}
+	return len(p), nil
+}
+
+var _ = Describe("pcap Writer", func() {
+	var (
+		buf *bytes.Buffer
+		w   *Writer
+	)
+
+	// readPacket reads the next record of the pcap file, and returns its timestamp and the packet
+	readPacket := func() (time.Time, []byte) {
+		record := buf.Next(16)
+		Expect(record).To(HaveLen(16))
+		t := time.Unix(int64(binary.LittleEndian.Uint32(record[0:4])), int64(binary.LittleEndian.Uint32(record[4:8]))*int64(time.Microsecond))
+		length := binary.LittleEndian.Uint32(record[8:12])
+		Expect(binary.LittleEndian.Uint32(record[12:16])).To(Equal(length))
+		packet := buf.Next(int(length))
+		Expect(packet).To(HaveLen(int(length)))
+		return t, packet
+	}
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		var err error
+		w, err = NewWriter(buf)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("writes the header", func() {
+		Expect(buf.Bytes()).To(Equal([]byte{
+			0xd4, 0xc3, 0xb2, 0xa1, // magic number
+			2, 0, 4, 0, // version 2.4
+			0, 0, 0, 0, 0, 0, 0, 0, // time zone and accuracy
+			0xff, 0xff, 0, 0, // snap length
+			101, 0, 0, 0, // LINKTYPE_RAW
+		}))
+	})
+
+	It("writes a packet sent over IPv4", func() {
+		buf.Reset()
+		now := time.Unix(1234, 5678000)
+		src := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 2), Port: 1337}
+		dst := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 443}
+		w.CapturePacket(now, src, dst, []byte("foobar"))
+		t, packet := readPacket()
+		Expect(t).To(Equal(now))
+		Expect(buf.Len()).To(BeZero())
+		Expect(packet).To(HaveLen(20 + 8 + 6))
+		Expect(packet[0]).To(Equal(byte(0x45)))
+		Expect(binary.BigEndian.Uint16(packet[2:4])).To(Equal(uint16(34)))
+		Expect(packet[9]).To(Equal(byte(protocolUDP)))
+		Expect(net.IP(packet[12:16]).Equal(src.IP)).To(BeTrue())
+		Expect(net.IP(packet[16:20]).Equal(dst.IP)).To(BeTrue())
+		Expect(checksum(0, packet[:20])).To(Equal(uint32(0xffff)))
+		udp := packet[20:]
+		Expect(binary.BigEndian.Uint16(udp[0:2])).To(Equal(uint16(1337)))
+		Expect(binary.BigEndian.Uint16(udp[2:4])).To(Equal(uint16(443)))
+		Expect(binary.BigEndian.Uint16(udp[4:6])).To(Equal(uint16(14)))
+		Expect(udp[8:]).To(Equal([]byte("foobar")))
+		pseudoHeader := checksum(0, packet[12:20]) + protocolUDP + 14
+		Expect(checksum(pseudoHeader, udp)).To(Equal(uint32(0xffff)))
+	})
+
+	It("writes a packet sent over IPv6", func() {
+		buf.Reset()
+		src := &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 1337}
+		dst := &net.UDPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443}
+		w.CapturePacket(time.Now(), src, dst, []byte("foo"))
+		_, packet := readPacket()
+		Expect(packet).To(HaveLen(40 + 8 + 3))
+		Expect(packet[0] >> 4).To(Equal(byte(6)))
+		Expect(binary.BigEndian.Uint16(packet[4:6])).To(Equal(uint16(11)))
+		Expect(packet[6]).To(Equal(byte(protocolUDP)))
+		Expect(net.IP(packet[8:24]).Equal(src.IP)).To(BeTrue())
+		Expect(net.IP(packet[24:40]).Equal(dst.IP)).To(BeTrue())
+		udp := packet[40:]
+		Expect(udp[8:]).To(Equal([]byte("foo")))
+		pseudoHeader := checksum(0, packet[8:40]) + protocolUDP + 11
+		Expect(checksum(pseudoHeader, udp)).To(Equal(uint32(0xffff)))
+	})
+
+	It("uses an IPv6 header if only one of the addresses is an IPv6 address", func() {
+		buf.Reset()
+		src := &net.UDPAddr{IP: net.IPv4zero, Port: 1337}
+		dst := &net.UDPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443}
+		w.CapturePacket(time.Now(), src, dst, []byte("foo"))
+		_, packet := readPacket()
+		Expect(packet[0] >> 4).To(Equal(byte(6)))
+		Expect(net.IP(packet[8:24]).Equal(net.IPv4zero)).To(BeTrue())
+	})
+
+	It("stops writing after an error", func() {
+		ew := &errorWriter{}
+		w, err := NewWriter(ew)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Err()).ToNot(HaveOccurred())
+		addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1337}
+		w.CapturePacket(time.Now(), addr, addr, []byte("foo"))
+		Expect(w.Err()).To(MatchError("write failed"))
+		w.CapturePacket(time.Now(), addr, addr, []byte("foo"))
+		Expect(ew.writes).To(Equal(2))
+	})
+})
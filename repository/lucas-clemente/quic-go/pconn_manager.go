@@ -43,6 +43,48 @@ type pconnManager struct {
 	closed      chan struct{}
 	errorConn   chan error
 	timer       *time.Timer
+
+	// if set, all PacketConns are wrapped to capture the datagrams sent and received on them
+	packetCapture PacketCapture
+}
+
+// A capturingPacketConn passes the datagrams sent and received on a PacketConn to a PacketCapture
+type capturingPacketConn struct {
+	net.PacketConn
+	packetCapture PacketCapture
+}
+
+func (c *capturingPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(p)
+	if err == nil {
+		c.packetCapture.CapturePacket(time.Now(), addr, c.LocalAddr(), p[:n])
+	}
+	return n, addr, err
+}
+
+func (c *capturingPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(p, addr)
+	if err == nil {
+		c.packetCapture.CapturePacket(time.Now(), c.LocalAddr(), addr, p[:n])
+	}
+	return n, err
+}
+
+// newPconnManager creates a pconnManager, that captures the packets if the config has a PacketCapture.
+// The config may be nil.
+func newPconnManager(perspective protocol.Perspective, config *Config) *pconnManager {
+	pcm := &pconnManager{perspective: perspective}
+	if config != nil {
+		pcm.packetCapture = config.PacketCapture
+	}
+	return pcm
+}
+
+func (pcm *pconnManager) wrap(pconn net.PacketConn) net.PacketConn {
+	if pcm.packetCapture == nil {
+		return pconn
+	}
+	return &capturingPacketConn{PacketConn: pconn, packetCapture: pcm.packetCapture}
 }
 
 // Setup the pconn_manager and the pconnAny connection
@@ -72,10 +114,10 @@ func (pcm *pconnManager) setup(pconnArg net.PacketConn, listenAddr net.Addr) err
 			operr := &net.OpError{Op: "listen", Net: "udp", Source: listenAddr, Addr: listenAddr, Err: err}
 			return operr
 		}
-		pcm.pconnAny = pconn
+		pcm.pconnAny = pcm.wrap(pconn)
 	} else {
 		// FIXME Update localAddrs
-		pcm.pconnAny = pconnArg
+		pcm.pconnAny = pcm.wrap(pconnArg)
 	}
 
 	if utils.Debug() {
@@ -184,14 +226,15 @@ func (pcm *pconnManager) createPconn(ip net.IP) (*net.UDPAddr, error) {
 	if err != nil {
 		return nil, err
 	}
+	wrappedPconn := pcm.wrap(pconn)
 	pcm.mutex.Lock()
-	pcm.pconns[locAddr.String()] = pconn
+	pcm.pconns[locAddr.String()] = wrappedPconn
 	pcm.mutex.Unlock()
 	if utils.Debug() {
 		utils.Debugf("Created pconn on %s", pconn.LocalAddr().String())
 	}
 	// Start to listen on this new socket
-	go pcm.listen(pconn)
+	go pcm.listen(wrappedPconn)
 	// Don't block
 	select {
 	case pcm.changePaths <- struct{}{}:
@@ -0,0 +1,95 @@
+package quic
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+type capturedPacket struct {
+	src, dst net.Addr
+	data     []byte
+}
+
+type mockPacketCapture struct {
+	mutex   sync.Mutex
+	packets []capturedPacket
+}
+
+var _ PacketCapture = &mockPacketCapture{}
+
+func (c *mockPacketCapture) CapturePacket(_ time.Time, src, dst net.Addr, data []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.packets = append(c.packets, capturedPacket{src: src, dst: dst, data: append([]byte{}, data...)})
+}
+
+func (c *mockPacketCapture) getPackets() []capturedPacket {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.packets
+}
+
+var _ = Describe("pconnManager", func() {
+	var (
+		capture    *mockPacketCapture
+		packetConn *mockPacketConn
+		localAddr  = &net.UDPAddr{IP: net.IPv4(192, 168, 100, 200), Port: 1337}
+		remoteAddr = &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 443}
+	)
+
+	BeforeEach(func() {
+		capture = &mockPacketCapture{}
+		packetConn = &mockPacketConn{addr: localAddr}
+	})
+
+	It("doesn't wrap the PacketConns without a PacketCapture", func() {
+		pcm := newPconnManager(protocol.PerspectiveServer, nil)
+		Expect(pcm.wrap(packetConn)).To(Equal(packetConn))
+		pcm = newPconnManager(protocol.PerspectiveServer, &Config{})
+		Expect(pcm.wrap(packetConn)).To(Equal(packetConn))
+	})
+
+	It("captures the packets sent", func() {
+		pcm := newPconnManager(protocol.PerspectiveServer, &Config{PacketCapture: capture})
+		n, err := pcm.wrap(packetConn).WriteTo([]byte("foobar"), remoteAddr)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(6))
+		Expect(packetConn.dataWritten.Bytes()).To(Equal([]byte("foobar")))
+		Expect(capture.getPackets()).To(Equal([]capturedPacket{
+			{src: localAddr, dst: remoteAddr, data: []byte("foobar")},
+		}))
+	})
+
+	It("captures the packets received on the sockets", func() {
+		packetConn.dataToRead = []byte("foobar")
+		packetConn.dataReadFrom = remoteAddr
+		pcm := newPconnManager(protocol.PerspectiveServer, &Config{PacketCapture: capture})
+		err := pcm.setup(packetConn, nil)
+		Expect(err).ToNot(HaveOccurred())
+		var rcvRawPacket *receivedRawPacket
+		Eventually(pcm.rcvRawPackets).Should(Receive(&rcvRawPacket))
+		Expect(rcvRawPacket.data).To(Equal([]byte("foobar")))
+		Expect(rcvRawPacket.rcvPconn).To(Equal(pcm.pconnAny))
+		Expect(capture.getPackets()).To(Equal([]capturedPacket{
+			{src: remoteAddr, dst: localAddr, data: []byte("foobar")},
+		}))
+		pcm.closeConns <- struct{}{}
+		Eventually(pcm.closed).Should(BeClosed())
+		Expect(packetConn.closed).To(BeTrue())
+	})
+
+	It("doesn't capture packets that failed to be received", func() {
+		packetConn.readErr = errors.New("read failed")
+		pconn := newPconnManager(protocol.PerspectiveServer, &Config{PacketCapture: capture}).wrap(packetConn)
+		_, _, err := pconn.ReadFrom(make([]byte, 10))
+		Expect(err).To(MatchError("read failed"))
+		Expect(capture.getPackets()).To(BeEmpty())
+	})
+})
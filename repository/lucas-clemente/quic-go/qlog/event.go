@@ -0,0 +1,239 @@
+package qlog
+
+import (
+	"fmt"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/logging"
+)
+
+type header struct {
+	QlogVersion string `json:"qlog_version"`
+	QlogFormat  string `json:"qlog_format"`
+	Title       string `json:"title"`
+	Trace       trace  `json:"trace"`
+}
+
+type trace struct {
+	VantagePoint string       `json:"vantage_point"`
+	CommonFields commonFields `json:"common_fields"`
+}
+
+type commonFields struct {
+	GroupID       string  `json:"group_id"`
+	ReferenceTime float64 `json:"reference_time"`
+	TimeFormat    string  `json:"time_format"`
+}
+
+type event struct {
+	// in ms, relative to the reference time
+	Time float64      `json:"time"`
+	Name string       `json:"name"`
+	Data eventDetails `json:"data"`
+}
+
+type eventDetails interface {
+	Name() string
+}
+
+// The connection events are recorded on the initial path
+type eventConnectionStarted struct {
+	PathID  logging.PathID `json:"path_id"`
+	Version string         `json:"version"`
+}
+
+func (eventConnectionStarted) Name() string { return "transport:connection_started" }
+
+type eventConnectionClosed struct {
+	PathID logging.PathID `json:"path_id"`
+	Error  string         `json:"error,omitempty"`
+}
+
+func (eventConnectionClosed) Name() string { return "transport:connection_closed" }
+
+type eventHandshakeCompleted struct {
+	PathID logging.PathID `json:"path_id"`
+}
+
+func (eventHandshakeCompleted) Name() string { return "transport:handshake_completed" }
+
+type packet struct {
+	PathID          logging.PathID       `json:"path_id"`
+	PacketNumber    logging.PacketNumber `json:"packet_number"`
+	PacketSize      logging.ByteCount    `json:"packet_size"`
+	EncryptionLevel string               `json:"encryption_level"`
+	Frames          []frame              `json:"frames"`
+}
+
+func newPacket(pathID logging.PathID, packetNumber logging.PacketNumber, size logging.ByteCount, encLevel logging.EncryptionLevel, frames []logging.Frame) packet {
+	p := packet{
+		PathID:          pathID,
+		PacketNumber:    packetNumber,
+		PacketSize:      size,
+		EncryptionLevel: encryptionLevel(encLevel),
+		Frames:          make([]frame, 0, len(frames)),
+	}
+	for _, f := range frames {
+		p.Frames = append(p.Frames, newFrame(f))
+	}
+	return p
+}
+
+type eventPacketSent struct {
+	packet
+}
+
+func (eventPacketSent) Name() string { return "transport:packet_sent" }
+
+type eventPacketReceived struct {
+	packet
+}
+
+func (eventPacketReceived) Name() string { return "transport:packet_received" }
+
+type eventPacketDropped struct {
+	PathID     logging.PathID    `json:"path_id"`
+	PacketSize logging.ByteCount `json:"packet_size"`
+	Trigger    string            `json:"trigger"`
+}
+
+func (eventPacketDropped) Name() string { return "transport:packet_dropped" }
+
+type eventPacketLost struct {
+	PathID       logging.PathID       `json:"path_id"`
+	PacketNumber logging.PacketNumber `json:"packet_number"`
+	Trigger      string               `json:"trigger"`
+}
+
+func (eventPacketLost) Name() string { return "recovery:packet_lost" }
+
+// RTTs are in ms
+type eventMetricsUpdated struct {
+	PathID           logging.PathID    `json:"path_id"`
+	MinRTT           float64           `json:"min_rtt"`
+	SmoothedRTT      float64           `json:"smoothed_rtt"`
+	LatestRTT        float64           `json:"latest_rtt"`
+	RTTVariance      float64           `json:"rtt_variance"`
+	CongestionWindow logging.ByteCount `json:"congestion_window"`
+	BytesInFlight    logging.ByteCount `json:"bytes_in_flight"`
+}
+
+func (eventMetricsUpdated) Name() string { return "recovery:metrics_updated" }
+
+type eventPathStateUpdated struct {
+	PathID logging.PathID `json:"path_id"`
+	State  string         `json:"state"`
+}
+
+func (eventPathStateUpdated) Name() string { return "multipath:path_state_updated" }
+
+type eventPathSelected struct {
+	PathID logging.PathID `json:"path_id"`
+	Reason string         `json:"reason"`
+}
+
+func (eventPathSelected) Name() string { return "multipath:path_selected" }
+
+type eventPacketsReinjected struct {
+	PathID logging.PathID `json:"path_id"`
+	Count  int            `json:"count"`
+}
+
+func (eventPacketsReinjected) Name() string { return "multipath:packets_reinjected" }
+
+type eventPacketDuplicated struct {
+	PathID       logging.PathID       `json:"path_id"`
+	PacketNumber logging.PacketNumber `json:"packet_number"`
+}
+
+func (eventPacketDuplicated) Name() string { return "multipath:packet_duplicated" }
+
+// Streams are not bound to a path, the gaps are recorded on the initial path
+type eventStreamGapFilled struct {
+	PathID   logging.PathID    `json:"path_id"`
+	StreamID logging.StreamID  `json:"stream_id"`
+	Offset   logging.ByteCount `json:"offset"`
+	Length   logging.ByteCount `json:"length"`
+}
+
+func (eventStreamGapFilled) Name() string { return "transport:stream_gap_filled" }
+
+func encryptionLevel(encLevel logging.EncryptionLevel) string {
+	switch encLevel {
+	case protocol.EncryptionUnencrypted:
+		return "unencrypted"
+	case protocol.EncryptionSecure:
+		return "secure"
+	case protocol.EncryptionForwardSecure:
+		return "forward_secure"
+	}
+	return "unknown"
+}
+
+// a frame is encoded as a JSON object with the frame_type and the fields of the frame
+type frame map[string]interface{}
+
+func newFrame(f logging.Frame) frame {
+	switch f := f.(type) {
+	case *logging.StreamFrame:
+		return frame{
+			"frame_type": "stream",
+			"stream_id":  f.StreamID,
+			"offset":     f.Offset,
+			"length":     f.DataLen(),
+			"fin":        f.FinBit,
+			"unreliable": f.UnreliableMarker,
+		}
+	case *logging.AckFrame:
+		return frame{
+			"frame_type":    "ack",
+			"acked_path_id": f.PathID,
+			"acked_ranges":  ackRanges(f.LowestAcked, f.LargestAcked, f.AckRanges),
+			"ack_delay":     milliseconds(f.DelayTime),
+		}
+	case *logging.ClosePathFrame:
+		return frame{
+			"frame_type":     "close_path",
+			"closed_path_id": f.PathID,
+			"acked_ranges":   ackRanges(f.LowestAcked, f.LargestAcked, f.AckRanges),
+		}
+	case *logging.StopWaitingFrame:
+		return frame{"frame_type": "stop_waiting", "least_unacked": f.LeastUnacked}
+	case *logging.WindowUpdateFrame:
+		return frame{"frame_type": "window_update", "stream_id": f.StreamID, "byte_offset": f.ByteOffset}
+	case *logging.BlockedFrame:
+		return frame{"frame_type": "blocked", "stream_id": f.StreamID}
+	case *logging.RstStreamFrame:
+		return frame{"frame_type": "rst_stream", "stream_id": f.StreamID, "error_code": f.ErrorCode, "byte_offset": f.ByteOffset}
+	case *logging.ConnectionCloseFrame:
+		return frame{"frame_type": "connection_close", "error_code": f.ErrorCode, "reason": f.ReasonPhrase}
+	case *logging.GoawayFrame:
+		return frame{"frame_type": "goaway", "error_code": f.ErrorCode, "last_good_stream": f.LastGoodStream, "last_good_uni_stream": f.LastGoodUniStream, "reason": f.ReasonPhrase}
+	case *logging.PingFrame:
+		return frame{"frame_type": "ping"}
+	case *logging.AddAddressFrame:
+		return frame{"frame_type": "add_address", "ip_version": f.IPVersion, "address": f.Addr.String()}
+	case *logging.PathsFrame:
+		paths := make([]map[string]interface{}, len(f.PathIDs))
+		for i, pathID := range f.PathIDs {
+			paths[i] = map[string]interface{}{"path_id": pathID}
+			if i < len(f.RemoteRTTs) {
+				paths[i]["remote_rtt"] = milliseconds(f.RemoteRTTs[i])
+			}
+		}
+		return frame{"frame_type": "paths", "max_num_paths": f.MaxNumPaths, "paths": paths}
+	}
+	return frame{"frame_type": fmt.Sprintf("%T", f)}
+}
+
+// ackRanges converts the ACK ranges to [first, last] pairs, starting with the highest range
+func ackRanges(lowestAcked, largestAcked logging.PacketNumber, ranges []logging.AckRange) [][2]logging.PacketNumber {
+	if len(ranges) == 0 {
+		return [][2]logging.PacketNumber{{lowestAcked, largestAcked}}
+	}
+	r := make([][2]logging.PacketNumber, len(ranges))
+	for i, ackRange := range ranges {
+		r[i] = [2]logging.PacketNumber{ackRange.First, ackRange.Last}
+	}
+	return r
+}
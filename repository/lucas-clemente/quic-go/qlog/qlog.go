@@ -0,0 +1,201 @@
+// Package qlog writes the events of QUIC connections to qlog files, in the JSON-SEQ format.
+// Every record is prefixed by the record separator (0x1e) and terminated by a newline.
+// The first record describes the trace, every following record is an event.
+// The events of a path carry its ID in the path_id field.
+package qlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/utils"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/logging"
+)
+
+const recordSeparator = 0x1e
+
+type tracer struct {
+	getLogWriter func(p logging.Perspective, connID logging.ConnectionID) io.WriteCloser
+}
+
+var _ logging.Tracer = &tracer{}
+
+// NewTracer creates a tracer that writes a qlog file for every connection.
+// getLogWriter is called for every new connection. If it returns nil, the connection is not traced.
+// The writer is closed when the connection is closed.
+func NewTracer(getLogWriter func(p logging.Perspective, connID logging.ConnectionID) io.WriteCloser) logging.Tracer {
+	return &tracer{getLogWriter: getLogWriter}
+}
+
+func (t *tracer) TracerForConnection(p logging.Perspective, connID logging.ConnectionID) logging.ConnectionTracer {
+	if w := t.getLogWriter(p, connID); w != nil {
+		return NewConnectionTracer(w, p, connID)
+	}
+	return nil
+}
+
+type connectionTracer struct {
+	mutex sync.Mutex
+
+	w             io.WriteCloser
+	buf           *bufio.Writer
+	referenceTime time.Time
+	// set when writing failed or the tracer was closed, no more events are written then
+	done bool
+}
+
+var _ logging.ConnectionTracer = &connectionTracer{}
+
+// NewConnectionTracer creates a tracer that writes the qlog of a connection to w.
+func NewConnectionTracer(w io.WriteCloser, p logging.Perspective, connID logging.ConnectionID) logging.ConnectionTracer {
+	t := &connectionTracer{
+		w:             w,
+		buf:           bufio.NewWriter(w),
+		referenceTime: time.Now(),
+	}
+	vantagePoint := "server"
+	if p == protocol.PerspectiveClient {
+		vantagePoint = "client"
+	}
+	t.mutex.Lock()
+	t.writeRecord(&header{
+		QlogVersion: "draft-02",
+		QlogFormat:  "JSON-SEQ",
+		Title:       "mpquic qlog",
+		Trace: trace{
+			VantagePoint: vantagePoint,
+			CommonFields: commonFields{
+				GroupID:       fmt.Sprintf("%x", uint64(connID)),
+				ReferenceTime: float64(t.referenceTime.UnixNano()) / float64(time.Millisecond),
+				TimeFormat:    "relative",
+			},
+		},
+	})
+	t.mutex.Unlock()
+	return t
+}
+
+func (t *connectionTracer) StartedConnection(version logging.VersionNumber) {
+	t.recordEvent(&eventConnectionStarted{Version: version.String()})
+}
+
+func (t *connectionTracer) CompletedHandshake() {
+	t.recordEvent(&eventHandshakeCompleted{})
+}
+
+func (t *connectionTracer) ClosedConnection(err error) {
+	ev := &eventConnectionClosed{}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	t.recordEvent(ev)
+}
+
+func (t *connectionTracer) SentPacket(pathID logging.PathID, packetNumber logging.PacketNumber, size logging.ByteCount, encLevel logging.EncryptionLevel, frames []logging.Frame) {
+	t.recordEvent(&eventPacketSent{packet: newPacket(pathID, packetNumber, size, encLevel, frames)})
+}
+
+func (t *connectionTracer) ReceivedPacket(pathID logging.PathID, packetNumber logging.PacketNumber, size logging.ByteCount, encLevel logging.EncryptionLevel, frames []logging.Frame) {
+	t.recordEvent(&eventPacketReceived{packet: newPacket(pathID, packetNumber, size, encLevel, frames)})
+}
+
+func (t *connectionTracer) DroppedPacket(pathID logging.PathID, size logging.ByteCount, reason logging.PacketDropReason) {
+	t.recordEvent(&eventPacketDropped{PathID: pathID, PacketSize: size, Trigger: reason.String()})
+}
+
+func (t *connectionTracer) LostPacket(pathID logging.PathID, packetNumber logging.PacketNumber, reason logging.PacketLossReason) {
+	t.recordEvent(&eventPacketLost{PathID: pathID, PacketNumber: packetNumber, Trigger: reason.String()})
+}
+
+func (t *connectionTracer) UpdatedMetrics(pathID logging.PathID, rttStats *logging.RTTStats, cwnd, bytesInFlight logging.ByteCount) {
+	t.recordEvent(&eventMetricsUpdated{
+		PathID:           pathID,
+		MinRTT:           milliseconds(rttStats.MinRTT()),
+		SmoothedRTT:      milliseconds(rttStats.SmoothedRTT()),
+		LatestRTT:        milliseconds(rttStats.LatestRTT()),
+		RTTVariance:      milliseconds(rttStats.MeanDeviation()),
+		CongestionWindow: cwnd,
+		BytesInFlight:    bytesInFlight,
+	})
+}
+
+// UpdatedRTT doesn't record an event, the changes of the RTT estimates are recorded by UpdatedMetrics
+func (t *connectionTracer) UpdatedRTT(logging.PathID, *logging.RTTStats) {}
+
+func (t *connectionTracer) UpdatedPathState(pathID logging.PathID, state logging.PathState) {
+	t.recordEvent(&eventPathStateUpdated{PathID: pathID, State: state.String()})
+}
+
+func (t *connectionTracer) SelectedPath(pathID logging.PathID, reason logging.SchedulingReason) {
+	t.recordEvent(&eventPathSelected{PathID: pathID, Reason: reason.String()})
+}
+
+func (t *connectionTracer) ReinjectedPackets(pathID logging.PathID, count int) {
+	t.recordEvent(&eventPacketsReinjected{PathID: pathID, Count: count})
+}
+
+func (t *connectionTracer) DuplicatedPacket(pathID logging.PathID, packetNumber logging.PacketNumber) {
+	t.recordEvent(&eventPacketDuplicated{PathID: pathID, PacketNumber: packetNumber})
+}
+
+func (t *connectionTracer) FilledStreamGap(streamID logging.StreamID, offset, length logging.ByteCount) {
+	t.recordEvent(&eventStreamGapFilled{StreamID: streamID, Offset: offset, Length: length})
+}
+
+// Close flushes the events and closes the writer.
+// Events recorded afterwards are dropped.
+func (t *connectionTracer) Close() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.done {
+		return
+	}
+	t.done = true
+	if err := t.buf.Flush(); err != nil {
+		utils.Errorf("qlog: writing the events failed: %s", err.Error())
+	}
+	if err := t.w.Close(); err != nil {
+		utils.Errorf("qlog: closing the writer failed: %s", err.Error())
+	}
+}
+
+func (t *connectionTracer) recordEvent(details eventDetails) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.done {
+		return
+	}
+	t.writeRecord(&event{
+		Time: milliseconds(time.Since(t.referenceTime)),
+		Name: details.Name(),
+		Data: details,
+	})
+}
+
+// writeRecord writes a JSON-SEQ record
+// the mutex must be held
+func (t *connectionTracer) writeRecord(record interface{}) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		utils.Errorf("qlog: encoding the event failed: %s", err.Error())
+		return
+	}
+	t.buf.WriteByte(recordSeparator)
+	t.buf.Write(data)
+	if err := t.buf.WriteByte('\n'); err != nil {
+		utils.Errorf("qlog: writing the events failed: %s", err.Error())
+		t.done = true
+		t.w.Close()
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
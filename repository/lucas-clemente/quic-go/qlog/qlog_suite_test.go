@@ -0,0 +1,13 @@
+package qlog
+
+import (
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+
+	"testing"
+)
+
+func TestQlog(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "qlog Suite")
+}
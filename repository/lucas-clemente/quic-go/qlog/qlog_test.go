@@ -0,0 +1,280 @@
+package qlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/congestion"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/wire"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/logging"
+
+	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
+	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
+)
+
+type bufferWriteCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferWriteCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+type failingWriteCloser struct {
+	closed bool
+}
+
+func (w *failingWriteCloser) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+func (w *failingWriteCloser) Close() error {
+	w.closed = true
+	return nil
+}
+
+var _ = Describe("qlog", func() {
+	var (
+		buf    *bufferWriteCloser
+		tracer logging.ConnectionTracer
+	)
+
+	// records splits the JSON-SEQ output into its records
+	records := func() []map[string]interface{} {
+		data := buf.Bytes()
+		ExpectWithOffset(1, data).ToNot(BeEmpty())
+		ExpectWithOffset(1, data[0]).To(Equal(byte(recordSeparator)))
+		var recs []map[string]interface{}
+		for _, r := range bytes.Split(data[1:], []byte{recordSeparator}) {
+			ExpectWithOffset(1, r[len(r)-1]).To(Equal(byte('\n')))
+			var rec map[string]interface{}
+			ExpectWithOffset(1, json.Unmarshal(r, &rec)).To(Succeed())
+			recs = append(recs, rec)
+		}
+		return recs
+	}
+
+	// lastEvent returns the name and the data of the last event
+	lastEvent := func() (string, map[string]interface{}) {
+		tracer.Close()
+		recs := records()
+		ev := recs[len(recs)-1]
+		ExpectWithOffset(1, ev).To(HaveKey("time"))
+		return ev["name"].(string), ev["data"].(map[string]interface{})
+	}
+
+	BeforeEach(func() {
+		buf = &bufferWriteCloser{}
+		tracer = NewConnectionTracer(buf, protocol.PerspectiveClient, 0xdecafbad)
+	})
+
+	It("writes the header", func() {
+		tracer.Close()
+		recs := records()
+		Expect(recs).To(HaveLen(1))
+		Expect(recs[0]).To(HaveKeyWithValue("qlog_format", "JSON-SEQ"))
+		trace := recs[0]["trace"].(map[string]interface{})
+		Expect(trace).To(HaveKeyWithValue("vantage_point", "client"))
+		commonFields := trace["common_fields"].(map[string]interface{})
+		Expect(commonFields).To(HaveKeyWithValue("group_id", "decafbad"))
+		Expect(commonFields).To(HaveKeyWithValue("time_format", "relative"))
+	})
+
+	It("flushes and closes the writer when it is closed", func() {
+		tracer.StartedConnection(protocol.VersionWhatever)
+		Expect(buf.closed).To(BeFalse())
+		tracer.Close()
+		Expect(buf.closed).To(BeTrue())
+		Expect(records()).To(HaveLen(2))
+	})
+
+	It("drops the events recorded after it was closed", func() {
+		tracer.Close()
+		tracer.UpdatedPathState(1, logging.PathStateClosed)
+		tracer.Close()
+		Expect(records()).To(HaveLen(1))
+	})
+
+	It("stops writing if the writer fails", func() {
+		w := &failingWriteCloser{}
+		tracer = NewConnectionTracer(w, protocol.PerspectiveServer, 1)
+		for i := 0; i < 1000; i++ {
+			tracer.SelectedPath(1, logging.SchedulingLowestRTT)
+		}
+		Expect(w.closed).To(BeTrue())
+		Expect(tracer.(*connectionTracer).done).To(BeTrue())
+	})
+
+	It("records the events of multiple goroutines", func() {
+		var wg sync.WaitGroup
+		for i := 1; i <= 4; i++ {
+			wg.Add(1)
+			go func(pathID logging.PathID) {
+				defer GinkgoRecover()
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					tracer.SelectedPath(pathID, logging.SchedulingLowestRTT)
+				}
+			}(logging.PathID(i))
+		}
+		wg.Wait()
+		tracer.Close()
+		Expect(records()).To(HaveLen(401))
+	})
+
+	It("records the connection close", func() {
+		tracer.ClosedConnection(errors.New("connection timed out"))
+		name, data := lastEvent()
+		Expect(name).To(Equal("transport:connection_closed"))
+		Expect(data).To(HaveKeyWithValue("path_id", BeEquivalentTo(0)))
+		Expect(data).To(HaveKeyWithValue("error", "connection timed out"))
+	})
+
+	It("records the completion of the handshake", func() {
+		tracer.CompletedHandshake()
+		name, data := lastEvent()
+		Expect(name).To(Equal("transport:handshake_completed"))
+		Expect(data).To(HaveKeyWithValue("path_id", BeEquivalentTo(0)))
+	})
+
+	It("records dropped packets", func() {
+		tracer.DroppedPacket(2, 1350, logging.PacketDropQueueFull)
+		name, data := lastEvent()
+		Expect(name).To(Equal("transport:packet_dropped"))
+		Expect(data).To(HaveKeyWithValue("path_id", BeEquivalentTo(2)))
+		Expect(data).To(HaveKeyWithValue("packet_size", BeEquivalentTo(1350)))
+		Expect(data).To(HaveKeyWithValue("trigger", "queue_full"))
+	})
+
+	It("records the gaps of unreliable streams that were filled", func() {
+		tracer.FilledStreamGap(5, 1000, 200)
+		name, data := lastEvent()
+		Expect(name).To(Equal("transport:stream_gap_filled"))
+		Expect(data).To(HaveKeyWithValue("path_id", BeEquivalentTo(0)))
+		Expect(data).To(HaveKeyWithValue("stream_id", BeEquivalentTo(5)))
+		Expect(data).To(HaveKeyWithValue("offset", BeEquivalentTo(1000)))
+		Expect(data).To(HaveKeyWithValue("length", BeEquivalentTo(200)))
+	})
+
+	It("records sent packets with their frames", func() {
+		tracer.SentPacket(3, 42, 1234, protocol.EncryptionForwardSecure, []logging.Frame{
+			&wire.StreamFrame{StreamID: 5, Offset: 100, Data: []byte("foobar"), FinBit: true},
+			&wire.AckFrame{PathID: 1, LowestAcked: 10, LargestAcked: 20, AckRanges: []wire.AckRange{{First: 15, Last: 20}, {First: 10, Last: 12}}},
+			&wire.AddAddressFrame{IPVersion: 4, Addr: net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 4242}},
+		})
+		name, data := lastEvent()
+		Expect(name).To(Equal("transport:packet_sent"))
+		Expect(data).To(HaveKeyWithValue("path_id", BeEquivalentTo(3)))
+		Expect(data).To(HaveKeyWithValue("packet_number", BeEquivalentTo(42)))
+		Expect(data).To(HaveKeyWithValue("packet_size", BeEquivalentTo(1234)))
+		Expect(data).To(HaveKeyWithValue("encryption_level", "forward_secure"))
+		frames := data["frames"].([]interface{})
+		Expect(frames).To(HaveLen(3))
+		Expect(frames[0]).To(And(
+			HaveKeyWithValue("frame_type", "stream"),
+			HaveKeyWithValue("stream_id", BeEquivalentTo(5)),
+			HaveKeyWithValue("offset", BeEquivalentTo(100)),
+			HaveKeyWithValue("length", BeEquivalentTo(6)),
+			HaveKeyWithValue("fin", true),
+		))
+		Expect(frames[1]).To(And(
+			HaveKeyWithValue("frame_type", "ack"),
+			HaveKeyWithValue("acked_path_id", BeEquivalentTo(1)),
+			HaveKeyWithValue("acked_ranges", Equal([]interface{}{
+				[]interface{}{float64(15), float64(20)},
+				[]interface{}{float64(10), float64(12)},
+			})),
+		))
+		Expect(frames[2]).To(And(
+			HaveKeyWithValue("frame_type", "add_address"),
+			HaveKeyWithValue("address", "127.0.0.1:4242"),
+		))
+	})
+
+	It("records received packets", func() {
+		tracer.ReceivedPacket(2, 7, 100, protocol.EncryptionUnencrypted, []logging.Frame{
+			&wire.AckFrame{PathID: 2, LowestAcked: 1, LargestAcked: 3},
+			&wire.PathsFrame{MaxNumPaths: 4, NumPaths: 1, PathIDs: []protocol.PathID{2}, RemoteRTTs: []time.Duration{20 * time.Millisecond}},
+		})
+		name, data := lastEvent()
+		Expect(name).To(Equal("transport:packet_received"))
+		Expect(data).To(HaveKeyWithValue("path_id", BeEquivalentTo(2)))
+		Expect(data).To(HaveKeyWithValue("encryption_level", "unencrypted"))
+		frames := data["frames"].([]interface{})
+		Expect(frames[0]).To(HaveKeyWithValue("acked_ranges", Equal([]interface{}{[]interface{}{float64(1), float64(3)}})))
+		Expect(frames[1]).To(HaveKeyWithValue("paths", Equal([]interface{}{
+			map[string]interface{}{"path_id": float64(2), "remote_rtt": float64(20)},
+		})))
+	})
+
+	It("records lost packets", func() {
+		tracer.LostPacket(1, 1337, logging.PacketLossRetransmissionTimeout)
+		name, data := lastEvent()
+		Expect(name).To(Equal("recovery:packet_lost"))
+		Expect(data).To(HaveKeyWithValue("path_id", BeEquivalentTo(1)))
+		Expect(data).To(HaveKeyWithValue("packet_number", BeEquivalentTo(1337)))
+		Expect(data).To(HaveKeyWithValue("trigger", "retransmission_timeout"))
+	})
+
+	It("records the metrics", func() {
+		rttStats := &congestion.RTTStats{}
+		rttStats.UpdateRTT(50*time.Millisecond, 0, time.Now())
+		tracer.UpdatedMetrics(5, rttStats, 12345, 1000)
+		name, data := lastEvent()
+		Expect(name).To(Equal("recovery:metrics_updated"))
+		Expect(data).To(HaveKeyWithValue("path_id", BeEquivalentTo(5)))
+		Expect(data).To(HaveKeyWithValue("smoothed_rtt", BeEquivalentTo(50)))
+		Expect(data).To(HaveKeyWithValue("latest_rtt", BeEquivalentTo(50)))
+		Expect(data).To(HaveKeyWithValue("congestion_window", BeEquivalentTo(12345)))
+		Expect(data).To(HaveKeyWithValue("bytes_in_flight", BeEquivalentTo(1000)))
+	})
+
+	It("records path state changes", func() {
+		tracer.UpdatedPathState(3, logging.PathStatePotentiallyFailed)
+		name, data := lastEvent()
+		Expect(name).To(Equal("multipath:path_state_updated"))
+		Expect(data).To(HaveKeyWithValue("path_id", BeEquivalentTo(3)))
+		Expect(data).To(HaveKeyWithValue("state", "potentially_failed"))
+	})
+
+	It("records scheduling decisions", func() {
+		tracer.SelectedPath(1, logging.SchedulingUnprobedPath)
+		tracer.ReinjectedPackets(3, 10)
+		tracer.DuplicatedPacket(1, 8)
+		tracer.Close()
+		recs := records()
+		Expect(recs).To(HaveLen(4))
+		Expect(recs[1]).To(HaveKeyWithValue("name", "multipath:path_selected"))
+		Expect(recs[1]["data"]).To(Equal(map[string]interface{}{"path_id": float64(1), "reason": "unprobed_path"}))
+		Expect(recs[2]).To(HaveKeyWithValue("name", "multipath:packets_reinjected"))
+		Expect(recs[2]["data"]).To(Equal(map[string]interface{}{"path_id": float64(3), "count": float64(10)}))
+		Expect(recs[3]).To(HaveKeyWithValue("name", "multipath:packet_duplicated"))
+		Expect(recs[3]["data"]).To(Equal(map[string]interface{}{"path_id": float64(1), "packet_number": float64(8)}))
+	})
+
+	Context("creating tracers", func() {
+		It("creates a tracer for every connection", func() {
+			var perspective logging.Perspective
+			var connID logging.ConnectionID
+			t := NewTracer(func(p logging.Perspective, c logging.ConnectionID) io.WriteCloser {
+				perspective = p
+				connID = c
+				return buf
+			})
+			connTracer := t.TracerForConnection(protocol.PerspectiveServer, 0x1337)
+			Expect(connTracer).ToNot(BeNil())
+			Expect(perspective).To(Equal(protocol.PerspectiveServer))
+			Expect(connID).To(Equal(logging.ConnectionID(0x1337)))
+		})
+
+		It("doesn't trace the connection if there is no writer", func() {
+			t := NewTracer(func(logging.Perspective, logging.ConnectionID) io.WriteCloser { return nil })
+			Expect(t.TracerForConnection(protocol.PerspectiveClient, 1)).To(BeNil())
+		})
+	})
+})
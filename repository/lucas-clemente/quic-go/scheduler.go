@@ -1,13 +1,13 @@
 package quic
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/ackhandler"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/utils"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/wire"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/logging"
 )
 
 type scheduler struct {
@@ -15,7 +15,6 @@ type scheduler struct {
 	quotas  map[protocol.PathID]uint
 	quotas2 map[protocol.PathID]uint
 	sess    *session
-	cnt     int
 }
 
 func (sch *scheduler) setup() {
@@ -50,6 +49,11 @@ func (sch *scheduler) getRetransmission(s *session) (hasRetransmission bool, ret
 
 		if retransmitPacket.EncryptionLevel != protocol.EncryptionForwardSecure {
 			if s.handshakeComplete {
+				if s.perspective == protocol.PerspectiveClient && retransmitPacket.EncryptionLevel == protocol.EncryptionSecure {
+					// the client sent stream data with 0-RTT keys, it still has to arrive
+					sch.queueFramesForRetransmission(s, retransmitPacket, pth)
+					continue
+				}
 				// 当握手结束的时候，不能重传握手报文
 				// Don't retransmit handshake packets when the handshake is complete
 				continue
@@ -57,38 +61,97 @@ func (sch *scheduler) getRetransmission(s *session) (hasRetransmission bool, ret
 			utils.Debugf("\tDequeueing handshake retransmission for packet 0x%x", retransmitPacket.PacketNumber)
 			return
 		}
+		if retransmitPacket.Reinjected {
+			// The frames were already queued for retransmission on another path
+			continue
+		}
 		utils.Debugf("\tDequeueing retransmission of packet 0x%x from path %d", retransmitPacket.PacketNumber, pth.pathID)
 		// resend the frames that were in the packet
-		for _, frame := range retransmitPacket.GetFramesForRetransmission() { // 从该报文当中拿出所有的frame
-
-			switch f := frame.(type) { //丢失的报文可能包含多种的Frame类型
-			case *wire.StreamFrame: //如果是streamFrame的话，那么就判断其类型是否是unreliable的，如果是不可靠的话，那么就不需要重传
-				//sch.cnt += 1
-				//fmt.Println(sch.cnt)
-				if ok, _ := sch.sess.streamsMap.GetStreasmType(f.StreamID); ok {
-					fmt.Println("aaaa")
-					//不可靠传输的逻辑,直接什么也不做，放弃重传是否可行？，应该是不可以的，接收方的逻辑应该改变一下，一旦ack之后，那些确实的Frame应该不再管了
-					//s.streamFramer.AddFrameForRetransmission(f)
-				} else { //如果是可靠的话，那么就需要进行重传
-					s.streamFramer.AddFrameForRetransmission(f)
-				}
-				// todo windowUpdateFrame中的ByteOffset是什么意思？？
-			case *wire.WindowUpdateFrame: //如果是windowUpdate帧的话，
-				// only retransmit WindowUpdates if the stream is not yet closed and the we haven't sent another WindowUpdate with a higher ByteOffset for the stream
-				// XXX Should it be adapted to multiple paths?
-				currentOffset, err := s.flowControlManager.GetReceiveWindow(f.StreamID)
-				if err == nil && f.ByteOffset >= currentOffset {
-					s.packer.QueueControlFrame(f, pth)
-				}
-			case *wire.PathsFrame:
-				// Schedule a new PATHS frame to send
-				s.schedulePathsFrame()
-			default:
-				s.packer.QueueControlFrame(frame, pth)
+		sch.queueFramesForRetransmission(s, retransmitPacket, pth)
+	}
+	return
+}
+
+// queueFramesForRetransmission queues the frames of a packet to be sent again, on any path
+func (sch *scheduler) queueFramesForRetransmission(s *session, packet *ackhandler.Packet, pth *path) {
+	for _, frame := range packet.GetFramesForRetransmission() { // 从该报文当中拿出所有的frame
+
+		switch f := frame.(type) { //丢失的报文可能包含多种的Frame类型
+		case *wire.StreamFrame: //如果是streamFrame的话，那么就判断其类型是否是unreliable的，如果是不可靠的话，那么就不需要重传
+			if ok, _ := sch.sess.streamsMap.GetStreasmType(f.StreamID); ok {
+				//不可靠传输的逻辑,直接什么也不做，放弃重传是否可行？，应该是不可以的，接收方的逻辑应该改变一下，一旦ack之后，那些确实的Frame应该不再管了
+				//s.streamFramer.AddFrameForRetransmission(f)
+			} else { //如果是可靠的话，那么就需要进行重传
+				s.streamFramer.AddFrameForRetransmission(f)
+			}
+			// todo windowUpdateFrame中的ByteOffset是什么意思？？
+		case *wire.WindowUpdateFrame: //如果是windowUpdate帧的话，
+			// only retransmit WindowUpdates if the stream is not yet closed and the we haven't sent another WindowUpdate with a higher ByteOffset for the stream
+			// XXX Should it be adapted to multiple paths?
+			currentOffset, err := s.flowControlManager.GetReceiveWindow(f.StreamID)
+			if err == nil && f.ByteOffset >= currentOffset {
+				s.packer.QueueControlFrame(f, pth)
 			}
+		case *wire.PathsFrame:
+			// Schedule a new PATHS frame to send
+			s.schedulePathsFrame()
+		default:
+			// The control frame queue is not bound to a path, the frame is sent on the next path selected
+			s.packer.QueueControlFrame(frame, pth)
 		}
 	}
-	return
+}
+
+// reinjectStalledPaths queues the data in flight on stalled paths for retransmission on the other paths,
+// instead of waiting for the RTO of the stalled path. The receiver drops the duplicate data.
+// STREAM frames go to the retransmission queue of the stream framer, the other control frames to the control frame queue of the packer.
+// Both queues are shared by all paths, and the stalled path is marked as potentially failed, so the frames are sent on another path.
+// Handshake packets are never reinjected: they are only sent on the initial path, before the handshake completes,
+// and getRetransmission drops them once it has completed.
+// Lock of s.paths must be free
+func (sch *scheduler) reinjectStalledPaths(s *session) {
+	if !s.handshakeComplete {
+		return
+	}
+	now := time.Now()
+	s.pathsLock.RLock()
+	defer s.pathsLock.RUnlock()
+	for pathID, pth := range s.paths {
+		if pathID == protocol.InitialPathID || !pth.open.Get() {
+			continue
+		}
+		stalled, sentBefore := pth.stalled(now)
+		if !stalled || !sch.hasAlternatePath(s, pth) {
+			continue
+		}
+		packets := pth.sentPacketHandler.GetPacketsForReinjection(sentBefore)
+		if len(packets) == 0 {
+			continue
+		}
+		utils.Debugf("Reinjecting %d packets of stalled path %x", len(packets), pathID)
+		if s.tracer != nil {
+			s.tracer.ReinjectedPackets(pathID, len(packets))
+		}
+		// Don't send the reinjected data on the stalled path again
+		pth.setPotentiallyFailed(true)
+		for _, packet := range packets {
+			sch.queueFramesForRetransmission(s, packet, pth)
+		}
+	}
+}
+
+// hasAlternatePath checks if there is another path that can be used instead of pth
+// Lock of s.paths must be held
+func (sch *scheduler) hasAlternatePath(s *session, pth *path) bool {
+	for pathID, pthTmp := range s.paths {
+		if pathID == protocol.InitialPathID || pthTmp == pth {
+			continue
+		}
+		if pthTmp.open.Get() && !pthTmp.potentiallyFailed.Get() {
+			return true
+		}
+	}
+	return false
 }
 
 func (sch *scheduler) selectPathRoundRobin(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
@@ -195,14 +258,15 @@ pathLoop:
 }
 
 // 选择一条 低延迟的路径
-func (sch *scheduler) selectPathLowLatency(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
+// It also returns why the path was selected, to record the scheduling decision
+func (sch *scheduler) selectPathLowLatency(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) (*path, logging.SchedulingReason) {
 	// XXX Avoid using PathID 0 if there is more than 1 path
 	if len(s.paths) <= 1 {
 		// 如果没有重传，且0 Path不允许重传，就返回nil
 		if !hasRetransmission && !s.paths[protocol.InitialPathID].SendingAllowed() {
-			return nil
+			return nil, logging.SchedulingSinglePath
 		}
-		return s.paths[protocol.InitialPathID]
+		return s.paths[protocol.InitialPathID], logging.SchedulingSinglePath
 	}
 
 	// FIXME Only works at the beginning... Cope with new paths during the connection
@@ -215,12 +279,13 @@ func (sch *scheduler) selectPathLowLatency(s *session, hasRetransmission bool, h
 			}
 			// The congestion window was checked when duplicating the packet
 			if sch.quotas[pathID] < currentQuota {
-				return pth
+				return pth, logging.SchedulingUnprobedPath
 			}
 		}
 	}
 
 	var selectedPath *path
+	var reason logging.SchedulingReason
 	var lowerRTT time.Duration
 	var currentRTT time.Duration
 	selectedPathID := protocol.PathID(255)
@@ -272,21 +337,33 @@ pathLoop:
 		lowerRTT = currentRTT
 		selectedPath = pth
 		selectedPathID = pathID
+		if currentRTT == 0 {
+			reason = logging.SchedulingUnprobedPath
+		} else {
+			reason = logging.SchedulingLowestRTT
+		}
 	}
 
-	return selectedPath
+	return selectedPath, reason
 }
 
 // Lock of s.paths must be held
-func (sch *scheduler) selectPath(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) *path {
+func (sch *scheduler) selectPath(s *session, hasRetransmission bool, hasStreamRetransmission bool, fromPth *path) (*path, logging.SchedulingReason) {
 	// XXX Currently round-robin
 	// TODO select the right scheduler dynamically
 	return sch.selectPathLowLatency(s, hasRetransmission, hasStreamRetransmission, fromPth)
 	// return sch.selectPathRoundRobin(s, hasRetransmission, hasStreamRetransmission, fromPth)
 }
 
+// traceSelectedPath records the scheduling decision, it must only be called once a packet was packed for the path
+func (sch *scheduler) traceSelectedPath(s *session, pth *path, reason logging.SchedulingReason) {
+	if s.tracer != nil {
+		s.tracer.SelectedPath(pth.pathID, reason)
+	}
+}
+
 // Lock of s.paths must be free (in case of log print)
-func (sch *scheduler) performPacketSending(s *session, windowUpdateFrames []*wire.WindowUpdateFrame, pth *path) (*ackhandler.Packet, bool, error) {
+func (sch *scheduler) performPacketSending(s *session, windowUpdateFrames []*wire.WindowUpdateFrame, pth *path, reason logging.SchedulingReason) (*ackhandler.Packet, bool, error) {
 	// add a retransmittable frame
 	if pth.sentPacketHandler.ShouldSendRetransmittablePacket() {
 		s.packer.QueueControlFrame(&wire.PingFrame{}, pth)
@@ -295,6 +372,7 @@ func (sch *scheduler) performPacketSending(s *session, windowUpdateFrames []*wir
 	if err != nil || packet == nil {
 		return nil, false, err
 	}
+	sch.traceSelectedPath(s, pth, reason)
 	if err = s.sendPackedPacket(packet, pth); err != nil {
 		return nil, false, err
 	}
@@ -320,9 +398,10 @@ func (sch *scheduler) performPacketSending(s *session, windowUpdateFrames []*wir
 				s.pathsLock.RLock()
 				utils.Infof("Info for stream %x of %x", frame.StreamID, s.connectionID)
 				for pathID, pth := range s.paths {
-					sntPkts, sntRetrans, sntLost := pth.sentPacketHandler.GetStatistics()
-					rcvPkts := pth.receivedPacketHandler.GetStatistics()
-					utils.Infof("Path %x: sent %d retrans %d lost %d; rcv %d rtt %v", pathID, sntPkts, sntRetrans, sntLost, rcvPkts, pth.rttStats.SmoothedRTT())
+					stats := pth.stats()
+					reorderings, reorderingDegree := pth.sentPacketHandler.GetReorderingStatistics()
+					spuriousLosses, spuriousRTOs := pth.sentPacketHandler.GetSpuriousRetransmissionStatistics()
+					utils.Infof("Path %x: sent %d retrans %d lost %d (spurious %d, spurious RTOs %d) reordered %d (degree %d); rcv %d rtt %v", pathID, stats.PacketsSent, stats.PacketsRetransmitted, stats.PacketsLost, spuriousLosses, spuriousRTOs, reorderings, reorderingDegree, stats.PacketsReceived, stats.SmoothedRTT)
 				}
 				s.pathsLock.RUnlock()
 			}
@@ -348,30 +427,38 @@ func (sch *scheduler) ackRemainingPaths(s *session, totalWindowUpdateFrames []*w
 	// not be an issue.
 	s.pathsLock.RLock()
 	defer s.pathsLock.RUnlock()
+	sch.updateStreamPaths(s)
 	// get WindowUpdate frames
 	// this call triggers the flow controller to increase the flow control windows, if necessary
 	windowUpdateFrames := totalWindowUpdateFrames
 	if len(windowUpdateFrames) == 0 {
 		windowUpdateFrames = s.getWindowUpdateFrames(s.peerBlocked)
 	}
+	// The ACKs of a path may be sent on another one, so first queue all of them
+	hasAck := make(map[protocol.PathID]bool)
+	for _, pthTmp := range s.paths {
+		if ackTmp := pthTmp.GetAckFrame(); ackTmp != nil {
+			ackPth := sch.ackPathFor(s, pthTmp)
+			s.packer.QueueControlFrame(ackTmp, ackPth)
+			hasAck[ackPth.pathID] = true
+		}
+	}
 	for _, pthTmp := range s.paths {
-		ackTmp := pthTmp.GetAckFrame()
 		for _, wuf := range windowUpdateFrames {
 			s.packer.QueueControlFrame(wuf, pthTmp)
 		}
-		if ackTmp != nil || len(windowUpdateFrames) > 0 {
-			if pthTmp.pathID == protocol.InitialPathID && ackTmp == nil {
+		if hasAck[pthTmp.pathID] || len(windowUpdateFrames) > 0 {
+			if pthTmp.pathID == protocol.InitialPathID && !hasAck[pthTmp.pathID] {
 				continue
 			}
 			swf := pthTmp.GetStopWaitingFrame(false)
 			if swf != nil {
 				s.packer.QueueControlFrame(swf, pthTmp)
 			}
-			s.packer.QueueControlFrame(ackTmp, pthTmp)
 			// XXX (QDC) should we instead call PackPacket to provides WUFs?
 			var packet *packedPacket
 			var err error
-			if ackTmp != nil {
+			if hasAck[pthTmp.pathID] {
 				// Avoid internal error bug
 				packet, err = s.packer.PackAckPacket(pthTmp)
 			} else {
@@ -390,6 +477,119 @@ func (sch *scheduler) ackRemainingPaths(s *session, totalWindowUpdateFrames []*w
 	return nil
 }
 
+// ackPathFor returns the path on which the ACKs for the given path are sent, according to the ACK path policy.
+// The ACKs are only sent on another path if the peer accepts them there.
+// Lock of s.paths must be held
+func (sch *scheduler) ackPathFor(s *session, pth *path) *path {
+	if s.config.AckPathPolicy != AckOnLowestRTTPath || !s.handshakeComplete || pth.pathID == protocol.InitialPathID {
+		return pth
+	}
+	if !s.connectionParameters.PeerAcceptsAcksOnOtherPaths() {
+		return pth
+	}
+	lowestRTTPath := sch.lowestRTTPath(s)
+	if lowestRTTPath == nil {
+		return pth
+	}
+	// Paths without RTT estimate are not used, unless it is the path itself
+	rtt := pth.rttStats.SmoothedRTT()
+	if rtt != 0 && lowestRTTPath.rttStats.SmoothedRTT() >= rtt {
+		return pth
+	}
+	return lowestRTTPath
+}
+
+// lowestRTTPath returns the open path with the lowest RTT, or nil if no path has an RTT estimate yet
+// Lock of s.paths must be held
+func (sch *scheduler) lowestRTTPath(s *session) *path {
+	var lowestRTTPath *path
+	var lowestRTT time.Duration
+	for pathID, pth := range s.paths {
+		if pathID == protocol.InitialPathID || !pth.open.Get() || pth.potentiallyFailed.Get() {
+			continue
+		}
+		rtt := pth.rttStats.SmoothedRTT()
+		if rtt == 0 {
+			continue
+		}
+		if lowestRTTPath == nil || rtt < lowestRTT {
+			lowestRTTPath = pth
+			lowestRTT = rtt
+		}
+	}
+	return lowestRTTPath
+}
+
+// updateStreamPaths tells the stream framer which paths can be used to send the data of the streams
+// Lock of s.paths must be held
+func (sch *scheduler) updateStreamPaths(s *session) {
+	if len(s.paths) <= 1 {
+		s.streamFramer.SetStreamPaths(nil, nil)
+		return
+	}
+	usablePaths := make(map[protocol.PathID]bool)
+	for pathID, pth := range s.paths {
+		if pathID == protocol.InitialPathID || !pth.open.Get() || pth.potentiallyFailed.Get() {
+			continue
+		}
+		usablePaths[pathID] = true
+	}
+	s.streamFramer.SetStreamPaths(usablePaths, sch.lowestRTTPath(s))
+}
+
+// sendOnOtherPaths tries to send a packet on the paths other than the given one,
+// for the streams that prefer other paths than the selected one
+// Lock of s.paths must be free
+func (sch *scheduler) sendOnOtherPaths(s *session, pth *path) (*ackhandler.Packet, *path, bool, error) {
+	var otherPaths []*path
+	s.pathsLock.RLock()
+	if len(s.paths) > 1 {
+		for pathID, pthTmp := range s.paths {
+			if pathID == protocol.InitialPathID || pathID == pth.pathID || !pthTmp.SendingAllowed() || pthTmp.potentiallyFailed.Get() {
+				continue
+			}
+			otherPaths = append(otherPaths, pthTmp)
+		}
+	}
+	s.pathsLock.RUnlock()
+	for _, pthTmp := range otherPaths {
+		pkt, sent, err := sch.performPacketSending(s, nil, pthTmp, logging.SchedulingStreamPreference)
+		if err != nil || sent {
+			return pkt, pthTmp, sent, err
+		}
+	}
+	return nil, pth, false, nil
+}
+
+// queueAcksForOtherPaths queues the ACKs of the other paths that are sent on the given path
+func (sch *scheduler) queueAcksForOtherPaths(s *session, pth *path) {
+	if s.config.AckPathPolicy == AckOnSamePath {
+		return
+	}
+	s.pathsLock.RLock()
+	defer s.pathsLock.RUnlock()
+	for pathID, pthTmp := range s.paths {
+		if pathID == pth.pathID || sch.ackPathFor(s, pthTmp) != pth {
+			continue
+		}
+		if ack := pthTmp.GetAckFrame(); ack != nil {
+			s.packer.QueueControlFrame(ack, pth)
+		}
+	}
+}
+
+// markApplicationLimited notifies the congestion controllers of the paths that could still send
+// that there is no more data, so that their window does not grow while it is not used
+func (sch *scheduler) markApplicationLimited(s *session) {
+	s.pathsLock.RLock()
+	defer s.pathsLock.RUnlock()
+	for _, pth := range s.paths {
+		if pth.open.Get() && pth.sentPacketHandler.SendingAllowed() {
+			pth.sentPacketHandler.SetApplicationLimited()
+		}
+	}
+}
+
 // 这个是供session调用选路的接口
 func (sch *scheduler) sendPacket(s *session) error {
 	var pth *path
@@ -400,6 +600,11 @@ func (sch *scheduler) sendPacket(s *session) error {
 		pthTmp.SetLeastUnacked(pthTmp.sentPacketHandler.GetLeastUnacked())
 	}
 	s.pathsLock.RUnlock()
+	// The flow control windows must allow the peer to fill all paths
+	s.updateBandwidthDelayProduct()
+
+	// Don't wait for the RTO of stalled paths to send their data on the other paths
+	sch.reinjectStalledPaths(s)
 
 	// get WindowUpdate frames
 	// this call triggers the flow controller to increase the flow control windows, if necessary
@@ -422,7 +627,9 @@ func (sch *scheduler) sendPacket(s *session) error {
 		// Select the path here
 		// 拿到最低延迟的路径
 		s.pathsLock.RLock()
-		pth = sch.selectPath(s, hasRetransmission, hasStreamRetransmission, fromPth)
+		sch.updateStreamPaths(s)
+		var reason logging.SchedulingReason
+		pth, reason = sch.selectPath(s, hasRetransmission, hasStreamRetransmission, fromPth)
 		s.pathsLock.RUnlock()
 
 		// XXX No more path available, should we have a new QUIC error message?
@@ -445,6 +652,7 @@ func (sch *scheduler) sendPacket(s *session) error {
 			s.pathsLock.RLock()
 			pth = sch.selectPathUnreliable(s, hasRetransmission, hasStreamRetransmission, fromPth)
 			s.pathsLock.RUnlock()
+			reason = logging.SchedulingUnreliable
 		}
 
 		// If we have an handshake packet retransmission, do it directly
@@ -454,6 +662,7 @@ func (sch *scheduler) sendPacket(s *session) error {
 			if err != nil {
 				return err
 			}
+			sch.traceSelectedPath(s, pth, reason)
 			if err = s.sendPackedPacket(packet, pth); err != nil {
 				return err
 			}
@@ -467,6 +676,7 @@ func (sch *scheduler) sendPacket(s *session) error {
 		if ack != nil {
 			s.packer.QueueControlFrame(ack, pth)
 		}
+		sch.queueAcksForOtherPaths(s, pth)
 		if ack != nil || hasStreamRetransmission {
 			swf := pth.sentPacketHandler.GetStopWaitingFrame(hasStreamRetransmission)
 			if swf != nil {
@@ -489,12 +699,21 @@ func (sch *scheduler) sendPacket(s *session) error {
 			s.packer.QueueControlFrame(pf, pth)
 		}
 		//传输报文
-		pkt, sent, err := sch.performPacketSending(s, windowUpdateFrames, pth)
+		pkt, sent, err := sch.performPacketSending(s, windowUpdateFrames, pth, reason)
 		if err != nil {
 			return err
 		}
 		windowUpdateFrames = nil
 		if !sent {
+			// Streams that prefer other paths may still have data to send
+			pkt, pth, sent, err = sch.sendOnOtherPaths(s, pth)
+			if err != nil {
+				return err
+			}
+		}
+		if !sent {
+			// The application ran out of data before the paths ran out of window
+			sch.markApplicationLimited(s)
 			// Prevent sending empty packets
 			return sch.ackRemainingPaths(s, windowUpdateFrames)
 		}
@@ -512,6 +731,9 @@ func (sch *scheduler) sendPacket(s *session) error {
 				if sch.quotas[pathID] < currentQuota && tmpPth.sentPacketHandler.SendingAllowed() {
 					// Duplicate it
 					pth.sentPacketHandler.DuplicatePacket(pkt)
+					if s.tracer != nil {
+						s.tracer.DuplicatedPacket(pth.pathID, pkt.PacketNumber)
+					}
 					break duplicateLoop
 				}
 			}
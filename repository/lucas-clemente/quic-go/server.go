@@ -32,8 +32,11 @@ type server struct {
 
 	pconnMgr *pconnManager
 
-	certChain crypto.CertChain
-	scfg      *handshake.ServerConfig
+	certChain  crypto.CertChain
+	scfg       *handshake.ServerConfig
+	scfgMutex  sync.RWMutex
+	stopRotate chan struct{}
+	closeOnce  sync.Once
 
 	sessions                  map[protocol.ConnectionID]packetHandler
 	sessionsMutex             sync.RWMutex
@@ -69,7 +72,7 @@ func ListenAddrImpl(addr string, tlsConf *tls.Config, config *Config, pconnMgrAr
 
 	if pconnMgrArg == nil {
 		// Create the pconnManager here. It will be used to start udp connections
-		pconnMgr = &pconnManager{perspective: protocol.PerspectiveServer}
+		pconnMgr = newPconnManager(protocol.PerspectiveServer, config)
 		// XXX (QDC): make this cleaner
 		pconn, err := net.ListenUDP("udp", udpAddr)
 		if err != nil {
@@ -93,7 +96,7 @@ func ListenAddrImpl(addr string, tlsConf *tls.Config, config *Config, pconnMgrAr
 // The tls.Config must not be nil, the quic.Config may be nil.
 func Listen(pconn net.PacketConn, tlsConf *tls.Config, config *Config) (Listener, error) {
 	// Create the pconnManager here. It will be used to start udp connections
-	pconnMgr := &pconnManager{perspective: protocol.PerspectiveServer}
+	pconnMgr := newPconnManager(protocol.PerspectiveServer, config)
 	err := pconnMgr.setup(pconn, nil)
 	if err != nil {
 		return nil, err
@@ -115,11 +118,13 @@ func ListenImpl(pconn net.PacketConn, tlsConf *tls.Config, config *Config, pconn
 	if err != nil {
 		return nil, err
 	}
+	populatedConfig := populateServerConfig(config)
+	scfg.SetZeroRTTReplayWindow(populatedConfig.ZeroRTTReplayWindow)
 
 	var pconnMgr *pconnManager
 
 	if pconnMgrArg == nil {
-		pconnMgr = &pconnManager{perspective: protocol.PerspectiveServer}
+		pconnMgr = newPconnManager(protocol.PerspectiveServer, config)
 		err := pconnMgr.setup(pconn, nil)
 		if err != nil {
 			return nil, err
@@ -131,7 +136,7 @@ func ListenImpl(pconn net.PacketConn, tlsConf *tls.Config, config *Config, pconn
 	s := &server{
 		pconnMgr:                  pconnMgr,
 		tlsConf:                   tlsConf,
-		config:                    populateServerConfig(config),
+		config:                    populatedConfig,
 		certChain:                 certChain,
 		scfg:                      scfg,
 		sessions:                  map[protocol.ConnectionID]packetHandler{},
@@ -139,8 +144,12 @@ func ListenImpl(pconn net.PacketConn, tlsConf *tls.Config, config *Config, pconn
 		deleteClosedSessionsAfter: protocol.ClosedSessionDeleteTimeout,
 		sessionQueue:              make(chan Session, 5),
 		errorChan:                 make(chan struct{}),
+		stopRotate:                make(chan struct{}),
 	}
 	go s.serve()
+	if populatedConfig.ServerConfigRotationInterval > 0 {
+		go s.rotateServerConfigs(populatedConfig.ServerConfigRotationInterval)
+	}
 	utils.Debugf("Listening for %s connections on %s", pconn.LocalAddr().Network(), pconn.LocalAddr().String())
 	return s, nil
 }
@@ -188,13 +197,14 @@ func populateServerConfig(config *Config) *Config {
 		idleTimeout = config.IdleTimeout
 	}
 
-	maxReceiveStreamFlowControlWindow := config.MaxReceiveStreamFlowControlWindow
-	if maxReceiveStreamFlowControlWindow == 0 {
-		maxReceiveStreamFlowControlWindow = protocol.DefaultMaxReceiveStreamFlowControlWindowServer
+	zeroRTTReplayWindow := protocol.DefaultZeroRTTReplayWindow
+	if config.ZeroRTTReplayWindow != 0 {
+		zeroRTTReplayWindow = config.ZeroRTTReplayWindow
 	}
-	maxReceiveConnectionFlowControlWindow := config.MaxReceiveConnectionFlowControlWindow
-	if maxReceiveConnectionFlowControlWindow == 0 {
-		maxReceiveConnectionFlowControlWindow = protocol.DefaultMaxReceiveConnectionFlowControlWindowServer
+
+	unreliableSendQueueSize := config.UnreliableSendQueueSize
+	if unreliableSendQueueSize == 0 && config.UnreliableSendQueuePolicy != SendQueueBlock {
+		unreliableSendQueueSize = protocol.DefaultUnreliableSendQueueSize
 	}
 
 	return &Config{
@@ -202,9 +212,22 @@ func populateServerConfig(config *Config) *Config {
 		HandshakeTimeout:                      handshakeTimeout,
 		IdleTimeout:                           idleTimeout,
 		AcceptCookie:                          vsa,
+		Accept0RTT:                            config.Accept0RTT,
+		ZeroRTTReplayWindow:                   zeroRTTReplayWindow,
+		ServerConfigRotationInterval:          config.ServerConfigRotationInterval,
 		KeepAlive:                             config.KeepAlive,
-		MaxReceiveStreamFlowControlWindow:     maxReceiveStreamFlowControlWindow,
-		MaxReceiveConnectionFlowControlWindow: maxReceiveConnectionFlowControlWindow,
+		MaxReceiveStreamFlowControlWindow:     config.MaxReceiveStreamFlowControlWindow,
+		MaxReceiveConnectionFlowControlWindow: config.MaxReceiveConnectionFlowControlWindow,
+		ReorderingTolerance:                   config.ReorderingTolerance,
+		AckPathPolicy:                         config.AckPathPolicy,
+		AckSendDelay:                          config.AckSendDelay,
+		RetransmittablePacketsBeforeAck:       config.RetransmittablePacketsBeforeAck,
+		StreamSendBufferSize:                  config.StreamSendBufferSize,
+		UnreliableSendQueueSize:               unreliableSendQueueSize,
+		UnreliableSendQueuePolicy:             config.UnreliableSendQueuePolicy,
+		Tracer:                                config.Tracer,
+		KeyLogWriter:                          config.KeyLogWriter,
+		PacketCapture:                         config.PacketCapture,
 	}
 }
 
@@ -225,6 +248,46 @@ func (s *server) serve() {
 	}
 }
 
+// rotateServerConfigs replaces the server config in regular intervals, until the server is closed
+func (s *server) rotateServerConfigs(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopRotate:
+			return
+		case <-ticker.C:
+			if err := s.rotateServerConfig(); err != nil {
+				utils.Errorf("error rotating the server config: %s", err.Error())
+			}
+		}
+	}
+}
+
+// rotateServerConfig replaces the server config and the secret used to encrypt STKs.
+// Sessions that were already created keep using the server config they were created with.
+func (s *server) rotateServerConfig() error {
+	kex, err := crypto.NewCurve25519KEX()
+	if err != nil {
+		return err
+	}
+	s.scfgMutex.Lock()
+	defer s.scfgMutex.Unlock()
+	scfg, err := s.scfg.Rotate(kex)
+	if err != nil {
+		return err
+	}
+	utils.Debugf("Rotated the server config, new SCID: %x", scfg.ID)
+	s.scfg = scfg
+	return nil
+}
+
+func (s *server) getServerConfig() *handshake.ServerConfig {
+	s.scfgMutex.RLock()
+	defer s.scfgMutex.RUnlock()
+	return s.scfg
+}
+
 // Accept returns newly openend sessions
 func (s *server) Accept() (Session, error) {
 	var sess Session
@@ -238,6 +301,12 @@ func (s *server) Accept() (Session, error) {
 
 // Close the server
 func (s *server) Close() error {
+	s.closeOnce.Do(func() {
+		if s.stopRotate != nil {
+			close(s.stopRotate)
+		}
+	})
+
 	s.sessionsMutex.Lock()
 	for _, session := range s.sessions {
 		if session != nil {
@@ -360,7 +429,7 @@ func (s *server) handlePacket(rcvRawPacket *receivedRawPacket) error {
 			s.config.CreatePaths,
 			version,
 			hdr.ConnectionID,
-			s.scfg,
+			s.getServerConfig(),
 			s.tlsConf,
 			s.config,
 		)
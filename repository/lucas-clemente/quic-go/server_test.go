@@ -43,6 +43,10 @@ func (s *mockSession) run() error {
 func (s *mockSession) WaitUntilHandshakeComplete() error {
 	return <-s.handshakeComplete
 }
+func (s *mockSession) EarlyDataRejected() <-chan struct{} { panic("not implemented") }
+func (s *mockSession) CloseGracefully(time.Duration) error {
+	panic("not implemented")
+}
 func (s *mockSession) Close(e error) error {
 	if s.closed {
 		return nil
@@ -61,12 +65,16 @@ func (s *mockSession) closeRemote(e error) {
 func (s *mockSession) OpenStream() (Stream, error) {
 	return &stream{streamID: 1337}, nil
 }
-func (s *mockSession) AcceptStream() (Stream, error)    { panic("not implemented") }
-func (s *mockSession) OpenStreamSync() (Stream, error)  { panic("not implemented") }
-func (s *mockSession) LocalAddr() net.Addr              { panic("not implemented") }
-func (s *mockSession) RemoteAddr() net.Addr             { return s.remoteAddr }
-func (*mockSession) Context() context.Context           { panic("not implemented") }
-func (*mockSession) GetVersion() protocol.VersionNumber { return protocol.VersionWhatever }
+func (s *mockSession) AcceptStream() (Stream, error)           { panic("not implemented") }
+func (s *mockSession) OpenStreamSync() (Stream, error)         { panic("not implemented") }
+func (s *mockSession) OpenUniStream() (SendStream, error)      { panic("not implemented") }
+func (s *mockSession) AcceptUniStream() (ReceiveStream, error) { panic("not implemented") }
+func (s *mockSession) LocalAddr() net.Addr                     { panic("not implemented") }
+func (s *mockSession) RemoteAddr() net.Addr                    { return s.remoteAddr }
+func (*mockSession) Context() context.Context                  { panic("not implemented") }
+func (*mockSession) GetVersion() protocol.VersionNumber        { return protocol.VersionWhatever }
+func (*mockSession) ConnectionState() ConnectionState          { panic("not implemented") }
+func (*mockSession) Stats() ConnectionStats                    { panic("not implemented") }
 
 var _ Session = &mockSession{}
 var _ NonFWSession = &mockSession{}
@@ -144,6 +152,24 @@ var _ = Describe("Server", func() {
 			Expect(sess.packetCount).To(Equal(1))
 		})
 
+		It("creates new sessions with the current server config", func() {
+			kex, err := crypto.NewCurve25519KEX()
+			Expect(err).ToNot(HaveOccurred())
+			oldScfg, err := handshake.NewServerConfig(kex, nil)
+			Expect(err).ToNot(HaveOccurred())
+			serv.scfg = oldScfg
+			Expect(serv.rotateServerConfig()).To(Succeed())
+			Expect(serv.scfg.ID).ToNot(Equal(oldScfg.ID))
+			var scfg *handshake.ServerConfig
+			serv.newSession = func(conn connection, pconnMgr *pconnManager, createPaths bool, v protocol.VersionNumber, connectionID protocol.ConnectionID, sCfg *handshake.ServerConfig, tlsConf *tls.Config, config *Config) (packetHandler, <-chan handshakeEvent, error) {
+				scfg = sCfg
+				return newMockSession(conn, pconnMgr, createPaths, v, connectionID, sCfg, tlsConf, config)
+			}
+			err = serv.handlePacket(&receivedRawPacket{rcvPconn: nil, remoteAddr: nil, data: firstPacket, rcvTime: time.Now()})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(scfg).To(Equal(serv.scfg))
+		})
+
 		It("accepts a session once the connection it is forward secure", func(done Done) {
 			var acceptedSess Session
 			go func() {
@@ -192,7 +218,7 @@ var _ = Describe("Server", func() {
 		It("closes and deletes sessions", func() {
 			serv.deleteClosedSessionsAfter = time.Second // make sure that the nil value for the closed session doesn't get deleted in this test
 			nullAEAD := crypto.NewNullAEAD(protocol.PerspectiveServer, protocol.VersionWhatever)
-			err := serv.handlePacket(&receivedRawPacket{rcvPconn: nil, remoteAddr: nil, data: append(firstPacket, nullAEAD.Seal(nil, nil, 0, firstPacket)...), rcvTime: time.Now()})
+			err := serv.handlePacket(&receivedRawPacket{rcvPconn: nil, remoteAddr: nil, data: append(firstPacket, nullAEAD.Seal(nil, nil, 0, 0, firstPacket)...), rcvTime: time.Now()})
 			Expect(err).ToNot(HaveOccurred())
 			Expect(serv.sessions).To(HaveLen(1))
 			Expect(serv.sessions[connID]).ToNot(BeNil())
@@ -206,7 +232,7 @@ var _ = Describe("Server", func() {
 		It("deletes nil session entries after a wait time", func() {
 			serv.deleteClosedSessionsAfter = 25 * time.Millisecond
 			nullAEAD := crypto.NewNullAEAD(protocol.PerspectiveServer, protocol.VersionWhatever)
-			err := serv.handlePacket(&receivedRawPacket{rcvPconn: nil, remoteAddr: nil, data: append(firstPacket, nullAEAD.Seal(nil, nil, 0, firstPacket)...), rcvTime: time.Now()})
+			err := serv.handlePacket(&receivedRawPacket{rcvPconn: nil, remoteAddr: nil, data: append(firstPacket, nullAEAD.Seal(nil, nil, 0, 0, firstPacket)...), rcvTime: time.Now()})
 			Expect(err).ToNot(HaveOccurred())
 			Expect(serv.sessions).To(HaveLen(1))
 			Expect(serv.sessions).To(HaveKey(connID))
@@ -350,12 +376,17 @@ var _ = Describe("Server", func() {
 	It("setups with the right values", func() {
 		supportedVersions := []protocol.VersionNumber{1, 3, 5}
 		acceptCookie := func(_ net.Addr, _ *Cookie) bool { return true }
+		accept0RTT := func(net.Addr) bool { return false }
 		config := Config{
-			Versions:         supportedVersions,
-			AcceptCookie:     acceptCookie,
-			HandshakeTimeout: 1337 * time.Hour,
-			IdleTimeout:      42 * time.Minute,
-			KeepAlive:        true,
+			Versions:            supportedVersions,
+			AcceptCookie:        acceptCookie,
+			Accept0RTT:          accept0RTT,
+			ZeroRTTReplayWindow: time.Minute,
+			HandshakeTimeout:    1337 * time.Hour,
+			IdleTimeout:         42 * time.Minute,
+			KeepAlive:           true,
+
+			ServerConfigRotationInterval: 24 * time.Hour,
 		}
 		ln, err := Listen(conn, &tls.Config{}, &config)
 		Expect(err).ToNot(HaveOccurred())
@@ -367,6 +398,9 @@ var _ = Describe("Server", func() {
 		Expect(server.config.HandshakeTimeout).To(Equal(1337 * time.Hour))
 		Expect(server.config.IdleTimeout).To(Equal(42 * time.Minute))
 		Expect(reflect.ValueOf(server.config.AcceptCookie)).To(Equal(reflect.ValueOf(acceptCookie)))
+		Expect(reflect.ValueOf(server.config.Accept0RTT)).To(Equal(reflect.ValueOf(accept0RTT)))
+		Expect(server.config.ZeroRTTReplayWindow).To(Equal(time.Minute))
+		Expect(server.config.ServerConfigRotationInterval).To(Equal(24 * time.Hour))
 		Expect(server.config.KeepAlive).To(BeTrue())
 	})
 
@@ -378,9 +412,23 @@ var _ = Describe("Server", func() {
 		Expect(server.config.HandshakeTimeout).To(Equal(protocol.DefaultHandshakeTimeout))
 		Expect(server.config.IdleTimeout).To(Equal(protocol.DefaultIdleTimeout))
 		Expect(reflect.ValueOf(server.config.AcceptCookie)).To(Equal(reflect.ValueOf(defaultAcceptCookie)))
+		Expect(server.config.Accept0RTT).To(BeNil())
+		Expect(server.config.ZeroRTTReplayWindow).To(Equal(protocol.DefaultZeroRTTReplayWindow))
+		Expect(server.config.ServerConfigRotationInterval).To(BeZero())
 		Expect(server.config.KeepAlive).To(BeFalse())
 	})
 
+	It("rotates the server config until it is closed", func() {
+		ln, err := ListenAddr("127.0.0.1:0", nil, &Config{ServerConfigRotationInterval: 10 * time.Millisecond})
+		Expect(err).ToNot(HaveOccurred())
+		server := ln.(*server)
+		scfg := server.getServerConfig()
+		Eventually(func() []byte { return server.getServerConfig().ID }).ShouldNot(Equal(scfg.ID))
+		Expect(ln.Close()).To(Succeed())
+		scfg = server.getServerConfig()
+		Consistently(func() []byte { return server.getServerConfig().ID }, 50*time.Millisecond).Should(Equal(scfg.ID))
+	})
+
 	It("listens on a given address", func() {
 		addr := "127.0.0.1:13579"
 		ln, err := ListenAddr(addr, nil, config)
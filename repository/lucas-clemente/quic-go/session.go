@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"sync"
 	"time"
@@ -16,6 +17,7 @@ import (
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/utils"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/wire"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/logging"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/qerr"
 )
 
@@ -32,8 +34,10 @@ type receivedPacket struct {
 }
 
 var (
-	errRstStreamOnInvalidStream   = errors.New("RST_STREAM received for unknown stream")
-	errWindowUpdateOnClosedStream = errors.New("WINDOW_UPDATE received for an already closed stream")
+	errRstStreamOnInvalidStream      = errors.New("RST_STREAM received for unknown stream")
+	errWindowUpdateOnClosedStream    = errors.New("WINDOW_UPDATE received for an already closed stream")
+	errGoawayReceived                = qerr.Error(qerr.PeerGoingAway, "received a GOAWAY")
+	errUnreliableStreamsNotSupported = errors.New("the peer doesn't support unreliable streams")
 )
 
 var (
@@ -41,6 +45,9 @@ var (
 	newCryptoSetupClient = handshake.NewCryptoSetupClient
 )
 
+// the KeyLogWriter may be shared by many sessions, every line of the key log is written at once
+var keyLogMutex sync.Mutex
+
 type handshakeEvent struct {
 	encLevel protocol.EncryptionLevel
 	err      error
@@ -87,6 +94,9 @@ type session struct {
 	// closeChan is used to notify the run loop that it should terminate.
 	closeChan chan closeError //
 	closeOnce sync.Once
+	// drainedChan is closed by the run loop once all streams are closed after a GOAWAY was sent
+	drainedChan chan struct{}
+	drainedOnce sync.Once
 
 	ctx       context.Context
 	ctxCancel context.CancelFunc
@@ -106,9 +116,14 @@ type session struct {
 	handshakeCompleteChan chan error
 	// handshakeChan receives handshake events and is closed as soon the handshake completes
 	// the receiving end of this channel is passed to the creator of the session
-	// it receives at most 3 handshake events: 2 when the encryption level changes, and one error
+	// it receives at most 4 handshake events: 3 when the encryption level changes (one for the 0-RTT keys), and one error
 	handshakeChan chan<- handshakeEvent
 
+	// earlyData is set on the client if it is allowed to send data with 0-RTT keys
+	earlyData bool
+	// earlyDataRejectedChan is closed when the server rejected the data sent with 0-RTT keys
+	earlyDataRejectedChan chan struct{}
+
 	connectionParameters handshake.ConnectionParametersManager
 
 	sessionCreationTime     time.Time // 会话创建的时间
@@ -125,6 +140,18 @@ type session struct {
 	pathManagerLaunched bool
 
 	scheduler *scheduler
+
+	tracer logging.ConnectionTracer
+
+	// statsRequests is used to collect the statistics in the run loop
+	statsRequests chan chan ConnectionStats
+	// closedStats are the statistics at the time the run loop terminated
+	closedStats ConnectionStats
+	// the bytes filled with zeros when reading unreliable streams, updated when the streams are read
+	zeroFilledMutex sync.Mutex
+	zeroFilledBytes protocol.ByteCount
+	// the bandwidth-delay product of all paths, last passed to the flow control manager by the run loop
+	bandwidthDelayProduct protocol.ByteCount
 }
 
 var _ Session = &session{}
@@ -164,6 +191,7 @@ var newClientSession = func(
 	tlsConf *tls.Config,
 	config *Config,
 	negotiatedVersions []protocol.VersionNumber,
+	earlyData bool,
 ) (packetHandler, <-chan handshakeEvent, error) {
 	s := &session{
 		paths:        make(map[protocol.PathID]*path),
@@ -174,6 +202,7 @@ var newClientSession = func(
 		perspective:  protocol.PerspectiveClient,
 		version:      v,
 		config:       config,
+		earlyData:    earlyData,
 	}
 	return s.setup(nil, hostname, tlsConf, negotiatedVersions, conn, pconnMgr)
 }
@@ -186,13 +215,17 @@ func (s *session) setup(
 	conn connection,
 	pconnMgr *pconnManager,
 ) (packetHandler, <-chan handshakeEvent, error) {
-	aeadChanged := make(chan protocol.EncryptionLevel, 2)
+	// with 0-RTT, the client can change its keys up to 4 times:
+	// to the 0-RTT keys, back to unencrypted (if the server rejects them), to secure and to forward-secure
+	aeadChanged := make(chan protocol.EncryptionLevel, 4)
 	s.aeadChanged = aeadChanged
-	handshakeChan := make(chan handshakeEvent, 3)
+	handshakeChan := make(chan handshakeEvent, 4)
 	s.handshakeChan = handshakeChan
 	s.handshakeCompleteChan = make(chan error, 1)
+	s.earlyDataRejectedChan = make(chan struct{})
 	s.receivedPackets = make(chan *receivedPacket, protocol.MaxSessionUnprocessedPackets)
 	s.closeChan = make(chan closeError, 1)
+	s.drainedChan = make(chan struct{})
 	s.sendingScheduled = make(chan struct{}, 1)
 	s.undecryptablePackets = make([]*receivedPacket, 0, protocol.MaxUndecryptablePackets)
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
@@ -202,12 +235,28 @@ func (s *session) setup(
 	s.lastNetworkActivityTime = now
 	s.sessionCreationTime = now
 
+	if s.config.Tracer != nil {
+		s.tracer = s.config.Tracer.TracerForConnection(s.perspective, s.connectionID)
+	}
+	if s.tracer != nil {
+		s.tracer.StartedConnection(s.version)
+	}
+	var keyLog handshake.KeyLogFunc
+	var tlsKeyLog io.Writer
+	if s.config.KeyLogWriter != nil {
+		keyLog = s.logKey
+		tlsKeyLog = keyLogWriter{s.config.KeyLogWriter}
+	}
+
+	maxStreamWindow, maxConnectionWindow, maxAutoTunedStreamWindow, maxAutoTunedConnectionWindow := s.receiveWindowLimits()
 	s.connectionParameters = handshake.NewConnectionParamatersManager(
 		s.perspective,
 		s.version,
-		protocol.ByteCount(s.config.MaxReceiveStreamFlowControlWindow),
-		protocol.ByteCount(s.config.MaxReceiveConnectionFlowControlWindow),
+		maxStreamWindow,
+		maxConnectionWindow,
 		s.config.IdleTimeout,
+		s.config.AckSendDelay,
+		uint32(s.config.RetransmittablePacketsBeforeAck),
 	)
 
 	s.scheduler = &scheduler{sess: s}
@@ -229,10 +278,11 @@ func (s *session) setup(
 	}
 	// XXX (QDC): use the PathID 0 as the session RTT path
 	s.rttStats = s.paths[protocol.InitialPathID].rttStats
-	s.flowControlManager = flowcontrol.NewFlowControlManager(s.connectionParameters, s.rttStats, s.remoteRTTs)
-	s.streamsMap = newStreamsMap(s.newStream, s.perspective, s.connectionParameters) // 将创建stream的函数传递给streamMap
+	s.flowControlManager = flowcontrol.NewFlowControlManager(s.connectionParameters, s.rttStats, s.remoteRTTs, maxAutoTunedStreamWindow, maxAutoTunedConnectionWindow)
+	s.streamsMap = newStreamsMap(s.newStream, s.newUniStream, s.perspective, s.connectionParameters) // 将创建stream的函数传递给streamMap
 	s.streamFramer = newStreamFramer(s.streamsMap, s.flowControlManager)             //创建streamFramer，这个东西是为了装所有的要发送的streamFrame,包括了那些需要重传的streamFrame
 	s.pathTimers = make(chan *path)
+	s.statsRequests = make(chan chan ConnectionStats)
 
 	var err error
 	if s.perspective == protocol.PerspectiveServer { //如果是服务器的话
@@ -242,13 +292,15 @@ func (s *session) setup(
 			return s.config.AcceptCookie(clientAddr, cookie)
 		}
 		if s.version.UsesTLS() {
-			s.cryptoSetup, err = handshake.NewCryptoSetupTLS(
-				"",
-				s.perspective,
-				s.version,
-				tlsConf,
+			s.cryptoSetup, err = handshake.NewCryptoSetupTLSServer(
 				cryptoStream, //加密流
-				aeadChanged,  //管道
+				tlsConf,
+				s.connectionParameters,
+				s.config.Versions,
+				s.version,
+				aeadChanged, //管道
+				keyLog,
+				tlsKeyLog,
 			)
 		} else {
 			s.cryptoSetup, err = newCryptoSetup(
@@ -260,19 +312,25 @@ func (s *session) setup(
 				s.connectionParameters,
 				s.config.Versions,
 				verifySourceAddr,
+				s.config.Accept0RTT,
 				aeadChanged,
+				keyLog,
 			)
 		}
 	} else { //如果是客户端的话
 		cryptoStream, _ := s.OpenStream() //打开第一个流
 		if s.version.UsesTLS() {
-			s.cryptoSetup, err = handshake.NewCryptoSetupTLS(
+			s.cryptoSetup, err = handshake.NewCryptoSetupTLSClient(
+				cryptoStream,
 				hostname,
-				s.perspective,
-				s.version,
 				tlsConf,
-				cryptoStream,
+				s.connectionParameters,
+				&handshake.TransportParameters{RequestConnectionIDTruncation: s.config.RequestConnectionIDTruncation},
+				negotiatedVersions,
+				s.version,
 				aeadChanged,
+				keyLog,
+				tlsKeyLog,
 			)
 		} else {
 			s.cryptoSetup, err = newCryptoSetupClient(
@@ -283,8 +341,13 @@ func (s *session) setup(
 				tlsConf,
 				s.connectionParameters,
 				aeadChanged,
-				&handshake.TransportParameters{RequestConnectionIDTruncation: s.config.RequestConnectionIDTruncation, CacheHandshake: s.config.CacheHandshake},
+				&handshake.TransportParameters{
+					RequestConnectionIDTruncation: s.config.RequestConnectionIDTruncation,
+					HandshakeCache:                s.config.HandshakeCache,
+					EarlyData:                     s.earlyData,
+				},
 				negotiatedVersions,
+				keyLog,
 			)
 		}
 	}
@@ -298,6 +361,7 @@ func (s *session) setup(
 		s.streamFramer,
 		s.perspective,
 		s.version,
+		s.tracer,
 	) //用来将将各种类型的Frame打包成packet
 	s.unpacker = &packetUnpacker{aead: s.cryptoSetup, version: s.version, sess: s}
 
@@ -353,6 +417,9 @@ runLoop:
 		case <-s.sendingScheduled: //如果有数据要发送的话
 			// We do all the interesting stuff after the switch statement, so
 			// nothing to see here.
+		case c := <-s.statsRequests:
+			c <- s.collectStats()
+			continue
 		case tmpPth := <-s.pathTimers: //存在路径超时的话，拿出超时的路径
 			timerPth = tmpPth
 			// We do all the interesting stuff after the switch statement, so
@@ -374,8 +441,15 @@ runLoop:
 			if !ok { // the aeadChanged chan was closed. This means that the handshake is completed.
 				s.handshakeComplete = true
 				aeadChanged = nil // prevent this case from ever being selected again
+				s.applyAckFrequency()
+				if s.tracer != nil {
+					s.tracer.CompletedHandshake()
+				}
 				close(s.handshakeChan)
 				close(s.handshakeCompleteChan)
+			} else if l == protocol.EncryptionUnencrypted {
+				// the server rejected the 0-RTT keys
+				s.retransmitEarlyData()
 			} else {
 				s.tryDecryptingQueuedPackets()
 				s.handshakeChan <- handshakeEvent{encLevel: l}
@@ -433,6 +507,9 @@ runLoop:
 		}
 
 		s.garbageCollectStreams() //这个是为了删除那些已经结束生命周期的stream
+		if s.streamsMap.Drained() {
+			s.drainedOnce.Do(func() { close(s.drainedChan) })
+		}
 	}
 
 	// only send the error the handshakeChan when the handshake is not completed yet
@@ -442,6 +519,11 @@ runLoop:
 		s.handshakeChan <- handshakeEvent{err: closeErr.err}
 	}
 	s.handleCloseError(closeErr)
+	s.closedStats = s.collectStats()
+	if s.tracer != nil {
+		s.tracer.ClosedConnection(closeErr.err)
+		s.tracer.Close()
+	}
 	defer s.ctxCancel()
 	return closeErr.err
 }
@@ -514,17 +596,19 @@ func (s *session) handleFrames(fs []wire.Frame, p *path) error {
 		case *wire.StreamFrame: // 如果是streamFrame的话
 			err = s.handleStreamFrame(frame)
 		case *wire.AckFrame: // 如果是AckFrame的话
-			err = s.handleAckFrame(frame)
+			err = s.handleAckFrame(frame, p)
 		case *wire.ConnectionCloseFrame: //如果是连接关闭的Frame
 			s.closeRemote(qerr.Error(frame.ErrorCode, frame.ReasonPhrase))
-		case *wire.GoawayFrame: // GoawayFrame还未实现
-			err = errors.New("unimplemented: handling GOAWAY frames")
+		case *wire.GoawayFrame:
+			s.handleGoawayFrame(frame)
 		case *wire.StopWaitingFrame: //停止等待frame
 			// LeastUnacked is guaranteed to have LeastUnacked > 0
 			// therefore this will never underflow
 			p.receivedPacketHandler.SetLowerLimit(frame.LeastUnacked - 1)
 		case *wire.RstStreamFrame: // 关闭stream的frame
 			err = s.handleRstStreamFrame(frame)
+		case *wire.StopSendingFrame:
+			err = s.handleStopSendingFrame(frame)
 		case *wire.WindowUpdateFrame: // 窗口更新的Frame
 			err = s.handleWindowUpdateFrame(frame)
 		case *wire.BlockedFrame:
@@ -536,7 +620,7 @@ func (s *session) handleFrames(fs []wire.Frame, p *path) error {
 				s.schedulePathsFrame()
 			}
 		case *wire.ClosePathFrame:
-			s.handleClosePathFrame(frame)
+			s.handleClosePathFrame(frame, p)
 		case *wire.PathsFrame:
 			// So far, do nothing
 			s.pathsLock.RLock()
@@ -579,6 +663,9 @@ func (s *session) handlePacket(p *receivedPacket) {
 	select {
 	case s.receivedPackets <- p:
 	default:
+		if s.tracer != nil {
+			s.tracer.DroppedPacket(p.publicHeader.PathID, protocol.ByteCount(len(p.publicHeader.Raw)+len(p.data)), logging.PacketDropQueueFull)
+		}
 	}
 }
 
@@ -599,16 +686,59 @@ func (s *session) handleStreamFrame(frame *wire.StreamFrame) error {
 		s.pathsLock.RLock()
 		utils.Infof("Info for stream %x of %x", frame.StreamID, s.connectionID)
 		for pathID, pth := range s.paths {
-			sntPkts, sntRetrans, sntLost := pth.sentPacketHandler.GetStatistics()
-			rcvPkts := pth.receivedPacketHandler.GetStatistics()
-			utils.Infof("Path %x: sent %d retrans %d lost %d; rcv %d", pathID, sntPkts, sntRetrans, sntLost, rcvPkts)
-			fmt.Println("Path %x: sent %d retrans %d lost %d; rcv %d", pathID, sntPkts, sntRetrans, sntLost, rcvPkts)
+			stats := pth.stats()
+			utils.Infof("Path %x: sent %d retrans %d lost %d; rcv %d", pathID, stats.PacketsSent, stats.PacketsRetransmitted, stats.PacketsLost, stats.PacketsReceived)
 		}
 		s.pathsLock.RUnlock()
 	}
 	return str.AddStreamFrame(frame)
 }
 
+// receiveWindowLimits returns the maximum stream- and connection-level receive windows,
+// and the limits up to which the auto-tuning may grow them beyond their maximum.
+// A configured maximum is a hard limit, only the default ones are auto-tuned.
+func (s *session) receiveWindowLimits() (protocol.ByteCount, protocol.ByteCount, protocol.ByteCount, protocol.ByteCount) {
+	maxStreamWindow := protocol.ByteCount(s.config.MaxReceiveStreamFlowControlWindow)
+	maxConnectionWindow := protocol.ByteCount(s.config.MaxReceiveConnectionFlowControlWindow)
+	var maxAutoTunedStreamWindow, maxAutoTunedConnectionWindow protocol.ByteCount
+	if maxStreamWindow == 0 {
+		maxStreamWindow = protocol.DefaultMaxReceiveStreamFlowControlWindowServer
+		if s.perspective == protocol.PerspectiveClient {
+			maxStreamWindow = protocol.DefaultMaxReceiveStreamFlowControlWindowClient
+		}
+		maxAutoTunedStreamWindow = protocol.MaxAutoTunedReceiveStreamFlowControlWindow
+	}
+	if maxConnectionWindow == 0 {
+		maxConnectionWindow = protocol.DefaultMaxReceiveConnectionFlowControlWindowServer
+		if s.perspective == protocol.PerspectiveClient {
+			maxConnectionWindow = protocol.DefaultMaxReceiveConnectionFlowControlWindowClient
+		}
+		maxAutoTunedConnectionWindow = protocol.MaxAutoTunedReceiveConnectionFlowControlWindow
+	}
+	return maxStreamWindow, maxConnectionWindow, maxAutoTunedStreamWindow, maxAutoTunedConnectionWindow
+}
+
+// updateBandwidthDelayProduct passes the bandwidth-delay product of all open paths to the flow control manager.
+// It is the sum of the receive bandwidths of the paths times the largest RTT, not the sum of the bandwidth-delay
+// products of the paths: the data received on the fast paths is buffered until the data sent at the same time
+// on the slowest path arrives, so the windows have to cover all paths for the RTT of the slowest path.
+// Lock of s.paths must be free
+func (s *session) updateBandwidthDelayProduct() {
+	var bandwidth congestion.Bandwidth
+	var maxRTT time.Duration
+	s.pathsLock.RLock()
+	for _, pth := range s.paths {
+		if !pth.open.Get() {
+			continue
+		}
+		bandwidth += pth.receiveBandwidth
+		maxRTT = utils.MaxDuration(maxRTT, pth.rttStats.SmoothedRTT())
+	}
+	s.pathsLock.RUnlock()
+	s.bandwidthDelayProduct = protocol.ByteCount(bandwidth / congestion.BytesPerSecond * congestion.Bandwidth(maxRTT) / congestion.Bandwidth(time.Second))
+	s.flowControlManager.UpdateBandwidthDelayProduct(s.bandwidthDelayProduct)
+}
+
 func (s *session) handleWindowUpdateFrame(frame *wire.WindowUpdateFrame) error {
 	if frame.StreamID != 0 {
 		str, err := s.streamsMap.GetOrOpenStream(frame.StreamID)
@@ -633,29 +763,70 @@ func (s *session) handleRstStreamFrame(frame *wire.RstStreamFrame) error {
 		return errRstStreamOnInvalidStream
 	}
 
-	str.RegisterRemoteError(fmt.Errorf("RST_STREAM received with code %d", frame.ErrorCode))
+	str.RegisterRemoteError(&StreamError{
+		StreamID:  frame.StreamID,
+		ErrorCode: StreamErrorCode(frame.ErrorCode),
+		Remote:    true,
+	})
 	return s.flowControlManager.ResetStream(frame.StreamID, frame.ByteOffset)
 }
 
+func (s *session) handleGoawayFrame(frame *wire.GoawayFrame) {
+	utils.Infof("Received GOAWAY for connection %x, last good stream %d, last good unidirectional stream %d: %s", s.connectionID, frame.LastGoodStream, frame.LastGoodUniStream, frame.ReasonPhrase)
+	s.streamsMap.ReceivedGoaway(frame.LastGoodStream, frame.LastGoodUniStream, errGoawayReceived)
+}
+
+func (s *session) handleStopSendingFrame(frame *wire.StopSendingFrame) error {
+	str, err := s.streamsMap.GetOrOpenStream(frame.StreamID)
+	if err != nil {
+		return err
+	}
+	if str == nil {
+		// Stream is closed and already garbage collected
+		return nil
+	}
+	if str.receiveOnly {
+		return qerr.Error(qerr.InvalidStreamID, fmt.Sprintf("STOP_SENDING for receive-only stream %d", frame.StreamID))
+	}
+	str.StopSending(StreamErrorCode(frame.ErrorCode))
+	return nil
+}
+
 //  handleAckFrame 将会让对应的path的sentPacketHandler来处理该ack，然后更新session的rttStats
-func (s *session) handleAckFrame(frame *wire.AckFrame) error {
+func (s *session) handleAckFrame(frame *wire.AckFrame, rcvPth *path) error {
 	pth := s.paths[frame.PathID] // 每一个 ackFrame 都会包含一个pathID
-	err := pth.sentPacketHandler.ReceivedAck(frame, pth.lastRcvdPacketNumber, pth.lastNetworkActivityTime)
-	if err == nil && pth.rttStats.SmoothedRTT() > s.rttStats.SmoothedRTT() {
+	// The ACK may have been received on another path, so its time is the one of the receiving path
+	err := pth.sentPacketHandler.ReceivedAck(frame, rcvPth.pathID, rcvPth.lastRcvdPacketNumber, rcvPth.lastNetworkActivityTime)
+	if err != nil {
+		return err
+	}
+	if rcvPth != pth {
+		pth.receivedAck(frame, rcvPth.lastNetworkActivityTime)
+	}
+	if pth.rttStats.SmoothedRTT() > s.rttStats.SmoothedRTT() {
 		// Update the session RTT, which comes to take the max RTT on all paths
 		s.rttStats.UpdateSessionRTT(pth.rttStats.SmoothedRTT())
 	}
-	return err
+	return nil
 }
 
-func (s *session) handleClosePathFrame(frame *wire.ClosePathFrame) error {
+// applyAckFrequency makes all paths acknowledge packets as often as negotiated during the handshake
+func (s *session) applyAckFrequency() {
+	s.pathsLock.RLock()
+	defer s.pathsLock.RUnlock()
+	for _, pth := range s.paths {
+		pth.applyAckFrequency()
+	}
+}
+
+func (s *session) handleClosePathFrame(frame *wire.ClosePathFrame, rcvPth *path) error {
 	if err := s.closePath(frame.PathID, false); err != nil {
 		return err
 	}
 	// This is safe because closePath checks this
 	pth := s.paths[frame.PathID]
 	// This allows the host to retransmit packets sent on this path that were not acked by the ClosePath frame
-	return pth.sentPacketHandler.ReceivedClosePath(frame, pth.lastRcvdPacketNumber, pth.lastNetworkActivityTime)
+	return pth.sentPacketHandler.ReceivedClosePath(frame, rcvPth.pathID, rcvPth.lastRcvdPacketNumber, rcvPth.lastNetworkActivityTime)
 }
 
 func (s *session) closePath(pthID protocol.PathID, sendClosePathFrame bool) error {
@@ -733,6 +904,29 @@ func (s *session) closeRemote(e error) {
 	})
 }
 
+// CloseGracefully sends a GOAWAY frame, so that the peer doesn't open new streams anymore.
+// It closes the connection once all open streams are finished, or when the timeout expires.
+func (s *session) CloseGracefully(timeout time.Duration) error {
+	if lastGoodStream, lastGoodUniStream, ok := s.streamsMap.SendGoaway(); ok {
+		s.packer.QueueControlFrame(&wire.GoawayFrame{
+			ErrorCode:         qerr.PeerGoingAway,
+			LastGoodStream:    lastGoodStream,
+			LastGoodUniStream: lastGoodUniStream,
+		}, s.paths[protocol.InitialPathID])
+		s.scheduleSending()
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-s.drainedChan:
+	case <-timer.C:
+	case <-s.ctx.Done():
+		return nil
+	}
+	return s.Close(nil)
+}
+
 // Close the connection. If err is nil it will be set to qerr.PeerGoingAway.
 // It waits until the run loop has stopped before returning
 func (s *session) Close(e error) error {
@@ -877,21 +1071,81 @@ func (s *session) OpenStream() (Stream, error) {
 
 // this function opens an unreliableStream
 func (s *session) OpenUnreliableStream() (Stream, error) {
-	return s.streamsMap.OpenUnreliableStream()
+	if !s.connectionParameters.PeerSupportsUnreliableStreams() {
+		return nil, errUnreliableStreamsNotSupported
+	}
+	str, err := s.streamsMap.OpenUnreliableStream()
+	if err != nil {
+		return nil, err
+	}
+	if s.config.UnreliableSendQueueSize > 0 {
+		str.setSendQueue(protocol.ByteCount(s.config.UnreliableSendQueueSize), s.config.UnreliableSendQueuePolicy)
+	}
+	return str, nil
 }
 
 func (s *session) OpenStreamSync() (Stream, error) {
 	return s.streamsMap.OpenStreamSync()
 }
 
+// OpenUniStream opens a unidirectional stream
+func (s *session) OpenUniStream() (SendStream, error) {
+	str, err := s.streamsMap.OpenUniStream()
+	if str != nil {
+		return str, err
+	}
+	// make sure to return an actual nil value here, not a SendStream with value nil
+	return nil, err
+}
+
+// AcceptUniStream returns the next unidirectional stream openend by the peer
+func (s *session) AcceptUniStream() (ReceiveStream, error) {
+	str, err := s.streamsMap.AcceptUniStream()
+	if str != nil {
+		return str, err
+	}
+	return nil, err
+}
+
 func (s *session) WaitUntilHandshakeComplete() error {
 	return <-s.handshakeCompleteChan
 }
 
-func (s *session) queueResetStreamFrame(id protocol.StreamID, offset protocol.ByteCount) {
+func (s *session) EarlyDataRejected() <-chan struct{} {
+	return s.earlyDataRejectedChan
+}
+
+// retransmitEarlyData queues the frames sent with 0-RTT keys to be sent again, after the server rejected these keys
+func (s *session) retransmitEarlyData() {
+	s.pathsLock.RLock()
+	for _, pth := range s.paths {
+		for _, packet := range pth.sentPacketHandler.DequeuePacketsWithEncryptionLevel(protocol.EncryptionSecure) {
+			utils.Debugf("\tQueueing frames of 0-RTT packet 0x%x from path %d", packet.PacketNumber, pth.pathID)
+			s.scheduler.queueFramesForRetransmission(s, packet, pth)
+		}
+	}
+	s.pathsLock.RUnlock()
+	select {
+	case <-s.earlyDataRejectedChan:
+	default:
+		close(s.earlyDataRejectedChan)
+	}
+	s.scheduleSending()
+}
+
+func (s *session) queueResetStreamFrame(id protocol.StreamID, offset protocol.ByteCount, errorCode StreamErrorCode) {
 	s.packer.QueueControlFrame(&wire.RstStreamFrame{
 		StreamID:   id,
 		ByteOffset: offset,
+		ErrorCode:  uint32(errorCode),
+	}, s.paths[protocol.InitialPathID])
+	s.scheduleSending()
+}
+
+func (s *session) queueStopSendingFrame(id protocol.StreamID, errorCode StreamErrorCode) {
+	s.packer.QueueControlFrame(&wire.StopSendingFrame{
+		StreamID:  id,
+		ErrorCode: uint32(errorCode),
 	}, s.paths[protocol.InitialPathID])
 	s.scheduleSending()
 }
@@ -907,6 +1161,16 @@ func (s *session) newStream(id protocol.StreamID) *stream {
 	return newStreamType(id, s.scheduleSending, s.queueResetStreamFrame, s.flowControlManager, s)
 }
 
+// newUniStream creates a unidirectional stream, which is only flow controlled in the direction data is sent
+func (s *session) newUniStream(id protocol.StreamID, sendOnly bool) *stream {
+	if sendOnly {
+		s.flowControlManager.NewSendOnlyStream(id)
+	} else {
+		s.flowControlManager.NewReceiveOnlyStream(id)
+	}
+	return newStreamType(id, s.scheduleSending, s.queueResetStreamFrame, s.flowControlManager, s)
+}
+
 // garbageCollectStreams goes through all streams and removes EOF'ed streams
 // from the streams map.
 func (s *session) garbageCollectStreams() {
@@ -985,6 +1249,71 @@ func (s *session) RemoteAddr() net.Addr { //返回远端的地址
 	return s.paths[0].conn.RemoteAddr()
 }
 
+// ConnectionState returns basic details about the handshake
+func (s *session) ConnectionState() ConnectionState {
+	return s.cryptoSetup.ConnectionState()
+}
+
+// Stats returns the statistics of the connection.
+// They are collected by the run loop, so that they are consistent.
+func (s *session) Stats() ConnectionStats {
+	c := make(chan ConnectionStats, 1)
+	select {
+	case s.statsRequests <- c:
+		return <-c
+	case <-s.ctx.Done():
+		return s.closedStats
+	}
+}
+
+func (s *session) collectStats() ConnectionStats {
+	stats := ConnectionStats{Paths: make(map[PathID]PathStats)}
+	s.pathsLock.RLock()
+	for pathID, pth := range s.paths {
+		stats.Paths[pathID] = pth.stats()
+	}
+	s.pathsLock.RUnlock()
+	s.zeroFilledMutex.Lock()
+	stats.ZeroFilledBytes = s.zeroFilledBytes
+	s.zeroFilledMutex.Unlock()
+	stats.BandwidthDelayProduct = s.bandwidthDelayProduct
+	if windows, err := s.flowControlManager.GetWindowStatistics(0); err == nil {
+		stats.ReceiveWindow = windows.ReceiveWindow
+		stats.ReceiveWindowIncrement = windows.ReceiveWindowIncrement
+	}
+	return stats
+}
+
+// onZeroFilled is called when a gap in an unreliable stream is filled with zeros
+func (s *session) onZeroFilled(streamID protocol.StreamID, offset, length protocol.ByteCount) {
+	s.zeroFilledMutex.Lock()
+	s.zeroFilledBytes += length
+	s.zeroFilledMutex.Unlock()
+	if s.tracer != nil {
+		s.tracer.FilledStreamGap(streamID, offset, length)
+	}
+}
+
+// logKey writes a key or an IV derived by the crypto setup to the KeyLogWriter.
+// The lines have the format of the NSS key log, with the connection ID in place of the client random.
+func (s *session) logKey(label string, secret []byte) {
+	keyLogMutex.Lock()
+	defer keyLogMutex.Unlock()
+	fmt.Fprintf(s.config.KeyLogWriter, "%s %016x %x\n", label, uint64(s.connectionID), secret)
+}
+
+// A keyLogWriter writes the NSS key log lines of the TLS handshake to the KeyLogWriter of the Config.
+// mint writes every line at once, the keyLogMutex keeps the lines of concurrent sessions apart.
+type keyLogWriter struct {
+	w io.Writer
+}
+
+func (w keyLogWriter) Write(p []byte) (int, error) {
+	keyLogMutex.Lock()
+	defer keyLogMutex.Unlock()
+	return w.w.Write(p)
+}
+
 func (s *session) GetVersion() protocol.VersionNumber { // 拿到该quic协议的版本
 	return s.version
 }
@@ -14,14 +14,18 @@ import (
 	. "github.com/yyleeshine/mpquic/repository/onsi/ginkgo"
 	. "github.com/yyleeshine/mpquic/repository/onsi/gomega"
 
+	"github.com/yyleeshine/mpquic/repository/golang/mock/gomock"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/ackhandler"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/congestion"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/crypto"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/flowcontrol"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/handshake"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/mocks"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/mocks/mocks_fc"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/testdata"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/wire"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/logging"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/qerr"
 )
 
@@ -76,6 +80,7 @@ type mockSentPacketHandler struct {
 	congestionLimited               bool
 	requestedStopWaiting            bool
 	shouldSendRetransmittablePacket bool
+	earlyPackets                    []*ackhandler.Packet
 }
 
 func (h *mockSentPacketHandler) SentPacket(packet *ackhandler.Packet) error {
@@ -83,11 +88,11 @@ func (h *mockSentPacketHandler) SentPacket(packet *ackhandler.Packet) error {
 	return nil
 }
 
-func (h *mockSentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, withPacketNumber protocol.PacketNumber, recvTime time.Time) error {
+func (h *mockSentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, rcvPathID protocol.PathID, withPacketNumber protocol.PacketNumber, recvTime time.Time) error {
 	return nil
 }
 
-func (h *mockSentPacketHandler) ReceivedClosePath(f *wire.ClosePathFrame, withPacketNumber protocol.PacketNumber, recvTime time.Time) error {
+func (h *mockSentPacketHandler) ReceivedClosePath(f *wire.ClosePathFrame, rcvPathID protocol.PathID, withPacketNumber protocol.PacketNumber, recvTime time.Time) error {
 	return nil
 }
 
@@ -95,13 +100,34 @@ func (h *mockSentPacketHandler) GetLeastUnacked() protocol.PacketNumber { return
 func (h *mockSentPacketHandler) GetAlarmTimeout() time.Time             { return time.Now() }
 func (h *mockSentPacketHandler) OnAlarm()                               { panic("not implemented") }
 func (h *mockSentPacketHandler) DuplicatePacket(_ *ackhandler.Packet)   { panic("not implemented") }
-func (h *mockSentPacketHandler) SendingAllowed() bool                   { return !h.congestionLimited }
+func (h *mockSentPacketHandler) GetPacketsForReinjection(_ time.Time) []*ackhandler.Packet {
+	return nil
+}
+func (h *mockSentPacketHandler) DequeuePacketsWithEncryptionLevel(encLevel protocol.EncryptionLevel) []*ackhandler.Packet {
+	if encLevel != protocol.EncryptionSecure {
+		return nil
+	}
+	packets := h.earlyPackets
+	h.earlyPackets = nil
+	return packets
+}
+func (h *mockSentPacketHandler) SendingAllowed() bool       { return !h.congestionLimited }
+func (h *mockSentPacketHandler) SetApplicationLimited()     {}
+func (h *mockSentPacketHandler) IsApplicationLimited() bool { return false }
 func (h *mockSentPacketHandler) ShouldSendRetransmittablePacket() bool {
 	b := h.shouldSendRetransmittablePacket
 	h.shouldSendRetransmittablePacket = false
 	return b
 }
-func (h *mockSentPacketHandler) GetStatistics() (uint64, uint64, uint64) { panic("not implemented") }
+func (h *mockSentPacketHandler) GetStatistics() ackhandler.SentPacketStatistics {
+	return ackhandler.SentPacketStatistics{}
+}
+func (h *mockSentPacketHandler) GetReorderingStatistics() (uint64, protocol.PacketNumber) {
+	panic("not implemented")
+}
+func (h *mockSentPacketHandler) GetSpuriousRetransmissionStatistics() (uint64, uint64) {
+	panic("not implemented")
+}
 
 func (h *mockSentPacketHandler) GetStopWaitingFrame(force bool) *wire.StopWaitingFrame {
 	h.requestedStopWaiting = true
@@ -144,10 +170,9 @@ func (m *mockReceivedPacketHandler) ReceivedPacket(packetNumber protocol.PacketN
 func (m *mockReceivedPacketHandler) SetLowerLimit(protocol.PacketNumber) {
 	panic("not implemented")
 }
-func (m *mockReceivedPacketHandler) GetAlarmTimeout() time.Time { return m.ackAlarm }
-func (m *mockReceivedPacketHandler) GetStatistics() uint64 {
-	panic("not implemented")
-}
+func (m *mockReceivedPacketHandler) SetAckFrequency(time.Duration, int) {}
+func (m *mockReceivedPacketHandler) GetAlarmTimeout() time.Time         { return m.ackAlarm }
+func (m *mockReceivedPacketHandler) GetStatistics() uint64              { return 0 }
 
 func (m *mockReceivedPacketHandler) GetClosePathFrame() *wire.ClosePathFrame {
 	panic("not implemented")
@@ -185,7 +210,9 @@ var _ = Describe("Session", func() {
 			_ handshake.ConnectionParametersManager,
 			_ []protocol.VersionNumber,
 			_ func(net.Addr, *Cookie) bool,
+			_ func(net.Addr) bool,
 			aeadChangedP chan<- protocol.EncryptionLevel,
+			_ handshake.KeyLogFunc,
 		) (handshake.CryptoSetup, error) {
 			aeadChanged = aeadChangedP
 			return cryptoSetup, nil
@@ -214,6 +241,11 @@ var _ = Describe("Session", func() {
 
 		mockCpm = mocks.NewMockConnectionParametersManager(mockCtrl)
 		mockCpm.EXPECT().GetIdleConnectionStateLifetime().Return(time.Minute).AnyTimes()
+		mockCpm.EXPECT().GetAckSendDelay().Return(protocol.AckSendDelay).AnyTimes()
+		mockCpm.EXPECT().GetRetransmittablePacketsBeforeAck().Return(protocol.RetransmittablePacketsBeforeAck).AnyTimes()
+		mockCpm.EXPECT().PeerSupportsMultipath().Return(true).AnyTimes()
+		mockCpm.EXPECT().PeerSupportsUnreliableStreams().Return(true).AnyTimes()
+		mockCpm.EXPECT().PeerSupportsStopSending().Return(true).AnyTimes()
 		sess.connectionParameters = mockCpm
 	})
 
@@ -240,7 +272,9 @@ var _ = Describe("Session", func() {
 				_ handshake.ConnectionParametersManager,
 				_ []protocol.VersionNumber,
 				cookieFunc func(net.Addr, *Cookie) bool,
+				_ func(net.Addr) bool,
 				_ chan<- protocol.EncryptionLevel,
+				_ handshake.KeyLogFunc,
 			) (handshake.CryptoSetup, error) {
 				cookieVerify = cookieFunc
 				return cryptoSetup, nil
@@ -283,6 +317,57 @@ var _ = Describe("Session", func() {
 		})
 	})
 
+	Context("key log", func() {
+		var keyLog handshake.KeyLogFunc
+
+		newSessionWithConfig := func(conf *Config) {
+			newCryptoSetup = func(
+				_ protocol.ConnectionID,
+				_ net.Addr,
+				_ protocol.VersionNumber,
+				_ *handshake.ServerConfig,
+				_ io.ReadWriter,
+				_ handshake.ConnectionParametersManager,
+				_ []protocol.VersionNumber,
+				_ func(net.Addr, *Cookie) bool,
+				_ func(net.Addr) bool,
+				_ chan<- protocol.EncryptionLevel,
+				keyLogP handshake.KeyLogFunc,
+			) (handshake.CryptoSetup, error) {
+				keyLog = keyLogP
+				return cryptoSetup, nil
+			}
+			_, _, err := newSession(
+				mconn,
+				nil,
+				true, // Try doing multipath
+				protocol.Version37,
+				0x1337,
+				scfg,
+				nil,
+				populateServerConfig(conf),
+			)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		It("writes the keys to the KeyLogWriter", func() {
+			buf := &bytes.Buffer{}
+			newSessionWithConfig(&Config{KeyLogWriter: buf})
+			Expect(keyLog).ToNot(BeNil())
+			keyLog("QUIC_CLIENT_SECURE_KEY", []byte{0xde, 0xca, 0xfb, 0xad})
+			keyLog("QUIC_CLIENT_SECURE_IV", []byte{0x13, 0x37})
+			Expect(buf.String()).To(Equal(
+				"QUIC_CLIENT_SECURE_KEY 0000000000001337 decafbad\n" +
+					"QUIC_CLIENT_SECURE_IV 0000000000001337 1337\n",
+			))
+		})
+
+		It("doesn't log the keys without a KeyLogWriter", func() {
+			newSessionWithConfig(&Config{})
+			Expect(keyLog).To(BeNil())
+		})
+	})
+
 	Context("when handling stream frames", func() {
 		It("makes new streams", func() {
 			sess.handleStreamFrame(&wire.StreamFrame{
@@ -455,6 +540,26 @@ var _ = Describe("Session", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("bounds the send queue of unreliable streams", func() {
+			sess.config.UnreliableSendQueueSize = 1000
+			sess.config.UnreliableSendQueuePolicy = SendQueueDropNewest
+			str, err := sess.OpenUnreliableStream()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.(*stream).sendBufferSize).To(Equal(protocol.ByteCount(1000)))
+			Expect(str.(*stream).sendQueuePolicy).To(Equal(SendQueueDropNewest))
+			str, err = sess.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.(*stream).sendQueuePolicy).To(Equal(SendQueueBlock))
+		})
+
+		It("doesn't open unreliable streams if the peer doesn't support them", func() {
+			mockCpm = mocks.NewMockConnectionParametersManager(mockCtrl)
+			mockCpm.EXPECT().PeerSupportsUnreliableStreams().Return(false)
+			sess.connectionParameters = mockCpm
+			_, err := sess.OpenUnreliableStream()
+			Expect(err).To(MatchError(errUnreliableStreamsNotSupported))
+		})
+
 		It("ignores STREAM frames for closed streams (server-side)", func() {
 			ostr, err := sess.OpenStream()
 			Expect(err).ToNot(HaveOccurred())
@@ -481,6 +586,102 @@ var _ = Describe("Session", func() {
 		})
 	})
 
+	Context("handling STOP_SENDING frames", func() {
+		It("resets the stream", func() {
+			s, err := sess.GetOrOpenStream(5)
+			Expect(err).ToNot(HaveOccurred())
+			err = sess.handleStopSendingFrame(&wire.StopSendingFrame{
+				StreamID:  5,
+				ErrorCode: 42,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			_, err = s.Write([]byte{0})
+			Expect(err).To(MatchError("stream 5 canceled by peer with error code 42"))
+			Expect(sess.packer.controlFrames).To(ContainElement(&wire.RstStreamFrame{
+				StreamID:  5,
+				ErrorCode: 42,
+			}))
+		})
+
+		It("queues a STOP_SENDING frame when the reading is canceled", func() {
+			str, err := sess.GetOrOpenStream(5)
+			Expect(err).ToNot(HaveOccurred())
+			str.CancelRead(42)
+			Expect(sess.packer.controlFrames).To(Equal([]wire.Frame{&wire.StopSendingFrame{
+				StreamID:  5,
+				ErrorCode: 42,
+			}}))
+		})
+
+		It("queues a RST_STREAM frame instead, if the peer doesn't support STOP_SENDING", func() {
+			mockCpm = mocks.NewMockConnectionParametersManager(mockCtrl)
+			mockCpm.EXPECT().PeerSupportsStopSending().Return(false)
+			sess.connectionParameters = mockCpm
+			str, err := sess.GetOrOpenStream(5)
+			Expect(err).ToNot(HaveOccurred())
+			str.(*stream).writeOffset = 0x1337
+			str.CancelRead(42)
+			Expect(sess.packer.controlFrames).To(Equal([]wire.Frame{&wire.RstStreamFrame{
+				StreamID:   5,
+				ByteOffset: 0x1337,
+				ErrorCode:  42,
+			}}))
+			_, err = str.Write([]byte{0})
+			Expect(err).To(MatchError("stream 5 canceled with error code 42"))
+		})
+
+		It("ignores STOP_SENDING frames for closed streams", func() {
+			_, err := sess.GetOrOpenStream(5)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sess.streamsMap.RemoveStream(5)).To(Succeed())
+			err = sess.handleStopSendingFrame(&wire.StopSendingFrame{StreamID: 5})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sess.packer.controlFrames).To(BeEmpty())
+		})
+	})
+
+	Context("GOAWAY", func() {
+		It("doesn't open new streams after receiving a GOAWAY frame", func() {
+			err := sess.handleFrames([]wire.Frame{&wire.GoawayFrame{
+				ErrorCode:      qerr.PeerGoingAway,
+				LastGoodStream: 3,
+			}}, sess.paths[0])
+			Expect(err).ToNot(HaveOccurred())
+			_, err = sess.OpenStream()
+			Expect(err).To(MatchError(errGoawayReceived))
+		})
+
+		It("closes gracefully once all streams are finished", func() {
+			go sess.run()
+			str, err := sess.GetOrOpenStream(5)
+			Expect(err).ToNot(HaveOccurred())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				err := sess.CloseGracefully(time.Hour)
+				Expect(err).ToNot(HaveOccurred())
+				close(done)
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			_, err = sess.GetOrOpenStream(7)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sess.streamsMap.streams).ToNot(HaveKey(protocol.StreamID(7)))
+			str.CancelRead(0)
+			str.CancelWrite(0)
+			Eventually(done).Should(BeClosed())
+			Expect(sess.Context().Done()).To(BeClosed())
+		})
+
+		It("closes when the timeout expires", func() {
+			go sess.run()
+			_, err := sess.GetOrOpenStream(5)
+			Expect(err).ToNot(HaveOccurred())
+			err = sess.CloseGracefully(10 * time.Millisecond)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sess.Context().Done()).To(BeClosed())
+		})
+	})
+
 	Context("handling RST_STREAM frames", func() {
 		It("closes the streams for writing", func() {
 			s, err := sess.GetOrOpenStream(5)
@@ -492,7 +693,7 @@ var _ = Describe("Session", func() {
 			Expect(err).ToNot(HaveOccurred())
 			n, err := s.Write([]byte{0})
 			Expect(n).To(BeZero())
-			Expect(err).To(MatchError("RST_STREAM received with code 42"))
+			Expect(err).To(MatchError("stream 5 canceled by peer with error code 42"))
 		})
 
 		It("doesn't close the stream for reading", func() {
@@ -675,12 +876,6 @@ var _ = Describe("Session", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
-	It("errors on GOAWAY frames", func() {
-		// XXX (QDC): adapted to multiple paths
-		err := sess.handleFrames([]wire.Frame{&wire.GoawayFrame{}}, sess.paths[0])
-		Expect(err).To(MatchError("unimplemented: handling GOAWAY frames"))
-	})
-
 	It("handles STOP_WAITING frames", func() {
 		// XXX (QDC): adapted to multiple paths
 		err := sess.handleFrames([]wire.Frame{&wire.StopWaitingFrame{LeastUnacked: 10}}, sess.paths[0])
@@ -875,6 +1070,158 @@ var _ = Describe("Session", func() {
 		})
 	})
 
+	Context("tracing", func() {
+		var tracer *mocks.MockConnectionTracer
+
+		BeforeEach(func() {
+			tracer = mocks.NewMockConnectionTracer(mockCtrl)
+			tracerFactory := mocks.NewMockTracer(mockCtrl)
+			gomock.InOrder(
+				tracerFactory.EXPECT().TracerForConnection(protocol.PerspectiveServer, protocol.ConnectionID(0)).Return(tracer),
+				tracer.EXPECT().StartedConnection(protocol.Version37),
+				tracer.EXPECT().UpdatedPathState(protocol.PathID(0), logging.PathStateOpen),
+			)
+			conf := populateServerConfig(&Config{Tracer: tracerFactory})
+			Expect(conf.Tracer).To(Equal(tracerFactory))
+			pSess, _, err := newSession(
+				mconn,
+				nil,
+				true, // Try doing multipath
+				protocol.Version37,
+				0,
+				scfg,
+				nil,
+				conf,
+			)
+			Expect(err).NotTo(HaveOccurred())
+			sess = pSess.(*session)
+			sess.connectionParameters = mockCpm
+		})
+
+		It("records the received packets", func() {
+			sess.unpacker = &mockUnpacker{}
+			hdr := &wire.PublicHeader{PacketNumber: 5, PacketNumberLen: protocol.PacketNumberLen6, Raw: make([]byte, 10)}
+			tracer.EXPECT().ReceivedPacket(protocol.PathID(0), protocol.PacketNumber(5), protocol.ByteCount(110), gomock.Any(), gomock.Any())
+			err := sess.handlePacketImpl(&receivedPacket{publicHeader: hdr, data: make([]byte, 100)})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("records the path selected to send a packet", func() {
+			sess.paths[0].receivedPacketHandler.ReceivedPacket(0x1337, true)
+			gomock.InOrder(
+				tracer.EXPECT().SentPacket(protocol.PathID(0), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()),
+				tracer.EXPECT().SelectedPath(protocol.PathID(0), logging.SchedulingSinglePath),
+			)
+			err := sess.sendPacket()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mconn.written).To(HaveLen(1))
+		})
+
+		It("doesn't record a path selection if no packet is sent", func() {
+			err := sess.sendPacket()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(mconn.written).To(BeEmpty())
+		})
+
+		It("records when a path is potentially failed, and when it works again", func() {
+			pth := sess.paths[0]
+			tracer.EXPECT().UpdatedPathState(protocol.PathID(0), logging.PathStatePotentiallyFailed)
+			pth.setPotentiallyFailed(true)
+			pth.setPotentiallyFailed(true)
+			tracer.EXPECT().UpdatedPathState(protocol.PathID(0), logging.PathStateOpen)
+			pth.setPotentiallyFailed(false)
+		})
+
+		It("records the packets dropped because too many packets are queued", func() {
+			hdr := &wire.PublicHeader{PathID: 1, Raw: make([]byte, 10)}
+			for i := 0; i < protocol.MaxSessionUnprocessedPackets; i++ {
+				sess.handlePacket(&receivedPacket{publicHeader: hdr, data: make([]byte, 100)})
+			}
+			tracer.EXPECT().DroppedPacket(protocol.PathID(1), protocol.ByteCount(110), logging.PacketDropQueueFull)
+			sess.handlePacket(&receivedPacket{publicHeader: hdr, data: make([]byte, 100)})
+		})
+
+		It("records the gaps of unreliable streams that were filled with zeros", func() {
+			tracer.EXPECT().FilledStreamGap(protocol.StreamID(5), protocol.ByteCount(100), protocol.ByteCount(20))
+			sess.onZeroFilled(5, 100, 20)
+			Expect(sess.zeroFilledBytes).To(Equal(protocol.ByteCount(20)))
+		})
+
+		It("records the completion of the handshake", func() {
+			tracer.EXPECT().SelectedPath(gomock.Any(), gomock.Any()).AnyTimes()
+			tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+			tracer.EXPECT().UpdatedPathState(gomock.Any(), gomock.Any()).AnyTimes()
+			tracer.EXPECT().ClosedConnection(gomock.Any())
+			tracer.EXPECT().Close()
+			tracer.EXPECT().CompletedHandshake()
+			go sess.run()
+			close(aeadChanged)
+			Expect(sess.WaitUntilHandshakeComplete()).To(Succeed())
+			sess.Close(nil)
+			Eventually(areSessionsRunning).Should(BeFalse())
+		})
+
+		It("records the sent packets, the closing of the paths and of the connection", func() {
+			testErr := errors.New("test error")
+			tracer.EXPECT().SelectedPath(gomock.Any(), gomock.Any()).AnyTimes()
+			gomock.InOrder(
+				tracer.EXPECT().UpdatedPathState(protocol.PathID(0), logging.PathStateClosed),
+				tracer.EXPECT().SentPacket(protocol.PathID(0), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(
+					func(_ protocol.PathID, _ protocol.PacketNumber, _ protocol.ByteCount, _ protocol.EncryptionLevel, frames []wire.Frame) {
+						Expect(frames).To(HaveLen(1))
+						Expect(frames[0]).To(BeAssignableToTypeOf(&wire.ConnectionCloseFrame{}))
+					},
+				),
+				tracer.EXPECT().ClosedConnection(testErr),
+				tracer.EXPECT().Close(),
+			)
+			go sess.run()
+			Eventually(areSessionsRunning).Should(BeTrue())
+			sess.Close(testErr)
+			Eventually(areSessionsRunning).Should(BeFalse())
+		})
+	})
+
+	Context("statistics", func() {
+		BeforeEach(func() {
+			sess.unpacker = &mockUnpacker{}
+			hdr := &wire.PublicHeader{PacketNumber: 5, PacketNumberLen: protocol.PacketNumberLen6, Raw: make([]byte, 10)}
+			err := sess.handlePacketImpl(&receivedPacket{publicHeader: hdr, data: make([]byte, 100)})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("returns the statistics of the paths", func() {
+			sess.paths[0].rttStats.UpdateRTT(100*time.Millisecond, 0, time.Now())
+			sess.onZeroFilled(3, 0, 10)
+			sess.onZeroFilled(5, 100, 5)
+			go sess.run()
+			stats := sess.Stats()
+			Expect(stats.ZeroFilledBytes).To(Equal(protocol.ByteCount(15)))
+			Expect(stats.Paths).To(HaveLen(1))
+			Expect(stats.Paths).To(HaveKey(protocol.PathID(0)))
+			pathStats := stats.Paths[0]
+			Expect(pathStats.Open).To(BeTrue())
+			Expect(pathStats.PacketsReceived).To(BeEquivalentTo(1))
+			Expect(pathStats.BytesReceived).To(Equal(protocol.ByteCount(110)))
+			Expect(pathStats.SmoothedRTT).To(Equal(100 * time.Millisecond))
+			Expect(pathStats.MinRTT).To(Equal(100 * time.Millisecond))
+			Expect(pathStats.CongestionWindow).ToNot(BeZero())
+			Expect(pathStats.PacingRate).To(BeEquivalentTo(pathStats.CongestionWindow * 10))
+			sess.Close(nil)
+			Eventually(areSessionsRunning).Should(BeFalse())
+		})
+
+		It("returns the statistics at the time the session was closed", func() {
+			go sess.run()
+			Eventually(areSessionsRunning).Should(BeTrue())
+			sess.Close(nil)
+			Eventually(areSessionsRunning).Should(BeFalse())
+			stats := sess.Stats()
+			Expect(stats.Paths).To(HaveKey(protocol.PathID(0)))
+			Expect(stats.Paths[0].BytesReceived).To(Equal(protocol.ByteCount(110)))
+		})
+	})
+
 	Context("receiving packets", func() {
 		var hdr *wire.PublicHeader
 
@@ -1190,6 +1537,7 @@ var _ = Describe("Session", func() {
 				Expect(err).ToNot(HaveOccurred())
 				fcm := mocks_fc.NewMockFlowControlManager(mockCtrl)
 				sess.flowControlManager = fcm
+				fcm.EXPECT().UpdateBandwidthDelayProduct(gomock.Any())
 				fcm.EXPECT().GetWindowUpdates(false)
 				fcm.EXPECT().GetWindowUpdates(false)
 				fcm.EXPECT().GetReceiveWindow(protocol.StreamID(5)).Return(protocol.ByteCount(0x1000), nil)
@@ -1212,6 +1560,7 @@ var _ = Describe("Session", func() {
 				Expect(err).ToNot(HaveOccurred())
 				fcm := mocks_fc.NewMockFlowControlManager(mockCtrl)
 				sess.flowControlManager = fcm
+				fcm.EXPECT().UpdateBandwidthDelayProduct(gomock.Any())
 				fcm.EXPECT().GetWindowUpdates(false)
 				fcm.EXPECT().GetWindowUpdates(false)
 				fcm.EXPECT().GetReceiveWindow(protocol.StreamID(5)).Return(protocol.ByteCount(0x2000), nil)
@@ -1580,6 +1929,11 @@ var _ = Describe("Session", func() {
 			mockCpm = mocks.NewMockConnectionParametersManager(mockCtrl)
 			mockCpm.EXPECT().GetIdleConnectionStateLifetime().Return(9999 * time.Second).AnyTimes()
 			mockCpm.EXPECT().TruncateConnectionID().Return(false).AnyTimes()
+			mockCpm.EXPECT().GetAckSendDelay().Return(protocol.AckSendDelay).AnyTimes()
+			mockCpm.EXPECT().GetRetransmittablePacketsBeforeAck().Return(protocol.RetransmittablePacketsBeforeAck).AnyTimes()
+			mockCpm.EXPECT().PeerSupportsMultipath().Return(true).AnyTimes()
+			mockCpm.EXPECT().PeerSupportsUnreliableStreams().Return(true).AnyTimes()
+			mockCpm.EXPECT().PeerSupportsStopSending().Return(true).AnyTimes()
 			sess.connectionParameters = mockCpm
 			sess.packer.connectionParameters = mockCpm
 			// the handshake timeout is irrelevant here, since it depends on the time the session was created,
@@ -1597,6 +1951,11 @@ var _ = Describe("Session", func() {
 			mockCpm = mocks.NewMockConnectionParametersManager(mockCtrl)
 			mockCpm.EXPECT().GetIdleConnectionStateLifetime().Return(0 * time.Second)
 			mockCpm.EXPECT().TruncateConnectionID().Return(false).AnyTimes()
+			mockCpm.EXPECT().GetAckSendDelay().Return(protocol.AckSendDelay).AnyTimes()
+			mockCpm.EXPECT().GetRetransmittablePacketsBeforeAck().Return(protocol.RetransmittablePacketsBeforeAck).AnyTimes()
+			mockCpm.EXPECT().PeerSupportsMultipath().Return(true).AnyTimes()
+			mockCpm.EXPECT().PeerSupportsUnreliableStreams().Return(true).AnyTimes()
+			mockCpm.EXPECT().PeerSupportsStopSending().Return(true).AnyTimes()
 			sess.connectionParameters = mockCpm
 			sess.packer.connectionParameters = mockCpm
 			mockCpm.EXPECT().GetIdleConnectionStateLifetime().Return(0 * time.Second).AnyTimes()
@@ -1671,6 +2030,183 @@ var _ = Describe("Session", func() {
 		})
 	})
 
+	Context("ACKs received on another path", func() {
+		var pth *path
+
+		BeforeEach(func() {
+			pth = &path{pathID: 1, sess: sess, conn: mconn}
+			pth.setup(nil)
+			sess.paths[1] = pth
+			for i := protocol.PacketNumber(1); i <= 2; i++ {
+				err := pth.sentPacketHandler.SentPacket(&ackhandler.Packet{PacketNumber: i, Length: 1, Frames: []wire.Frame{&wire.PingFrame{}}})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			pth.lastNetworkActivityTime = time.Now().Add(-time.Hour)
+			pth.setPotentiallyFailed(true)
+			sess.paths[0].lastNetworkActivityTime = time.Now()
+		})
+
+		It("keeps the acked path alive", func() {
+			sess.paths[0].lastRcvdPacketNumber = 10
+			err := sess.handleFrames([]wire.Frame{&wire.AckFrame{PathID: 1, LargestAcked: 1, LowestAcked: 1}}, sess.paths[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pth.lastNetworkActivityTime).To(Equal(sess.paths[0].lastNetworkActivityTime))
+			Expect(pth.potentiallyFailed.Get()).To(BeFalse())
+			stalled, _ := pth.stalled(time.Now())
+			Expect(stalled).To(BeFalse())
+			Expect(pth.onRTO(time.Now().Add(-time.Minute))).To(BeFalse())
+		})
+
+		It("doesn't keep the acked path alive on ACKs that don't ack new packets", func() {
+			sess.paths[0].lastRcvdPacketNumber = 10
+			err := sess.handleFrames([]wire.Frame{&wire.AckFrame{PathID: 1, LargestAcked: 1, LowestAcked: 1}}, sess.paths[0])
+			Expect(err).NotTo(HaveOccurred())
+			lastActivity := pth.lastNetworkActivityTime
+			pth.setPotentiallyFailed(true)
+			sess.paths[0].lastRcvdPacketNumber = 11
+			sess.paths[0].lastNetworkActivityTime = time.Now().Add(time.Second)
+			err = sess.handleFrames([]wire.Frame{&wire.AckFrame{PathID: 1, LargestAcked: 1, LowestAcked: 1}}, sess.paths[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pth.lastNetworkActivityTime).To(Equal(lastActivity))
+			Expect(pth.potentiallyFailed.Get()).To(BeTrue())
+		})
+	})
+
+	Context("ACK path", func() {
+		var slowPath, fastPath *path
+
+		BeforeEach(func() {
+			sess.config.AckPathPolicy = AckOnLowestRTTPath
+			sess.handshakeComplete = true
+			slowPath = &path{pathID: 1, sess: sess, conn: mconn}
+			slowPath.setup(nil)
+			slowPath.rttStats.UpdateRTT(200*time.Millisecond, 0, time.Now())
+			fastPath = &path{pathID: 2, sess: sess, conn: mconn}
+			fastPath.setup(nil)
+			fastPath.rttStats.UpdateRTT(20*time.Millisecond, 0, time.Now())
+			sess.paths[1] = slowPath
+			sess.paths[2] = fastPath
+		})
+
+		It("sends the ACKs on the path with the lowest RTT, if the peer accepts them there", func() {
+			mockCpm.EXPECT().PeerAcceptsAcksOnOtherPaths().Return(true).AnyTimes()
+			Expect(sess.scheduler.ackPathFor(sess, slowPath)).To(Equal(fastPath))
+			Expect(sess.scheduler.ackPathFor(sess, fastPath)).To(Equal(fastPath))
+		})
+
+		It("sends the ACKs on the same path, if the peer doesn't accept them on other paths", func() {
+			mockCpm.EXPECT().PeerAcceptsAcksOnOtherPaths().Return(false).AnyTimes()
+			Expect(sess.scheduler.ackPathFor(sess, slowPath)).To(Equal(slowPath))
+			// the ACK of the slow path is not queued on the fast path
+			Expect(slowPath.receivedPacketHandler.ReceivedPacket(1, true)).To(Succeed())
+			sess.scheduler.queueAcksForOtherPaths(sess, fastPath)
+			Expect(slowPath.GetAckFrame()).ToNot(BeNil())
+		})
+	})
+
+	Context("stalled paths", func() {
+		var pth *path
+
+		BeforeEach(func() {
+			pth = &path{pathID: 1, sess: sess, conn: mconn}
+			pth.setup(nil)
+		})
+
+		It("doesn't stall a path that receives ACKs within the RTO", func() {
+			// a path with a low RTT, acked by the peer with delayed ACKs
+			now := time.Now()
+			pth.rttStats.UpdateRTT(5*time.Millisecond, 0, now)
+			for i := 0; i < 100; i++ {
+				pth.lastNetworkActivityTime = now
+				now = now.Add(minStallTimeout)
+				stalled, _ := pth.stalled(now)
+				Expect(stalled).To(BeFalse())
+				pth.rttStats.UpdateRTT(30*time.Millisecond, 25*time.Millisecond, now)
+			}
+		})
+
+		It("doesn't stall a path before the RTO with a variable RTT", func() {
+			now := time.Now()
+			for i := 0; i < 10; i++ {
+				pth.rttStats.UpdateRTT(time.Duration(100+100*(i%2))*time.Millisecond, 0, now)
+			}
+			rto := pth.rttStats.SmoothedRTT() + 4*pth.rttStats.MeanDeviation()
+			Expect(rto).To(BeNumerically(">", minStallTimeout))
+			pth.lastNetworkActivityTime = now
+			stalled, _ := pth.stalled(now.Add(rto))
+			Expect(stalled).To(BeFalse())
+			stalled, sentBefore := pth.stalled(now.Add(rto + time.Millisecond))
+			Expect(stalled).To(BeTrue())
+			Expect(sentBefore).To(Equal(now.Add(time.Millisecond)))
+		})
+
+		It("stalls a path after the minimum RTO", func() {
+			now := time.Now()
+			pth.rttStats.UpdateRTT(5*time.Millisecond, 0, now)
+			pth.lastNetworkActivityTime = now
+			stalled, _ := pth.stalled(now.Add(minStallTimeout))
+			Expect(stalled).To(BeFalse())
+			stalled, _ = pth.stalled(now.Add(minStallTimeout + time.Millisecond))
+			Expect(stalled).To(BeTrue())
+		})
+	})
+
+	Context("receive bandwidth", func() {
+		var pth *path
+
+		BeforeEach(func() {
+			pth = &path{pathID: 1, sess: sess, conn: mconn}
+			pth.setup(nil)
+		})
+
+		It("measures the bandwidth once per RTT", func() {
+			pth.rttStats.UpdateRTT(100*time.Millisecond, 0, time.Now())
+			start := time.Now()
+			pth.updateReceiveBandwidth(start)
+			pth.bytesReceived = 5000
+			pth.updateReceiveBandwidth(start.Add(50 * time.Millisecond))
+			Expect(pth.receiveBandwidth).To(BeZero())
+			pth.bytesReceived = 10000
+			pth.updateReceiveBandwidth(start.Add(100 * time.Millisecond))
+			// 10000 bytes in one RTT
+			Expect(pth.receiveBandwidth).To(Equal(100000 * congestion.BytesPerSecond))
+		})
+
+		It("sizes the windows for the bandwidth of all paths and the largest RTT", func() {
+			newPath := func(pathID protocol.PathID, rtt time.Duration, bandwidth congestion.Bandwidth) *path {
+				p := &path{pathID: pathID, sess: sess, conn: mconn}
+				p.setup(nil)
+				p.rttStats.UpdateRTT(rtt, 0, time.Now())
+				p.receiveBandwidth = bandwidth
+				sess.paths[pathID] = p
+				return p
+			}
+			sess.paths[0].open.Set(false)
+			newPath(1, 10*time.Millisecond, 10000000*congestion.BytesPerSecond)
+			newPath(2, 200*time.Millisecond, 1000000*congestion.BytesPerSecond)
+			// closed paths are ignored
+			newPath(3, time.Second, 1000000*congestion.BytesPerSecond).open.Set(false)
+			fcm := mocks_fc.NewMockFlowControlManager(mockCtrl)
+			sess.flowControlManager = fcm
+			// 11 MB/s for 200ms, the sum of the bandwidth-delay products of the paths would only be 300 kB
+			fcm.EXPECT().UpdateBandwidthDelayProduct(protocol.ByteCount(2200000))
+			sess.updateBandwidthDelayProduct()
+			fcm.EXPECT().GetWindowStatistics(protocol.StreamID(0)).Return(flowcontrol.WindowStatistics{ReceiveWindow: 0x3000, ReceiveWindowIncrement: 0x1000}, nil)
+			stats := sess.collectStats()
+			Expect(stats.BandwidthDelayProduct).To(Equal(protocol.ByteCount(2200000)))
+			Expect(stats.ReceiveWindow).To(Equal(protocol.ByteCount(0x3000)))
+			Expect(stats.ReceiveWindowIncrement).To(Equal(protocol.ByteCount(0x1000)))
+		})
+
+		It("doesn't measure the bandwidth without an RTT estimate", func() {
+			start := time.Now()
+			pth.updateReceiveBandwidth(start)
+			pth.bytesReceived = 10000
+			pth.updateReceiveBandwidth(start.Add(time.Second))
+			Expect(pth.receiveBandwidth).To(BeZero())
+		})
+	})
+
 	Context("ignoring errors", func() {
 		It("ignores duplicate acks", func() {
 			// XXX (QDC): adapted to multiple paths
@@ -1730,6 +2266,7 @@ var _ = Describe("Client Session", func() {
 		mconn       *mockConnection
 		pconnMgr    *pconnManager
 		aeadChanged chan<- protocol.EncryptionLevel
+		params      *handshake.TransportParameters
 
 		cryptoSetup *mockCryptoSetup
 	)
@@ -1746,10 +2283,12 @@ var _ = Describe("Client Session", func() {
 			_ *tls.Config,
 			_ handshake.ConnectionParametersManager,
 			aeadChangedP chan<- protocol.EncryptionLevel,
-			_ *handshake.TransportParameters,
+			paramsP *handshake.TransportParameters,
 			_ []protocol.VersionNumber,
+			_ handshake.KeyLogFunc,
 		) (handshake.CryptoSetup, error) {
 			aeadChanged = aeadChangedP
+			params = paramsP
 			return cryptoSetup, nil
 		}
 
@@ -1765,6 +2304,7 @@ var _ = Describe("Client Session", func() {
 			nil,
 			populateClientConfig(&Config{}),
 			nil,
+			true,
 		)
 		sess = sessP.(*session)
 		Expect(err).ToNot(HaveOccurred())
@@ -1795,12 +2335,45 @@ var _ = Describe("Client Session", func() {
 	})
 
 	It("does not block if an error occurs", func(done Done) {
-		// this test basically tests that the handshakeChan has a capacity of 3
+		// this test basically tests that the handshakeChan has a capacity of 4
 		// The session needs to run (and close) properly, even if no one is receiving from the handshakeChan
 		go sess.run()
+		aeadChanged <- protocol.EncryptionSecure // 0-RTT keys
 		aeadChanged <- protocol.EncryptionSecure
 		aeadChanged <- protocol.EncryptionForwardSecure
 		Expect(sess.Close(nil)).To(Succeed())
 		close(done)
 	})
+
+	Context("0-RTT", func() {
+		It("sends the 0-RTT data again when the server rejects it", func() {
+			str, err := sess.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			f := &wire.StreamFrame{StreamID: str.StreamID(), Data: []byte("foobar")}
+			sph := newMockSentPacketHandler().(*mockSentPacketHandler)
+			sph.earlyPackets = []*ackhandler.Packet{{
+				PacketNumber:    1,
+				Frames:          []wire.Frame{f},
+				EncryptionLevel: protocol.EncryptionSecure,
+			}}
+			sess.paths[protocol.InitialPathID].sentPacketHandler = sph
+			Expect(sess.EarlyDataRejected()).ToNot(BeClosed())
+			sess.retransmitEarlyData()
+			Expect(sess.EarlyDataRejected()).To(BeClosed())
+			Expect(sess.streamFramer.retransmissionQueue).To(Equal([]*wire.StreamFrame{f}))
+			Expect(sph.earlyPackets).To(BeEmpty())
+		})
+
+		It("notices when the server rejected the 0-RTT keys", func(done Done) {
+			go sess.run()
+			aeadChanged <- protocol.EncryptionUnencrypted
+			Eventually(sess.EarlyDataRejected()).Should(BeClosed())
+			Expect(sess.Close(nil)).To(Succeed())
+			close(done)
+		})
+
+		It("requests 0-RTT keys from the crypto setup", func() {
+			Expect(params.EarlyData).To(BeTrue())
+		})
+	})
 })
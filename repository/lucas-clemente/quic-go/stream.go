@@ -2,6 +2,7 @@ package quic
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -12,6 +13,7 @@ import (
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/utils"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/wire"
+	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/qerr"
 )
 
 // A Stream assembles the data from StreamFrames and provides a super-convenient Read-Interface
@@ -27,7 +29,11 @@ type stream struct {
 	onData   func()
 	// onReset is a callback that should send a RST_STREAM
 	// 这个函数是一个回调函数，目的是当该stream被关闭的时候发送一个RST_STREAM 的Frame
-	onReset func(protocol.StreamID, protocol.ByteCount)
+	onReset func(protocol.StreamID, protocol.ByteCount, StreamErrorCode)
+	// onStopSending is a callback that should send a STOP_SENDING
+	onStopSending func(protocol.StreamID, StreamErrorCode)
+	// peerSupportsStopSending says if onStopSending can be used, if nil it can always be used
+	peerSupportsStopSending func() bool
 
 	readPosInFrame int
 	writeOffset    protocol.ByteCount // 写入的offset
@@ -46,6 +52,9 @@ type stream struct {
 	resetLocally utils.AtomicBool
 	// resetRemotely is set if RegisterRemoteError() is called
 	resetRemotely utils.AtomicBool
+	// set once the reading or the writing was canceled, returned by Read and Write
+	cancelReadErr  error
+	cancelWriteErr error
 
 	frameQueue *streamFrameSorter
 
@@ -60,6 +69,29 @@ type stream struct {
 	unreliableMarker   bool
 	sess               *session
 	flowControlManager flowcontrol.FlowControlManager
+
+	// the maximum number of bytes queued in dataForWriting before Write blocks
+	// If zero, Write blocks until all its data was handed to the framer
+	sendBufferSize protocol.ByteCount
+	// for unreliable streams, what happens to a message when the send queue is full
+	sendQueuePolicy SendQueuePolicy
+	// the lengths of the messages in dataForWriting, if a drop policy is used
+	queuedMessages []protocol.ByteCount
+	// the number of bytes of the first queued message that were already handed to the framer
+	firstMessageSent protocol.ByteCount
+	bytesDropped     protocol.ByteCount
+
+	// the priority used to schedule the data of the stream
+	urgency     uint8
+	incremental bool
+
+	// the paths on which the data of the stream is sent
+	pathPreference []protocol.PathID
+	pathPolicy     PathPolicy
+
+	// set for unidirectional streams, depending on which side opened them
+	sendOnly    bool
+	receiveOnly bool
 }
 
 var _ Stream = &stream{}
@@ -72,11 +104,15 @@ func (deadlineError) Timeout() bool   { return true }
 
 var errDeadline net.Error = &deadlineError{}
 
+// ErrMessageDropped is returned by Write on unreliable streams with a drop policy,
+// if the message was dropped because it didn't fit into the send queue
+var ErrMessageDropped = errors.New("message dropped, the send queue is full")
+
 // newStream creates a new Stream
 // unused
 func newStream(StreamID protocol.StreamID,
 	onData func(),
-	onReset func(protocol.StreamID, protocol.ByteCount),
+	onReset func(protocol.StreamID, protocol.ByteCount, StreamErrorCode),
 	flowControlManager flowcontrol.FlowControlManager) *stream {
 	s := &stream{
 		onData:             onData,
@@ -86,6 +122,8 @@ func newStream(StreamID protocol.StreamID,
 		frameQueue:         newStreamFrameSorter(),
 		readChan:           make(chan struct{}, 1),
 		writeChan:          make(chan struct{}, 1),
+		urgency:            protocol.DefaultStreamUrgency,
+		incremental:        true,
 	}
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
 	return s
@@ -94,7 +132,7 @@ func newStream(StreamID protocol.StreamID,
 // newStream creates a new Stream
 func newStreamType(StreamID protocol.StreamID,
 	onData func(),
-	onReset func(protocol.StreamID, protocol.ByteCount),
+	onReset func(protocol.StreamID, protocol.ByteCount, StreamErrorCode),
 	flowControlManager flowcontrol.FlowControlManager, sess *session) *stream {
 	s := &stream{
 		onData:             onData,
@@ -104,8 +142,13 @@ func newStreamType(StreamID protocol.StreamID,
 		frameQueue:         newStreamFrameSorter(),
 		readChan:           make(chan struct{}, 1),
 		writeChan:          make(chan struct{}, 1),
+		urgency:            protocol.DefaultStreamUrgency,
+		incremental:        true,
 		sess:               sess,
+		sendBufferSize:     protocol.ByteCount(sess.config.StreamSendBufferSize),
 	}
+	s.onStopSending = sess.queueStopSendingFrame
+	s.peerSupportsStopSending = sess.connectionParameters.PeerSupportsStopSending
 	s.frameQueue.sess = sess
 	s.frameQueue.SID = StreamID
 	s.frameQueue.unreliableMarker = s.frameQueue.sess.streamsMap.unreliableStreamMark[StreamID]
@@ -114,14 +157,34 @@ func newStreamType(StreamID protocol.StreamID,
 	return s
 }
 
+// makeSendOnly turns the stream into the sending half of a unidirectional stream
+func (s *stream) makeSendOnly() {
+	s.sendOnly = true
+	s.finishedReading.Set(true)
+}
+
+// makeReceiveOnly turns the stream into the receiving half of a unidirectional stream
+func (s *stream) makeReceiveOnly() {
+	s.receiveOnly = true
+	s.finishedWriting.Set(true)
+	s.finSent.Set(true)
+}
+
 // Read implements io.Reader. It is not thread safe!
 func (s *stream) Read(p []byte) (int, error) { // 读取一定的字节数到[]byte当中
+	if s.sendOnly {
+		return 0, fmt.Errorf("read from send-only stream %d", s.streamID)
+	}
 	s.mutex.Lock()
 	err := s.err
+	cancelReadErr := s.cancelReadErr
 	s.mutex.Unlock()
 	if s.cancelled.Get() || s.resetLocally.Get() { //如果该流被重置了，或者是被关闭了，那么该流就返回零
 		return 0, err
 	}
+	if cancelReadErr != nil {
+		return 0, cancelReadErr
+	}
 	if s.finishedReading.Get() {
 		return 0, io.EOF
 	}
@@ -143,6 +206,10 @@ func (s *stream) Read(p []byte) (int, error) { // 读取一定的字节数到[]b
 				err = s.err
 				break
 			}
+			if s.cancelReadErr != nil {
+				err = s.cancelReadErr
+				break
+			}
 
 			deadline := s.readDeadline
 			if !deadline.IsZero() && !time.Now().Before(deadline) { //如果已经超时的话
@@ -217,59 +284,189 @@ func (s *stream) Read(p []byte) (int, error) { // 读取一定的字节数到[]b
 //	}
 // }
 func (s *stream) Write(p []byte) (int, error) {
+	return s.WriteBuffers([][]byte{p})
+}
+
+// WriteBuffers writes the data of all buffers to the stream, as a single Write would do with their concatenation
+func (s *stream) WriteBuffers(bufs [][]byte) (int, error) {
+	if s.receiveOnly {
+		return 0, fmt.Errorf("write on receive-only stream %d", s.streamID)
+	}
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	if s.resetLocally.Get() || s.err != nil {
 		return 0, s.err
 	}
+	if s.cancelWriteErr != nil {
+		return 0, s.cancelWriteErr
+	}
 	if s.finishedWriting.Get() {
 		return 0, fmt.Errorf("write on closed stream %d", s.streamID)
 	}
-	if len(p) == 0 {
+	var length int
+	for _, b := range bufs {
+		length += len(b)
+	}
+	if length == 0 {
 		return 0, nil
 	}
+	if s.sendQueuePolicy != SendQueueBlock {
+		return s.writeMessage(bufs, length)
+	}
+	if s.sendBufferSize == 0 {
+		return s.writeAndWait(bufs, length)
+	}
+	return s.writeBuffered(bufs)
+}
+
+// writeMessage queues the data as one message, or drops messages according to the send queue policy if it doesn't fit.
+// It never blocks. If the new message is dropped, it returns an ErrMessageDropped.
+// Attention: this function must only be called if the mutex has been acquired previously
+func (s *stream) writeMessage(bufs [][]byte, length int) (int, error) {
+	l := protocol.ByteCount(length)
+	// a message larger than the send queue can't be queued, no matter how many messages are dropped
+	if l > s.sendBufferSize {
+		s.bytesDropped += l
+		return 0, ErrMessageDropped
+	}
+	if s.sendQueuePolicy == SendQueueDropOldest {
+		for protocol.ByteCount(len(s.dataForWriting))+l > s.sendBufferSize {
+			if !s.dropOldestMessage() {
+				break
+			}
+		}
+	}
+	if protocol.ByteCount(len(s.dataForWriting))+l > s.sendBufferSize {
+		s.bytesDropped += l
+		return 0, ErrMessageDropped
+	}
+	for _, b := range bufs {
+		s.dataForWriting = append(s.dataForWriting, b...)
+	}
+	s.queuedMessages = append(s.queuedMessages, l)
+	s.onData()
+	return length, nil
+}
+
+// dropOldestMessage removes the oldest message that the framer didn't start to send yet from the send queue
+// Attention: this function must only be called if the mutex has been acquired previously
+func (s *stream) dropOldestMessage() bool {
+	var i int
+	var start protocol.ByteCount
+	if s.firstMessageSent > 0 {
+		// the first message was partially sent, and has to be sent completely
+		i = 1
+		start = s.queuedMessages[0] - s.firstMessageSent
+	}
+	if i >= len(s.queuedMessages) {
+		return false
+	}
+	l := s.queuedMessages[i]
+	s.dataForWriting = append(s.dataForWriting[:start], s.dataForWriting[start+l:]...)
+	if len(s.dataForWriting) == 0 {
+		s.dataForWriting = nil
+	}
+	s.queuedMessages = append(s.queuedMessages[:i], s.queuedMessages[i+1:]...)
+	s.bytesDropped += l
+	return true
+}
+
+// setSendQueue bounds the send queue of an unreliable stream, and sets the policy applied when it is full
+func (s *stream) setSendQueue(size protocol.ByteCount, policy SendQueuePolicy) {
+	s.mutex.Lock()
+	s.sendBufferSize = size
+	s.sendQueuePolicy = policy
+	s.mutex.Unlock()
+}
 
-	s.dataForWriting = make([]byte, len(p)) //将写入的数据放入到缓冲区里面
-	copy(s.dataForWriting, p)
+// writeAndWait queues the data and blocks until the framer took all of it
+// Attention: this function must only be called if the mutex has been acquired previously
+func (s *stream) writeAndWait(bufs [][]byte, length int) (int, error) {
+	s.dataForWriting = make([]byte, 0, length) //将写入的数据放入到缓冲区里面
+	for _, b := range bufs {
+		s.dataForWriting = append(s.dataForWriting, b...)
+	}
 	s.onData() //通知session存在数据要发送了
 
 	var err error
-	for {
-		deadline := s.writeDeadline                             //一般这个是为0的
-		if !deadline.IsZero() && !time.Now().Before(deadline) { //如果deadline不是0，且已经超时的话
-			err = errDeadline
-			break
-		}
-		if s.dataForWriting == nil || s.err != nil { //此时证明缓冲区已经被发送完毕了，因此可以退出for循环了
+	for s.dataForWriting != nil { //缓冲区被发送完毕的时候退出循环
+		if err = s.waitForWriteSpace(); err != nil {
 			break
 		}
+	}
 
-		s.mutex.Unlock()
-		if deadline.IsZero() {
-			<-s.writeChan //一旦有人从缓冲区中拿数据，都会通过该管道通知的
-		} else {
-			select {
-			case <-s.writeChan:
-			case <-time.After(deadline.Sub(time.Now())):
+	if err == errDeadline {
+		return 0, err
+	}
+	if s.cancelWriteErr != nil {
+		n := length - len(s.dataForWriting)
+		s.dataForWriting = nil
+		return n, err
+	}
+	if err != nil { //如果报错的话
+		return length - len(s.dataForWriting), err //返回发出的报文字节数和错误的原因
+	}
+	return length, nil
+}
+
+// writeBuffered copies the data into the send buffer, and only blocks while the buffer is full
+// Attention: this function must only be called if the mutex has been acquired previously
+func (s *stream) writeBuffered(bufs [][]byte) (int, error) {
+	var n int
+	for _, b := range bufs {
+		for len(b) > 0 {
+			free := int(s.sendBufferSize) - len(s.dataForWriting)
+			if free <= 0 {
+				if err := s.waitForWriteSpace(); err != nil {
+					return n, err
+				}
+				continue
 			}
+			if free > len(b) {
+				free = len(b)
+			}
+			s.dataForWriting = append(s.dataForWriting, b[:free]...)
+			b = b[free:]
+			n += free
+			s.onData()
 		}
-		s.mutex.Lock()
 	}
+	return n, nil
+}
 
-	if err != nil {
-		return 0, err
+// waitForWriteSpace blocks until the framer took data from the stream, or the write deadline expires
+// Attention: this function must only be called if the mutex has been acquired previously
+func (s *stream) waitForWriteSpace() error {
+	deadline := s.writeDeadline                             //一般这个是为0的
+	if !deadline.IsZero() && !time.Now().Before(deadline) { //如果deadline不是0，且已经超时的话
+		return errDeadline
+	}
+
+	s.mutex.Unlock()
+	if deadline.IsZero() {
+		<-s.writeChan //一旦有人从缓冲区中拿数据，都会通过该管道通知的
+	} else {
+		select {
+		case <-s.writeChan:
+		case <-time.After(deadline.Sub(time.Now())):
+		}
 	}
-	if s.err != nil { //如果报错的话
-		return len(p) - len(s.dataForWriting), s.err //返回发出的报文字节数和错误的原因
+	s.mutex.Lock()
+
+	if s.err != nil {
+		return s.err
 	}
-	return len(p), nil
+	if s.cancelWriteErr != nil {
+		return s.cancelWriteErr
+	}
+	return nil
 }
 
 func (s *stream) lenOfDataForWriting() protocol.ByteCount {
 	s.mutex.Lock()
 	var l protocol.ByteCount
-	if s.err == nil {
+	if s.err == nil && s.cancelWriteErr == nil {
 		l = protocol.ByteCount(len(s.dataForWriting))
 	}
 	s.mutex.Unlock()
@@ -281,19 +478,29 @@ func (s *stream) getDataForWriting(maxBytes protocol.ByteCount) []byte {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if s.err != nil || s.dataForWriting == nil {
+	if s.err != nil || s.cancelWriteErr != nil || s.dataForWriting == nil {
 		return nil
 	}
 
 	var ret []byte
 	if protocol.ByteCount(len(s.dataForWriting)) > maxBytes { //如果缓冲区当中的数据大于所需要的话，那么久拿到所需要的数据
-		ret = s.dataForWriting[:maxBytes]
+		ret = s.dataForWriting[:maxBytes:maxBytes]
 		s.dataForWriting = s.dataForWriting[maxBytes:]
+		if s.sendBufferSize > 0 {
+			s.signalWrite()
+		}
 	} else {
 		ret = s.dataForWriting
 		s.dataForWriting = nil
 		s.signalWrite()
 	}
+	if s.queuedMessages != nil {
+		s.firstMessageSent += protocol.ByteCount(len(ret))
+		for len(s.queuedMessages) > 0 && s.firstMessageSent >= s.queuedMessages[0] {
+			s.firstMessageSent -= s.queuedMessages[0]
+			s.queuedMessages = s.queuedMessages[1:]
+		}
+	}
 	s.writeOffset += protocol.ByteCount(len(ret))
 	return ret
 }
@@ -315,7 +522,7 @@ func (s *stream) shouldSendReset() bool {
 
 func (s *stream) shouldSendFin() bool {
 	s.mutex.Lock()
-	res := s.finishedWriting.Get() && !s.finSent.Get() && s.err == nil && s.dataForWriting == nil
+	res := s.finishedWriting.Get() && !s.finSent.Get() && s.err == nil && s.cancelWriteErr == nil && s.dataForWriting == nil
 	s.mutex.Unlock()
 	return res
 }
@@ -327,6 +534,9 @@ func (s *stream) sentFin() {
 // AddStreamFrame adds a new stream frame
 // 添加一个stream frame
 func (s *stream) AddStreamFrame(frame *wire.StreamFrame) error {
+	if s.sendOnly {
+		return qerr.Error(qerr.InvalidStreamID, fmt.Sprintf("received data for send-only stream %d", s.streamID))
+	}
 	maxOffset := frame.Offset + frame.DataLen()
 	err := s.flowControlManager.UpdateHighestReceived(s.streamID, maxOffset)
 	if err != nil {
@@ -335,6 +545,14 @@ func (s *stream) AddStreamFrame(frame *wire.StreamFrame) error {
 
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
+	if s.cancelReadErr != nil {
+		// The data is discarded, but it still counts for connection-level flow control
+		if maxOffset > s.readOffset {
+			s.flowControlManager.AddBytesRead(s.streamID, maxOffset-s.readOffset)
+			s.readOffset = maxOffset
+		}
+		return nil
+	}
 	if !s.unreliableMarker { //可靠
 		err = s.frameQueue.Push(frame, true)
 	} else { //非可靠
@@ -418,6 +636,65 @@ func (s *stream) Cancel(err error) {
 	s.mutex.Unlock()
 }
 
+// CancelRead aborts receiving on the stream and asks the peer to stop sending
+func (s *stream) CancelRead(errorCode StreamErrorCode) {
+	if s.sendOnly {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.finishedReading.Get() || s.cancelReadErr != nil {
+		return
+	}
+	s.cancelReadErr = &StreamError{StreamID: s.streamID, ErrorCode: errorCode}
+	s.finishedReading.Set(true)
+	s.frameQueue = newStreamFrameSorter()
+	s.signalRead()
+	if s.peerSupportsStopSending != nil && !s.peerSupportsStopSending() {
+		// older peers don't know STOP_SENDING, but stop sending when they receive a RST_STREAM
+		// This aborts the writing as well.
+		if !s.receiveOnly && s.cancelWriteErr == nil && !s.finishedWriteAndSentFin() {
+			s.cancelWriteErr = s.cancelReadErr
+			s.ctxCancel()
+			s.signalWrite()
+		}
+		if !s.rstSent.Get() {
+			s.onReset(s.streamID, s.writeOffset, errorCode)
+			s.rstSent.Set(true)
+		}
+		return
+	}
+	if s.onStopSending != nil {
+		s.onStopSending(s.streamID, errorCode)
+	}
+}
+
+// CancelWrite aborts sending on the stream and sends a RST_STREAM
+func (s *stream) CancelWrite(errorCode StreamErrorCode) {
+	s.mutex.Lock()
+	s.cancelWriteImpl(&StreamError{StreamID: s.streamID, ErrorCode: errorCode}, errorCode)
+	s.mutex.Unlock()
+}
+
+// StopSending is called by session when the peer asked to stop sending on the stream
+func (s *stream) StopSending(errorCode StreamErrorCode) {
+	s.mutex.Lock()
+	s.cancelWriteImpl(&StreamError{StreamID: s.streamID, ErrorCode: errorCode, Remote: true}, errorCode)
+	s.mutex.Unlock()
+}
+
+// Attention: this function must only be called if the mutex has been acquired previously
+func (s *stream) cancelWriteImpl(err error, errorCode StreamErrorCode) {
+	if s.receiveOnly || s.cancelWriteErr != nil || s.rstSent.Get() || s.finishedWriteAndSentFin() {
+		return
+	}
+	s.cancelWriteErr = err
+	s.ctxCancel()
+	s.signalWrite()
+	s.onReset(s.streamID, s.writeOffset, errorCode)
+	s.rstSent.Set(true)
+}
+
 // resets the stream locally
 func (s *stream) Reset(err error) {
 	if s.resetLocally.Get() {
@@ -433,7 +710,7 @@ func (s *stream) Reset(err error) {
 		s.signalWrite()
 	}
 	if s.shouldSendReset() {
-		s.onReset(s.streamID, s.writeOffset)
+		s.onReset(s.streamID, s.writeOffset, 0)
 		s.rstSent.Set(true)
 	}
 	s.mutex.Unlock()
@@ -453,7 +730,7 @@ func (s *stream) RegisterRemoteError(err error) {
 		s.signalWrite()
 	}
 	if s.shouldSendReset() {
-		s.onReset(s.streamID, s.writeOffset)
+		s.onReset(s.streamID, s.writeOffset, 0)
 		s.rstSent.Set(true)
 	}
 	s.mutex.Unlock()
@@ -479,6 +756,44 @@ func (s *stream) StreamID() protocol.StreamID {
 	return s.streamID
 }
 
+// SetPriority sets the priority of the stream.
+// The urgency is capped to protocol.MaxStreamUrgency.
+func (s *stream) SetPriority(urgency uint8, incremental bool) {
+	if urgency > protocol.MaxStreamUrgency {
+		urgency = protocol.MaxStreamUrgency
+	}
+	s.mutex.Lock()
+	s.urgency = urgency
+	s.incremental = incremental
+	s.mutex.Unlock()
+}
+
+func (s *stream) getPriority() (uint8, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.urgency, s.incremental
+}
+
+// SetPathPreference sets the paths on which the data of the stream is sent
+func (s *stream) SetPathPreference(pathIDs []protocol.PathID) {
+	s.mutex.Lock()
+	s.pathPreference = append([]protocol.PathID(nil), pathIDs...)
+	s.mutex.Unlock()
+}
+
+// SetPathPolicy sets the policy used to select the paths on which the data of the stream is sent
+func (s *stream) SetPathPolicy(policy PathPolicy) {
+	s.mutex.Lock()
+	s.pathPolicy = policy
+	s.mutex.Unlock()
+}
+
+func (s *stream) getPathPreference() ([]protocol.PathID, PathPolicy) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.pathPreference, s.pathPolicy
+}
+
 func (s *stream) GetBytesSent() (protocol.ByteCount, error) {
 	return s.flowControlManager.GetBytesSent(s.streamID)
 }
@@ -486,3 +801,9 @@ func (s *stream) GetBytesSent() (protocol.ByteCount, error) {
 func (s *stream) GetBytesRetrans() (protocol.ByteCount, error) {
 	return s.flowControlManager.GetBytesRetrans(s.streamID)
 }
+
+func (s *stream) GetBytesDropped() protocol.ByteCount {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.bytesDropped
+}
@@ -82,7 +82,6 @@ func (s *streamFrameSorter) Push(frame *wire.StreamFrame, flag bool) error {
 	for gap = s.gaps.Front(); gap != nil; gap = gap.Next() {
 		// the frame is a duplicate. Ignore it
 		if end <= gap.Value.Start {
-			log.Println(end)
 			return errDuplicateStreamData
 		}
 		if end > gap.Value.Start && start <= gap.Value.End { //证明存在交叉
@@ -222,6 +221,9 @@ func (s *streamFrameSorter) Head() *wire.StreamFrame {
 		dataPadding = make([]byte, -elem.Value.Start+elem.Value.End+1, -elem.Value.Start+elem.Value.End+1)
 		s.cntZero += -elem.Value.Start + elem.Value.End + 1
 		log.Println("zero filled:", s.cntZero)
+		if s.sess != nil {
+			s.sess.onZeroFilled(s.SID, elem.Value.Start, -elem.Value.Start+elem.Value.End+1)
+		}
 		res = &wire.StreamFrame{Offset: elem.Value.Start, Data: dataPadding}
 		s.Push(res, false)
 		//}
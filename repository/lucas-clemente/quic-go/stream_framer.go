@@ -20,6 +20,11 @@ type streamFramer struct {
 	addAddressFrameQueue []*wire.AddAddressFrame
 	closePathFrameQueue  []*wire.ClosePathFrame
 	pathsFrame           *wire.PathsFrame
+
+	// the paths that can be used to send the data of the streams, nil if there is a single path
+	usablePaths      map[protocol.PathID]bool
+	lowestRTTPathID  protocol.PathID
+	hasLowestRTTPath bool
 }
 
 func newStreamFramer(streamsMap *streamsMap, flowControlManager flowcontrol.FlowControlManager) *streamFramer {
@@ -34,15 +39,60 @@ func (f *streamFramer) AddFrameForRetransmission(frame *wire.StreamFrame) {
 }
 
 func (f *streamFramer) PopStreamFrames(maxLen protocol.ByteCount) []*wire.StreamFrame { //这个地方是不是可以改？
-	fs, _ := f.maybePopFramesForRetransmission(maxLen) //先拿到需要重传的frame
+	fs, _ := f.maybePopFramesForRetransmission(maxLen, nil) //先拿到需要重传的frame
 	//currentLen = 0
 	return fs //append(fs, f.maybePopNormalFrames(maxLen-currentLen, path)...)
 }
 
 func (f *streamFramer) PopStreamFrames2(maxLen protocol.ByteCount, path *path) []*wire.StreamFrame { //这个地方是不是可以改？
-	fs, currentLen := f.maybePopFramesForRetransmission(maxLen) //先拿到需要重传的frame
+	fs, currentLen := f.maybePopFramesForRetransmission(maxLen, path) //先拿到需要重传的frame
 	return append(fs, f.maybePopNormalFrames(maxLen-currentLen, path)...)
 }
+
+// SetStreamPaths sets the paths that can be used to send the data of the streams.
+// usablePaths is nil if there is a single path, lowestRTTPath is nil if no path has an RTT estimate yet.
+func (f *streamFramer) SetStreamPaths(usablePaths map[protocol.PathID]bool, lowestRTTPath *path) {
+	f.usablePaths = usablePaths
+	f.hasLowestRTTPath = lowestRTTPath != nil
+	if lowestRTTPath != nil {
+		f.lowestRTTPathID = lowestRTTPath.pathID
+	}
+}
+
+// canSendOnPath returns true if the data of the stream can be sent on the path, according to its path preference
+func (f *streamFramer) canSendOnPath(s *stream, pathID protocol.PathID) bool {
+	if f.usablePaths == nil {
+		return true
+	}
+	pathIDs, policy := s.getPathPreference()
+	if policy == SendOnLowestRTTPath && f.hasLowestRTTPath {
+		return pathID == f.lowestRTTPathID
+	}
+	// Fall back to any path if none of the preferred paths is usable
+	preferredPathUsable := false
+	for _, id := range pathIDs {
+		if id == pathID {
+			return true
+		}
+		if f.usablePaths[id] {
+			preferredPathUsable = true
+		}
+	}
+	return !preferredPathUsable
+}
+
+func (f *streamFramer) canSendStreamOnPath(streamID protocol.StreamID, pathID protocol.PathID) bool {
+	if f.usablePaths == nil {
+		return true
+	}
+	f.streamsMap.mutex.RLock()
+	s, ok := f.streamsMap.streams[streamID]
+	f.streamsMap.mutex.RUnlock()
+	if !ok || s == nil {
+		return true
+	}
+	return f.canSendOnPath(s, pathID)
+}
 func (f *streamFramer) PopBlockedFrame() *wire.BlockedFrame {
 	if len(f.blockedFrameQueue) == 0 {
 		return nil
@@ -131,9 +181,15 @@ func (f *streamFramer) PopCryptoStreamFrame(maxLen protocol.ByteCount) *wire.Str
 	return frame
 }
 
-func (f *streamFramer) maybePopFramesForRetransmission(maxLen protocol.ByteCount) (res []*wire.StreamFrame, currentLen protocol.ByteCount) {
-	for len(f.retransmissionQueue) > 0 { //从重传队列当中拿出需要重传的所有报文，这个队列应该是在scheduler当中添加的。
-		frame := f.retransmissionQueue[0]
+// maybePopFramesForRetransmission pops the frames to retransmit on the path.
+// The frames of streams that prefer other paths stay in the queue, unless the path is nil.
+func (f *streamFramer) maybePopFramesForRetransmission(maxLen protocol.ByteCount, path *path) (res []*wire.StreamFrame, currentLen protocol.ByteCount) {
+	for i := 0; i < len(f.retransmissionQueue); { //从重传队列当中拿出需要重传的所有报文，这个队列应该是在scheduler当中添加的。
+		frame := f.retransmissionQueue[i]
+		if path != nil && !f.canSendStreamOnPath(frame.StreamID, path.pathID) {
+			i++
+			continue
+		}
 		frame.DataLenPresent = true
 
 		frameHeaderLen, _ := frame.MinLength(protocol.VersionWhatever) // can never error
@@ -155,7 +211,7 @@ func (f *streamFramer) maybePopFramesForRetransmission(maxLen protocol.ByteCount
 			break
 		}
 
-		f.retransmissionQueue = f.retransmissionQueue[1:]
+		f.retransmissionQueue = append(f.retransmissionQueue[:i], f.retransmissionQueue[i+1:]...)
 		res = append(res, frame)
 		frameLen := frame.DataLen()
 		currentLen += frameLen
@@ -176,6 +232,9 @@ func (f *streamFramer) maybePopNormalFrames(maxBytes protocol.ByteCount, path *p
 		if s == nil || s.streamID == 1 /* crypto stream is handled separately */ {
 			return true, nil
 		}
+		if path != nil && !f.canSendOnPath(s, path.pathID) {
+			return true, nil
+		}
 
 		frame.StreamID = s.streamID
 		// not perfect, but thread-safe since writeOffset is only written when getting data
@@ -37,7 +37,7 @@ var _ = Describe("Stream Framer", func() {
 		stream1 = &stream{streamID: id1}
 		stream2 = &stream{streamID: id2}
 
-		streamsMap = newStreamsMap(nil, protocol.PerspectiveServer, nil)
+		streamsMap = newStreamsMap(nil, nil, protocol.PerspectiveServer, nil)
 		streamsMap.putStream(stream1)
 		streamsMap.putStream(stream2)
 
@@ -233,7 +233,7 @@ var _ = Describe("Stream Framer", func() {
 					if i - int(frameHeaderLen) > 0 {
 						mockFcm.EXPECT().AddBytesRetrans(origFrame.StreamID, protocol.ByteCount(i) - frameHeaderLen)
 					}
-					frames, currentLen := framer.maybePopFramesForRetransmission(protocol.ByteCount(i))
+					frames, currentLen := framer.maybePopFramesForRetransmission(protocol.ByteCount(i), nil)
 					if len(frames) == 0 {
 						Expect(currentLen).To(BeZero())
 					} else {
@@ -466,4 +466,66 @@ var _ = Describe("Stream Framer", func() {
 			Expect(framer.PopBlockedFrame()).To(BeNil())
 		})
 	})
+
+	Context("path preference", func() {
+		var pth1, pth2 *path
+
+		BeforeEach(func() {
+			pth1 = &path{pathID: 1, sentPacketHandler: &mockSentPacketHandler{}}
+			pth2 = &path{pathID: 2, sentPacketHandler: &mockSentPacketHandler{}}
+			framer.SetStreamPaths(map[protocol.PathID]bool{1: true, 2: true}, nil)
+		})
+
+		It("sends on every path by default", func() {
+			Expect(framer.canSendOnPath(stream1, 1)).To(BeTrue())
+			Expect(framer.canSendOnPath(stream1, 2)).To(BeTrue())
+		})
+
+		It("only sends on the preferred paths", func() {
+			stream1.SetPathPreference([]protocol.PathID{2})
+			Expect(framer.canSendOnPath(stream1, 1)).To(BeFalse())
+			Expect(framer.canSendOnPath(stream1, 2)).To(BeTrue())
+		})
+
+		It("sends on any path if none of the preferred paths is usable", func() {
+			stream1.SetPathPreference([]protocol.PathID{3})
+			Expect(framer.canSendOnPath(stream1, 1)).To(BeTrue())
+			Expect(framer.canSendOnPath(stream1, 2)).To(BeTrue())
+		})
+
+		It("ignores the preference if there is a single path", func() {
+			framer.SetStreamPaths(nil, nil)
+			stream1.SetPathPreference([]protocol.PathID{2})
+			Expect(framer.canSendOnPath(stream1, 1)).To(BeTrue())
+		})
+
+		It("only sends on the path with the lowest RTT", func() {
+			framer.SetStreamPaths(map[protocol.PathID]bool{1: true, 2: true}, pth2)
+			stream1.SetPathPolicy(SendOnLowestRTTPath)
+			Expect(framer.canSendOnPath(stream1, 1)).To(BeFalse())
+			Expect(framer.canSendOnPath(stream1, 2)).To(BeTrue())
+			Expect(framer.canSendOnPath(stream2, 1)).To(BeTrue())
+		})
+
+		It("sends on any path if no path has an RTT estimate yet", func() {
+			stream1.SetPathPolicy(SendOnLowestRTTPath)
+			Expect(framer.canSendOnPath(stream1, 1)).To(BeTrue())
+			Expect(framer.canSendOnPath(stream1, 2)).To(BeTrue())
+		})
+
+		It("keeps retransmissions of streams that prefer other paths", func() {
+			stream1.SetPathPreference([]protocol.PathID{2})
+			frame1 := &wire.StreamFrame{StreamID: id1, Data: []byte("foo")}
+			frame2 := &wire.StreamFrame{StreamID: id2, Data: []byte("bar")}
+			framer.AddFrameForRetransmission(frame1)
+			framer.AddFrameForRetransmission(frame2)
+			mockFcm.EXPECT().AddBytesRetrans(id2, protocol.ByteCount(3))
+			fs := framer.PopStreamFrames2(1000, pth1)
+			Expect(fs).To(Equal([]*wire.StreamFrame{frame2}))
+			mockFcm.EXPECT().AddBytesRetrans(id1, protocol.ByteCount(3))
+			fs = framer.PopStreamFrames2(1000, pth2)
+			Expect(fs).To(Equal([]*wire.StreamFrame{frame1}))
+			Expect(framer.HasFramesForRetransmission()).To(BeFalse())
+		})
+	})
 })
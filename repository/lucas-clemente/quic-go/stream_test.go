@@ -29,6 +29,7 @@ var _ = Describe("Stream", func() {
 		resetCalled          bool
 		resetCalledForStream protocol.StreamID
 		resetCalledAtOffset  protocol.ByteCount
+		resetCalledWithCode  StreamErrorCode
 
 		mockFcm *mocks_fc.MockFlowControlManager
 	)
@@ -49,10 +50,11 @@ var _ = Describe("Stream", func() {
 		onDataCalled = true
 	}
 
-	onReset := func(id protocol.StreamID, offset protocol.ByteCount) {
+	onReset := func(id protocol.StreamID, offset protocol.ByteCount, code StreamErrorCode) {
 		resetCalled = true
 		resetCalledForStream = id
 		resetCalledAtOffset = offset
+		resetCalledWithCode = code
 	}
 
 	BeforeEach(func() {
@@ -75,6 +77,55 @@ var _ = Describe("Stream", func() {
 		Expect(str.StreamID()).To(Equal(protocol.StreamID(1337)))
 	})
 
+	Context("unidirectional streams", func() {
+		It("doesn't read from send-only streams", func() {
+			str.makeSendOnly()
+			_, err := strWithTimeout.Read(make([]byte, 4))
+			Expect(err).To(MatchError("read from send-only stream 1337"))
+		})
+
+		It("rejects data for send-only streams", func() {
+			str.makeSendOnly()
+			err := str.AddStreamFrame(&wire.StreamFrame{Data: []byte("foo")})
+			Expect(err).To(MatchError("InvalidStreamID: received data for send-only stream 1337"))
+		})
+
+		It("doesn't write on receive-only streams", func() {
+			str.makeReceiveOnly()
+			_, err := strWithTimeout.Write([]byte("foo"))
+			Expect(err).To(MatchError("write on receive-only stream 1337"))
+			Expect(str.shouldSendFin()).To(BeFalse())
+		})
+
+		It("is finished after reading the FIN of a receive-only stream", func() {
+			str.makeReceiveOnly()
+			Expect(str.finished()).To(BeFalse())
+			str.finishedReading.Set(true)
+			Expect(str.finished()).To(BeTrue())
+		})
+	})
+
+	Context("priorities", func() {
+		It("uses the default priority", func() {
+			urgency, incremental := str.getPriority()
+			Expect(urgency).To(Equal(protocol.DefaultStreamUrgency))
+			Expect(incremental).To(BeTrue())
+		})
+
+		It("sets the priority", func() {
+			str.SetPriority(1, false)
+			urgency, incremental := str.getPriority()
+			Expect(urgency).To(Equal(uint8(1)))
+			Expect(incremental).To(BeFalse())
+		})
+
+		It("caps the urgency", func() {
+			str.SetPriority(protocol.MaxStreamUrgency+1, true)
+			urgency, _ := str.getPriority()
+			Expect(urgency).To(Equal(protocol.MaxStreamUrgency))
+		})
+	})
+
 	Context("reading", func() {
 		It("reads a single StreamFrame", func() {
 			mockFcm.EXPECT().UpdateHighestReceived(streamID, protocol.ByteCount(4))
@@ -735,6 +786,179 @@ var _ = Describe("Stream", func() {
 		})
 	})
 
+	Context("canceling", func() {
+		Context("reading", func() {
+			var (
+				stopSendingCalled   bool
+				stopSendingWithCode StreamErrorCode
+			)
+
+			BeforeEach(func() {
+				stopSendingCalled = false
+				str.onStopSending = func(id protocol.StreamID, code StreamErrorCode) {
+					Expect(id).To(Equal(streamID))
+					stopSendingCalled = true
+					stopSendingWithCode = code
+				}
+			})
+
+			It("returns an error from Read and queues a STOP_SENDING", func() {
+				str.CancelRead(1234)
+				Expect(stopSendingCalled).To(BeTrue())
+				Expect(stopSendingWithCode).To(Equal(StreamErrorCode(1234)))
+				_, err := strWithTimeout.Read(make([]byte, 4))
+				Expect(err).To(MatchError("stream 1337 canceled with error code 1234"))
+				Expect(err.(*StreamError).Remote).To(BeFalse())
+			})
+
+			It("unblocks a pending Read", func() {
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					_, err := strWithTimeout.Read(make([]byte, 4))
+					Expect(err).To(MatchError("stream 1337 canceled with error code 1234"))
+					close(done)
+				}()
+				Consistently(done).ShouldNot(BeClosed())
+				str.CancelRead(1234)
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("discards data received after canceling, but returns the flow control credit", func() {
+				str.CancelRead(1234)
+				mockFcm.EXPECT().UpdateHighestReceived(streamID, protocol.ByteCount(6))
+				mockFcm.EXPECT().AddBytesRead(streamID, protocol.ByteCount(6))
+				err := str.AddStreamFrame(&wire.StreamFrame{Data: []byte("foobar")})
+				Expect(err).ToNot(HaveOccurred())
+				_, err = strWithTimeout.Read(make([]byte, 6))
+				Expect(err).To(BeAssignableToTypeOf(&StreamError{}))
+			})
+
+			It("only sends one STOP_SENDING", func() {
+				str.CancelRead(1234)
+				stopSendingCalled = false
+				str.CancelRead(4321)
+				Expect(stopSendingCalled).To(BeFalse())
+			})
+
+			It("doesn't send a STOP_SENDING after the FIN was read", func() {
+				str.finishedReading.Set(true)
+				str.CancelRead(1234)
+				Expect(stopSendingCalled).To(BeFalse())
+			})
+
+			It("doesn't cancel reading on send-only streams", func() {
+				str.makeSendOnly()
+				str.CancelRead(1234)
+				Expect(stopSendingCalled).To(BeFalse())
+			})
+
+			Context("for peers that don't support STOP_SENDING", func() {
+				BeforeEach(func() {
+					str.peerSupportsStopSending = func() bool { return false }
+				})
+
+				It("sends a RST_STREAM instead", func() {
+					str.writeOffset = 0x1000
+					str.CancelRead(1234)
+					Expect(stopSendingCalled).To(BeFalse())
+					Expect(resetCalled).To(BeTrue())
+					Expect(resetCalledAtOffset).To(Equal(protocol.ByteCount(0x1000)))
+					Expect(resetCalledWithCode).To(Equal(StreamErrorCode(1234)))
+					_, err := strWithTimeout.Read(make([]byte, 4))
+					Expect(err).To(MatchError("stream 1337 canceled with error code 1234"))
+				})
+
+				It("aborts the writing", func() {
+					str.CancelRead(1234)
+					_, err := strWithTimeout.Write([]byte("foobar"))
+					Expect(err).To(MatchError("stream 1337 canceled with error code 1234"))
+					Expect(str.Context().Done()).To(BeClosed())
+					Expect(str.finished()).To(BeTrue())
+				})
+
+				It("doesn't send a second RST_STREAM if the writing was already canceled", func() {
+					str.CancelWrite(42)
+					resetCalled = false
+					str.CancelRead(1234)
+					Expect(resetCalled).To(BeFalse())
+				})
+
+				It("sends a RST_STREAM on receive-only streams", func() {
+					str.makeReceiveOnly()
+					str.CancelRead(1234)
+					Expect(resetCalled).To(BeTrue())
+					Expect(resetCalledWithCode).To(Equal(StreamErrorCode(1234)))
+				})
+			})
+		})
+
+		Context("writing", func() {
+			It("queues a RST_STREAM with the error code", func() {
+				str.writeOffset = 0x1000
+				str.CancelWrite(1234)
+				Expect(resetCalled).To(BeTrue())
+				Expect(resetCalledForStream).To(Equal(streamID))
+				Expect(resetCalledAtOffset).To(Equal(protocol.ByteCount(0x1000)))
+				Expect(resetCalledWithCode).To(Equal(StreamErrorCode(1234)))
+				Expect(str.Context().Done()).To(BeClosed())
+			})
+
+			It("unblocks a pending Write and doesn't send its data", func() {
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					n, err := strWithTimeout.Write([]byte("foobar"))
+					Expect(err).To(MatchError("stream 1337 canceled with error code 1234"))
+					Expect(n).To(BeZero())
+					close(done)
+				}()
+				Eventually(func() protocol.ByteCount { return str.lenOfDataForWriting() }).ShouldNot(BeZero())
+				str.CancelWrite(1234)
+				Eventually(done).Should(BeClosed())
+				Expect(str.lenOfDataForWriting()).To(BeZero())
+				Expect(str.getDataForWriting(1000)).To(BeNil())
+			})
+
+			It("returns an error from subsequent Writes and doesn't send a FIN", func() {
+				str.CancelWrite(1234)
+				Expect(str.Close()).To(Succeed())
+				_, err := strWithTimeout.Write([]byte("foo"))
+				Expect(err).To(MatchError("stream 1337 canceled with error code 1234"))
+				Expect(str.shouldSendFin()).To(BeFalse())
+			})
+
+			It("doesn't queue a RST_STREAM after the FIN was sent", func() {
+				str.finishedWriting.Set(true)
+				str.finSent.Set(true)
+				str.CancelWrite(1234)
+				Expect(resetCalled).To(BeFalse())
+			})
+
+			It("only queues one RST_STREAM", func() {
+				str.CancelWrite(1234)
+				resetCalled = false
+				str.CancelWrite(4321)
+				Expect(resetCalled).To(BeFalse())
+			})
+
+			It("doesn't cancel writing on receive-only streams", func() {
+				str.makeReceiveOnly()
+				str.CancelWrite(1234)
+				Expect(resetCalled).To(BeFalse())
+			})
+
+			It("resets the stream with the peer's error code when receiving a STOP_SENDING", func() {
+				str.StopSending(42)
+				Expect(resetCalled).To(BeTrue())
+				Expect(resetCalledWithCode).To(Equal(StreamErrorCode(42)))
+				_, err := strWithTimeout.Write([]byte("foo"))
+				Expect(err).To(MatchError("stream 1337 canceled by peer with error code 42"))
+				Expect(err.(*StreamError).Remote).To(BeTrue())
+			})
+		})
+	})
+
 	Context("writing", func() {
 		It("writes and gets all data at once", func() {
 			done := make(chan struct{})
@@ -818,6 +1042,150 @@ var _ = Describe("Stream", func() {
 			Expect(err).ToNot(HaveOccurred())
 		})
 
+		It("writes multiple buffers", func() {
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				n, err := str.WriteBuffers([][]byte{[]byte("foo"), nil, []byte("bar")})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(Equal(6))
+				close(done)
+			}()
+			Eventually(func() protocol.ByteCount { return str.lenOfDataForWriting() }).Should(Equal(protocol.ByteCount(6)))
+			Consistently(done).ShouldNot(BeClosed())
+			Expect(str.getDataForWriting(1000)).To(Equal([]byte("foobar")))
+			Eventually(done).Should(BeClosed())
+		})
+
+		Context("with a send buffer", func() {
+			BeforeEach(func() {
+				str.sendBufferSize = 10
+			})
+
+			It("returns as soon as the data is queued", func() {
+				n, err := strWithTimeout.Write([]byte("foobar"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(Equal(6))
+				Expect(onDataCalled).To(BeTrue())
+				n, err = str.WriteBuffers([][]byte{[]byte("fo"), []byte("o")})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(Equal(3))
+				Expect(str.getDataForWriting(1000)).To(Equal([]byte("foobarfoo")))
+			})
+
+			It("blocks while the send buffer is full", func() {
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					n, err := strWithTimeout.Write([]byte("foobarfoobar"))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(n).To(Equal(12))
+					close(done)
+				}()
+				Eventually(func() protocol.ByteCount { return str.lenOfDataForWriting() }).Should(Equal(protocol.ByteCount(10)))
+				Consistently(done).ShouldNot(BeClosed())
+				Expect(str.getDataForWriting(3)).To(Equal([]byte("foo")))
+				Eventually(done).Should(BeClosed())
+				Expect(str.getDataForWriting(1000)).To(Equal([]byte("barfoobar")))
+				Expect(str.writeOffset).To(Equal(protocol.ByteCount(12)))
+			})
+
+			It("doesn't overwrite data that was already dequeued", func() {
+				_, err := strWithTimeout.Write([]byte("foobar"))
+				Expect(err).ToNot(HaveOccurred())
+				data := str.getDataForWriting(3)
+				_, err = strWithTimeout.Write([]byte("baz"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte("foo")))
+				Expect(str.getDataForWriting(1000)).To(Equal([]byte("barbaz")))
+			})
+
+			It("returns the number of queued bytes when the deadline expires", func() {
+				str.SetWriteDeadline(time.Now().Add(scaleDuration(20 * time.Millisecond)))
+				n, err := strWithTimeout.Write([]byte("foobarfoobar"))
+				Expect(err).To(MatchError(errDeadline))
+				Expect(n).To(Equal(10))
+			})
+
+			It("unblocks when writing is canceled", func() {
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					n, err := strWithTimeout.Write([]byte("foobarfoobar"))
+					Expect(err).To(MatchError("stream 1337 canceled with error code 1234"))
+					Expect(n).To(Equal(10))
+					close(done)
+				}()
+				Eventually(func() protocol.ByteCount { return str.lenOfDataForWriting() }).Should(Equal(protocol.ByteCount(10)))
+				str.CancelWrite(1234)
+				Eventually(done).Should(BeClosed())
+				Expect(str.getDataForWriting(1000)).To(BeNil())
+			})
+
+			Context("dropping messages", func() {
+				It("drops new messages if the send queue is full", func() {
+					str.sendQueuePolicy = SendQueueDropNewest
+					_, err := strWithTimeout.Write([]byte("foobar"))
+					Expect(err).ToNot(HaveOccurred())
+					n, err := str.WriteBuffers([][]byte{[]byte("foo"), []byte("bar")})
+					Expect(err).To(MatchError(ErrMessageDropped))
+					Expect(n).To(BeZero())
+					_, err = strWithTimeout.Write([]byte("baz"))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(str.GetBytesDropped()).To(Equal(protocol.ByteCount(6)))
+					Expect(str.getDataForWriting(1000)).To(Equal([]byte("foobarbaz")))
+				})
+
+				It("drops the oldest messages if the send queue is full", func() {
+					str.sendQueuePolicy = SendQueueDropOldest
+					for _, msg := range []string{"foo", "bar", "baz", "foobar"} {
+						n, err := strWithTimeout.Write([]byte(msg))
+						Expect(err).ToNot(HaveOccurred())
+						Expect(n).To(Equal(len(msg)))
+					}
+					Expect(str.GetBytesDropped()).To(Equal(protocol.ByteCount(6)))
+					Expect(str.getDataForWriting(1000)).To(Equal([]byte("bazfoobar")))
+				})
+
+				It("doesn't drop messages that were partially dequeued", func() {
+					str.sendQueuePolicy = SendQueueDropOldest
+					_, err := strWithTimeout.Write([]byte("foobar"))
+					Expect(err).ToNot(HaveOccurred())
+					_, err = strWithTimeout.Write([]byte("baz"))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(str.getDataForWriting(4)).To(Equal([]byte("foob")))
+					_, err = strWithTimeout.Write([]byte("abcdefgh"))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(str.GetBytesDropped()).To(Equal(protocol.ByteCount(3)))
+					Expect(str.getDataForWriting(1000)).To(Equal([]byte("arabcdefgh")))
+					Expect(str.writeOffset).To(Equal(protocol.ByteCount(14)))
+				})
+
+				It("drops messages that are larger than the send queue, without dropping the queued messages", func() {
+					str.sendQueuePolicy = SendQueueDropOldest
+					_, err := strWithTimeout.Write([]byte("foo"))
+					Expect(err).ToNot(HaveOccurred())
+					_, err = strWithTimeout.Write([]byte("bar"))
+					Expect(err).ToNot(HaveOccurred())
+					n, err := strWithTimeout.Write([]byte("foobarfoobar"))
+					Expect(err).To(MatchError(ErrMessageDropped))
+					Expect(n).To(BeZero())
+					Expect(str.GetBytesDropped()).To(Equal(protocol.ByteCount(12)))
+					Expect(str.queuedMessages).To(Equal([]protocol.ByteCount{3, 3}))
+					Expect(str.getDataForWriting(1000)).To(Equal([]byte("foobar")))
+				})
+			})
+
+			It("sends the FIN after all queued data", func() {
+				_, err := strWithTimeout.Write([]byte("foobar"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(str.Close()).To(Succeed())
+				Expect(str.shouldSendFin()).To(BeFalse())
+				str.getDataForWriting(1000)
+				Expect(str.shouldSendFin()).To(BeTrue())
+			})
+		})
+
 		Context("deadlines", func() {
 			It("returns an error when Write is called after the deadline", func() {
 				str.SetWriteDeadline(time.Now().Add(-time.Second))
@@ -32,37 +32,60 @@ type streamsMap struct {
 	closeErr           error
 	nextStreamToAccept protocol.StreamID
 
-	newStream newStreamLambda
+	newStream    newStreamLambda
+	newUniStream newUniStreamLambda
 
 	numOutgoingStreams uint32
 	numIncomingStreams uint32
+
+	// unidirectional streams have their own StreamIDs and limits
+	nextUniStream                protocol.StreamID // StreamID of the next Stream that will be returned by OpenUniStream()
+	highestUniStreamOpenedByPeer protocol.StreamID
+	nextUniStreamToAccept        protocol.StreamID
+	nextUniStreamOrErrCond       sync.Cond
+	numOutgoingUniStreams        uint32
+	numIncomingUniStreams        uint32
+
+	// set once we sent a GOAWAY frame, the streams of the peer opened afterwards are ignored
+	goawaySent        bool
+	lastGoodStream    protocol.StreamID
+	lastGoodUniStream protocol.StreamID
+	// set once we received a GOAWAY frame, no new streams can be opened
+	goawayErr error
 }
 
 type streamLambda func(*stream) (bool, error)
 type newStreamLambda func(protocol.StreamID) *stream
+type newUniStreamLambda func(id protocol.StreamID, sendOnly bool) *stream
 
 var (
 	errMapAccess = errors.New("streamsMap: Error accessing the streams map")
 )
 
-func newStreamsMap(newStream newStreamLambda, pers protocol.Perspective, connectionParameters handshake.ConnectionParametersManager) *streamsMap {
+func newStreamsMap(newStream newStreamLambda, newUniStream newUniStreamLambda, pers protocol.Perspective, connectionParameters handshake.ConnectionParametersManager) *streamsMap {
 	sm := streamsMap{
 		perspective:          pers,
 		streams:              map[protocol.StreamID]*stream{},
 		unreliableStreamMark: map[protocol.StreamID]bool{},
 		openStreams:          make([]protocol.StreamID, 0),
 		newStream:            newStream,
+		newUniStream:         newUniStream,
 		connectionParameters: connectionParameters,
 	}
 	sm.nextStreamOrErrCond.L = &sm.mutex
 	sm.openStreamOrErrCond.L = &sm.mutex
+	sm.nextUniStreamOrErrCond.L = &sm.mutex
 
 	if pers == protocol.PerspectiveClient {
 		sm.nextStream = 1
 		sm.nextStreamToAccept = 2
+		sm.nextUniStream = protocol.UniStreamIDOffset + 1
+		sm.nextUniStreamToAccept = protocol.UniStreamIDOffset + 2
 	} else {
 		sm.nextStream = 2
 		sm.nextStreamToAccept = 1
+		sm.nextUniStream = protocol.UniStreamIDOffset + 2
+		sm.nextUniStreamToAccept = protocol.UniStreamIDOffset + 1
 	}
 
 	return &sm
@@ -103,6 +126,9 @@ func (m *streamsMap) GetOrOpenStream(id protocol.StreamID) (*stream, error) {
 		return s, nil
 	}
 	// 下面所有的情况说明了该流不存在
+	if id.IsUniStream() {
+		return m.getOrOpenRemoteUniStream(id)
+	}
 
 	//下面的两个大的判断语句是在将那些自己侧发起的流和已经结束的流给排除掉
 	if m.perspective == protocol.PerspectiveServer {
@@ -127,6 +153,9 @@ func (m *streamsMap) GetOrOpenStream(id protocol.StreamID) (*stream, error) {
 			return nil, nil
 		}
 	}
+	if m.goawaySent && id > m.lastGoodStream { // the peer opened this stream after we sent a GOAWAY
+		return nil, nil
+	}
 
 	// 走到下面的代码后，说明了所需要的流还没有打开，并且不是自己侧发起的流
 	// 还没有流打开的话，highestStreamOpenedByPeer一开始等于0，服务器的流计数从2开始，客户端的流计数从1开始
@@ -168,6 +197,9 @@ func (m *streamsMap) GetOrOpenStreamType(id protocol.StreamID, marker bool) (*st
 		return s, nil
 	}
 	// 下面所有的情况说明了该流不存在
+	if id.IsUniStream() {
+		return m.getOrOpenRemoteUniStream(id)
+	}
 
 	//下面的两个大的判断语句是在将那些自己侧发起的流和已经结束的流给排除掉
 	if m.perspective == protocol.PerspectiveServer {
@@ -192,6 +224,9 @@ func (m *streamsMap) GetOrOpenStreamType(id protocol.StreamID, marker bool) (*st
 			return nil, nil
 		}
 	}
+	if m.goawaySent && id > m.lastGoodStream { // the peer opened this stream after we sent a GOAWAY
+		return nil, nil
+	}
 
 	// 走到下面的代码后，说明了所需要的流还没有打开，并且不是自己侧发起的流
 	// 还没有流打开的话，highestStreamOpenedByPeer一开始等于0，服务器的流计数从2开始，客户端的流计数从1开始
@@ -212,6 +247,49 @@ func (m *streamsMap) GetOrOpenStreamType(id protocol.StreamID, marker bool) (*st
 	return m.streams[id], nil
 }
 
+// isOwnUniStream returns true if the unidirectional stream was opened by us
+func (m *streamsMap) isOwnUniStream(id protocol.StreamID) bool {
+	return (id%2 == 1) == (m.perspective == protocol.PerspectiveClient)
+}
+
+// getOrOpenRemoteUniStream opens the unidirectional streams of the peer up to the given StreamID
+// Attention: this function must only be called if a mutex has been acquired previously
+func (m *streamsMap) getOrOpenRemoteUniStream(id protocol.StreamID) (*stream, error) {
+	if m.isOwnUniStream(id) {
+		if id < m.nextUniStream { // this is a stream that we already opened. Must have been closed already
+			return nil, nil
+		}
+		return nil, qerr.Error(qerr.InvalidStreamID, fmt.Sprintf("attempted to open unidirectional stream %d of the peer", id))
+	}
+	if id <= m.highestUniStreamOpenedByPeer { // this stream doesn't exist anymore. Must have been closed already
+		return nil, nil
+	}
+	if m.goawaySent && id > m.lastGoodUniStream { // the peer opened this stream after we sent a GOAWAY
+		return nil, nil
+	}
+	sid := m.highestUniStreamOpenedByPeer + 2
+	if m.highestUniStreamOpenedByPeer == 0 {
+		sid = protocol.UniStreamIDOffset + 1
+		if m.perspective == protocol.PerspectiveClient {
+			sid = protocol.UniStreamIDOffset + 2
+		}
+	}
+	// the limit of incoming streams also bounds this loop
+	for ; sid <= id; sid += 2 {
+		if m.numIncomingUniStreams >= m.connectionParameters.GetMaxIncomingUniStreams() {
+			return nil, qerr.TooManyOpenStreams
+		}
+		m.numIncomingUniStreams++
+		m.highestUniStreamOpenedByPeer = sid
+		s := m.newUniStream(sid, false)
+		s.makeReceiveOnly()
+		m.putStream(s)
+	}
+
+	m.nextUniStreamOrErrCond.Broadcast()
+	return m.streams[id], nil
+}
+
 func (m *streamsMap) openRemoteStream(id protocol.StreamID) (*stream, error) {
 	if m.numIncomingStreams >= m.connectionParameters.GetMaxIncomingStreams() {
 		return nil, qerr.TooManyOpenStreams
@@ -257,6 +335,9 @@ func (m *streamsMap) openRemoteStreamType(id protocol.StreamID, marker bool) (*s
 	return s, nil
 }
 func (m *streamsMap) openStreamImpl() (*stream, error) {
+	if m.goawayErr != nil {
+		return nil, m.goawayErr
+	}
 	id := m.nextStream
 	if m.numOutgoingStreams >= m.connectionParameters.GetMaxOutgoingStreams() {
 		return nil, qerr.TooManyOpenStreams
@@ -275,6 +356,9 @@ func (m *streamsMap) openStreamImpl() (*stream, error) {
 }
 
 func (m *streamsMap) openUnreliableStreamImpl() (*stream, error) {
+	if m.goawayErr != nil {
+		return nil, m.goawayErr
+	}
 	id := m.nextStream
 	if m.numOutgoingStreams >= m.connectionParameters.GetMaxOutgoingStreams() {
 		return nil, qerr.TooManyOpenStreams
@@ -314,6 +398,31 @@ func (m *streamsMap) OpenUnreliableStream() (*stream, error) { //发起新的不
 	return m.openUnreliableStreamImpl()
 }
 
+// OpenUniStream opens the next available unidirectional stream
+// It returns a TooManyOpenStreams error until the peer announced how many unidirectional streams it accepts
+func (m *streamsMap) OpenUniStream() (*stream, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closeErr != nil {
+		return nil, m.closeErr
+	}
+	if m.goawayErr != nil {
+		return nil, m.goawayErr
+	}
+	if m.numOutgoingUniStreams >= m.connectionParameters.GetMaxOutgoingUniStreams() {
+		return nil, qerr.TooManyOpenStreams
+	}
+	m.numOutgoingUniStreams++
+
+	id := m.nextUniStream
+	m.nextUniStream += 2
+	s := m.newUniStream(id, true)
+	s.makeSendOnly()
+	m.putStream(s)
+	return s, nil
+}
+
 func (m *streamsMap) OpenStreamSync() (*stream, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -354,6 +463,27 @@ func (m *streamsMap) AcceptStream() (*stream, error) {
 	return str, nil
 }
 
+// AcceptUniStream returns the next unidirectional stream opened by the peer
+// it blocks until a new stream is opened
+func (m *streamsMap) AcceptUniStream() (*stream, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var str *stream
+	for {
+		var ok bool
+		if m.closeErr != nil {
+			return nil, m.closeErr
+		}
+		str, ok = m.streams[m.nextUniStreamToAccept]
+		if ok {
+			break
+		}
+		m.nextUniStreamOrErrCond.Wait()
+	}
+	m.nextUniStreamToAccept += 2
+	return str, nil
+}
+
 func (m *streamsMap) Iterate(fn streamLambda) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -428,13 +558,14 @@ func (m *streamsMap) RoundRobinIterate(fn streamLambda) error { //这里是否
 	}
 	return nil
 }
+
+// RoundRobinIterate2 executes the streamLambda for the open streams that can send on the path, until the streamLambda returns false
+// It prioritizes the crypto- and the header-stream (StreamIDs 1 and 3), then the streams with the lowest urgency.
+// Among streams of the same urgency, reliable streams come first, unless the path only allows to send unreliable data.
 func (m *streamsMap) RoundRobinIterate2(fn streamLambda, path *path) error { //这里是否需要优先轮询可靠流？
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	numStreams := uint32(len(m.streams))
-	startIndex := m.roundRobinIndex
-	startIndexUnreliable := m.unreliableRobinIndex
 	for _, i := range []protocol.StreamID{1, 3} { //优先轮询1和3流
 		cont, err := m.iterateFunc(i, fn)
 		if err != nil && err != errMapAccess {
@@ -444,44 +575,70 @@ func (m *streamsMap) RoundRobinIterate2(fn streamLambda, path *path) error { //
 			return nil
 		}
 	}
-	if path.sentPacketHandler.SendingAllowed() { //如果该路径允许发送的话，证明是正常的途径到达的,那么先要轮询可靠的再轮询不可靠的
-		for i := uint32(0); i < numStreams; i++ {
-			streamID := m.openStreams[(i+startIndexUnreliable)%numStreams]
-			if streamID == 1 || streamID == 3 {
-				continue
-			}
-			if _, ok := m.unreliableStreamMark[streamID]; ok { // 如果是不可靠的，那就跳过本轮循环
-				continue
-			}
-			cont, err := m.iterateFunc(streamID, fn)
-			if err != nil {
+	sendingAllowed := path.sentPacketHandler.SendingAllowed() //如果该路径允许发送的话，证明是正常的途径到达的,那么先要轮询可靠的再轮询不可靠的
+	for urgency := uint8(0); urgency <= protocol.MaxStreamUrgency; urgency++ {
+		if sendingAllowed {
+			cont, err := m.iterateUrgency(fn, urgency, false, &m.unreliableRobinIndex)
+			if err != nil || !cont {
 				return err
 			}
-			m.unreliableRobinIndex = (m.unreliableRobinIndex + 1) % numStreams
-			if !cont { //是否需要跳出循环？，如果已经获取了所需呀的数据量，那么就跳出循环
-				break
-			}
+		}
+		cont, err := m.iterateUrgency(fn, urgency, true, &m.roundRobinIndex)
+		if err != nil || !cont {
+			return err
 		}
 	}
-	for i := uint32(0); i < numStreams; i++ {
-		streamID := m.openStreams[(i+startIndex)%numStreams]
+	return nil
+}
+
+// iterateUrgency executes the streamLambda for the reliable or unreliable streams of an urgency.
+// Non-incremental streams are served one after the other, in the order they were opened.
+// Incremental streams share the bandwidth in round-robin, starting at the given index.
+func (m *streamsMap) iterateUrgency(fn streamLambda, urgency uint8, unreliable bool, robinIndex *uint32) (bool, error) {
+	numStreams := uint32(len(m.openStreams))
+	matches := func(streamID protocol.StreamID, incremental bool) bool {
 		if streamID == 1 || streamID == 3 {
+			return false
+		}
+		if _, ok := m.unreliableStreamMark[streamID]; ok != unreliable {
+			return false
+		}
+		str, ok := m.streams[streamID]
+		if !ok || str == nil {
+			return false
+		}
+		u, inc := str.getPriority()
+		return u == urgency && inc == incremental
+	}
+
+	for _, streamID := range m.openStreams {
+		if !matches(streamID, false) {
 			continue
 		}
-		if _, ok := m.unreliableStreamMark[streamID]; !ok { // 如果是可靠的，那就跳过本轮循环
+		cont, err := m.iterateFunc(streamID, fn)
+		if err != nil || !cont {
+			return false, err
+		}
+	}
+
+	startIndex := *robinIndex
+	for i := uint32(0); i < numStreams; i++ {
+		streamID := m.openStreams[(i+startIndex)%numStreams]
+		if !matches(streamID, true) {
 			continue
 		}
 		cont, err := m.iterateFunc(streamID, fn)
 		if err != nil {
-			return err
+			return false, err
 		}
-		m.roundRobinIndex = (m.roundRobinIndex + 1) % numStreams
-		if !cont {
-			break
+		*robinIndex = (i + startIndex + 1) % numStreams
+		if !cont { //是否需要跳出循环？，如果已经获取了所需呀的数据量，那么就跳出循环
+			return false, nil
 		}
 	}
-	return nil
+	return true, nil
 }
+
 func (m *streamsMap) iterateFunc(streamID protocol.StreamID, fn streamLambda) (bool, error) {
 	str, ok := m.streams[streamID]
 	if !ok {
@@ -522,7 +679,13 @@ func (m *streamsMap) RemoveStream(id protocol.StreamID) error {
 		return fmt.Errorf("attempted to remove non-existing stream: %d", id)
 	}
 
-	if id%2 == 0 {
+	if id.IsUniStream() {
+		if m.isOwnUniStream(id) {
+			m.numOutgoingUniStreams--
+		} else {
+			m.numIncomingUniStreams--
+		}
+	} else if id%2 == 0 {
 		m.numOutgoingStreams--
 	} else {
 		m.numIncomingStreams--
@@ -545,12 +708,66 @@ func (m *streamsMap) RemoveStream(id protocol.StreamID) error {
 	return nil
 }
 
+// SendGoaway stops accepting streams opened by the peer, and returns the highest bidirectional and unidirectional streams the peer opened so far.
+// It returns false if a GOAWAY was already sent.
+func (m *streamsMap) SendGoaway() (protocol.StreamID, protocol.StreamID, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.goawaySent {
+		return 0, 0, false
+	}
+	m.goawaySent = true
+	m.lastGoodStream = m.highestStreamOpenedByPeer
+	m.lastGoodUniStream = m.highestUniStreamOpenedByPeer
+	return m.lastGoodStream, m.lastGoodUniStream, true
+}
+
+// ReceivedGoaway makes opening new streams fail with the error.
+// Our streams that the peer didn't accept anymore are canceled, the crypto and the header stream are kept.
+// A lastGoodUniStream of 0 means that the peer didn't accept any of our unidirectional streams.
+func (m *streamsMap) ReceivedGoaway(lastGoodStream, lastGoodUniStream protocol.StreamID, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.goawayErr = err
+	m.openStreamOrErrCond.Broadcast()
+	for _, id := range m.openStreams {
+		if id.IsUniStream() {
+			if m.isOwnUniStream(id) && id > lastGoodUniStream {
+				m.streams[id].Cancel(err)
+			}
+			continue
+		}
+		if id <= lastGoodStream || id == 1 || id == 3 {
+			continue
+		}
+		if (id%2 == 1) == (m.perspective == protocol.PerspectiveClient) {
+			m.streams[id].Cancel(err)
+		}
+	}
+}
+
+// Drained returns true once a GOAWAY was sent, and all streams except the crypto and the header stream are closed
+func (m *streamsMap) Drained() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if !m.goawaySent {
+		return false
+	}
+	for _, id := range m.openStreams {
+		if id != 1 && id != 3 {
+			return false
+		}
+	}
+	return true
+}
+
 func (m *streamsMap) CloseWithError(err error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 	m.closeErr = err
 	m.nextStreamOrErrCond.Broadcast()
 	m.openStreamOrErrCond.Broadcast()
+	m.nextUniStreamOrErrCond.Broadcast()
 	for _, s := range m.openStreams {
 		m.streams[s].Cancel(err)
 	}
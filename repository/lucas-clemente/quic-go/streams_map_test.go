@@ -2,6 +2,7 @@ package quic
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/mocks"
 	"github.com/yyleeshine/mpquic/repository/lucas-clemente/quic-go/internal/protocol"
@@ -12,8 +13,10 @@ import (
 
 var _ = Describe("Streams Map", func() {
 	const (
-		maxIncomingStreams = 75
-		maxOutgoingStreams = 60
+		maxIncomingStreams    = 75
+		maxOutgoingStreams    = 60
+		maxIncomingUniStreams = 5
+		maxOutgoingUniStreams = 4
 	)
 
 	var (
@@ -26,11 +29,16 @@ var _ = Describe("Streams Map", func() {
 
 		mockCpm.EXPECT().GetMaxOutgoingStreams().AnyTimes().Return(uint32(maxOutgoingStreams))
 		mockCpm.EXPECT().GetMaxIncomingStreams().AnyTimes().Return(uint32(maxIncomingStreams))
+		mockCpm.EXPECT().GetMaxOutgoingUniStreams().AnyTimes().Return(uint32(maxOutgoingUniStreams))
+		mockCpm.EXPECT().GetMaxIncomingUniStreams().AnyTimes().Return(uint32(maxIncomingUniStreams))
 
-		m = newStreamsMap(nil, p, mockCpm)
+		m = newStreamsMap(nil, nil, p, mockCpm)
 		m.newStream = func(id protocol.StreamID) *stream {
 			return newStream(id, nil, nil, nil)
 		}
+		m.newUniStream = func(id protocol.StreamID, _ bool) *stream {
+			return newStream(id, nil, nil, nil)
+		}
 	}
 
 	AfterEach(func() {
@@ -457,6 +465,204 @@ var _ = Describe("Streams Map", func() {
 				})
 			})
 		})
+
+		Context("unidirectional streams", func() {
+			const firstClientUniStream = protocol.UniStreamIDOffset + 1
+			const firstServerUniStream = protocol.UniStreamIDOffset + 2
+
+			BeforeEach(func() {
+				setNewStreamsMap(protocol.PerspectiveServer)
+			})
+
+			It("opens send-only streams in their own ID space", func() {
+				s1, err := m.OpenUniStream()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(s1.StreamID()).To(Equal(firstServerUniStream))
+				Expect(s1.StreamID().IsUniStream()).To(BeTrue())
+				Expect(s1.sendOnly).To(BeTrue())
+				s2, err := m.OpenUniStream()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(s2.StreamID()).To(Equal(firstServerUniStream + 2))
+				Expect(m.numOutgoingUniStreams).To(BeEquivalentTo(2))
+				Expect(m.numOutgoingStreams).To(BeZero())
+				Expect(m.nextStream).To(Equal(protocol.StreamID(2)))
+			})
+
+			It("doesn't open more streams than the peer allows", func() {
+				for i := 0; i < maxOutgoingUniStreams; i++ {
+					_, err := m.OpenUniStream()
+					Expect(err).ToNot(HaveOccurred())
+				}
+				_, err := m.OpenUniStream()
+				Expect(err).To(MatchError(qerr.TooManyOpenStreams))
+				err = m.RemoveStream(firstServerUniStream)
+				Expect(err).ToNot(HaveOccurred())
+				_, err = m.OpenUniStream()
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("opens receive-only streams of the peer, including skipped ones", func() {
+				s, err := m.GetOrOpenStream(firstClientUniStream + 2)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(s.receiveOnly).To(BeTrue())
+				Expect(m.streams).To(HaveKey(firstClientUniStream))
+				Expect(m.numIncomingUniStreams).To(BeEquivalentTo(2))
+				Expect(m.numIncomingStreams).To(BeZero())
+			})
+
+			It("limits the number of streams of the peer", func() {
+				_, err := m.GetOrOpenStream(firstClientUniStream + 2*maxIncomingUniStreams)
+				Expect(err).To(MatchError(qerr.TooManyOpenStreams))
+			})
+
+			It("rejects own streams that were not opened yet", func() {
+				_, err := m.GetOrOpenStream(firstServerUniStream)
+				Expect(err).To(MatchError(fmt.Sprintf("InvalidStreamID: attempted to open unidirectional stream %d of the peer", firstServerUniStream)))
+			})
+
+			It("doesn't reopen closed streams", func() {
+				_, err := m.GetOrOpenStream(firstClientUniStream)
+				Expect(err).ToNot(HaveOccurred())
+				err = m.RemoveStream(firstClientUniStream)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(m.numIncomingUniStreams).To(BeZero())
+				str, err := m.GetOrOpenStream(firstClientUniStream)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(str).To(BeNil())
+			})
+
+			It("accepts the streams of the peer", func() {
+				var str *stream
+				go func() {
+					defer GinkgoRecover()
+					var err error
+					str, err = m.AcceptUniStream()
+					Expect(err).ToNot(HaveOccurred())
+				}()
+				_, err := m.GetOrOpenStream(firstClientUniStream)
+				Expect(err).ToNot(HaveOccurred())
+				Eventually(func() *stream { return str }).ShouldNot(BeNil())
+				Expect(str.StreamID()).To(Equal(firstClientUniStream))
+			})
+
+			It("returns an error when accepting a stream after closing", func() {
+				testErr := errors.New("test error")
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					_, err := m.AcceptUniStream()
+					Expect(err).To(MatchError(testErr))
+					close(done)
+				}()
+				m.CloseWithError(testErr)
+				Eventually(done).Should(BeClosed())
+			})
+		})
+
+		Context("GOAWAY", func() {
+			testErr := errors.New("goaway")
+
+			BeforeEach(func() {
+				setNewStreamsMap(protocol.PerspectiveServer)
+			})
+
+			It("ignores streams opened by the peer after sending a GOAWAY", func() {
+				_, err := m.GetOrOpenStream(5)
+				Expect(err).ToNot(HaveOccurred())
+				_, err = m.GetOrOpenStream(protocol.UniStreamIDOffset + 1)
+				Expect(err).ToNot(HaveOccurred())
+				lastGoodStream, lastGoodUniStream, ok := m.SendGoaway()
+				Expect(ok).To(BeTrue())
+				Expect(lastGoodStream).To(Equal(protocol.StreamID(5)))
+				Expect(lastGoodUniStream).To(Equal(protocol.UniStreamIDOffset + 1))
+				s, err := m.GetOrOpenStream(7)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(s).To(BeNil())
+				s, err = m.GetOrOpenStream(protocol.UniStreamIDOffset + 3)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(s).To(BeNil())
+				s, err = m.GetOrOpenStream(5)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(s).ToNot(BeNil())
+				s, err = m.GetOrOpenStream(protocol.UniStreamIDOffset + 1)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(s).ToNot(BeNil())
+				_, _, ok = m.SendGoaway()
+				Expect(ok).To(BeFalse())
+			})
+
+			It("is drained once all streams except the crypto and the header stream are closed", func() {
+				_, err := m.GetOrOpenStream(5)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(m.Drained()).To(BeFalse())
+				m.SendGoaway()
+				Expect(m.Drained()).To(BeFalse())
+				Expect(m.RemoveStream(5)).To(Succeed())
+				Expect(m.Drained()).To(BeTrue())
+				Expect(m.streams).To(HaveKey(protocol.StreamID(1)))
+				Expect(m.streams).To(HaveKey(protocol.StreamID(3)))
+			})
+
+			It("doesn't open new streams after receiving a GOAWAY", func() {
+				m.ReceivedGoaway(0, 0, testErr)
+				_, err := m.OpenStream()
+				Expect(err).To(MatchError(testErr))
+				_, err = m.OpenUniStream()
+				Expect(err).To(MatchError(testErr))
+				_, err = m.OpenUnreliableStream()
+				Expect(err).To(MatchError(testErr))
+			})
+
+			It("unblocks OpenStreamSync when receiving a GOAWAY", func() {
+				for i := 0; i < maxOutgoingStreams; i++ {
+					_, err := m.OpenStream()
+					Expect(err).ToNot(HaveOccurred())
+				}
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					_, err := m.OpenStreamSync()
+					Expect(err).To(MatchError(testErr))
+					close(done)
+				}()
+				Consistently(done).ShouldNot(BeClosed())
+				m.ReceivedGoaway(4, 0, testErr)
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("cancels the streams that the peer didn't accept", func() {
+				s1, err := m.OpenStream()
+				Expect(err).ToNot(HaveOccurred())
+				s2, err := m.OpenStream()
+				Expect(err).ToNot(HaveOccurred())
+				s3, err := m.GetOrOpenStream(5)
+				Expect(err).ToNot(HaveOccurred())
+				m.ReceivedGoaway(s1.StreamID(), 0, testErr)
+				Expect(s1.cancelled.Get()).To(BeFalse())
+				Expect(s2.cancelled.Get()).To(BeTrue())
+				Expect(s3.cancelled.Get()).To(BeFalse())
+			})
+
+			It("cancels the unidirectional streams that the peer didn't accept", func() {
+				s1, err := m.OpenUniStream()
+				Expect(err).ToNot(HaveOccurred())
+				s2, err := m.OpenUniStream()
+				Expect(err).ToNot(HaveOccurred())
+				s3, err := m.GetOrOpenStream(protocol.UniStreamIDOffset + 1)
+				Expect(err).ToNot(HaveOccurred())
+				m.ReceivedGoaway(0, s1.StreamID(), testErr)
+				Expect(s1.cancelled.Get()).To(BeFalse())
+				Expect(s2.cancelled.Get()).To(BeTrue())
+				Expect(s3.cancelled.Get()).To(BeFalse())
+			})
+
+			It("cancels all unidirectional streams if the peer didn't accept any", func() {
+				s, err := m.OpenUniStream()
+				Expect(err).ToNot(HaveOccurred())
+				m.ReceivedGoaway(0, 0, testErr)
+				Expect(s.cancelled.Get()).To(BeTrue())
+			})
+		})
 	})
 
 	Context("DoS mitigation, iterating and deleting", func() {
@@ -673,5 +879,83 @@ var _ = Describe("Streams Map", func() {
 				})
 			})
 		})
+
+		Context("RoundRobinIterate2", func() {
+			var (
+				pth                   *path
+				lambdaCalledForStream []protocol.StreamID
+				fn                    streamLambda
+			)
+
+			putStreamWithPriority := func(id protocol.StreamID, urgency uint8, incremental bool) {
+				err := m.putStream(&stream{streamID: id, urgency: urgency, incremental: incremental})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			BeforeEach(func() {
+				pth = &path{sentPacketHandler: &mockSentPacketHandler{}}
+				lambdaCalledForStream = lambdaCalledForStream[:0]
+				fn = func(str *stream) (bool, error) {
+					lambdaCalledForStream = append(lambdaCalledForStream, str.StreamID())
+					return true, nil
+				}
+			})
+
+			It("serves streams with a lower urgency first", func() {
+				putStreamWithPriority(5, 4, true)
+				putStreamWithPriority(7, 1, true)
+				putStreamWithPriority(9, protocol.DefaultStreamUrgency, true)
+				err := m.RoundRobinIterate2(fn, pth)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(lambdaCalledForStream).To(Equal([]protocol.StreamID{7, 9, 5}))
+			})
+
+			It("serves non-incremental streams in the order they were opened, before incremental streams", func() {
+				putStreamWithPriority(5, 2, true)
+				putStreamWithPriority(7, 2, false)
+				putStreamWithPriority(9, 2, false)
+				m.roundRobinIndex = 2
+				m.unreliableRobinIndex = 2
+				err := m.RoundRobinIterate2(fn, pth)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(lambdaCalledForStream).To(Equal([]protocol.StreamID{7, 9, 5}))
+			})
+
+			It("continues the round-robin of incremental streams after the last stream served", func() {
+				for _, id := range []protocol.StreamID{5, 7, 9} {
+					putStreamWithPriority(id, 2, true)
+				}
+				var numIterations int
+				fn = func(str *stream) (bool, error) {
+					lambdaCalledForStream = append(lambdaCalledForStream, str.StreamID())
+					numIterations++
+					return numIterations%2 != 0, nil
+				}
+				err := m.RoundRobinIterate2(fn, pth)
+				Expect(err).ToNot(HaveOccurred())
+				err = m.RoundRobinIterate2(fn, pth)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(lambdaCalledForStream).To(Equal([]protocol.StreamID{5, 7, 9, 5}))
+			})
+
+			It("gets crypto- and header stream first", func() {
+				putStreamWithPriority(5, 0, false)
+				putStreamWithPriority(3, protocol.DefaultStreamUrgency, true)
+				putStreamWithPriority(1, protocol.DefaultStreamUrgency, true)
+				err := m.RoundRobinIterate2(fn, pth)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(lambdaCalledForStream).To(Equal([]protocol.StreamID{1, 3, 5}))
+			})
+
+			It("only serves unreliable streams if the path is congestion limited", func() {
+				putStreamWithPriority(5, 0, true)
+				putStreamWithPriority(7, protocol.MaxStreamUrgency, true)
+				m.unreliableStreamMark[7] = true
+				pth.sentPacketHandler.(*mockSentPacketHandler).congestionLimited = true
+				err := m.RoundRobinIterate2(fn, pth)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(lambdaCalledForStream).To(Equal([]protocol.StreamID{7}))
+			})
+		})
 	})
 })